@@ -0,0 +1,315 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+
+// Abuse detection:
+//
+// Clients that keep sending invalid or unknown boat keys (or spectator or
+// group IDs), e.g. while guessing keys, are banned by IP address once they've
+// sent -ban-invalid-keys of them within a minute. Likewise, clients that keep
+// connecting and disconnecting are banned once they've connected
+// -ban-connects times within a minute, as are boat keys subscribed to that
+// many times within a minute (from anywhere). Both are disabled if 0.
+//
+// Bans last -ban-duration at first, doubling with each further ban (up to
+// BAN_DURATION_MAX), until a day has passed since the last one ended. While
+// an IP address is banned, its WebSocket upgrade requests get a 429 response
+// (with a Retry-After header), and while a boat key is banned, subscriptions
+// to it are rejected with a "banned" error (with "retry_after"). Bans can be
+// listed, and lifted, via the admin listener's /bans endpoint.
+
+const ERR_BANNED = "banned"
+
+const BAN_WINDOW = 1 * time.Minute
+const BAN_DURATION_MAX = 24 * time.Hour
+const BAN_FORGET = 24 * time.Hour // After a ban ends, before the next one starts from -ban-duration again
+
+type AbuseEntry struct {
+	WindowStart time.Time
+	InvalidKeys int // Within the current window
+	Connects int // Within the current window
+	Bans int
+	BannedUntil time.Time
+}
+
+type BanMsg struct {
+	Ip string `json:"ip,omitempty"`
+	BoatKey string `json:"key,omitempty"`
+	Bans int `json:"bans"` // Including the current one
+	Until int64 `json:"until"` // Unix time (ms)
+}
+
+type BansMsg struct {
+	Ips []BanMsg `json:"ips"`
+	Keys []BanMsg `json:"keys"`
+}
+
+// Abuse tracking by IP address and boat key (guarded by _abuseLock, which may be taken with _lock held, but not the other way around)
+var _abuseLock sync.Mutex
+var _abuseIps = make(map[string]*AbuseEntry)
+var _abuseKeys = make(map[string]*AbuseEntry)
+
+var _countBans int64 = 0
+var _countBanRejects int64 = 0
+
+
+func abuseInit() {
+	if _config.BanInvalidKeys <= 0 && _config.BanConnects <= 0 {
+		return
+	}
+
+	go func() {
+		for now := range time.Tick(BAN_WINDOW) {
+			pruneAbuse(now)
+		}
+	}()
+}
+
+// Returns the entry for an IP address or boat key (with _abuseLock held), starting a new window if the last one is over.
+func abuseEntry(entries map[string]*AbuseEntry, id string, now time.Time) *AbuseEntry {
+	e := entries[id]
+	if e == nil {
+		e = &AbuseEntry { WindowStart: now }
+		entries[id] = e
+	} else if now.Sub(e.WindowStart) >= BAN_WINDOW {
+		e.WindowStart = now
+		e.InvalidKeys = 0
+		e.Connects = 0
+	}
+	return e
+}
+
+// Bans an IP address or boat key (with _abuseLock held), for twice as long as the previous ban, if recent.
+func ban(e *AbuseEntry, what string, now time.Time, reason string) {
+	if e.Bans > 0 && now.Sub(e.BannedUntil) > BAN_FORGET {
+		e.Bans = 0
+	}
+	e.Bans++
+
+	duration := _config.BanDuration
+	for i := 1; i < e.Bans && duration < BAN_DURATION_MAX; i++ {
+		duration *= 2
+	}
+	duration = min(duration, BAN_DURATION_MAX)
+
+	e.BannedUntil = now.Add(duration)
+	e.InvalidKeys = 0
+	e.Connects = 0
+	atomic.AddInt64(&_countBans, 1)
+
+	log.Println("Banning " + what + " for " + duration.String() + " (ban #" + strconv.Itoa(e.Bans) + "): " + reason)
+}
+
+// Returns the number of seconds (rounded up) until the ban ends, or 0 if not banned (with _abuseLock held).
+func banRemaining(e *AbuseEntry, now time.Time) int {
+	if e == nil || !now.Before(e.BannedUntil) {
+		return 0
+	}
+	return int((e.BannedUntil.Sub(now) + time.Second - 1) / time.Second)
+}
+
+// Called when a client sends an invalid or unknown boat key, spectator ID or group ID.
+func noteInvalidKey(ip string) {
+	if _config.BanInvalidKeys <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	_abuseLock.Lock()
+	defer _abuseLock.Unlock()
+
+	e := abuseEntry(_abuseIps, ip, now)
+	e.InvalidKeys++
+	if e.InvalidKeys >= _config.BanInvalidKeys && banRemaining(e, now) == 0 {
+		ban(e, "client " + ip, now, strconv.Itoa(e.InvalidKeys) + " invalid keys within " + BAN_WINDOW.String())
+	}
+}
+
+// Counts a connection from a client, returning the number of seconds until its ban ends, or 0 if not banned.
+func noteConnect(ip string) int {
+	now := time.Now()
+
+	_abuseLock.Lock()
+	defer _abuseLock.Unlock()
+
+	if _config.BanConnects <= 0 {
+		return banRemaining(_abuseIps[ip], now)
+	}
+
+	e := abuseEntry(_abuseIps, ip, now)
+	if remaining := banRemaining(e, now); remaining > 0 {
+		return remaining
+	}
+
+	e.Connects++
+	if e.Connects > _config.BanConnects {
+		ban(e, "client " + ip, now, strconv.Itoa(e.Connects) + " connections within " + BAN_WINDOW.String())
+		return banRemaining(e, now)
+	}
+	return 0
+}
+
+// Wraps a WebSocket upgrade handler to reject (and count connections from) banned clients.
+func withAbuseCheck(handler http.HandlerFunc) http.HandlerFunc {
+	return func (w http.ResponseWriter, r *http.Request) {
+		ip := clientIp(r)
+		if remaining := noteConnect(ip); remaining > 0 {
+			atomic.AddInt64(&_countBanRejects, 1)
+
+			w.Header().Set("Retry-After", strconv.Itoa(remaining))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// Returns whether the boat key is banned (rejecting the request, if so), counting the subscription otherwise.
+func rejectIfBannedKey(conn *WsConn, boatKey string) bool {
+	if _config.BanConnects <= 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	_abuseLock.Lock()
+	e := abuseEntry(_abuseKeys, boatKey, now)
+	remaining := banRemaining(e, now)
+	if remaining == 0 {
+		e.Connects++
+		if e.Connects > _config.BanConnects {
+			ban(e, "boat key " + boatKey, now, strconv.Itoa(e.Connects) + " subscriptions within " + BAN_WINDOW.String())
+			remaining = banRemaining(e, now)
+		}
+	}
+	_abuseLock.Unlock()
+
+	if remaining == 0 {
+		return false
+	}
+
+	atomic.AddInt64(&_countBanRejects, 1)
+	log.Println("Client (" + conn.RemoteIp + ") subscribed to banned boat key: " + boatKey)
+
+	sendRetryErrorMsg(conn, ERR_BANNED, "Too many subscriptions to this boat; please retry later", remaining)
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Banned")
+	return true
+}
+
+// Forgets entries with nothing left to remember.
+func pruneAbuse(now time.Time) {
+	_abuseLock.Lock()
+	defer _abuseLock.Unlock()
+
+	for _, entries := range []map[string]*AbuseEntry { _abuseIps, _abuseKeys } {
+		for id, e := range entries {
+			if now.Sub(e.WindowStart) >= BAN_WINDOW && (e.Bans == 0 || now.Sub(e.BannedUntil) > BAN_FORGET) {
+				delete(entries, id)
+			}
+		}
+	}
+}
+
+// Returns the current number of banned IP addresses and boat keys.
+func countBanned() int {
+	now := time.Now()
+
+	_abuseLock.Lock()
+	defer _abuseLock.Unlock()
+
+	n := 0
+	for _, entries := range []map[string]*AbuseEntry { _abuseIps, _abuseKeys } {
+		for _, e := range entries {
+			if banRemaining(e, now) > 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Admin endpoint listing current bans (GET), or lifting the ban on "ip" or "key" (DELETE).
+func bansHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		ip := r.URL.Query().Get("ip")
+		boatKey := r.URL.Query().Get("key")
+		if (ip == "") == (boatKey == "") {
+			http.Error(w, "Expected ip or key", http.StatusBadRequest)
+			return
+		}
+
+		_abuseLock.Lock()
+		var lifted bool
+		if ip != "" {
+			_, lifted = _abuseIps[ip]
+			delete(_abuseIps, ip)
+		} else {
+			_, lifted = _abuseKeys[boatKey]
+			delete(_abuseKeys, boatKey)
+		}
+		_abuseLock.Unlock()
+
+		if !lifted {
+			http.NotFound(w, r)
+			return
+		}
+		log.Println("Lifted ban on " + ip + boatKey)
+
+	case http.MethodGet:
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	msg := &BansMsg { Ips: []BanMsg {}, Keys: []BanMsg {} }
+
+	_abuseLock.Lock()
+	for ip, e := range _abuseIps {
+		if banRemaining(e, now) > 0 {
+			msg.Ips = append(msg.Ips, BanMsg { Ip: ip, Bans: e.Bans, Until: e.BannedUntil.UnixMilli() })
+		}
+	}
+	for boatKey, e := range _abuseKeys {
+		if banRemaining(e, now) > 0 {
+			msg.Keys = append(msg.Keys, BanMsg { BoatKey: boatKey, Bans: e.Bans, Until: e.BannedUntil.UnixMilli() })
+		}
+	}
+	_abuseLock.Unlock()
+
+	sort.Slice(msg.Ips, func (i int, j int) bool { return msg.Ips[i].Ip < msg.Ips[j].Ip })
+	sort.Slice(msg.Keys, func (i int, j int) bool { return msg.Keys[i].BoatKey < msg.Keys[j].BoatKey })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
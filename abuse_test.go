@@ -0,0 +1,97 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+
+func TestAbuseBans(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	savedConfig := *_config
+	defer func() { *_config = savedConfig }()
+	_config.BanInvalidKeys = 3
+	_config.BanConnects = 2
+	_config.BanDuration = time.Minute
+
+	const ip = "198.51.100.1"
+	defer func() {
+		_abuseLock.Lock()
+		delete(_abuseIps, ip)
+		delete(_abuseIps, "198.51.100.2")
+		_abuseLock.Unlock()
+	}()
+
+	noteInvalidKey(ip)
+	noteInvalidKey(ip)
+	if noteConnect(ip) != 0 {
+		t.Fatalf("Banned too early")
+	}
+	noteInvalidKey(ip)
+	if remaining := noteConnect(ip); remaining < 59 || remaining > 60 {
+		t.Errorf("Expected a one-minute ban, but got %d seconds", remaining)
+	}
+
+	// Repeat bans last twice as long.
+	now := time.Now()
+	_abuseLock.Lock()
+	e := _abuseIps[ip]
+	ban(e, "client " + ip, now, "test")
+	until := e.BannedUntil
+	_abuseLock.Unlock()
+	if until.Sub(now) != 2 * time.Minute {
+		t.Errorf("Expected a two-minute ban, but got %v", until.Sub(now))
+	}
+
+	// Connecting too often gets a ban too.
+	handler := withAbuseCheck(func (w http.ResponseWriter, r *http.Request) {})
+	for i, expected := range []int { http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests } {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/ws", nil)
+		r.RemoteAddr = "198.51.100.2:1234"
+		handler(w, r)
+		if w.Code != expected {
+			t.Errorf("Connection %d got status %d, expected %d", i, w.Code, expected)
+		}
+		if expected == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected Retry-After header")
+		}
+	}
+
+	// Bans can be listed and lifted.
+	var bans BansMsg
+	w := httptest.NewRecorder()
+	bansHandler(w, httptest.NewRequest("GET", "/bans", nil))
+	json.Unmarshal(w.Body.Bytes(), &bans)
+	if len(bans.Ips) != 2 || bans.Ips[0].Ip != ip || bans.Ips[0].Bans != 2 {
+		t.Errorf("Unexpected bans: %s", w.Body.Bytes())
+	}
+
+	w = httptest.NewRecorder()
+	bansHandler(w, httptest.NewRequest("DELETE", "/bans?ip=" + ip, nil))
+	json.Unmarshal(w.Body.Bytes(), &bans)
+	if w.Code != http.StatusOK || len(bans.Ips) != 1 || noteConnect(ip) != 0 {
+		t.Errorf("Ban not lifted: %d %s", w.Code, w.Body.Bytes())
+	}
+}
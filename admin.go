@@ -0,0 +1,59 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+
+// Admin listener:
+//
+// Operational endpoints (metrics, draining and debugging) are served only on the
+// -admin-listen listener, which is separate from the public WebSocket one, so
+// that it can be bound to e.g. 127.0.0.1 and never exposed to the internet.
+// The public listener uses its own mux, so nothing registered here (or on
+// http.DefaultServeMux) ever ends up on it.
+
+var _adminMux = http.NewServeMux()
+
+
+func adminInit() {
+	if _config.AdminListenHostPort == "" {
+		return
+	}
+
+	_adminMux.HandleFunc("/drain", drainHandler)
+	_adminMux.HandleFunc("/bans", bansHandler)
+
+	_adminMux.HandleFunc("/debug/pprof/", pprof.Index)
+	_adminMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	_adminMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	_adminMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	_adminMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		log.Println("About to serve admin endpoints on " + _config.AdminListenHostPort + "...")
+
+		err := http.ListenAndServe(_config.AdminListenHostPort, withCors(_adminMux))
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+}
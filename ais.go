@@ -0,0 +1,147 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+
+// AIS output:
+//
+// A "bdl_g" request with "ais" set to true also gets the other (nearby) boats
+// in each group message as AIS AIVDM sentences (type 18, "Class B position
+// report"), for passing on to standard marine software, which then shows the
+// other boats as AIS targets. Each boat is given a pseudo-MMSI derived from
+// its friendly name.
+
+// Flag bits (cs, display, dsc, band, msg22, assigned, raim) for a Class B "CS" unit able to use the whole marine band
+const AIS_TYPE_18_FLAGS = 0x48
+
+// Pseudo-MMSIs are in the range 100000000 to 199999999, which isn't allocated to any country.
+const AIS_PSEUDO_MMSI_BASE = 100000000
+const AIS_PSEUDO_MMSI_RANGE = 100000000
+
+type AisType18 struct {
+	Mmsi uint32
+	Sog float64 // Knots (negative for not available)
+	Lat float64
+	Lon float64
+	Cog float64 // Degrees (negative for not available)
+	Heading int // Degrees (511 for not available)
+	Second int // UTC second (60 for not available)
+	Flags uint32 // The 7 flag bits following the second
+	Radio uint32 // The 20 bits of radio status
+	Channel string // "A" or "B"
+}
+
+type AisBits struct {
+	Bits []byte
+}
+
+
+func aisPseudoMmsi(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return AIS_PSEUDO_MMSI_BASE + h.Sum32() % AIS_PSEUDO_MMSI_RANGE
+}
+
+// Creates the AIS sentences for the other boats of a group message.
+func createAisSentences(others map[string][]float64, now time.Time) []string {
+	sentences := make([]string, 0, len(others))
+
+	for name, other := range others {
+		sentences = append(sentences, encodeAisType18(&AisType18 {
+			Mmsi: aisPseudoMmsi(name),
+			Sog: -1.0,
+			Lat: other[0],
+			Lon: other[1],
+			Cog: other[2],
+			Heading: 511,
+			Second: now.UTC().Second(),
+			Flags: AIS_TYPE_18_FLAGS,
+			Radio: 0,
+			Channel: "B",
+		}))
+	}
+
+	return sentences
+}
+
+func encodeAisType18(r *AisType18) string {
+	b := &AisBits { make([]byte, 0, 168) }
+
+	sog := uint32(1023)
+	if r.Sog >= 0.0 {
+		sog = uint32(math.Min(math.Round(r.Sog * 10.0), 1022.0))
+	}
+
+	cog := uint32(3600)
+	if r.Cog >= 0.0 {
+		cog = uint32(math.Round(r.Cog * 10.0)) % 3600
+	}
+
+	b.put(18, 6) // Message type
+	b.put(0, 2) // Repeat indicator
+	b.put(r.Mmsi, 30)
+	b.put(0, 8) // Reserved
+	b.put(sog, 10)
+	b.put(0, 1) // Position accuracy
+	b.put(uint32(int32(math.Round(r.Lon * 600000.0))), 28)
+	b.put(uint32(int32(math.Round(r.Lat * 600000.0))), 27)
+	b.put(cog, 12)
+	b.put(uint32(r.Heading), 9)
+	b.put(uint32(r.Second), 6)
+	b.put(0, 2) // Reserved
+	b.put(r.Flags, 7)
+	b.put(r.Radio, 20)
+
+	payload := b.armor()
+	sentence := "AIVDM,1,1,," + r.Channel + "," + payload + ",0"
+
+	return fmt.Sprintf("!%s*%02X", sentence, nmeaChecksum(sentence)) // See nmea.go.
+}
+
+// Appends the low n bits of v, most significant first.
+func (b *AisBits) put(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.Bits = append(b.Bits, byte((v >> uint(i)) & 1))
+	}
+}
+
+// Encodes the bits (a multiple of 6 of them) with AIS 6-bit ASCII armoring.
+func (b *AisBits) armor() string {
+	out := make([]byte, 0, len(b.Bits) / 6)
+
+	for i := 0; i + 6 <= len(b.Bits); i += 6 {
+		v := byte(0)
+		for j := 0; j < 6; j++ {
+			v = (v << 1) | b.Bits[i + j]
+		}
+
+		if v < 40 {
+			out = append(out, v + 48)
+		} else {
+			out = append(out, v + 56)
+		}
+	}
+
+	return string(out)
+}
@@ -0,0 +1,53 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+
+func TestEncodeAisType18(t *testing.T) {
+	// Well-known example sentence (Class B position report in San Francisco Bay)
+	expected := "!AIVDM,1,1,,A,B5NJ;PP005l4ot5Isbl03wsUkP06,0*76"
+
+	s := encodeAisType18(&AisType18 {
+		Mmsi: 367430530,
+		Sog: 0.0,
+		Lat: 37.785035,
+		Lon: -122.26732,
+		Cog: 0.0,
+		Heading: 511,
+		Second: 55,
+		Flags: 0x5c,
+		Radio: 917510,
+		Channel: "A",
+	})
+
+	if s != expected {
+		t.Errorf("Got %s, expected %s", s, expected)
+	}
+}
+
+func TestAisPseudoMmsi(t *testing.T) {
+	for _, name := range []string { "", "a", "Some Boat", "Another Boat" } {
+		mmsi := aisPseudoMmsi(name)
+		if mmsi < AIS_PSEUDO_MMSI_BASE || mmsi >= AIS_PSEUDO_MMSI_BASE + AIS_PSEUDO_MMSI_RANGE {
+			t.Errorf("Pseudo-MMSI out of range for %q: %d", name, mmsi)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+
+// Operational alerts:
+//
+// With -alert-webhook, short messages are posted to a Slack or Discord
+// (incoming) webhook when a simulator becomes unreachable (dialing it failed
+// even after retries) or reachable again, and, with -alert-conns, when the
+// number of connections rises to each of the given thresholds, or falls back
+// below 90% of it (so that connection counts hovering around a threshold don't
+// flood the channel). Discord webhooks are recognised by their host, and
+// otherwise Slack's payload format is used (which Mattermost and others also
+// accept).
+
+const ALERT_QUEUE_SIZE = 32
+const ALERT_CONNS_HYSTERESIS = 0.9
+
+type SlackAlertMsg struct {
+	Text string `json:"text"`
+}
+
+type DiscordAlertMsg struct {
+	Content string `json:"content"`
+}
+
+var _alertQueue chan *WebhookDelivery = nil
+var _alertPrefix string = "[snsw] "
+
+// Unreachable simulators, by host:port (guarded by _alertsLock, since dialing isn't done with _lock held)
+var _alertsLock sync.Mutex
+var _alertSimsDown = make(map[string]bool)
+
+// Whether the number of connections is above each -alert-conns threshold (guarded by _lock)
+var _alertConnsAbove []bool = nil
+
+
+// Parses connection count thresholds given as "<n>[,...]", returning them in ascending order.
+func parseAlertConns(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var thresholds []int
+	for _, item := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n < 1 {
+			return nil, errors.New("Invalid connection count threshold: " + item)
+		}
+		thresholds = append(thresholds, n)
+	}
+
+	sort.Ints(thresholds)
+	return thresholds, nil
+}
+
+func alertsInit() {
+	if _config.AlertWebhook == "" {
+		return
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		_alertPrefix = "[snsw " + hostname + "] "
+	}
+
+	_alertConnsAbove = make([]bool, len(_config.AlertConns))
+
+	_alertQueue = make(chan *WebhookDelivery, ALERT_QUEUE_SIZE)
+	go alertWorker()
+}
+
+func alertWorker() {
+	client := &http.Client { Timeout: WEBHOOK_TIMEOUT }
+
+	for d := range _alertQueue {
+		if err := deliverWebhookRetrying(client, d); err != nil {
+			log.Println("Failed to deliver alert: " + err.Error())
+		}
+	}
+}
+
+// Queues an alert, dropping it (with a log message) if the queue is full.
+func sendAlert(text string) {
+	if _alertQueue == nil {
+		return
+	}
+
+	body, err := json.Marshal(alertMsg(_config.AlertWebhook, _alertPrefix + text))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	select {
+	case _alertQueue <- &WebhookDelivery { Url: _config.AlertWebhook, Body: body }:
+	default:
+		log.Println("Alert queue full, dropping alert: " + text)
+	}
+}
+
+// Returns the payload for an alert, in the format of the webhook's service.
+func alertMsg(webhookUrl string, text string) interface{} {
+	if u, err := url.Parse(webhookUrl); err == nil {
+		host := strings.ToLower(u.Hostname())
+		if host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com") {
+			return &DiscordAlertMsg { Content: text }
+		}
+	}
+
+	return &SlackAlertMsg { Text: text }
+}
+
+// Called after each attempt to dial a simulator (including retries), with whether it succeeded.
+func alertSimReachable(hostPort string, reachable bool) {
+	if _alertQueue == nil {
+		return
+	}
+
+	_alertsLock.Lock()
+	changed := _alertSimsDown[hostPort] == reachable
+	if reachable {
+		delete(_alertSimsDown, hostPort)
+	} else {
+		_alertSimsDown[hostPort] = true
+	}
+	_alertsLock.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if reachable {
+		sendAlert("Simulator " + hostPort + " is reachable again")
+	} else {
+		sendAlert("Simulator " + hostPort + " is unreachable")
+	}
+}
+
+// Called (with _lock held) once per iteration, with the current number of connections (subscribed or not).
+func updateAlertConns(conns int) {
+	for i, threshold := range _config.AlertConns {
+		if i >= len(_alertConnsAbove) {
+			return
+		}
+
+		if !_alertConnsAbove[i] && conns >= threshold {
+			_alertConnsAbove[i] = true
+			sendAlert("Connections reached " + strconv.Itoa(threshold) + " (now " + strconv.Itoa(conns) + ")")
+		} else if _alertConnsAbove[i] && float64(conns) < float64(threshold) * ALERT_CONNS_HYSTERESIS {
+			_alertConnsAbove[i] = false
+			sendAlert("Connections fell below " + strconv.Itoa(threshold) + " (now " + strconv.Itoa(conns) + ")")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+
+func TestParseAlertConns(t *testing.T) {
+	thresholds, err := parseAlertConns("500, 100")
+	if err != nil || len(thresholds) != 2 || thresholds[0] != 100 || thresholds[1] != 500 {
+		t.Errorf("Unexpected thresholds: %v (%v)", thresholds, err)
+	}
+
+	for _, s := range []string { "0", "100,", "x", "-5" } {
+		if _, err := parseAlertConns(s); err == nil {
+			t.Errorf("Expected invalid thresholds: %s", s)
+		}
+	}
+}
+
+func TestAlertMsg(t *testing.T) {
+	if _, ok := alertMsg("https://discord.com/api/webhooks/1/abc", "x").(*DiscordAlertMsg); !ok {
+		t.Errorf("Expected Discord payload")
+	}
+	if _, ok := alertMsg("https://hooks.slack.com/services/T/B/x", "x").(*SlackAlertMsg); !ok {
+		t.Errorf("Expected Slack payload")
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	savedConfig := *_config
+	defer func() { *_config = savedConfig }()
+	_config.AlertWebhook = "https://hooks.slack.com/services/T/B/x"
+	_config.AlertConns = []int { 10, 100 }
+
+	_alertQueue = make(chan *WebhookDelivery, ALERT_QUEUE_SIZE)
+	_alertConnsAbove = make([]bool, 2)
+	defer func() {
+		_alertQueue = nil
+		_alertConnsAbove = nil
+	}()
+
+	expect := func (contains string) {
+		t.Helper()
+
+		select {
+		case d := <-_alertQueue:
+			var msg SlackAlertMsg
+			json.Unmarshal(d.Body, &msg)
+			if contains == "" || !strings.Contains(msg.Text, contains) {
+				t.Errorf("Expected alert containing %q, but got: %s", contains, d.Body)
+			}
+		default:
+			if contains != "" {
+				t.Errorf("Expected alert containing %q, but got none", contains)
+			}
+		}
+	}
+
+	updateAlertConns(5)
+	expect("")
+	updateAlertConns(12)
+	expect("reached 10 (now 12)")
+	updateAlertConns(9) // Within the hysteresis band
+	expect("")
+	updateAlertConns(8)
+	expect("fell below 10 (now 8)")
+
+	alertSimReachable("127.0.0.1:1", true)
+	expect("")
+	alertSimReachable("127.0.0.1:1", false)
+	expect("127.0.0.1:1 is unreachable")
+	alertSimReachable("127.0.0.1:1", false)
+	expect("")
+	alertSimReachable("127.0.0.1:1", true)
+	expect("127.0.0.1:1 is reachable again")
+}
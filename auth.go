@@ -0,0 +1,108 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+
+// Upgrade request authentication:
+//
+// Independently of boat keys, the WebSocket endpoints may each require an
+// Authorization header on the upgrade request, with either a bearer token
+// ("bearer:<token>") or HTTP Basic credentials ("basic:<user>:<password>").
+
+const AUTH_SCHEME_BEARER = "bearer"
+const AUTH_SCHEME_BASIC = "basic"
+
+type AuthSpec struct {
+	Scheme string
+	Token string // For bearer auth
+	User string // For basic auth
+	Password string
+}
+
+
+// Parses an auth spec, returning nil for "" (no auth required).
+func parseAuthSpec(s string) (*AuthSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(s, ":", 3)
+	switch {
+	case parts[0] == AUTH_SCHEME_BEARER && len(parts) >= 2 && parts[1] != "":
+		return &AuthSpec { Scheme: AUTH_SCHEME_BEARER, Token: strings.TrimPrefix(s, AUTH_SCHEME_BEARER + ":") }, nil
+
+	case parts[0] == AUTH_SCHEME_BASIC && len(parts) == 3 && parts[1] != "":
+		return &AuthSpec { Scheme: AUTH_SCHEME_BASIC, User: parts[1], Password: parts[2] }, nil
+	}
+
+	return nil, errors.New("Invalid auth spec (expected \"bearer:<token>\" or \"basic:<user>:<password>\")")
+}
+
+func secretsEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (spec *AuthSpec) check(r *http.Request) bool {
+	switch spec.Scheme {
+	case AUTH_SCHEME_BEARER:
+		header := r.Header.Get("Authorization")
+		if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+			return false
+		}
+		return secretsEqual(strings.TrimSpace(header[7:]), spec.Token)
+
+	case AUTH_SCHEME_BASIC:
+		user, password, ok := r.BasicAuth()
+		// Check both, regardless, to not leak which was wrong via timing.
+		userOk := secretsEqual(user, spec.User)
+		passwordOk := secretsEqual(password, spec.Password)
+		return ok && userOk && passwordOk
+	}
+
+	return false
+}
+
+// Wraps a handler to require authentication (if spec isn't nil).
+func requireAuth(spec *AuthSpec, handler http.HandlerFunc) http.HandlerFunc {
+	if spec == nil {
+		return handler
+	}
+
+	return func (w http.ResponseWriter, r *http.Request) {
+		if !spec.check(r) {
+			log.Println("Rejecting unauthorized request from " + clientIp(r) + " for " + r.URL.Path)
+
+			if spec.Scheme == AUTH_SCHEME_BASIC {
+				w.Header().Set("WWW-Authenticate", "Basic realm=\"sailnavsim\"")
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		handler(w, r)
+	}
+}
@@ -0,0 +1,69 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+
+func TestAuth(t *testing.T) {
+	bearer, err := parseAuthSpec("bearer:s3cret:with:colons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := parseAuthSpec("basic:user:pa:ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authChecks(t, bearer, "Bearer s3cret:with:colons", http.StatusOK)
+	authChecks(t, bearer, "bearer s3cret:with:colons", http.StatusOK)
+	authChecks(t, bearer, "Bearer s3cret", http.StatusUnauthorized)
+	authChecks(t, bearer, "", http.StatusUnauthorized)
+
+	r := httptest.NewRequest("GET", "/v1/ws", nil)
+	r.SetBasicAuth("user", "pa:ss")
+	authChecks(t, basic, r.Header.Get("Authorization"), http.StatusOK)
+	r.SetBasicAuth("user", "wrong")
+	authChecks(t, basic, r.Header.Get("Authorization"), http.StatusUnauthorized)
+	authChecks(t, basic, "Bearer s3cret:with:colons", http.StatusUnauthorized)
+
+	authChecks(t, nil, "", http.StatusOK)
+
+	for _, s := range []string { "bearer", "bearer:", "basic:user", "basic::pass", "digest:x" } {
+		_, err = parseAuthSpec(s)
+		if err == nil {
+			t.Errorf("Expected error for invalid auth spec: %s", s)
+		}
+	}
+}
+
+func authChecks(t *testing.T, spec *AuthSpec, authorization string, expected int) {
+	r := httptest.NewRequest("GET", "/v1/ws", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+
+	w := httptest.NewRecorder()
+	requireAuth(spec, func (w http.ResponseWriter, r *http.Request) {})(w, r)
+	if w.Code != expected {
+		t.Errorf("Authorization %q: got status %d, expected %d", authorization, w.Code, expected)
+	}
+}
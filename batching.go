@@ -0,0 +1,87 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bytes"
+)
+
+
+// Per-tick batching:
+//
+// A client may set "batch" to true in any request to have everything it's
+// sent for each iteration (live data, and any status, time sync, stats and
+// event messages) combined into one frame, rather than a frame per message,
+// e.g. for clients paying a per-frame cost (and, eventually, connections
+// subscribed to several boats). From then on, every frame sent to it is a
+// JSON (or MessagePack) array of messages, even if it only holds one:
+//
+// [<message>,<message>,...]
+//
+// While the main loop assembles an iteration's messages, batching connections'
+// writers are held, and once it's done, each writes everything queued in the
+// meantime as one frame. Messages sent outside of iterations (e.g. replies to
+// requests) are written as soon as possible, as arrays of what's queued.
+
+
+// Enables batching for the connection (see above).
+func (wc *WsConn) SetBatch() {
+	wc.lock.Lock()
+	wc.batch = true
+	wc.lock.Unlock()
+}
+
+// Holds the writers of batching connections while an iteration's messages are queued,
+// returning the held connections (to be released with releaseBatches). Caller must hold _lock.
+func holdBatches() []*WsConn {
+	var held []*WsConn
+	for conn := range _conns {
+		conn.lock.Lock()
+		if conn.batch {
+			conn.held = true
+			held = append(held, conn)
+		}
+		conn.lock.Unlock()
+	}
+
+	return held
+}
+
+func releaseBatches(held []*WsConn) {
+	for _, conn := range held {
+		conn.lock.Lock()
+		conn.held = false
+		conn.cond.Signal()
+		conn.lock.Unlock()
+	}
+}
+
+// Combines queued messages' JSON into one array.
+func batchFrame(msgs []QueuedMsg) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, msg := range msgs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(bytes.TrimRight(msg.Data, "\n"))
+	}
+	buf.WriteByte(']')
+
+	return buf.Bytes()
+}
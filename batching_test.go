@@ -0,0 +1,69 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+
+func TestIntegrationBatch(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	_testSim.setBoat(boatKey, 45.0, -30.0, 90.0)
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey, "batch": true })
+
+	// Every frame is an array of messages, starting with the acknowledgement.
+	var frame []json.RawMessage
+	testRead(t, conn, &frame)
+
+	var ack SubscribedMsg
+	if len(frame) == 0 || json.Unmarshal(frame[0], &ack) != nil || ack.Type != "subscribed" {
+		t.Fatalf("Unexpected first frame: %s", frame)
+	}
+
+	for i := 0; i < 3; i++ {
+		testRead(t, conn, &frame)
+		for _, m := range frame {
+			var msg BoatDataLiveRespMsg
+			if json.Unmarshal(m, &msg) == nil && msg.Lat == 45.0 {
+				return
+			}
+		}
+	}
+	t.Error("Live data not received in a batch")
+}
+
+func TestBatchFrame(t *testing.T) {
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	wc.Send([]byte(`{"type":"time"}`), false)
+	wc.SendLiveAt("a", []byte(`{"lat":1}`), time.Time {})
+
+	frame := batchFrame(wc.queue)
+	if string(frame) != `[{"type":"time"},{"lat":1}]` {
+		t.Errorf("Unexpected batch frame: %s", frame)
+	}
+}
@@ -0,0 +1,240 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+// Boat control actions:
+//
+// With -actions, a connection subscribed by boat key (bdl, bdl_g or bdl_x,
+// not as a spectator) may control its boat through the connector, rather
+// than through a separate API: {"cmd":"action","action":"<action>","value":...}
+// is validated, and forwarded to the simulator as an "action" request
+// (protocol version 4; see sim-version.go). Only the actions listed in
+// -actions are allowed:
+//
+// course  Steer a course, in degrees true (a number from 0 up to 360)
+// trim    Set the sails, in percent of full sail (a number from 0 to 100)
+// anchor  Drop (true) or weigh (false) the anchor
+//
+// Since boat keys are secrets, a subscription by boat key is what authorizes
+// controlling that boat (and only that boat). Actions are rate limited per
+// boat (ACTION_BURST, refilled at one per ACTION_INTERVAL), however many
+// connections are subscribed to it.
+//
+// Every action is answered with exactly one
+// {"type":"action_result","id":<id>,"action":"<action>","ok":<bool>}, where
+// <id> is the request's optional "id" (a string or number, echoed as is) so
+// that clients may correlate results with their commands. Failed actions
+// also have "error" and "msg" (and "limit", when rate limited), as for error
+// messages.
+
+const ACTION_COURSE = "course"
+const ACTION_TRIM = "trim"
+const ACTION_ANCHOR = "anchor"
+
+const ACTION_BURST = 3
+const ACTION_INTERVAL = 5 * time.Second
+
+const ACTION_ID_MAX_LEN = 64 // Bytes, as JSON
+
+const SIM_ACTION_REJECTED = "rejected" // Simulator response status for a refused action
+
+const ERR_ACTION_NOT_ALLOWED = "action_not_allowed"
+const ERR_ACTION_REJECTED = "action_rejected"
+const ERR_ACTION_UNAVAILABLE = "action_unavailable"
+
+type ActionResultMsg struct {
+	Type string `json:"type"`
+	Id json.RawMessage `json:"id,omitempty"`
+	Action string `json:"action"`
+	Ok bool `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Msg string `json:"msg,omitempty"`
+	Limit int `json:"limit,omitempty"`
+}
+
+// Per-boat action rate limiters, by boat key (guarded by _lock)
+var _actionLimiters = make(map[string]*RateLimiter)
+
+
+// Parses the comma-separated list of allowed actions.
+func parseActions(s string) (map[string]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	actions := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		switch name {
+		case ACTION_COURSE, ACTION_TRIM, ACTION_ANCHOR:
+			actions[name] = true
+		default:
+			return nil, errors.New("Invalid action: " + name)
+		}
+	}
+
+	return actions, nil
+}
+
+// Checks an action's value, returning it as sent to the simulator.
+func actionValue(action string, value json.RawMessage) (string, error) {
+	switch action {
+	case ACTION_COURSE, ACTION_TRIM:
+		var v float64
+		if err := json.Unmarshal(value, &v); err != nil {
+			return "", errors.New("Invalid " + action + " value")
+		}
+
+		if action == ACTION_COURSE {
+			v = math.Round(v * 10.0) / 10.0
+			if !(v >= 0.0 && v < 360.0) {
+				return "", errors.New("Course must be from 0 up to 360")
+			}
+		} else {
+			v = math.Round(v)
+			if !(v >= 0.0 && v <= 100.0) {
+				return "", errors.New("Trim must be from 0 to 100")
+			}
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+
+	case ACTION_ANCHOR:
+		var v bool
+		if err := json.Unmarshal(value, &v); err != nil {
+			return "", errors.New("Invalid anchor value")
+		}
+		return strconv.Itoa(boolToInt(v)), nil
+	}
+
+	return "", errors.New("Unknown action")
+}
+
+// Checks that an action's id is a string or number, short enough to be echoed.
+func validActionId(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+	if len(id) > ACTION_ID_MAX_LEN {
+		return false
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case string, float64:
+		return true
+	}
+	return false
+}
+
+func sendActionResult(conn *WsConn, req *ReqMsg, errCode string, msg string, limit int) {
+	conn.SendJSON(&ActionResultMsg {
+		Type: "action_result",
+		Id: req.Id,
+		Action: req.Action,
+		Ok: errCode == "",
+		Error: errCode,
+		Msg: msg,
+		Limit: limit,
+	})
+}
+
+func wsReqAction(req *ReqMsg, conn *WsConn) {
+	if !validActionId(req.Id) {
+		// Without a usable id, the result couldn't be correlated.
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Action id must be a string or number, of up to " + strconv.Itoa(ACTION_ID_MAX_LEN) + " characters")
+		return
+	}
+
+	if !_config.Actions[req.Action] {
+		sendActionResult(conn, req, ERR_ACTION_NOT_ALLOWED, "Action not allowed", 0)
+		return
+	}
+
+	value, err := actionValue(req.Action, req.Value)
+	if err != nil {
+		sendActionResult(conn, req, ERR_INVALID_REQUEST, err.Error(), 0)
+		return
+	}
+
+	_lock.Lock()
+	connCtx, exists := _conns[conn]
+	allowed := exists && !connCtx.Spectator && !connCtx.GroupAll
+	limited := allowed && !actionAllowed(connCtx.BoatKey)
+	_lock.Unlock()
+
+	if !allowed {
+		sendActionResult(conn, req, ERR_ACTION_NOT_ALLOWED, "Actions require a (non-spectator) subscription by boat key", 0)
+		return
+	}
+	if limited {
+		sendActionResult(conn, req, ERR_RATE_LIMITED, "Action rate limit exceeded", ACTION_BURST)
+		return
+	}
+
+	client := simClient(connCtx.Sim)
+	if client == nil {
+		sendActionResult(conn, req, ERR_ACTION_UNAVAILABLE, "Simulator unavailable", 0)
+		return
+	}
+
+	status, reason, ok := client.SendAction(connCtx.BoatKey, req.Action, value)
+	switch {
+	case !ok:
+		sendActionResult(conn, req, ERR_ACTION_UNAVAILABLE, "Simulator unavailable, or doesn't support actions", 0)
+	case status == SIM_STATUS_NOBOAT:
+		sendActionResult(conn, req, ERR_UNKNOWN_BOAT, "Unknown boat", 0)
+	case status == SIM_ACTION_REJECTED:
+		sendActionResult(conn, req, ERR_ACTION_REJECTED, reason, 0)
+	default:
+		log.Println("Forwarded " + req.Action + " action (" + value + ") for boat " + connCtx.BoatKey + " from " + conn.RemoteIp)
+		sendActionResult(conn, req, "", "", 0)
+	}
+}
+
+// Checks (and updates) a boat's action rate limit. Caller must hold _lock.
+func actionAllowed(boatKey string) bool {
+	now := time.Now()
+
+	// Limiters idle for long enough to have refilled are no longer needed.
+	for k, l := range _actionLimiters {
+		if now.Sub(l.Last) >= ACTION_BURST * ACTION_INTERVAL {
+			delete(_actionLimiters, k)
+		}
+	}
+
+	limiter, exists := _actionLimiters[boatKey]
+	if !exists {
+		limiter = &RateLimiter { ACTION_BURST, now }
+		_actionLimiters[boatKey] = limiter
+	}
+
+	return limiter.allow(now, ACTION_BURST, ACTION_INTERVAL)
+}
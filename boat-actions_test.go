@@ -0,0 +1,146 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+
+func TestActionValue(t *testing.T) {
+	valid := map[string]string {
+		ACTION_COURSE + ":245.04": "245",
+		ACTION_COURSE + ":0": "0",
+		ACTION_TRIM + ":62.5": "63",
+		ACTION_ANCHOR + ":true": "1",
+		ACTION_ANCHOR + ":false": "0",
+	}
+	for req, expected := range valid {
+		action, value := splitTestAction(req)
+		v, err := actionValue(action, json.RawMessage(value))
+		if err != nil || v != expected {
+			t.Errorf("Unexpected value for %s: %q, %v", req, v, err)
+		}
+	}
+
+	for _, req := range []string { ACTION_COURSE + ":360", ACTION_COURSE + ":359.96", ACTION_COURSE + ":-1", ACTION_COURSE + ":\"north\"", ACTION_TRIM + ":101", ACTION_ANCHOR + ":1", "jibe:true" } {
+		action, value := splitTestAction(req)
+		if _, err := actionValue(action, json.RawMessage(value)); err == nil {
+			t.Errorf("Invalid action accepted: %s", req)
+		}
+	}
+
+	if _, err := parseActions("course,tack"); err == nil {
+		t.Error("Invalid action list accepted")
+	}
+}
+
+func TestValidActionId(t *testing.T) {
+	for _, id := range []string { "", `"c1"`, "42", "-1.5" } {
+		if !validActionId(json.RawMessage(id)) {
+			t.Errorf("Valid action id rejected: %s", id)
+		}
+	}
+	for _, id := range []string { "null", "true", `["c1"]`, `{"n":1}`, `"` + strings.Repeat("x", ACTION_ID_MAX_LEN) + `"` } {
+		if validActionId(json.RawMessage(id)) {
+			t.Errorf("Invalid action id accepted: %s", id)
+		}
+	}
+}
+
+func splitTestAction(req string) (string, string) {
+	for i := range req {
+		if req[i] == ':' {
+			return req[:i], req[i + 1:]
+		}
+	}
+	return req, ""
+}
+
+func TestWsReqAction(t *testing.T) {
+	boatKey := "f5000000000000000000000000000000"
+	fake := &FakeSimClient { boats: map[string]BoatDataLiveRespMsg { boatKey: {} } }
+
+	prevActions := _config.Actions
+	_config.Actions = map[string]bool { ACTION_COURSE: true, ACTION_ANCHOR: true }
+	_lock.Lock()
+	_simClients["fake-actions"] = fake
+	_lock.Unlock()
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	spectator := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	defer func() {
+		_config.Actions = prevActions
+		_lock.Lock()
+		delete(_simClients, "fake-actions")
+		delete(_conns, wc)
+		delete(_conns, spectator)
+		delete(_actionLimiters, boatKey)
+		_lock.Unlock()
+	}()
+
+	course := func (conn *WsConn, id string, value string) {
+		wsReqAction(&ReqMsg { Cmd: "action", Id: json.RawMessage(id), Action: ACTION_COURSE, Value: json.RawMessage(value) }, conn)
+	}
+
+	course(wc, `"c1"`, "90")
+	wsReqAction(&ReqMsg { Cmd: "action", Action: ACTION_TRIM, Value: json.RawMessage("50") }, wc)
+	course(wc, `{"n":1}`, "90")
+	expectQueued(t, wc,
+		`{"type":"action_result","id":"c1","action":"course","ok":false,"error":"action_not_allowed","msg":"Actions require a (non-spectator) subscription by boat key"}`,
+		`{"type":"action_result","action":"trim","ok":false,"error":"action_not_allowed","msg":"Action not allowed"}`,
+		`{"type":"error","error":"invalid_request","msg":"Action id must be a string or number, of up to 64 characters"}`)
+	wc.queue = nil
+
+	_lock.Lock()
+	_conns[wc] = ConnCtx { BoatKey: boatKey, Sim: "fake-actions" }
+	_conns[spectator] = ConnCtx { BoatKey: boatKey, Sim: "fake-actions", Spectator: true }
+	_lock.Unlock()
+
+	course(spectator, `"s1"`, "90")
+	expectQueued(t, spectator, `{"type":"action_result","id":"s1","action":"course","ok":false,"error":"action_not_allowed","msg":"Actions require a (non-spectator) subscription by boat key"}`)
+
+	course(wc, `"c2"`, "400")
+	course(wc, `"c3"`, "90")
+	wsReqAction(&ReqMsg { Cmd: "action", Id: json.RawMessage("4"), Action: ACTION_ANCHOR, Value: json.RawMessage("true") }, wc)
+	course(wc, `5`, "91")
+	course(wc, `"c6"`, "92")
+	expectQueued(t, wc,
+		`{"type":"action_result","id":"c2","action":"course","ok":false,"error":"invalid_request","msg":"Course must be from 0 up to 360"}`,
+		`{"type":"action_result","id":"c3","action":"course","ok":true}`,
+		`{"type":"action_result","id":4,"action":"anchor","ok":false,"error":"action_rejected","msg":"No anchor, in water this deep"}`,
+		`{"type":"action_result","id":5,"action":"course","ok":true}`,
+		`{"type":"action_result","id":"c6","action":"course","ok":false,"error":"rate_limited","msg":"Action rate limit exceeded","limit":3}`)
+
+	wc.queue = nil
+	_lock.Lock()
+	_conns[wc] = ConnCtx { BoatKey: "f5000000000000000000000000000001", Sim: "fake-actions" }
+	_lock.Unlock()
+
+	course(wc, `"c7"`, "93")
+	expectQueued(t, wc, `{"type":"action_result","id":"c7","action":"course","ok":false,"error":"unknown_boat","msg":"Unknown boat"}`)
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if len(fake.actions) != 4 || fake.actions[0] != "course,90" || fake.actions[1] != "anchor,1" {
+		t.Errorf("Unexpected actions forwarded: %v", fake.actions)
+	}
+}
@@ -0,0 +1,108 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+)
+
+// Extended boat data:
+//
+// For boats subscribed to with "bdl_x", the simulator is asked for extended
+// boat data with a "bdx,<boat_key>" request (instead of "bd_nc"), whose "ok"
+// response carries these additional fields after the usual ones:
+//
+//   ...,<hdg>,<heel>,<heave>,<leeway>,<rudder>,<cur_set>,<cur_drift>,<sail>
+//
+// (with <sail> being the simulator's sail state string, e.g. "up", "down").
+//
+// Cluster edge instances are only sent basic boat data by the poller (see
+// cluster.go), so "bdl_x" subscriptions are rejected there.
+
+const ERR_EXTENDED_UNAVAILABLE = "extended_unavailable"
+
+const BOAT_DATA_EXT_FIRST_FIELD = 11
+const BOAT_DATA_EXT_NUM_FIELDS = 8
+
+type BoatDataExt struct {
+	Hdg float64 `json:"hdg"`
+	Heel float64 `json:"heel"`
+	Heave float64 `json:"heave"`
+	Leeway float64 `json:"leeway"`
+	Rudder float64 `json:"rudder"`
+	CurSet float64 `json:"cur_set"`
+	CurDrift float64 `json:"cur_drift"`
+	Sail string `json:"sail"`
+}
+
+type BoatDataExtRespMsg struct {
+	BoatDataLiveRespMsg
+	*BoatDataExt
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+
+// Decodes the extended fields of a "bdx" response line, failing the line if it has some but not all of
+// them. Returns nil if there are none (only the usual fields), or if any is invalid, in which case they're
+// all left out (and counted as rejected), rather than failing the whole line.
+func decodeBoatDataExt(line *SimLineDecoder) *BoatDataExt {
+	line.Count(BOAT_DATA_EXT_FIRST_FIELD, BOAT_DATA_EXT_FIRST_FIELD + BOAT_DATA_EXT_NUM_FIELDS)
+	if line.err != nil || line.NumFields() == BOAT_DATA_EXT_FIRST_FIELD {
+		return nil
+	}
+
+	d := &SimLineDecoder { line: line.line, fields: line.fields }
+	i := BOAT_DATA_EXT_FIRST_FIELD
+
+	ext := &BoatDataExt {
+		Hdg: d.Float(i, -360.0, 360.0),
+		Heel: d.Float(i + 1, -180.0, 180.0),
+		Heave: d.Float(i + 2, -1000.0, 1000.0),
+		Leeway: d.Float(i + 3, -180.0, 180.0),
+		Rudder: d.Float(i + 4, -180.0, 180.0),
+		CurSet: d.Float(i + 5, -360.0, 360.0),
+		CurDrift: d.Float(i + 6, 0.0, SIM_MAX_SPEED),
+		Sail: d.String(i + 7),
+	}
+
+	if d.err != nil {
+		return nil
+	}
+	return ext
+}
+
+// Creates the message for a "bdl_x" subscription. If no extended data is
+// available for the boat (yet), then only the usual fields are included.
+func createBoatDataExtRespMsg(resp BoatDataLiveRespMsg) *BoatDataExtRespMsg {
+	return &BoatDataExtRespMsg {
+		BoatDataLiveRespMsg: resp,
+		BoatDataExt: resp.Ext,
+	}
+}
+
+// Rejects a bdl_x subscription (closing the connection) if extended boat data isn't available, returning whether it was rejected.
+func rejectIfExtendedUnavailable(conn *WsConn) bool {
+	if _config.ClusterRole != CLUSTER_ROLE_EDGE {
+		return false
+	}
+
+	log.Println("Client (" + conn.RemoteIp + ") requested extended boat data on a cluster edge")
+
+	sendErrorMsg(conn, ERR_EXTENDED_UNAVAILABLE, "Extended boat data unavailable")
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Extended boat data unavailable")
+	return true
+}
@@ -17,18 +17,15 @@
 package main
 
 import (
-	"bufio"
 	"container/list"
-	"fmt"
+	"context"
+	"encoding/json"
 	"log"
 	"math"
-	"net"
 	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
-	"github.com/gorilla/websocket"
+	"sailnavsim-snsw/internal/hub"
 )
 
 
@@ -43,19 +40,47 @@ type BoatInfo struct {
 type ConnCtx struct {
 	BoatKey string
 	GroupBoats *list.List
+	GroupPending bool // GroupBoats is a placeholder while the group is fetched (see group-fetch.go)
+	Session *Session
+	Spectator bool
+	Extended bool
+	GroupAll bool
+	Ais bool
+	OthersSog bool // Include other boats' SOG in "others"
+	Sim string // Simulator the subscription is directed to (see sims.go)
+	Fields map[string]bool // Fields of live data to send, or nil for all (see fields.go)
+	CogSmoother *CogSmoother // COG smoothing state, or nil if not requested (see cog-smoothing.go)
+	Units *Units // Units to convert live data to, or nil for the defaults (see units.go)
+	Hf *HfState // High-frequency mode state, or nil if not requested or unavailable (see hf.go)
+	Interp bool // Wants interpolated data between iterations (see interp.go)
+	Priority int // Priority class, for load shedding (see priority.go)
+	SubscribedAt time.Time // Zero for subscriptions without a maximum lifetime (see lifetime.go)
+	Resync bool // Send a full live data message on the next iteration (see resync.go)
+	Geofences *GeofenceState // Geofences to alert on, or nil if none (see geofence.go)
+	Waypoint *Waypoint // Waypoint to navigate to, or nil if none (see waypoint.go)
 }
-var _conns = make(map[*websocket.Conn]ConnCtx)
+var _conns = make(map[*WsConn]ConnCtx)
 
-// Map of boat keys to list of connections (one boat key can be associated with multiple connections)
-var _keys = make(map[string]*list.List)
+// Connections subscribed to each boat key (one boat key can be associated with multiple connections)
+var _hub = hub.New(hub.Options[BoatDataLiveRespMsg] {
+	Present: hubPresent,
+	Missing: hubMissing,
+	Dropped: hubDropped,
+})
 
 // Tracker for boat keys in groups
 type TrackedBoatEntry struct {
 	BoatKey string
+	Sim string // Simulator the boat is polled from
 	RefCount uint64
+	ExtRefCount uint64 // Number of subscriptions wanting extended data for this boat
+	Warm bool // Holding a reference for the watch list (see watch-list.go)
 }
 var _trackedBoats = make(map[string]*TrackedBoatEntry)
 
+// Boat data responses from the most recent iteration
+var _latestResps = make(map[string]BoatDataLiveRespMsg)
+
 var _countConns int64 = 0
 var _countMsgs int64 = 0
 
@@ -63,19 +88,88 @@ var _connectHostPort string = ""
 
 var _boatKeyRegexp *regexp.Regexp = regexp.MustCompile("^[0-9a-f]{32}$")
 
-const ITERATIONS_PER_LOG int64 = 60
 
 const DIAL_TIMEOUT = 3 * time.Second
 const CONN_RW_TIMEOUT = 3 * time.Second
 
+// Distance (NM) within which other boats in the group are included in live data
+const GROUP_VISIBILITY_DIST = 15.0
+
+
+func wsReqBoatDataLive(req *ReqMsg, conn *WsConn, withGroup bool, extended bool) {
+	ctx, span := startSpan(context.Background(), "subscribe")
+	span.SetAttr("cmd", req.Cmd)
+	span.SetAttr("sim", req.Sim)
+	defer span.Finish()
+
+	if rejectIfDraining(conn) || rejectIfMemoryPressure(conn) || (extended && rejectIfExtendedUnavailable(conn)) {
+		return
+	}
+
+	spectator := false
+	if req.SpectatorId != "" {
+		// View-only request by public spectator ID, rather than by boat key.
+		boatKey := resolveSpectatorId(req.Sim, req.SpectatorId)
+		if boatKey == "" {
+			log.Println("Client (" + conn.RemoteIp + ") sent unknown spectator ID: " + req.SpectatorId)
+			noteInvalidKey(conn.RemoteIp) // See abuse.go.
+
+			sendErrorMsg(conn, ERR_UNKNOWN_SPECTATOR_ID, "Unknown spectator ID")
+			conn.CloseWithReason(CLOSE_INVALID_KEY, "Unknown spectator ID")
+			return
+		}
+
+		req.BoatKey = boatKey
+		spectator = true
+	}
 
-func wsReqBoatDataLive(req *ReqMsg, conn *websocket.Conn, withGroup bool) {
 	if !_boatKeyRegexp.MatchString(req.BoatKey) {
-		log.Println("Client sent invalid boat key!")
-		conn.Close()
+		log.Println("Client (" + conn.RemoteIp + ") sent invalid boat key!")
+		noteInvalidKey(conn.RemoteIp) // See abuse.go.
+		conn.CloseWithReason(CLOSE_INVALID_KEY, "Invalid boat key")
+		return
+	}
+
+	if rejectIfRefusedKey(conn, req.BoatKey) || !validateBoatKey(ctx, req, conn) {
+		return
+	}
+
+	fields, ok := reqFields(req, conn, extended)
+	if !ok {
+		return
+	}
+
+	cogSmoother, ok := reqCogSmoothing(req, conn)
+	if !ok {
+		return
+	}
+
+	units, ok := reqUnits(req, conn)
+	if !ok {
+		return
+	}
+
+	geofences, ok := reqGeofences(req, conn, spectator)
+	if !ok {
+		return
+	}
+
+	waypoint, ok := reqWaypoint(req, conn)
+	if !ok {
 		return
 	}
 
+	defaultPriority := PRIORITY_NORMAL
+	if spectator {
+		defaultPriority = PRIORITY_LOW
+	}
+	priority, ok := reqPriority(req, conn, defaultPriority)
+	if !ok {
+		return
+	}
+
+	fetchBoatInfo(ctx, req.Sim, req.BoatKey) // For the subscription acknowledgement (see boat-info.go)
+
 	_lock.Lock()
 	defer _lock.Unlock()
 
@@ -83,47 +177,213 @@ func wsReqBoatDataLive(req *ReqMsg, conn *websocket.Conn, withGroup bool) {
 	if !exists {
 		// This is the first request on this connection, so associate it with the boat key.
 
+		if rejectIfTooManySubscribers(conn, req.BoatKey) || rejectIfTooManyTracked(conn, req.BoatKey) {
+			return
+		}
+
 		if withGroup {
 			// Request to include nearby boats in group
-			groupBoats := getBoatsInGroup(req.BoatKey)
-			if groupBoats == nil {
-				conn.Close()
-				return
-			}
-
-			_conns[conn] = ConnCtx {
+			connCtx := ConnCtx {
 				BoatKey: req.BoatKey,
-				GroupBoats: groupBoats,
+				GroupBoats: pendingGroup(req.BoatKey), // Until fetched (see group-fetch.go)
+				GroupPending: true,
+				Spectator: spectator,
+				Ais: req.Ais,
+				OthersSog: req.OthersSog,
+				Sim: req.Sim,
+				Fields: fields,
+				CogSmoother: cogSmoother,
+				Units: units,
+				Hf: reqHf(req, withGroup),
+				Interp: reqInterp(req),
+				Priority: priority,
+				SubscribedAt: time.Now(),
+				Geofences: geofences,
+				Waypoint: waypoint,
 			}
+			_conns[conn] = connCtx
+			conn.SetType(CONN_TYPE_BDL_G)
 
-			trackBoats(groupBoats)
+			trackConnCtx(&connCtx)
 		} else {
 			// Request to include only this boat
-			_conns[conn] = ConnCtx {
+			connCtx := ConnCtx {
 				BoatKey: req.BoatKey,
 				GroupBoats: nil,
+				Spectator: spectator,
+				Extended: extended && !spectator, // Spectators only get the (coarsened) basic data
+				Sim: req.Sim,
+				Fields: fields,
+				CogSmoother: cogSmoother,
+				Units: units,
+				Interp: reqInterp(req),
+				Priority: priority,
+				SubscribedAt: time.Now(),
+				Geofences: geofences,
+				Waypoint: waypoint,
 			}
+			_conns[conn] = connCtx
+			conn.SetType(CONN_TYPE_BDL)
 
-			trackBoat(req.BoatKey)
+			trackConnCtx(&connCtx)
+		}
+
+		if spectator {
+			conn.SetType(CONN_TYPE_SPECTATOR)
 		}
 
 		_countConns++
 	} else {
 		// Don't allow more than one boat key per connection.
 		// If we encounter this situation, then just close the connection.
-		conn.Close()
+		conn.CloseWithReason(CLOSE_DUPLICATE_SUBSCRIBE, "Already subscribed")
 		return
 	}
 
 	// Add the connection to the list of connections that this boat key maps to.
-	keyList, exists := _keys[req.BoatKey]
-	if exists {
-		keyList.PushBack(conn)
+	markUnconfirmedKey(req.BoatKey)
+	addConnToKey(req.BoatKey, conn)
+
+	connCtx := _conns[conn]
+	sendSubscribedMsg(conn, &connCtx)
+	sendHistory(conn, &connCtx)
+	sendReconnectToken(conn, &connCtx)
+
+	if req.Session {
+		connCtx.Session = startSession(conn, &connCtx)
+		_conns[conn] = connCtx
+	}
+
+	if connCtx.GroupPending {
+		fetchGroup(conn, &connCtx)
+	}
+}
+
+// Returns whether subscribing to the boat would exceed -max-subscribers-per-key (rejecting the request, if so). Caller must hold _lock.
+func rejectIfTooManySubscribers(conn *WsConn, boatKey string) bool {
+	if _config.MaxSubscribersPerKey <= 0 || _hub.Count(boatKey) < _config.MaxSubscribersPerKey {
+		return false
+	}
+
+	log.Println("Rejecting subscriber (" + conn.RemoteIp + ") over limit for boat key: " + boatKey)
+	sendLimitErrorMsg(conn, ERR_TOO_MANY_SUBSCRIBERS, "Too many subscribers for this boat", _config.MaxSubscribersPerKey)
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Too many subscribers")
+	return true
+}
+
+// Rejects a subscription to a boat key that's banned (see abuse.go) or on "noboat" cooldown (see deleted-boats.go),
+// returning whether it was rejected. Must be called without _lock held.
+func rejectIfRefusedKey(conn *WsConn, boatKey string) bool {
+	return rejectIfBannedKey(conn, boatKey) || rejectIfNoboatKey(conn, boatKey)
+}
+
+func addConnToKey(boatKey string, conn *WsConn) {
+	_hub.Subscribe(boatKey, conn)
+	coalesceDuplicates(boatKey, conn)
+}
+
+func removeConnFromKey(boatKey string, conn *WsConn) {
+	if _hub.Unsubscribe(boatKey, conn) {
+		// The boat has no more connections associated with it.
+		delete(_missedIters, boatKey)
+		delete(_unconfirmedKeys, boatKey)
+	}
+}
+
+// Called (with _lock held) once a connection's reader has exited, to unsubscribe it right away, rather
+// than leaving it (and the boats it has tracked) until the next iteration finds it closed.
+func releaseConn(conn *WsConn) {
+	connCtx, exists := _conns[conn]
+	if !exists {
+		return // Not subscribed, or already released.
+	}
+
+	removeConnFromKey(connCtx.BoatKey, conn)
+	releaseConnCtx(conn, &connCtx)
+}
+
+// Called (with _lock held) to forget a connection already unsubscribed from its boat key.
+func releaseConnCtx(conn *WsConn, connCtx *ConnCtx) {
+	if connCtx.Session != nil {
+		// Boats remain tracked by the session until it's resumed or expires.
+		connCtx.Session.detach(conn)
 	} else {
-		newList := list.New()
-		newList.PushBack(conn)
-		_keys[req.BoatKey] = newList
+		untrackConnCtx(connCtx)
+	}
+
+	delete(_conns, conn)
+	delete(_hfConns, conn)
+	delete(_interpConns, conn)
+}
+
+// Called (with _lock held) before sending a boat key's data to its connections.
+func hubPresent(boatKey string, resp BoatDataLiveRespMsg, conns []hub.Subscriber) {
+	delete(_unconfirmedKeys, boatKey)
+
+	if resp.LastKnown {
+		simMissed(boatKey, conns, true)
+	} else {
+		simRecovered(boatKey, conns)
+	}
+}
+
+// Called (with _lock held) when there's no data for a subscribed boat key (other than "noboat"),
+// returning whether to keep its connections open for now. On an edge instance, this includes
+// a snapshot not (yet) published by the poller, or a stale one (see cluster.go).
+func hubMissing(boatKey string, conns []hub.Subscriber) bool {
+	if simMissed(boatKey, conns, false) {
+		return true // Possibly a short simulator outage, so keep the connections open for now.
+	}
+
+	log.Println("No data for boat key: " + boatKey)
+	return false
+}
+
+// Called (with _lock held) to close a connection as there's no data for its boat key.
+func hubDropped(boatKey string, sub hub.Subscriber, noBoat bool) {
+	if noBoat && _unconfirmedKeys[boatKey] {
+		rejectUnknownBoat(sub.(*WsConn)) // See key-validation.go.
+	} else if noBoat {
+		closeBoatDeleted(sub.(*WsConn)) // See deleted-boats.go.
+	} else {
+		sub.(*WsConn).CloseWithReason(CLOSE_BACKEND_UNREACHABLE, "Simulator unreachable")
+	}
+}
+
+// Formats the live data message for a connection (or buffers it, for a session), sharing it
+// with other connections of the same stream if possible (see shared-streams.go).
+func formatLiveMsg(conn *WsConn, resp BoatDataLiveRespMsg, liveResps map[string]BoatDataLiveRespMsg, groupIndexes *GroupIndexes, shared *SharedStreams) *hub.Msg {
+	connCtx := _conns[conn]
+	return formatConnCtxMsg(&connCtx, resp, liveResps, groupIndexes, shared)
+}
+
+// Called (with _lock held) to format a live data message for a subscription.
+func formatConnCtxMsg(connCtx *ConnCtx, resp BoatDataLiveRespMsg, liveResps map[string]BoatDataLiveRespMsg, groupIndexes *GroupIndexes, shared *SharedStreams) *hub.Msg {
+	msg := shared.get(connCtx, func () []byte {
+		respMsg := createRespMsg(connCtx, resp, liveResps, groupIndexes)
+
+		if connCtx.Session != nil {
+			// Session messages are sequenced and buffered, in case the client needs to resume.
+			return connCtx.Session.bufferMsg(respMsg)
+		}
+
+		data, err := json.Marshal(respMsg)
+		if err != nil {
+			log.Println(err)
+		}
+		return data
+	})
+
+	if msg.Data == nil {
+		return nil
+	}
+
+	// Delivery latency isn't measured for last known data, which arrived long ago.
+	if !resp.LastKnown {
+		msg.Arrived = resp.ArrivedAt
 	}
+
+	return msg
 }
 
 type BoatDataLiveRespMsg struct {
@@ -135,16 +395,24 @@ type BoatDataLiveRespMsg struct {
 	Sog float64 `json:"sog"`
 	Lws float64 `json:"lws"`
 	Ha float64 `json:"ha"`
+
+	Ext *BoatDataExt `json:"-"` // Only present for boats tracked with extended data
+
+	Ts int64 `json:"ts,omitempty"` // Arrival time (Unix time in ms), only with -embed-timestamps
+	ArrivedAt time.Time `json:"-"`
+
+	Age int64 `json:"age,omitempty"` // Seconds since arrival, only for last known data (see sim-outage.go)
+	LastKnown bool `json:"-"`
+
+	Interp bool `json:"interp,omitempty"` // Only for interpolated data (see interp.go)
+
+	Wpt *WaypointInfo `json:"wpt,omitempty"` // Only for the subscribed boat, with a waypoint (see waypoint.go)
 }
 
 type BoatGroupRespMsg struct {
 	ThisBoat BoatDataLiveRespMsg `json:"you"`
-	OtherBoats map[string][3]float64 `json:"others"`
-}
-
-type KeyConnTuple struct {
-	Key string
-	Conn *websocket.Conn
+	OtherBoats map[string][]float64 `json:"others"` // [lat, lon, ctw] (or [lat, lon, ctw, sog], if requested)
+	Ais []string `json:"ais,omitempty"` // Other boats as AIS sentences, if requested
 }
 
 func boatDataLiveMain(connectHostPort string) {
@@ -155,107 +423,97 @@ func boatDataLiveMain(connectHostPort string) {
 	var iterTimeMax int64 = -999999999999
 	var iterTimeSum int64 = 0
 
-	connsRemove := list.New()
-	keysRemove := list.New()
-
-
 	log.Println("Starting boat data live main loop...")
 
+	tick := firstTick(time.Now(), _config.PollInterval, _config.AdaptiveTick)
+
 	// Main loop for live boat data.
-	// Iterates approximately once every second (or slower, if things run longer).
+	// Iterates once every poll interval (or slower, if things run longer; see tick.go).
 	for {
-		connsRemove.Init()
-		keysRemove.Init()
-
-		_lock.Lock()
 		iterStartTime := time.Now()
+		systemdWatchdog(iterStartTime)
 
-		// Get the boat data responses from the simulator.
-		resps := getBoatDataLiveResps()
+		iterCtx, iterSpan := startSpan(context.Background(), "iteration")
+		iterSpan.SetAttr("iteration", iterCount)
 
-		for boatKey, conns := range _keys {
-			resp, exists := resps[boatKey]
-			if !exists {
-				// There was no valid data from the simulator for this boat key.
-				log.Println("No data for boat key: " + boatKey)
+		// As a cluster poller, also poll for boats tracked by edge instances.
+		var clusterKeys []string = nil
+		if _config.ClusterRole == CLUSTER_ROLE_POLLER {
+			clusterKeys = clusterPollerKeys()
+		}
+
+		_lock.Lock()
 
-				// Close this connection.
-				for e := conns.Front(); e != nil; e = e.Next() {
-					conn := e.Value.(*websocket.Conn)
-					connsRemove.PushBack(conn)
-					keysRemove.PushBack(KeyConnTuple { boatKey, conn })
+		// Get the boat data responses from the simulator (or from the cluster poller, if we're an edge instance).
+		var resps map[string]BoatDataLiveRespMsg
+		var noBoats map[string]bool
+		if _config.ClusterRole == CLUSTER_ROLE_EDGE {
+			resps, noBoats = clusterEdgeResps()
+		} else {
+			resps, noBoats = getBoatDataLiveResps(iterCtx, clusterKeys)
+			countIterDegraded()
+		}
 
-					conn.Close()
-				}
+		normalizeResps(resps) // See normalize.go.
+		updateNoboatKeys(resps, noBoats, iterStartTime)
+		recordHistory(resps)
 
-				continue
-			}
+		// Hold batching connections' writers until all of this iteration's messages are queued (see batching.go).
+		held := holdBatches()
 
-			// For each connection in the list associated with this boat key,
-			// send the boat data response message over the WebSocket.
-			for e := conns.Front(); e != nil; e = e.Next() {
-				conn := e.Value.(*websocket.Conn)
-				connCtx := _conns[conn]
-				closeConn := false
-				if connCtx.GroupBoats != nil {
-					// Create the response message for this boat plus the other boats in the same group.
-					resp := createBoatGroupRespMsg(&connCtx, resps)
-					err := conn.WriteJSON(resp)
-					if err != nil {
-						log.Println(err)
-						closeConn = true
-					}
-				} else {
-					err := conn.WriteJSON(resp)
-					if err != nil {
-						log.Println(err)
-						closeConn = true
-					}
-				}
-
-				if closeConn {
-					// Error sending message, so close this connection.
-					connsRemove.PushBack(conn)
-					keysRemove.PushBack(KeyConnTuple { boatKey, conn })
-
-					conn.Close()
-				}
-
-				_countMsgs++
-			}
+		if _config.Events {
+			pollBoatEvents()
 		}
 
-		// Remove closed connections from our tracking map.
-		for e := connsRemove.Front(); e != nil; e = e.Next() {
-			connCtx := _conns[e.Value.(*websocket.Conn)]
-			if connCtx.GroupBoats != nil {
-				untrackBoats(connCtx.GroupBoats)
-			} else {
-				untrackBoat(connCtx.BoatKey)
+		// Data to send, which may also include last known data for boats missing from this iteration's.
+		liveResps := withLastKnownResps(resps, noBoats)
+
+		_latestResps = liveResps
+		groupIndexes := newGroupIndexes(liveResps)
+		updateHf(liveResps, groupIndexes)
+		updateInterp(liveResps, tick)
+		updateGeofences(liveResps)
+		updateWebhooks(liveResps, iterStartTime)
+		updateAlertConns(countWsConns())
+		updateTimeSync(iterCount, iterStartTime)
+		updateConnStats(iterCount)
+		updateWatchList(iterCount, iterStartTime)
+		expireConns()
+
+		_, fanoutSpan := startSpan(iterCtx, "fanout")
+		shared := newSharedStreams()
+		result := _hub.Publish(&hub.Snapshot[BoatDataLiveRespMsg] { Data: liveResps, NoBoats: noBoats }, func (sub hub.Subscriber, boatKey string, resp BoatDataLiveRespMsg) *hub.Msg {
+			conn := sub.(*WsConn)
+			if connCtx := _conns[conn]; connCtx.Resync {
+				return formatResyncMsg(conn, resp, liveResps, groupIndexes) // See resync.go.
+			} else if shedSkip(&connCtx) {
+				conn.SkipMseq() // See shedding.go and msg-seq.go.
+				return nil
 			}
-
-			delete(_conns, e.Value.(*websocket.Conn))
+			return formatLiveMsg(conn, resp, liveResps, groupIndexes, shared)
+		})
+		_countMsgs += int64(result.Attempts)
+		fanoutSpan.SetAttr("messages", result.Attempts)
+		fanoutSpan.SetAttr("removed", len(result.Removed))
+		fanoutSpan.Finish()
+
+		for _, boatKey := range result.EmptiedKeys {
+			delete(_missedIters, boatKey)
+			delete(_unconfirmedKeys, boatKey)
 		}
 
-		// Remove closed connections from the list associated with our tracked boat keys map.
-		for e := keysRemove.Front(); e != nil; e = e.Next() {
-			kct := e.Value.(KeyConnTuple)
-			connList, exists := _keys[kct.Key]
-			if exists {
-				for e2 := connList.Front(); e2 != nil; e2 = e2.Next() {
-					if e2.Value.(*websocket.Conn) == kct.Conn {
-						connList.Remove(e2)
-						break // The connection will only be in the list once, so we're done.
-					}
-				}
-
-				// If the boat has no more connections associated with it, then remove it from the map.
-				if connList.Len() == 0 {
-					delete(_keys, kct.Key)
-				}
+		// Remove closed connections (already unsubscribed from their boat keys) from our tracking map.
+		for _, r := range result.Removed {
+			conn := r.Sub.(*WsConn)
+			if connCtx, exists := _conns[conn]; exists {
+				releaseConnCtx(conn, &connCtx)
 			}
 		}
 
+		processDetachedSessions(liveResps, groupIndexes)
+
+		releaseBatches(held)
+
 		// Measure and record iteration duration.
 		iterTimeDuration := time.Now().Sub(iterStartTime)
 		iterTimeUs := iterTimeDuration.Microseconds()
@@ -266,16 +524,14 @@ func boatDataLiveMain(connectHostPort string) {
 			iterTimeMax = iterTimeUs
 		}
 		iterTimeSum += iterTimeUs
+		_lastIterTimeUs = iterTimeUs
+		updateShedding(iterTimeDuration)
 
-		// Log some statistics periodically.
-		if (iterCount > 0) && (iterCount % ITERATIONS_PER_LOG == 0) {
-			log.Println("Now:        conns=" + strconv.Itoa(len(_conns)) + ", keys=" + strconv.Itoa(len(_keys)) + ", tracked=" + strconv.Itoa(len(_trackedBoats)))
-			log.Println("Cumulative: conns=" + strconv.FormatInt(_countConns, 10) + ", msgs=" + strconv.FormatInt(_countMsgs, 10))
-
-			log.Println("Iteration times (min/avg/max us): " +
-				strconv.FormatInt(iterTimeMin, 10) + "/" +
-				strconv.FormatInt(iterTimeSum / ITERATIONS_PER_LOG, 10) + "/" +
-				strconv.FormatInt(iterTimeMax, 10))
+		// Collect some statistics periodically (to be reported once we've released the lock).
+		var stats *StatsSnapshot = nil
+		statsInterval := int64(_config.StatsInterval)
+		if (iterCount > 0) && (iterCount % statsInterval == 0) {
+			stats = collectStats(iterTimeMin, iterTimeSum / statsInterval, iterTimeMax)
 
 			// Reset iteration time counters.
 			iterTimeMin = 999999999999
@@ -285,193 +541,132 @@ func boatDataLiveMain(connectHostPort string) {
 
 		iterCount++
 		_lock.Unlock()
-		time.Sleep(time.Second - iterTimeDuration)
-	}
-}
-
-func getBoatDataLiveResps() map[string]BoatDataLiveRespMsg {
-	resps := make(map[string]BoatDataLiveRespMsg)
 
-	if len(_keys) == 0 {
-		return resps
-	}
-
-	conn, err := net.DialTimeout("tcp", _connectHostPort, DIAL_TIMEOUT)
-	if err != nil {
-		log.Println(err)
-		return resps
-	}
-	defer conn.Close()
-
-	if conn.SetDeadline(time.Now().Add(CONN_RW_TIMEOUT)) != nil {
-		log.Println(err)
-		return resps
-	}
+		if stats != nil {
+			reportStats(stats)
+		}
 
-	requestWriterDone := make(chan int)
-	go func() {
-		for boatKey, _ := range _trackedBoats {
-			fmt.Fprintf(conn, "bd_nc," + boatKey + "\n")
+		if _config.ClusterRole == CLUSTER_ROLE_POLLER {
+			clusterPublish(resps, noBoats)
 		}
 
-		requestWriterDone <- 0
-	}()
+		recordResps(iterStartTime, resps)
+		udpOutputResps(iterStartTime, resps)
 
-	responseReader := bufio.NewReader(conn)
+		iterSpan.SetAttr("boats", len(resps))
+		iterSpan.Finish()
 
-	// For each boat currently tracked, process its data from the simulator.
-	numTracked := len(_trackedBoats)
-	boatsToUntrack := list.New()
-	for i := 0; i < numTracked; i++ {
-		line, err := responseReader.ReadString('\n')
+		tick = waitForNextTick(tick)
+	}
+}
 
-		if err != nil {
-			log.Println(err)
-			break
+func getBoatDataLiveResps(ctx context.Context, extraBoatKeys []string) (map[string]BoatDataLiveRespMsg, map[string]bool) {
+	// Poll for all tracked boats (each from its own simulator), plus any extra boats
+	// (not already tracked, from the default simulator) requested by the caller.
+	reqsBySim := make(map[string][]SimBoatDataReq)
+	for boatKey, entry := range _trackedBoats {
+		reqsBySim[entry.Sim] = append(reqsBySim[entry.Sim], SimBoatDataReq { boatKey, entry.ExtRefCount > 0 })
+	}
+	now := time.Now()
+	for _, boatKey := range extraBoatKeys {
+		if _, exists := _trackedBoats[boatKey]; !exists && !isNoboatKey(boatKey, now) {
+			reqsBySim[DEFAULT_SIM] = append(reqsBySim[DEFAULT_SIM], SimBoatDataReq { boatKey, false })
 		}
+	}
 
-		line = strings.Trim(line, "\n")
-		if line == "error" {
-			log.Println("Error returned from simulator when trying to get live data for boat num: " + strconv.Itoa(i))
-			break
-		}
+	resps, noBoats := pollSims(ctx, reqsBySim)
 
-		s := strings.Split(line, ",")
+	for boatKey, _ := range noBoats {
+		log.Println("Untracking \"noboat\" " + boatKey)
+		delete(_trackedBoats, boatKey)
+	}
 
-		switch s[2] {
-		case "ok":
-			lat, err := strconv.ParseFloat(s[3], 64)
-			if err != nil {
-				continue
-			}
+	return resps, noBoats
+}
 
-			lon, err := strconv.ParseFloat(s[4], 64)
-			if err != nil {
-				continue
-			}
+// Polls each simulator for its boats' data.
+func pollSims(ctx context.Context, reqsBySim map[string][]SimBoatDataReq) (map[string]BoatDataLiveRespMsg, map[string]bool) {
+	resps := make(map[string]BoatDataLiveRespMsg)
+	noBoats := make(map[string]bool)
+
+	// Simulators are polled concurrently, so that a slow or unreachable one doesn't hold up the others.
+	var wg sync.WaitGroup
+	var respsLock sync.Mutex
+	for sim, reqs := range reqsBySim {
+		client := simClient(sim)
+		if client == nil {
+			log.Println("Not polling boats from unknown simulator: " + sim)
+			continue
+		}
 
-			ctw, err := strconv.ParseFloat(s[5], 64)
-			if err != nil {
-				continue
-			}
+		wg.Add(1)
+		go func(sim string, client SimClient, reqs []SimBoatDataReq) {
+			defer wg.Done()
 
-			stw, err := strconv.ParseFloat(s[6], 64)
-			if err != nil {
-				continue
-			}
+			pollCtx, span := startSpan(ctx, "sim.poll")
+			span.SetAttr("sim", sim)
+			span.SetAttr("boats", len(reqs))
+			defer span.Finish()
 
-			cog, err := strconv.ParseFloat(s[7], 64)
-			if err != nil {
-				continue
-			}
+			simResps, simNoBoats := client.GetBoatData(pollCtx, reqs)
 
-			sog, err := strconv.ParseFloat(s[8], 64)
-			if err != nil {
-				continue
+			respsLock.Lock()
+			for boatKey, resp := range simResps {
+				resps[boatKey] = resp
 			}
-
-			lws, err := strconv.ParseFloat(s[9], 64)
-			if err != nil {
-				continue
+			for boatKey, _ := range simNoBoats {
+				noBoats[boatKey] = true
 			}
+			respsLock.Unlock()
+		}(sim, client, reqs)
+	}
+	wg.Wait()
 
-			ha, err := strconv.ParseFloat(s[10], 64)
-			if err != nil {
-				continue
-			}
+	return resps, noBoats
+}
 
-			resps[s[1]] = BoatDataLiveRespMsg {
-				Lat: lat,
-				Lon: lon,
-				Ctw: ctw,
-				Stw: stw,
-				Cog: cog,
-				Sog: sog,
-				Lws: lws,
-				Ha: ha,
-			}
 
-		case "noboat":
-			log.Println("No boat for key: " + s[1])
-			boatsToUntrack.PushBack(s[1])
 
-		default:
-			log.Println("Unexpected response from simulator: " + s[2])
+// Tracks the boat(s) needed for a subscription, returning false (having tracked none of them) if a group's
+// boats would exceed -max-tracked-boats. A single boat is checked beforehand, with rejectIfTooManyTracked.
+func trackConnCtx(connCtx *ConnCtx) bool {
+	if connCtx.GroupBoats != nil {
+		if !trackBoats(connCtx.Sim, connCtx.GroupBoats) {
+			return false
 		}
+	} else {
+		trackBoat(connCtx.Sim, connCtx.BoatKey)
 	}
 
-	// Ensure that our request writer goroutine has finished before continuing.
-	<-requestWriterDone
-
-	for boat := boatsToUntrack.Front(); boat != nil; boat = boat.Next() {
-		log.Println("Untracking \"noboat\" " + boat.Value.(string))
-		delete(_trackedBoats, boat.Value.(string))
+	if connCtx.Extended {
+		trackBoatExt(connCtx.BoatKey)
 	}
-
-	return resps
+	return true
 }
 
-func getBoatsInGroup(boatKey string) *list.List {
-	conn, err := net.DialTimeout("tcp", _connectHostPort, DIAL_TIMEOUT)
-	if err != nil {
-		log.Println(err)
-		return nil
+// Untracks the boat(s) that were needed for a subscription.
+func untrackConnCtx(connCtx *ConnCtx) {
+	if connCtx.Extended {
+		untrackBoatExt(connCtx.BoatKey)
 	}
-	defer conn.Close()
 
-	if conn.SetDeadline(time.Now().Add(CONN_RW_TIMEOUT)) != nil {
-		log.Println(err)
-		return nil
+	if connCtx.GroupBoats != nil {
+		untrackBoats(connCtx.GroupBoats)
+	} else {
+		untrackBoat(connCtx.BoatKey)
 	}
+}
 
-	groupKeys := list.New()
-
-	fmt.Fprintf(conn, "boatgroupmembers," + boatKey + "\n")
-	start := true
-	reader := bufio.NewReader(conn)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			log.Println(err)
-			return nil
-		}
-
-		line = strings.Trim(line, "\n")
-
-		if start {
-			if line == "error" {
-				log.Println("Error returned from simulator when trying to get boat group membership for boat key: " + boatKey)
-				return nil
-			}
-
-			s := strings.Split(line, ",")
-			switch s[2] {
-			case "ok":
-				start = false
-				continue
-
-			default:
-				log.Println("Unexpected code (\"" + s[2] + "\") returned from simulator when trying to get boat group membership for boat key: " + boatKey)
-				return nil
-			}
-		} else if line == "" {
-			return groupKeys
-		} else {
-			s := strings.Split(line, ",")
-			if s[1] != "!" {
-				groupKeys.PushBack(&BoatInfo {
-					BoatKey: s[0],
-					FriendlyName: s[1],
-				})
-			}
-		}
+// Tracks a group's boats, returning false (having tracked none of them) if that would exceed -max-tracked-boats.
+func trackBoats(sim string, boats *list.List) bool {
+	if tooManyTracked(boats) {
+		return false
 	}
-}
 
-func trackBoats(boats *list.List) {
 	for boat := boats.Front(); boat != nil; boat = boat.Next() {
-		trackBoat(boat.Value.(*BoatInfo).BoatKey)
+		trackBoat(sim, boat.Value.(*BoatInfo).BoatKey)
 	}
+	return true
 }
 
 func untrackBoats(boats *list.List) {
@@ -480,13 +675,16 @@ func untrackBoats(boats *list.List) {
 	}
 }
 
-func trackBoat(boatKey string) {
+func trackBoat(sim string, boatKey string) {
 	entry, exists := _trackedBoats[boatKey]
 	if !exists {
 		_trackedBoats[boatKey] = &TrackedBoatEntry {
 			BoatKey: boatKey,
+			Sim: sim,
 			RefCount: 1,
 		}
+
+		clusterTrack(boatKey)
 	} else {
 		entry.RefCount++
 	}
@@ -498,49 +696,126 @@ func untrackBoat(boatKey string) {
 		entry.RefCount--
 		if entry.RefCount == 0 {
 			delete(_trackedBoats, boatKey)
+
+			clusterUntrack(boatKey)
 		}
 	}
 }
 
-func createBoatGroupRespMsg(connCtx *ConnCtx, resps map[string]BoatDataLiveRespMsg) *BoatGroupRespMsg {
-	others := make(map[string][3]float64)
+func trackBoatExt(boatKey string) {
+	entry, exists := _trackedBoats[boatKey]
+	if exists {
+		entry.ExtRefCount++
+	}
+}
 
-	thisBoatData := resps[connCtx.BoatKey]
+func untrackBoatExt(boatKey string) {
+	entry, exists := _trackedBoats[boatKey]
+	if exists && entry.ExtRefCount > 0 {
+		entry.ExtRefCount--
+	}
+}
 
-	// Iterate through all the other boats in the same group to see which should be included in the response message.
-	for e := connCtx.GroupBoats.Front(); e != nil; e = e.Next() {
-		otherBoatKey := e.Value.(*BoatInfo).BoatKey
-		friendlyName := e.Value.(*BoatInfo).FriendlyName
+// Creates the live data message to be sent for a connection (or session).
+// Group indexes for resps may be shared between calls, or nil if there's only one call.
+func createRespMsg(connCtx *ConnCtx, resp BoatDataLiveRespMsg, resps map[string]BoatDataLiveRespMsg, indexes *GroupIndexes) interface{} {
+	if connCtx.GroupAll {
+		return createGroupAllRespMsg(connCtx, resps)
+	}
 
-		otherBoatData, exists := resps[otherBoatKey]
+	if connCtx.GroupBoats != nil {
+		// Create the response message for this boat plus the other boats in the same group
+		// (or just this boat, while shedding load; see shedding.go).
+		var index *GroupIndex = nil
+		if !shedGroups(connCtx) {
+			if indexes != nil {
+				index = indexes.get(connCtx.GroupBoats)
+			} else {
+				index = newGroupIndex(connCtx.GroupBoats, resps)
+			}
+		}
 
-		if !exists {
-			continue // Data for other boat is missing.
+		msg := createBoatGroupRespMsg(connCtx, resps, index)
+		msg.ThisBoat = connCtx.CogSmoother.smooth(connCtx.BoatKey, msg.ThisBoat)
+		if connCtx.Spectator {
+			msg.ThisBoat = coarsenBoatData(msg.ThisBoat)
 		}
+		msg.ThisBoat.Wpt = connCtx.Waypoint.info(&msg.ThisBoat) // See waypoint.go.
+		msg.ThisBoat = connCtx.Units.convert(msg.ThisBoat)
+		return selectFields(connCtx, msg)
+	}
+
+	resp = connCtx.CogSmoother.smooth(connCtx.BoatKey, resp)
+	if connCtx.Spectator {
+		resp = coarsenBoatData(resp)
+	} else {
+		resp = reduceBoatPrecision(resp) // See precision.go.
+	}
+	resp.Wpt = connCtx.Waypoint.info(&resp) // See waypoint.go.
+
+	if connCtx.Extended {
+		return selectFields(connCtx, createBoatDataExtRespMsg(connCtx.Units.convert(resp)))
+	}
+
+	return selectFields(connCtx, connCtx.Units.convert(resp))
+}
+
+func createBoatGroupRespMsg(connCtx *ConnCtx, resps map[string]BoatDataLiveRespMsg, index *GroupIndex) *BoatGroupRespMsg {
+	others := make(map[string][]float64)
 
-		if connCtx.BoatKey == otherBoatKey {
-			continue // Our boat, so don't include it here.
+	thisBoatData := resps[connCtx.BoatKey]
+	if index == nil {
+		// Degraded while shedding load (see shedding.go).
+		return &BoatGroupRespMsg { ThisBoat: thisBoatData, OtherBoats: others }
+	}
+
+	// Iterate through the other boats in the same group near enough to this one to see which should be included in the response message.
+	index.forNearby(thisBoatData.Lat, thisBoatData.Lon, func(entry *GroupIndexEntry) {
+		otherBoatData := entry.Data
+
+		if connCtx.BoatKey == entry.BoatKey {
+			return // Our boat, so don't include it here.
 		}
 
 		dist := roughCloseDistance(thisBoatData.Lat, thisBoatData.Lon, otherBoatData.Lat, otherBoatData.Lon)
 
-		if dist > 15.0 {
-			continue // Other boat too far away (more than 15 NM) to see live, so don't include it.
+		if dist > GROUP_VISIBILITY_DIST {
+			return // Other boat too far away to see live, so don't include it.
 		}
 
 		// "Round" the other boat's lat/lon coordinates and course, depending on distance to the other boat,
 		// in order to reasonably disguise the other boat's set course through water.
-		others[friendlyName] = [3]float64 {
-			roundCoord(otherBoatData.Lat, dist),
-			roundCoord(otherBoatData.Lon, dist),
-			roundCourse(otherBoatData.Ctw, dist),
+		// Spectators never get more precision than they get for the boat they're watching.
+		precisionDist := dist
+		if connCtx.Spectator && precisionDist < SPECTATOR_PRECISION_DIST {
+			precisionDist = SPECTATOR_PRECISION_DIST
 		}
-	}
 
-	return &BoatGroupRespMsg {
+		other := []float64 {
+			roundCoord(otherBoatData.Lat, precisionDist),
+			roundCoord(otherBoatData.Lon, precisionDist),
+			roundCourse(otherBoatData.Ctw, precisionDist),
+		}
+		if connCtx.OthersSog {
+			sog := roundSpeed(otherBoatData.Sog, precisionDist)
+			if connCtx.Units != nil {
+				sog = connCtx.Units.speed(sog)
+			}
+			other = append(other, sog)
+		}
+		others[entry.FriendlyName] = other
+	})
+
+	msg := &BoatGroupRespMsg {
 		ThisBoat: resps[connCtx.BoatKey],
 		OtherBoats: others,
 	}
+
+	if connCtx.Ais {
+		msg.Ais = createAisSentences(others, time.Now())
+	}
+
+	return msg
 }
 
 func roughCloseDistance(localLat float64, localLon float64, otherLat float64, otherLon float64) float64 {
@@ -578,35 +853,3 @@ func diffLon(a float64, b float64) float64 {
 
 	return diff
 }
-
-func roundCoord(coord float64, distance float64) float64 {
-	if distance >= 5.0 {
-		return math.Round(coord * 2000.0) / 2000.0 // To nearest ~50m (at equator)
-	} else if distance >= 2.0 {
-		return math.Round(coord * 5000.0) / 5000.0 // To nearest ~20m (at equator)
-	} else if distance >= 1.0 {
-		return math.Round(coord * 10000.0) / 10000.0 // To nearest ~10m (at equator)
-	} else if distance >= 0.5 {
-		return math.Round(coord * 20000.0) / 20000.0 // To nearest ~5m (at equator)
-	} else if distance >= 0.2 {
-		return math.Round(coord * 50000.0) / 50000.0 // To nearest ~2m (at equator)
-	} else if distance >= 0.1 {
-		return math.Round(coord * 100000.0) / 100000.0 // To nearest ~1m (at equator)
-	} else if distance >= 0.05 {
-		return math.Round(coord * 200000.0) / 200000.0 // To nearest ~0.5m (at equator)
-	} else if distance >= 0.02 {
-		return math.Round(coord * 500000.0) / 500000.0 // To nearest ~0.2m (at equator)
-	} else {
-		return math.Round(coord * 1000000.0) / 1000000.0 // To nearest ~0.1m (at equator)
-	}
-}
-
-func roundCourse(course float64, distance float64) float64 {
-	if distance >= 6.0 {
-		return math.Round(course / 22.5) * 22.5 // To nearest 22.5 deg (16 points)
-	} else if distance >= 3.0 {
-		return math.Round(course / 11.25) * 11.25 // To nearest 11.25 deg (32 points)
-	} else {
-		return math.Round(course / 5.625) * 5.625 // To nearest 5.625 deg (64 points)
-	}
-}
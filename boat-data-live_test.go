@@ -204,3 +204,104 @@ func TestCoordRounding(t *testing.T) {
 		}
 	}
 }
+
+func TestReduceBoatPrecision(t *testing.T) {
+	prevDist := _config.BdlPrecisionDist
+	defer func() { _config.BdlPrecisionDist = prevDist }()
+
+	ext := &BoatDataExt { Hdg: 93.0 }
+	data := BoatDataLiveRespMsg { Lat: 45.1234567, Lon: -30.7654321, Ctw: 92.0, Cog: 105.0, Stw: 5.55, Ext: ext }
+
+	_config.BdlPrecisionDist = 0
+	if reduceBoatPrecision(data) != data {
+		t.Errorf("Data rounded at full precision!")
+	}
+
+	_config.BdlPrecisionDist = 6.0
+	reduced := reduceBoatPrecision(data)
+	if reduced.Lat != 45.1235 || reduced.Lon != -30.7655 || reduced.Ctw != 90.0 || reduced.Cog != 112.5 || reduced.Stw != 5.55 {
+		t.Errorf("Unexpected reduced data: %+v", reduced)
+	}
+
+	if reduced.Ext.Hdg != 90.0 || ext.Hdg != 93.0 {
+		t.Errorf("Unexpected heading: %f (shared: %f)", reduced.Ext.Hdg, ext.Hdg)
+	}
+}
+
+func TestGroupRespOthersSog(t *testing.T) {
+	boats, resps := testGroup(0, 0.0, 0.0, 0.0)
+	for i, lat := range []float64 { 45.0, 45.1, 45.01 } {
+		boatKey := string(rune('a' + i)) + "0000000000000000000000000000000"
+		boats.PushBack(&BoatInfo { BoatKey: boatKey, FriendlyName: string(rune('A' + i)) })
+		resps[boatKey] = BoatDataLiveRespMsg { Lat: lat, Lon: -30.0, Ctw: 90.0, Sog: 5.3 }
+	}
+	thisBoatKey := "a0000000000000000000000000000000"
+	indexes := newGroupIndexes(resps)
+
+	connCtx := &ConnCtx { BoatKey: thisBoatKey, GroupBoats: boats }
+	msg := createBoatGroupRespMsg(connCtx, resps, indexes.get(boats))
+	if len(msg.OtherBoats["B"]) != 3 || len(msg.OtherBoats["C"]) != 3 {
+		t.Errorf("Unexpected others without SOG: %v", msg.OtherBoats)
+	}
+
+	// Rounded to the nearest 0.5 kn from 6 NM away, but not from 0.6 NM
+	connCtx.OthersSog = true
+	msg = createBoatGroupRespMsg(connCtx, resps, indexes.get(boats))
+	if len(msg.OtherBoats["B"]) != 4 || msg.OtherBoats["B"][3] != 5.5 || len(msg.OtherBoats["C"]) != 4 || msg.OtherBoats["C"][3] != 5.3 {
+		t.Errorf("Unexpected others with SOG: %v", msg.OtherBoats)
+	}
+
+	connCtx.Units = &Units { Speed: UNITS_SPEED_KMH, Heading: UNITS_HEADING_TRUE }
+	msg = createBoatGroupRespMsg(connCtx, resps, indexes.get(boats))
+	if msg.OtherBoats["C"][3] != 9.82 {
+		t.Errorf("Unexpected SOG in km/h: %v", msg.OtherBoats["C"])
+	}
+}
+
+func TestReleaseConn(t *testing.T) {
+	boatKey := "f1000000000000000000000000000000"
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	connCtx := ConnCtx { BoatKey: boatKey }
+	_conns[wc] = connCtx
+	addConnToKey(boatKey, wc)
+	trackConnCtx(&connCtx)
+
+	releaseConn(wc)
+	if _, exists := _conns[wc]; exists {
+		t.Error("Connection still subscribed after release")
+	}
+	if _hub.Count(boatKey) != 0 {
+		t.Errorf("Boat key still has %d connection(s) after release", _hub.Count(boatKey))
+	}
+	if _, exists := _trackedBoats[boatKey]; exists {
+		t.Error("Boat still tracked after release")
+	}
+
+	// Releasing again (e.g. once the main loop notices the connection closed) is harmless.
+	releaseConn(wc)
+}
+
+func TestExtendedUnavailableOnEdge(t *testing.T) {
+	prevRole := _config.ClusterRole
+	defer func() { _config.ClusterRole = prevRole }()
+	_config.ClusterRole = CLUSTER_ROLE_EDGE
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	wsReqBoatDataLive(&ReqMsg { Cmd: "bdl_x", BoatKey: "f2000000000000000000000000000000" }, wc, false, true)
+
+	expectQueued(t, wc, `{"type":"error","error":"extended_unavailable","msg":"Extended boat data unavailable"}`)
+	if wc.closeCode != CLOSE_POLICY_VIOLATION {
+		t.Errorf("Unexpected close code: %d", wc.closeCode)
+	}
+
+	// Nor is a bdl_x subscription restored from a reconnect token.
+	_lock.Lock()
+	defer _lock.Unlock()
+	if resumeFromReconnectToken(&ReconnectState { BoatKey: "f2000000000000000000000000000000", Extended: true }, testQueuedConn(QUEUE_POLICY_DISCONNECT)) {
+		t.Errorf("bdl_x subscription restored on an edge")
+	}
+}
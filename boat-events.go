@@ -0,0 +1,164 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"log"
+	"regexp"
+	"strconv"
+)
+
+
+// Boat events:
+//
+// With -events, each simulator that tracked boats are on is also asked once
+// per iteration for boat events (a boat finishing, running aground, having
+// its sails damaged, anchoring, etc.) since the last ones seen. Events for
+// tracked boats are relayed as distinct messages to connections subscribed to
+// the boat, and (with the boat's name) to those whose group includes it, so
+// that client apps can show alerts instead of inferring them from positions:
+//
+// {"type":"event","event":"<event>","time":<ms>,"detail":"<detail>","boat":"<name>"}
+//
+// The simulator is asked with a "boatevents,<since_id>" request, and responds
+// with a "boatevents,<since_id>,ok,<latest_id>" line, a line for each event
+// with an ID after <since_id> (see decodeBoatEventLine), and an empty line.
+// A <since_id> of 0 asks for just the latest ID, so that events from before
+// the connector started (or the simulator was first asked) aren't relayed.
+
+const BOAT_EVENT_FINISHED = "finished"
+const BOAT_EVENT_AGROUND = "aground"
+const BOAT_EVENT_DAMAGE = "damage"
+const BOAT_EVENT_ANCHORED = "anchored"
+
+var _boatEventRegexp = regexp.MustCompile("^[a-z_]{1,32}$")
+
+type SimBoatEvent struct {
+	Id int64
+	BoatKey string
+	Event string // One of BOAT_EVENT_*, or any other event type the simulator knows of
+	Time int64 // Unix time (seconds)
+	Detail string // e.g. the sail damaged, or "" if none
+}
+
+type BoatEventMsg struct {
+	Type string `json:"type"`
+	Event string `json:"event"`
+	Time int64 `json:"time"` // Unix time (ms)
+	Detail string `json:"detail,omitempty"`
+	Boat string `json:"boat,omitempty"` // Name of the boat, if not the subscribed one
+}
+
+// Latest event ID seen from each simulator, and simulators whose events couldn't be fetched last time
+var _eventCursors = make(map[string]int64)
+var _eventsFailing = make(map[string]bool)
+
+
+// Called (with _lock held) once per iteration to fetch and relay boat events from each simulator with tracked boats.
+func pollBoatEvents() {
+	sims := make(map[string]bool)
+	for _, entry := range _trackedBoats {
+		sims[entry.Sim] = true
+	}
+
+	for sim, _ := range sims {
+		for _, ev := range fetchBoatEvents(sim) {
+			webhookBoatEvent(sim, ev) // See webhooks.go.
+
+			if _, tracked := _trackedBoats[ev.BoatKey]; tracked {
+				relayBoatEvent(ev)
+			}
+		}
+	}
+}
+
+// Fetches a simulator's boat events since the last ones seen (none the first time it's asked).
+func fetchBoatEvents(sim string) []SimBoatEvent {
+	client := simClient(sim)
+	if client == nil {
+		return nil
+	}
+
+	since := _eventCursors[sim]
+	events, latest, ok := client.GetBoatEvents(since)
+	if !ok {
+		if !_eventsFailing[sim] {
+			log.Println("Failed to get boat events from simulator: " + sim)
+			_eventsFailing[sim] = true
+		}
+		return nil
+	}
+
+	if _eventsFailing[sim] {
+		log.Println("Getting boat events from simulator again: " + sim)
+		delete(_eventsFailing, sim)
+	}
+
+	_eventCursors[sim] = latest
+
+	if since == 0 {
+		return nil // First time asking, so just note the latest ID.
+	}
+	if latest < since {
+		log.Println("Boat event IDs from simulator went backwards (from " + strconv.FormatInt(since, 10) + " to " + strconv.FormatInt(latest, 10) + "): " + sim)
+		return nil
+	}
+
+	return events
+}
+
+// Sends a boat event to the connections subscribed to the boat, or to a group including it.
+func relayBoatEvent(ev SimBoatEvent) {
+	msg := &BoatEventMsg {
+		Type: "event",
+		Event: ev.Event,
+		Time: ev.Time * 1000,
+		Detail: ev.Detail,
+	}
+
+	for conn, connCtx := range _conns {
+		if connCtx.BoatKey == ev.BoatKey && !connCtx.GroupAll {
+			conn.SendJSON(msg) // Any failure will be picked up when next sending live data.
+			continue
+		}
+
+		name := groupBoatName(connCtx.GroupBoats, ev.BoatKey)
+		if name == "" {
+			continue
+		}
+
+		other := *msg
+		other.Boat = name
+		conn.SendJSON(&other)
+	}
+}
+
+// Returns the name of a boat in a group, or "" if it's not in the group (or there's no group).
+func groupBoatName(boats *list.List, boatKey string) string {
+	if boats == nil {
+		return ""
+	}
+
+	for e := boats.Front(); e != nil; e = e.Next() {
+		if e.Value.(*BoatInfo).BoatKey == boatKey {
+			return e.Value.(*BoatInfo).FriendlyName
+		}
+	}
+
+	return ""
+}
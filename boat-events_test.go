@@ -0,0 +1,78 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"testing"
+)
+
+
+func TestBoatEvents(t *testing.T) {
+	boatKey := testBoatKey(t, 0)
+	otherKey := testBoatKey(t, 1)
+
+	fake := &FakeSimClient {
+		events: []SimBoatEvent { { 7, boatKey, BOAT_EVENT_ANCHORED, 1767225600, "" } },
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	_simClients["fake"] = fake
+	defer delete(_simClients, "fake")
+	defer delete(_eventCursors, "fake")
+
+	// Events from before the simulator was first asked aren't relayed.
+	if events := fetchBoatEvents("fake"); len(events) != 0 || _eventCursors["fake"] != 7 {
+		t.Fatalf("Unexpected first boat events: %+v", events)
+	}
+
+	fake.events = append(fake.events, SimBoatEvent { 8, boatKey, BOAT_EVENT_FINISHED, 1767225601, "1st" })
+
+	events := fetchBoatEvents("fake")
+	if len(events) != 1 || events[0].Id != 8 || _eventCursors["fake"] != 8 {
+		t.Fatalf("Unexpected boat events: %+v", events)
+	}
+
+	group := list.New()
+	group.PushBack(&BoatInfo { otherKey, "Other" })
+	group.PushBack(&BoatInfo { boatKey, "Finisher" })
+
+	own := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	member := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	unrelated := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	_conns[own] = ConnCtx { BoatKey: boatKey }
+	_conns[member] = ConnCtx { BoatKey: otherKey, GroupBoats: group }
+	_conns[unrelated] = ConnCtx { BoatKey: otherKey }
+	defer func() {
+		delete(_conns, own)
+		delete(_conns, member)
+		delete(_conns, unrelated)
+	}()
+
+	relayBoatEvent(events[0])
+
+	expectQueued(t, own, `{"type":"event","event":"finished","time":1767225601000,"detail":"1st"}`)
+	expectQueued(t, unrelated)
+
+	var msg BoatEventMsg
+	if len(member.queue) != 1 || json.Unmarshal(member.queue[0].Data, &msg) != nil || msg.Boat != "Finisher" {
+		t.Errorf("Boat event not relayed to group member!")
+	}
+}
@@ -0,0 +1,103 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+)
+
+
+// Boat info:
+//
+// So that clients don't have to ask another API for the name to show, a bdl,
+// bdl_g or bdl_x subscription's acknowledgement includes its boat's friendly
+// name, and any other info the simulator has about it (its flag, as an ISO
+// 3166-1 alpha-2 country code, and its type):
+//
+// {"type":"subscribed",...,"boat":{"name":"...","flag":"FR","type":"..."}}
+//
+// The simulator is asked with "boatinfo,<boat_key>", and answers with
+// "boatinfo,<boat_key>,ok,<flag>,<type>,<name>" (with <flag> and <type>
+// possibly empty), or "boatinfo,<boat_key>,noboat". Answers are cached for
+// BOAT_INFO_TTL, since boats are rarely renamed. If the simulator can't be
+// asked (or doesn't support it; see sim-version.go), "boat" is left out.
+
+const BOAT_INFO_TTL = 10 * time.Minute
+
+// A boat's info, as reported by the simulator
+type SimBoatInfo struct {
+	Name string `json:"name"`
+	Flag string `json:"flag,omitempty"`
+	Type string `json:"type,omitempty"`
+}
+
+type BoatInfoCacheEntry struct {
+	Info *SimBoatInfo
+	Expires time.Time
+}
+
+var _boatFlagRegexp *regexp.Regexp = regexp.MustCompile("^[A-Z]{2}$")
+
+var _boatInfoLock sync.Mutex
+var _boatInfoCache = make(map[string]BoatInfoCacheEntry) // By boat key
+
+
+// Makes sure a boat's info is cached, asking the simulator if it isn't. Must be called without _lock held.
+func fetchBoatInfo(ctx context.Context, sim string, boatKey string) {
+	if _config.ClusterRole == CLUSTER_ROLE_EDGE {
+		return
+	}
+
+	now := time.Now()
+
+	_boatInfoLock.Lock()
+	for k, e := range _boatInfoCache {
+		if now.After(e.Expires) {
+			delete(_boatInfoCache, k)
+		}
+	}
+	_, cached := _boatInfoCache[boatKey]
+	_boatInfoLock.Unlock()
+
+	if cached {
+		return
+	}
+
+	info := simClient(sim).GetBoatInfo(ctx, boatKey)
+	if info == nil {
+		return // Not cached, so asked again with the next subscription.
+	}
+
+	_boatInfoLock.Lock()
+	_boatInfoCache[boatKey] = BoatInfoCacheEntry { info, now.Add(BOAT_INFO_TTL) }
+	_boatInfoLock.Unlock()
+}
+
+// Returns a boat's cached info, or nil if there's none.
+func cachedBoatInfo(boatKey string) *SimBoatInfo {
+	_boatInfoLock.Lock()
+	defer _boatInfoLock.Unlock()
+
+	entry, exists := _boatInfoCache[boatKey]
+	if !exists || time.Now().After(entry.Expires) {
+		return nil
+	}
+	return entry.Info
+}
@@ -0,0 +1,67 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+
+func TestBoatInfo(t *testing.T) {
+	boatKey := "b0a71f0000000000000000000000000a"
+	fake := &FakeSimClient {
+		boatInfo: map[string]*SimBoatInfo { boatKey: { Name: "Boat A", Flag: "NZ" } },
+	}
+
+	_lock.Lock()
+	_simClients["fake-info"] = fake
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		delete(_simClients, "fake-info")
+		_lock.Unlock()
+	}()
+
+	unknownKey := "b0a71f0000000000000000000000000b"
+	fetchBoatInfo(context.Background(), "fake-info", boatKey)
+	fetchBoatInfo(context.Background(), "fake-info", unknownKey)
+
+	for key, expected := range map[string]string { boatKey: "Boat A", unknownKey: "" } {
+		wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+		sendSubscribedMsg(wc, &ConnCtx { BoatKey: key })
+
+		var msg SubscribedMsg
+		if len(wc.queue) != 1 || json.Unmarshal(wc.queue[0].Data, &msg) != nil {
+			t.Fatalf("Unexpected subscription acknowledgement: %v", wc.queue)
+		}
+
+		if expected == "" && msg.Boat != nil {
+			t.Errorf("Unexpected info for unknown boat: %+v", msg.Boat)
+		} else if expected != "" && (msg.Boat == nil || msg.Boat.Name != expected || msg.Boat.Flag != "NZ") {
+			t.Errorf("Unexpected boat info: %+v", msg.Boat)
+		}
+	}
+
+	// Cached, so not asked again
+	fake.boatInfo[boatKey] = &SimBoatInfo { Name: "Renamed" }
+	fetchBoatInfo(context.Background(), "fake-info", boatKey)
+	if info := cachedBoatInfo(boatKey); info == nil || info.Name != "Boat A" {
+		t.Errorf("Boat info not cached: %+v", info)
+	}
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+
+// Group chat:
+//
+// A connection subscribed with "bdl_g" may send short "chat" messages, which
+// are relayed to every bdl_g connection whose group includes the sending boat
+// (including the sender's own), tagged with the sending boat's friendly name.
+// Other subscriptions to the group's boats (e.g. bdl) don't receive them.
+// Spectators (with bdl_g) can receive, but not send, chat messages.
+//
+// Chat is rate limited per sending boat, however many connections are
+// subscribed to it, and relayed chat messages are queued as live data are
+// (see ws-conn.go), so that they're dropped (or the connection closed,
+// according to its queue policy) for a client that isn't keeping up.
+
+const CHAT_MAX_LEN = 200 // In characters
+
+// Each boat may send a burst of up to CHAT_BURST messages, refilled at one per CHAT_INTERVAL.
+const CHAT_BURST = 5
+const CHAT_INTERVAL = 3 * time.Second
+
+const ERR_CHAT_NOT_ALLOWED = "chat_not_allowed"
+const ERR_RATE_LIMITED = "rate_limited"
+
+type ChatMsg struct {
+	Type string `json:"type"`
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+// Token bucket rate limiter (also used for boat actions; see boat-actions.go)
+type RateLimiter struct {
+	Tokens float64
+	Last time.Time
+}
+
+// Per-boat chat rate limiters, by boat key (guarded by _lock)
+var _chatLimiters = make(map[string]*RateLimiter)
+
+
+func wsReqChat(req *ReqMsg, conn *WsConn) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	connCtx, exists := _conns[conn]
+	if !exists || connCtx.GroupBoats == nil || connCtx.GroupPending || connCtx.GroupAll || connCtx.Spectator {
+		sendErrorMsg(conn, ERR_CHAT_NOT_ALLOWED, "Chat requires a (non-spectator) group subscription")
+		return
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" || !utf8.ValidString(text) || utf8.RuneCountInString(text) > CHAT_MAX_LEN {
+		sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, "Chat message empty or too long", CHAT_MAX_LEN)
+		return
+	}
+
+	if !chatAllowed(connCtx.BoatKey) {
+		sendLimitErrorMsg(conn, ERR_RATE_LIMITED, "Chat rate limit exceeded", CHAT_BURST)
+		return
+	}
+
+	from, _ := groupMemberName(connCtx.GroupBoats, connCtx.BoatKey)
+
+	msg := &ChatMsg {
+		Type: "chat",
+		From: from,
+		Text: text,
+	}
+
+	for e := connCtx.GroupBoats.Front(); e != nil; e = e.Next() {
+		for _, c := range _hub.Subscribers(e.Value.(*BoatInfo).BoatKey) {
+			if chatRecipient(_conns[c.(*WsConn)], connCtx.BoatKey) {
+				c.(*WsConn).SendChat(msg) // Any failure will be picked up when next sending live data.
+			}
+		}
+	}
+
+	log.Println("Relayed chat message from: " + from + " (" + conn.RemoteIp + ")")
+}
+
+// Returns whether a subscription should receive chat from the given boat, i.e. it's a bdl_g
+// subscription whose group includes the boat.
+func chatRecipient(connCtx ConnCtx, from string) bool {
+	if connCtx.GroupBoats == nil || connCtx.GroupAll {
+		return false
+	}
+
+	_, exists := groupMemberName(connCtx.GroupBoats, from)
+	return exists
+}
+
+// Returns the friendly name of a boat in a group, and whether it's in the group at all.
+func groupMemberName(boats *list.List, boatKey string) (string, bool) {
+	for e := boats.Front(); e != nil; e = e.Next() {
+		boat := e.Value.(*BoatInfo)
+		if boat.BoatKey == boatKey {
+			return boat.FriendlyName, true
+		}
+	}
+
+	return "", false
+}
+
+// Checks (and updates) a boat's chat rate limit. Caller must hold _lock.
+func chatAllowed(boatKey string) bool {
+	now := time.Now()
+
+	// Limiters idle for long enough to have refilled are no longer needed.
+	for k, l := range _chatLimiters {
+		if now.Sub(l.Last) >= CHAT_BURST * CHAT_INTERVAL {
+			delete(_chatLimiters, k)
+		}
+	}
+
+	limiter, exists := _chatLimiters[boatKey]
+	if !exists {
+		limiter = &RateLimiter { CHAT_BURST, now }
+		_chatLimiters[boatKey] = limiter
+	}
+
+	return limiter.allow(now, CHAT_BURST, CHAT_INTERVAL)
+}
+
+// Refills the bucket (at one token per interval, up to burst), and takes a token if there is one.
+func (limiter *RateLimiter) allow(now time.Time, burst float64, interval time.Duration) bool {
+	limiter.Tokens += float64(now.Sub(limiter.Last)) / float64(interval)
+	if limiter.Tokens > burst {
+		limiter.Tokens = burst
+	}
+	limiter.Last = now
+
+	if limiter.Tokens < 1.0 {
+		return false
+	}
+
+	limiter.Tokens -= 1.0
+	return true
+}
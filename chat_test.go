@@ -0,0 +1,115 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"strings"
+	"testing"
+)
+
+
+func TestChatRateLimitPerBoat(t *testing.T) {
+	boatKey := "f6000000000000000000000000000000"
+	group := list.New()
+	group.PushBack(&BoatInfo { boatKey, "Me" })
+
+	wc1 := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	wc2 := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+
+	_lock.Lock()
+	_conns[wc1] = ConnCtx { BoatKey: boatKey, GroupBoats: group }
+	_conns[wc2] = ConnCtx { BoatKey: boatKey, GroupBoats: group }
+	_hub.Subscribe(boatKey, wc1)
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		_hub.Unsubscribe(boatKey, wc1)
+		delete(_conns, wc1)
+		delete(_conns, wc2)
+		delete(_chatLimiters, boatKey)
+		_lock.Unlock()
+	}()
+
+	for i := 0; i < CHAT_BURST; i++ {
+		wsReqChat(&ReqMsg { Text: "hi" }, wc1)
+	}
+	if len(wc1.queue) != CHAT_BURST || !wc1.queue[0].Chat {
+		t.Fatalf("Expected %d relayed chat messages, but got %d", CHAT_BURST, len(wc1.queue))
+	}
+
+	// Another connection to the same boat shares its budget.
+	wsReqChat(&ReqMsg { Text: "hi" }, wc2)
+	if len(wc2.queue) != 1 || !strings.Contains(string(wc2.queue[0].Data), ERR_RATE_LIMITED) {
+		t.Errorf("Chat from another connection to the same boat not rate limited!")
+	}
+}
+
+func TestChatRecipients(t *testing.T) {
+	sender := "f7000000000000000000000000000000"
+	member := "f7000000000000000000000000000001"
+	outsider := "f7000000000000000000000000000002"
+
+	group := list.New()
+	group.PushBack(&BoatInfo { sender, "Sender" })
+	group.PushBack(&BoatInfo { member, "Member" })
+
+	// The member's view of its group may differ (e.g. a different race), without the sender.
+	otherGroup := list.New()
+	otherGroup.PushBack(&BoatInfo { member, "Member" })
+	otherGroup.PushBack(&BoatInfo { outsider, "Outsider" })
+
+	senderConn := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	memberConn := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	spectatorConn := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	bdlConn := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	otherGroupConn := testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+
+	subs := map[*WsConn]ConnCtx {
+		senderConn: ConnCtx { BoatKey: sender, GroupBoats: group },
+		memberConn: ConnCtx { BoatKey: member, GroupBoats: group },
+		spectatorConn: ConnCtx { BoatKey: member, GroupBoats: group, Spectator: true },
+		bdlConn: ConnCtx { BoatKey: member },
+		otherGroupConn: ConnCtx { BoatKey: member, GroupBoats: otherGroup },
+	}
+
+	_lock.Lock()
+	for wc, connCtx := range subs {
+		_conns[wc] = connCtx
+		_hub.Subscribe(connCtx.BoatKey, wc)
+	}
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		for wc, connCtx := range subs {
+			_hub.Unsubscribe(connCtx.BoatKey, wc)
+			delete(_conns, wc)
+		}
+		delete(_chatLimiters, sender)
+		_lock.Unlock()
+	}()
+
+	wsReqChat(&ReqMsg { Text: "hi" }, senderConn)
+
+	for _, wc := range []*WsConn { senderConn, memberConn, spectatorConn } {
+		expectQueued(t, wc, `{"type":"chat","from":"Sender","text":"hi"}`)
+	}
+
+	// Subscriptions to group members that aren't bdl_g in the sender's group don't get chat.
+	expectQueued(t, bdlConn)
+	expectQueued(t, otherGroupConn)
+}
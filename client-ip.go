@@ -0,0 +1,115 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+
+// Client IP addresses:
+//
+// Behind a reverse proxy, every connection comes from the proxy. For
+// connections from a trusted proxy (see -trusted-proxies), the client's IP
+// address is instead taken from the X-Forwarded-For header, as the rightmost
+// address in it that isn't itself a trusted proxy.
+
+
+// Parses a comma-separated list of trusted proxy addresses or CIDR ranges.
+func parseTrustedProxies(s string) ([]*net.IPNet, error) {
+	return parseIpRanges(s, "trusted proxy")
+}
+
+// Parses a comma-separated list of addresses or CIDR ranges, described as "what" in any error.
+func parseIpRanges(s string, what string) ([]*net.IPNet, error) {
+	ranges := make([]*net.IPNet, 0)
+	if s == "" {
+		return ranges, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, errors.New("Invalid " + what + " address: " + item)
+			}
+
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, errors.New("Invalid " + what + " range: " + item)
+		}
+
+		ranges = append(ranges, ipNet)
+	}
+
+	return ranges, nil
+}
+
+func ipInRanges(ip net.IP, ranges []*net.IPNet) bool {
+	for _, ipNet := range ranges {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	return ipInRanges(ip, _config.TrustedProxies)
+}
+
+// Gets the IP address of the client making a request.
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isTrustedProxy(ip) {
+		return host
+	}
+
+	// Walk back through the forwarding chain, for as long as it's through trusted proxies.
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+
+		forwardedIp := net.ParseIP(addr)
+		if forwardedIp == nil {
+			break // Can't trust anything further back.
+		}
+
+		host = addr
+		if !isTrustedProxy(forwardedIp) {
+			break
+		}
+	}
+
+	return host
+}
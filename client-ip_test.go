@@ -0,0 +1,72 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+
+func TestClientIp(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.1,192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevProxies := _config.TrustedProxies
+	_config.TrustedProxies = proxies
+	defer func() { _config.TrustedProxies = prevProxies }()
+
+	clientIpChecks(t, "203.0.113.5:1234", "", "203.0.113.5")
+
+	// Not from a trusted proxy, so the header is ignored.
+	clientIpChecks(t, "203.0.113.5:1234", "198.51.100.7", "203.0.113.5")
+
+	// From a trusted proxy
+	clientIpChecks(t, "10.0.0.1:1234", "198.51.100.7", "198.51.100.7")
+	clientIpChecks(t, "10.0.0.1:1234", "", "10.0.0.1")
+
+	// Spoofed addresses to the left of the first untrusted one are ignored.
+	clientIpChecks(t, "10.0.0.1:1234", "1.2.3.4, 198.51.100.7", "198.51.100.7")
+
+	// Chain of trusted proxies
+	clientIpChecks(t, "10.0.0.1:1234", "198.51.100.7, 192.168.1.1", "198.51.100.7")
+
+	// Garbage stops the walk back.
+	clientIpChecks(t, "10.0.0.1:1234", "198.51.100.7, garbage, 192.168.1.1", "192.168.1.1")
+
+	_, err = parseTrustedProxies("10.0.0.1,nonsense")
+	if err == nil {
+		t.Error("Expected error for invalid trusted proxy")
+	}
+}
+
+func clientIpChecks(t *testing.T, remoteAddr string, forwardedFor string, expected string) {
+	r := &http.Request {
+		RemoteAddr: remoteAddr,
+		Header: make(http.Header),
+	}
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	ip := clientIp(r)
+	if ip != expected {
+		t.Errorf("clientIp(%s, %q) = %s, expected %s", remoteAddr, forwardedFor, ip, expected)
+	}
+}
@@ -0,0 +1,362 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+// Client library:
+//
+// Package client implements the connector's WebSocket protocol, for Go tools
+// (bots, recorders, race dashboards, etc.) that consume live data from it:
+//
+//	c, err := client.Dial("wss://example.com/v1/ws", nil)
+//	...
+//	err = c.SubscribeBoat(boatKey, &client.SubscribeOptions { Group: true })
+//	...
+//	for u := range c.Updates() {
+//		switch msg := u.(type) {
+//		case *client.GroupMsg:
+//			...
+//		}
+//	}
+//	// c.Err() now tells why the connection was closed (e.g. a
+//	// *websocket.CloseError with one of the CLOSE_* codes).
+//
+// Messages are decoded on a goroutine of the client's own, and sent on its
+// updates channel, which is closed once the connection is. If the updates
+// aren't consumed, the connector eventually applies its queue policy to the
+// connection, as with any other slow client.
+
+// Number of decoded messages buffered for the consumer
+const UPDATES_BUFFER_SIZE = 64
+
+const WRITE_TIMEOUT = 10 * time.Second
+
+type Client struct {
+	conn *websocket.Conn
+	writeLock sync.Mutex
+	updates chan Update
+	done chan int // Closed once the connection has been closed
+	stop chan int // Closed by Close()
+	stopOnce sync.Once
+	err error // Why the connection was closed (set before done is closed)
+	lastMseq uint64 // Message sequence number last received (only used by the reader)
+}
+
+type SubscribeOptions struct {
+	Group bool // Include nearby boats in the group (bdl_g)
+	Extended bool // Include extended boat data (bdl_x; not with Group)
+	Ais bool // Include other boats as AIS sentences (with Group)
+	OthersSog bool // Include other boats' SOG (with Group)
+	Session bool // Start a resumable session
+	Sim string // Named simulator ("" for the endpoint's)
+	Fields []string // Fields to send (all if empty)
+	SmoothCog int // Iterations to smooth COG over (0 for none)
+	Units *Units // Units to send data in (nil for the defaults)
+	Hf bool // Ask for high-frequency mode (with Group)
+	Interp bool // Ask for interpolated data between iterations
+	Batch bool // Receive each tick's messages batched into one frame
+	MsgSeq bool // Number messages, with gaps passed on as *GapMsg (see Keyframe)
+	Priority string // Priority class: "low", "normal" or "high" ("" for the default; raising it needs Admin)
+	Admin string // Admin token, to raise Priority above the default
+	Geofences []Geofence // Geofences to be alerted about entering or exiting, as *AlertMsg
+	Waypoint *Waypoint // Waypoint to be sent the bearing, distance, VMG and ETA to (in BoatDataMsg.Wpt)
+}
+
+// A request message (only the non-empty fields are sent)
+type Request struct {
+	Cmd string `json:"cmd"`
+	BoatKey string `json:"key,omitempty"`
+	SpectatorId string `json:"spec,omitempty"`
+	Group string `json:"group,omitempty"`
+	Token string `json:"token,omitempty"`
+	Admin string `json:"admin,omitempty"`
+	Session bool `json:"session,omitempty"`
+	Ais bool `json:"ais,omitempty"`
+	OthersSog bool `json:"others_sog,omitempty"`
+	Sim string `json:"sim,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	SmoothCog int `json:"smooth_cog,omitempty"`
+	Units *Units `json:"units,omitempty"`
+	Hf bool `json:"hf,omitempty"`
+	Interp bool `json:"interp,omitempty"`
+	Text string `json:"text,omitempty"`
+	Size int `json:"size,omitempty"`
+	Step float64 `json:"step,omitempty"`
+	Bbox []float64 `json:"bbox,omitempty"`
+	Seq uint64 `json:"seq,omitempty"`
+	Batch bool `json:"batch,omitempty"`
+	MsgSeq bool `json:"msg_seq,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	Geofences []Geofence `json:"geofences,omitempty"`
+	Waypoint *Waypoint `json:"waypoint,omitempty"`
+}
+
+
+// Connects to the connector's WebSocket endpoint (e.g. "ws://localhost:8080/v1/ws"),
+// with the given extra HTTP headers (e.g. "Authorization"; may be nil).
+func Dial(url string, header http.Header) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client {
+		conn: conn,
+		updates: make(chan Update, UPDATES_BUFFER_SIZE),
+		done: make(chan int),
+		stop: make(chan int),
+	}
+
+	go c.reader()
+
+	return c, nil
+}
+
+// Subscribes to live data for a boat, by its boat key (bdl, bdl_g or bdl_x, depending on opts, which may be nil).
+func (c *Client) SubscribeBoat(boatKey string, opts *SubscribeOptions) error {
+	return c.subscribe(&Request { BoatKey: boatKey }, opts)
+}
+
+// Subscribes to view-only live data for a boat, by its public spectator ID.
+func (c *Client) SubscribeSpectator(spectatorId string, opts *SubscribeOptions) error {
+	return c.subscribe(&Request { SpectatorId: spectatorId }, opts)
+}
+
+// Subscribes to live data for every boat in a group (gdl), by group ID, with the group's token.
+// Only Session, Sim, SmoothCog, Units, MsgSeq, Priority and Admin of opts (which may be nil) apply.
+func (c *Client) SubscribeGroup(groupId string, token string, opts *SubscribeOptions) error {
+	req := &Request {
+		Cmd: "gdl",
+		Group: groupId,
+		Token: token,
+	}
+	if opts != nil {
+		req.Session = opts.Session
+		req.Sim = opts.Sim
+		req.SmoothCog = opts.SmoothCog
+		req.Units = opts.Units
+		req.MsgSeq = opts.MsgSeq
+		req.Priority = opts.Priority
+		req.Admin = opts.Admin
+	}
+
+	return c.Send(req)
+}
+
+// Starts (or, if already started, moves) a stream of map updates for the public boats in an area,
+// with the map token. sim is the named simulator ("" for the endpoint's).
+func (c *Client) WatchArea(latMin float64, lonMin float64, latMax float64, lonMax float64, token string, sim string) error {
+	return c.Send(&Request {
+		Cmd: "map",
+		Token: token,
+		Sim: sim,
+		Bbox: []float64 { latMin, lonMin, latMax, lonMax },
+	})
+}
+
+// Resumes a subscription on a new connection, with a session token (from a *SessionMsg) and the last
+// sequence number received, or with a reconnect token (from a *ReconnectMsg), with seq 0.
+func (c *Client) Resume(token string, seq uint64) error {
+	return c.Send(&Request {
+		Cmd: "resume",
+		Token: token,
+		Seq: seq,
+	})
+}
+
+// Asks for the most recent live data again, e.g. after a *GapMsg (with MsgSeq).
+func (c *Client) Keyframe() error {
+	return c.Send(&Request { Cmd: "keyframe" })
+}
+
+// Asks for the next iteration's live data to be sent in full (all fields, and the whole group).
+func (c *Client) Resync() error {
+	return c.Send(&Request { Cmd: "resync" })
+}
+
+// Replaces the subscription's geofences (none, to remove them).
+func (c *Client) SetGeofences(fences []Geofence) error {
+	if fences == nil {
+		fences = []Geofence {}
+	}
+	return c.Send(&struct {
+		Cmd string `json:"cmd"`
+		Geofences []Geofence `json:"geofences"`
+	} { "geofences", fences })
+}
+
+// Controls the subscribed boat, e.g. Action("c1", "course", 245.0), answered with an *ActionResultMsg with the same id.
+func (c *Client) Action(id string, action string, value interface{}) error {
+	return c.Send(&struct {
+		Cmd string `json:"cmd"`
+		Id string `json:"id,omitempty"`
+		Action string `json:"action"`
+		Value interface{} `json:"value"`
+	} { "action", id, action, value })
+}
+
+// Sets, moves or (with nil) clears the subscription's waypoint.
+func (c *Client) SetWaypoint(waypoint *Waypoint) error {
+	return c.Send(&struct {
+		Cmd string `json:"cmd"`
+		Waypoint *Waypoint `json:"waypoint"`
+	} { "waypoint", waypoint })
+}
+
+func (c *Client) subscribe(req *Request, opts *SubscribeOptions) error {
+	req.Cmd = "bdl"
+	if opts != nil {
+		if opts.Group {
+			req.Cmd = "bdl_g"
+		} else if opts.Extended {
+			req.Cmd = "bdl_x"
+		}
+
+		req.Ais = opts.Ais
+		req.OthersSog = opts.OthersSog
+		req.Session = opts.Session
+		req.Sim = opts.Sim
+		req.Fields = opts.Fields
+		req.SmoothCog = opts.SmoothCog
+		req.Units = opts.Units
+		req.Hf = opts.Hf
+		req.Interp = opts.Interp
+		req.Batch = opts.Batch
+		req.MsgSeq = opts.MsgSeq
+		req.Priority = opts.Priority
+		req.Admin = opts.Admin
+		req.Geofences = opts.Geofences
+		req.Waypoint = opts.Waypoint
+	}
+
+	return c.Send(req)
+}
+
+// Sends a request (e.g. a *Request, or any other value encoding to a request message).
+func (c *Client) Send(req interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+	return c.conn.WriteJSON(req)
+}
+
+// Returns the channel of messages received, which is closed once the connection is closed.
+func (c *Client) Updates() <-chan Update {
+	return c.updates
+}
+
+// Returns why the connection was closed, once the updates channel has been closed
+// (a *websocket.CloseError if the connector closed it with a close code).
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Closes the connection (normally), after which the updates channel is closed.
+func (c *Client) Close() error {
+	c.stopOnce.Do(func () { close(c.stop) })
+
+	c.writeLock.Lock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(WRITE_TIMEOUT))
+	c.writeLock.Unlock()
+
+	return c.conn.Close()
+}
+
+func (c *Client) reader() {
+	defer c.conn.Close()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.finish(err)
+			return
+		}
+
+		// Batched frames are arrays of messages.
+		msgs := []json.RawMessage { data }
+		if len(data) > 0 && data[0] == '[' {
+			if err := json.Unmarshal(data, &msgs); err != nil {
+				c.finish(errors.New("Invalid message from connector: " + err.Error()))
+				return
+			}
+		}
+
+		for _, m := range msgs {
+			u, err := decodeUpdate(m)
+			if err != nil {
+				c.finish(errors.New("Invalid message from connector: " + err.Error()))
+				return
+			}
+
+			if gap := c.checkMseq(m); gap != nil && !c.deliver(gap) {
+				return
+			}
+
+			if !c.deliver(u) {
+				return
+			}
+		}
+	}
+}
+
+// Sends an update to the consumer. Returns false (having finished) if the client's been closed meanwhile.
+func (c *Client) deliver(u Update) bool {
+	select {
+	case c.updates <- u:
+		return true
+	case <-c.stop:
+		c.finish(errors.New("Client closed"))
+		return false
+	}
+}
+
+// Returns a *GapMsg if messages were missed before a numbered message, or nil.
+func (c *Client) checkMseq(data []byte) *GapMsg {
+	mseq := decodeMseq(data)
+	if mseq == 0 {
+		return nil
+	}
+
+	last := c.lastMseq
+	c.lastMseq = mseq
+	if last == 0 || mseq <= last + 1 {
+		return nil
+	}
+
+	return &GapMsg { Missed: mseq - last - 1 }
+}
+
+func (c *Client) finish(err error) {
+	c.err = err
+	close(c.done)
+	close(c.updates)
+}
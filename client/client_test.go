@@ -0,0 +1,153 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+func TestDecodeUpdate(t *testing.T) {
+	u, err := decodeUpdate([]byte(`{"lat":45.5,"lon":-30.25,"cog":90,"seq":7}`))
+	if msg, ok := u.(*BoatDataMsg); err != nil || !ok || msg.Lat != 45.5 || msg.Cog != 90.0 || msg.Seq != 7 || msg.BoatDataExt != nil {
+		t.Errorf("Unexpected boat data: %+v (%v)", u, err)
+	}
+
+	u, err = decodeUpdate([]byte(`{"lat":45.5,"hdg":88,"sail":"jib"}`))
+	if msg, ok := u.(*BoatDataMsg); err != nil || !ok || msg.BoatDataExt == nil || msg.Hdg != 88.0 || msg.Sail != "jib" {
+		t.Errorf("Unexpected extended boat data: %+v (%v)", u, err)
+	}
+
+	u, err = decodeUpdate([]byte(`{"you":{"lat":1},"others":{"B":[2,3,180]}}`))
+	if msg, ok := u.(*GroupMsg); err != nil || !ok || msg.You.Lat != 1.0 || msg.Others["B"][2] != 180.0 {
+		t.Errorf("Unexpected group data: %+v (%v)", u, err)
+	}
+
+	u, err = decodeUpdate([]byte(`{"boats":{"A":{"lat":1},"B":{"lat":2}}}`))
+	if msg, ok := u.(*GroupAllMsg); err != nil || !ok || len(msg.Boats) != 2 || msg.Boats["B"].Lat != 2.0 {
+		t.Errorf("Unexpected whole-group data: %+v (%v)", u, err)
+	}
+
+	u, err = decodeUpdate([]byte(`{"type":"error","error":"draining","msg":"...","retry_after":30}`))
+	if msg, ok := u.(*ErrorMsg); err != nil || !ok || msg.Code != "draining" || msg.RetryAfter != 30 {
+		t.Errorf("Unexpected error message: %+v (%v)", u, err)
+	}
+
+	u, err = decodeUpdate([]byte(`{"type":"history","samples":[{"lat":1},{"lat":2}]}`))
+	if msg, ok := u.(*HistoryMsg); err != nil || !ok || len(msg.Samples) != 2 || msg.Samples[1].Lat != 2.0 {
+		t.Errorf("Unexpected history message: %+v (%v)", u, err)
+	}
+
+	u, err = decodeUpdate([]byte(`{"type":"something_new","x":1}`))
+	if msg, ok := u.(*UnknownMsg); err != nil || !ok || msg.Type != "something_new" {
+		t.Errorf("Unexpected unknown message: %+v (%v)", u, err)
+	}
+
+	_, err = decodeUpdate([]byte(`not json`))
+	if err == nil {
+		t.Errorf("Invalid message decoded!")
+	}
+}
+
+func TestCheckMseq(t *testing.T) {
+	c := &Client {}
+
+	for i, expected := range []uint64 { 0, 0, 0, 2, 0 } {
+		data := []string { `{"type":"subscribed"}`, `{"lat":1,"mseq":1}`, `{"lat":2,"mseq":2}`, `{"lat":3,"mseq":5}`, `{"type":"pong","mseq":6}` }[i]
+		gap := c.checkMseq([]byte(data))
+		if (gap == nil) != (expected == 0) || (gap != nil && gap.Missed != expected) {
+			t.Errorf("Unexpected gap before %s: %+v", data, gap)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	reqs := make(chan map[string]interface{}, 1)
+
+	upgrader := websocket.Upgrader {}
+	srv := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req map[string]interface{}
+		if conn.ReadJSON(&req) != nil {
+			return
+		}
+		reqs <- req
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribed","version":1,"interval":1,"radius":2,"group":3}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"you":{"lat":45,"sog":11.11},"others":{}}`))
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(CLOSE_BOAT_DELETED, "No such boat"), time.Now().Add(time.Second))
+	}))
+	defer srv.Close()
+
+	c, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.SubscribeBoat("0123456789abcdef0123456789abcdef", &SubscribeOptions { Group: true, Units: &Units { Speed: "kmh" } })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-reqs
+	units, _ := json.Marshal(req["units"])
+	if req["cmd"] != "bdl_g" || req["key"] != "0123456789abcdef0123456789abcdef" || string(units) != `{"speed":"kmh"}` || req["fields"] != nil {
+		t.Errorf("Unexpected request: %v", req)
+	}
+
+	var updates []Update
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case u, ok := <-c.Updates():
+			if !ok {
+				done = true
+				break
+			}
+			updates = append(updates, u)
+		case <-timeout:
+			t.Fatal("Timed out waiting for updates")
+		}
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("Unexpected updates: %+v", updates)
+	}
+	if ack, ok := updates[0].(*SubscribedMsg); !ok || ack.Group != 3 {
+		t.Errorf("Unexpected acknowledgement: %+v", updates[0])
+	}
+	if msg, ok := updates[1].(*GroupMsg); !ok || msg.You.Sog != 11.11 {
+		t.Errorf("Unexpected group data: %+v", updates[1])
+	}
+
+	closeErr, ok := c.Err().(*websocket.CloseError)
+	if !ok || closeErr.Code != CLOSE_BOAT_DELETED {
+		t.Errorf("Unexpected close error: %v", c.Err())
+	}
+}
@@ -0,0 +1,388 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+)
+
+
+// Messages received from the connector, as sent on a client's Updates()
+// channel. Live data messages (which, unlike the others, have no "type") are
+// decoded as *BoatDataMsg (bdl and bdl_x), *GroupMsg (bdl_g) or *GroupAllMsg
+// (group_all and gdl). Fields left out by field selection are left zero.
+// Messages of unknown types are passed on as *UnknownMsg.
+
+// Close codes sent by the connector (see the README's "Close codes")
+const CLOSE_SERVER_SHUTDOWN = websocket.CloseGoingAway
+const CLOSE_POLICY_VIOLATION = websocket.ClosePolicyViolation
+const CLOSE_INTERNAL_ERROR = websocket.CloseInternalServerErr
+const CLOSE_INVALID_KEY = 4001
+const CLOSE_DUPLICATE_SUBSCRIBE = 4002
+const CLOSE_BACKEND_UNREACHABLE = 4003
+const CLOSE_BOAT_DELETED = 4004
+
+type Update interface {
+	isUpdate()
+}
+
+type BoatDataExt struct {
+	Hdg float64 `json:"hdg"`
+	Heel float64 `json:"heel"`
+	Heave float64 `json:"heave"`
+	Leeway float64 `json:"leeway"`
+	Rudder float64 `json:"rudder"`
+	CurSet float64 `json:"cur_set"`
+	CurDrift float64 `json:"cur_drift"`
+	Sail string `json:"sail"`
+}
+
+type BoatDataMsg struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Ctw float64 `json:"ctw"`
+	Stw float64 `json:"stw"`
+	Cog float64 `json:"cog"`
+	Sog float64 `json:"sog"`
+	Lws float64 `json:"lws"`
+	Ha float64 `json:"ha"`
+	*BoatDataExt // Only for bdl_x (nil otherwise)
+
+	Ts int64 `json:"ts"` // Arrival time (Unix time in ms), if sent
+	Age int64 `json:"age"` // Seconds since arrival, for last known data only
+	Interp bool `json:"interp"` // Whether the position is interpolated rather than from the simulator
+	Wpt *WaypointInfo `json:"wpt"` // Only with a waypoint (see SubscribeOptions.Waypoint)
+	Seq uint64 `json:"seq"` // For sessions only
+}
+
+type Waypoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type WaypointInfo struct {
+	Brg float64 `json:"brg"` // Initial great-circle bearing (degrees true)
+	Dist float64 `json:"dist"` // Great-circle distance (nm)
+	Vmg float64 `json:"vmg"` // Velocity made good toward the waypoint (in the subscription's speed unit)
+	Eta int64 `json:"eta"` // Seconds to reach the waypoint at VMG (0 if not making way toward it)
+}
+
+type GroupMsg struct {
+	You BoatDataMsg `json:"you"`
+	Others map[string][]float64 `json:"others"` // [lat, lon, ctw] (or [lat, lon, ctw, sog], with OthersSog), by friendly name
+	Ais []string `json:"ais"`
+	Seq uint64 `json:"seq"`
+}
+
+type GroupAllMsg struct {
+	Boats map[string]BoatDataMsg `json:"boats"` // By friendly name
+	Seq uint64 `json:"seq"`
+}
+
+type Units struct {
+	Speed string `json:"speed,omitempty"`
+	Heading string `json:"heading,omitempty"`
+}
+
+type SubscribedMsg struct {
+	Version int `json:"version"`
+	Interval int64 `json:"interval"`
+	IntervalMs int64 `json:"interval_ms"`
+	Radius float64 `json:"radius"`
+	Group int `json:"group"`
+	GroupPending bool `json:"group_pending"` // For bdl_g, until a GroupReadyMsg gives the group
+	Fields []string `json:"fields"`
+	SmoothCog int `json:"smooth_cog"`
+	Units *Units `json:"units"`
+	Hf int `json:"hf"` // Messages per poll interval in high-frequency mode (0 if not granted)
+	HfDist float64 `json:"hf_dist"`
+	Interp int `json:"interp"` // Messages per poll interval with interpolated data (0 if not granted)
+	Priority string `json:"priority"` // Priority class, for load shedding
+	Boat *BoatInfo `json:"boat"` // The boat's name, etc. (nil if unknown)
+}
+
+type BoatInfo struct {
+	Name string `json:"name"`
+	Flag string `json:"flag"` // ISO 3166-1 alpha-2 country code, if any
+	Type string `json:"type"`
+}
+
+type GroupReadyMsg struct {
+	Group int `json:"group"`
+}
+
+type HistoryMsg struct {
+	Samples []BoatDataMsg `json:"samples"`
+}
+
+type SessionMsg struct {
+	Token string `json:"token"`
+	Grace int64 `json:"grace"` // Seconds
+}
+
+type ReconnectMsg struct {
+	Token string `json:"token"`
+	Expires int64 `json:"expires"` // Unix time (seconds)
+}
+
+type StatusMsg struct {
+	Stale bool `json:"stale"`
+	Missed int `json:"missed"`
+}
+
+type EventMsg struct {
+	Event string `json:"event"` // e.g. "finished", "aground", "damage" or "anchored"
+	Time int64 `json:"time"` // Unix time in ms
+	Detail string `json:"detail"`
+	Boat string `json:"boat"` // Name of the boat, if not the subscribed one
+}
+
+type HfMsg struct {
+	Active bool `json:"active"`
+}
+
+type TimeMsg struct {
+	Poll int64 `json:"poll"` // Unix time in ms, by the server's clock (not the simulator's)
+	Utc int64 `json:"utc"` // Unix time in ms
+	Iter int64 `json:"iter"`
+}
+
+type VersionMsg struct {
+	Version string `json:"version"`
+	Commit string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	Protocol int `json:"protocol"`
+	Features []string `json:"features"` // Optional protocol features enabled on the server
+}
+
+type StatsMsg struct {
+	Dropped uint64 `json:"dropped"`
+	Coalesced uint64 `json:"coalesced"`
+	Conflated uint64 `json:"conflated"`
+	Queued int `json:"queued"`
+	Rtt float64 `json:"rtt"` // Milliseconds (0 if not yet measured)
+}
+
+type ChatMsg struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+type PongMsg struct {
+	Payload json.RawMessage `json:"payload"`
+	Time int64 `json:"time"` // Unix time in ms
+}
+
+type WindAreaMsg struct {
+	Lat0 float64 `json:"lat0"`
+	Lon0 float64 `json:"lon0"`
+	Step float64 `json:"step"`
+	Size int `json:"size"`
+	Wind [][2]float64 `json:"wind"` // [dir, speed]
+}
+
+type MapBoat struct {
+	SpectatorId string `json:"spec"`
+	Name string `json:"name"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Cog float64 `json:"cog"`
+	Sog float64 `json:"sog"`
+}
+
+type MapMsg struct {
+	Bbox [4]float64 `json:"bbox"` // [lat_min, lon_min, lat_max, lon_max], expanded to whole degrees
+	Boats []MapBoat `json:"boats"`
+	Truncated bool `json:"truncated"`
+}
+
+type ReauthMsg struct {
+	Msg string `json:"msg"`
+}
+
+type ResubscribeMsg struct {
+	Msg string `json:"msg"`
+}
+
+type ReplayEndMsg struct {
+}
+
+type ActionResultMsg struct {
+	Id string `json:"id"` // As sent with Action()
+	Action string `json:"action"`
+	Ok bool `json:"ok"`
+	Error string `json:"error"` // If not ok, e.g. "action_rejected"
+	Msg string `json:"msg"`
+	Limit int `json:"limit"`
+}
+
+type AlertMsg struct {
+	Alert string `json:"alert"` // "geofence"
+	Id string `json:"id"`
+	Event string `json:"event"` // "enter", "exit" or "inside"
+}
+
+// A geofence: either a circle or a polygon
+type Geofence struct {
+	Id string `json:"id"`
+	Circle *GeofenceCircle `json:"circle,omitempty"`
+	Polygon [][]float64 `json:"polygon,omitempty"` // [lat, lon] vertices
+}
+
+type GeofenceCircle struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Radius float64 `json:"radius"` // nm
+}
+
+type ErrorMsg struct {
+	Code string `json:"error"`
+	Msg string `json:"msg"`
+	Limit int `json:"limit"`
+	RetryAfter int `json:"retry_after"` // Seconds
+}
+
+// Not sent by the connector, but passed on (with MsgSeq) before a message
+// following a gap in message sequence numbers.
+type GapMsg struct {
+	Missed uint64 // Messages missed (dropped, or skipped by the connector)
+}
+
+type UnknownMsg struct {
+	Type string
+	Data json.RawMessage
+}
+
+func (*BoatDataMsg) isUpdate() {}
+func (*GroupMsg) isUpdate() {}
+func (*GroupAllMsg) isUpdate() {}
+func (*SubscribedMsg) isUpdate() {}
+func (*GroupReadyMsg) isUpdate() {}
+func (*HistoryMsg) isUpdate() {}
+func (*SessionMsg) isUpdate() {}
+func (*ReconnectMsg) isUpdate() {}
+func (*StatusMsg) isUpdate() {}
+func (*EventMsg) isUpdate() {}
+func (*HfMsg) isUpdate() {}
+func (*TimeMsg) isUpdate() {}
+func (*VersionMsg) isUpdate() {}
+func (*StatsMsg) isUpdate() {}
+func (*ChatMsg) isUpdate() {}
+func (*PongMsg) isUpdate() {}
+func (*WindAreaMsg) isUpdate() {}
+func (*MapMsg) isUpdate() {}
+func (*ReauthMsg) isUpdate() {}
+func (*ResubscribeMsg) isUpdate() {}
+func (*ReplayEndMsg) isUpdate() {}
+func (*ActionResultMsg) isUpdate() {}
+func (*AlertMsg) isUpdate() {}
+func (*ErrorMsg) isUpdate() {}
+func (*GapMsg) isUpdate() {}
+func (*UnknownMsg) isUpdate() {}
+
+
+// Decodes a message from the connector.
+func decodeUpdate(data []byte) (Update, error) {
+	var probe struct {
+		Type string `json:"type"`
+		You json.RawMessage `json:"you"`
+		Boats json.RawMessage `json:"boats"`
+	}
+	err := json.Unmarshal(data, &probe)
+	if err != nil {
+		return nil, err
+	}
+
+	var u Update
+	switch probe.Type {
+	case "":
+		if probe.You != nil {
+			u = &GroupMsg {}
+		} else if probe.Boats != nil {
+			u = &GroupAllMsg {}
+		} else {
+			u = &BoatDataMsg {}
+		}
+	case "subscribed":
+		u = &SubscribedMsg {}
+	case "group_ready":
+		u = &GroupReadyMsg {}
+	case "history":
+		u = &HistoryMsg {}
+	case "session":
+		u = &SessionMsg {}
+	case "reconnect":
+		u = &ReconnectMsg {}
+	case "status":
+		u = &StatusMsg {}
+	case "event":
+		u = &EventMsg {}
+	case "hf":
+		u = &HfMsg {}
+	case "time":
+		u = &TimeMsg {}
+	case "version":
+		u = &VersionMsg {}
+	case "stats":
+		u = &StatsMsg {}
+	case "chat":
+		u = &ChatMsg {}
+	case "pong":
+		u = &PongMsg {}
+	case "wind_area":
+		u = &WindAreaMsg {}
+	case "map":
+		u = &MapMsg {}
+	case "reauth":
+		u = &ReauthMsg {}
+	case "resubscribe":
+		u = &ResubscribeMsg {}
+	case "replay_end":
+		u = &ReplayEndMsg {}
+	case "action_result":
+		u = &ActionResultMsg {}
+	case "alert":
+		u = &AlertMsg {}
+	case "error":
+		u = &ErrorMsg {}
+	default:
+		return &UnknownMsg { Type: probe.Type, Data: json.RawMessage(data) }, nil
+	}
+
+	err = json.Unmarshal(data, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Returns a message's sequence number (see SubscribeOptions.MsgSeq), or 0 if it has none.
+func decodeMseq(data []byte) uint64 {
+	if !bytes.Contains(data, []byte(`"mseq":`)) {
+		return 0
+	}
+
+	var probe struct {
+		Mseq uint64 `json:"mseq"`
+	}
+	json.Unmarshal(data, &probe)
+
+	return probe.Mseq
+}
@@ -0,0 +1,96 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+// Close codes:
+//
+// When the server closes a connection for a reason other than the client's
+// request being done with (e.g. a finished replay), the close frame carries
+// one of the following codes (and a short human-readable reason), so that
+// client apps can decide whether to reconnect without parsing error messages:
+//
+// 1000 (normal)           Subscription lifetime reached ("resubscribe"); subscribe again.
+// 1001 (going away)        Server shutting down or draining; reconnect (after "retry_after", if given).
+// 1008 (policy violation)  Request not allowed (e.g. too many subscribers, missing admin token, invalid
+//                          fields, unknown simulator, commands during replay, connection lifetime reached,
+//                          banned boat key).
+// 1011 (internal error)    Unexpected server error; reconnect.
+// 1013 (try again later)   Server overloaded (see memory.go); reconnect after "retry_after".
+// 4001 (invalid key)       Boat key, spectator ID, group ID or session token invalid or unknown; don't retry.
+// 4002 (duplicate)         Connection already subscribed (or its session resumed on another connection).
+// 4003 (backend down)      Simulator unreachable; reconnect later.
+// 4004 (boat deleted)      Simulator no longer knows the boat; don't retry.
+
+const CLOSE_NORMAL = websocket.CloseNormalClosure
+const CLOSE_SERVER_SHUTDOWN = websocket.CloseGoingAway
+const CLOSE_POLICY_VIOLATION = websocket.ClosePolicyViolation
+const CLOSE_TRY_AGAIN_LATER = websocket.CloseTryAgainLater
+const CLOSE_INVALID_KEY = 4001
+const CLOSE_DUPLICATE_SUBSCRIBE = 4002
+const CLOSE_BACKEND_UNREACHABLE = 4003
+const CLOSE_BOAT_DELETED = 4004
+
+// Maximum time to wait on shutdown for connections' remaining messages and close frames to be written
+const SHUTDOWN_CLOSE_WAIT = 2 * time.Second
+
+
+// Installs the SIGTERM/SIGINT handler, which closes all connections (with CLOSE_SERVER_SHUTDOWN) before exiting.
+func shutdownInit() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		sig := <-sigs
+		log.Println("Received " + sig.String() + ", stopping...")
+
+		systemdNotify("STOPPING=1")
+		closeAllWsConns(CLOSE_SERVER_SHUTDOWN, "Server shutting down", SHUTDOWN_CLOSE_WAIT)
+		saveStatsFileNow() // See stats-file.go.
+		os.Exit(0)
+	}()
+}
+
+// Closes every open connection with the given code and reason, waiting (up to maxWait) for them to finish closing.
+// Returns the number of connections still open.
+func closeAllWsConns(code int, reason string, maxWait time.Duration) int {
+	_wsConnsLock.Lock()
+	for wc := range _wsConns {
+		wc.CloseWithReason(code, reason)
+	}
+	_wsConnsLock.Unlock()
+
+	deadline := time.Now().Add(maxWait)
+	for {
+		n := countWsConns()
+
+		if n == 0 || !time.Now().Before(deadline) {
+			return n
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,485 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Clustering via Redis pub/sub:
+//
+// - A "poller" instance behaves like a standalone instance, but additionally
+//   polls the simulator for every boat key registered by a live edge instance,
+//   and publishes each boat's snapshot to a per-boat channel.
+//
+// - "edge" instances never poll the simulator for live boat data. Instead,
+//   they subscribe to the per-boat channels of the boats they track locally
+//   and serve clients from the latest published snapshots. Each edge
+//   registers the boat keys it tracks in its own set in Redis, which expires
+//   unless refreshed periodically, so that the registrations of an edge that
+//   crashes or loses its connection simply lapse. A registry of edge IDs lets
+//   the poller find these sets.
+//
+// Group membership lookups are still made directly against the simulator by
+// every instance, as they are comparatively rare.
+
+const CLUSTER_ROLE_NONE = "none"
+const CLUSTER_ROLE_POLLER = "poller"
+const CLUSTER_ROLE_EDGE = "edge"
+
+const CLUSTER_NOBOAT_PAYLOAD = "noboat"
+const CLUSTER_STALE_TIMEOUT = 5 * time.Second
+const CLUSTER_RECONNECT_DELAY = 2 * time.Second
+
+// An edge's registrations expire unless refreshed within CLUSTER_REG_TTL.
+const CLUSTER_REG_TTL = 30 * time.Second
+const CLUSTER_REG_REFRESH_INTERVAL = 10 * time.Second
+
+// Edges not refreshed for this long are pruned from the registry. This is only housekeeping
+// (the registrations themselves expire in Redis), so it's generous enough to allow for clock skew.
+const CLUSTER_EDGE_PRUNE_AGE = 1 * time.Hour
+
+type ClusterSnapshot struct {
+	Data BoatDataLiveRespMsg
+	NoBoat bool
+	Received time.Time
+}
+
+// Latest snapshots received by an edge instance, keyed by boat key, and the boat keys it currently
+// tracks locally (which its Redis registrations are brought in line with by clusterEdgeMain)
+var _clusterLock sync.Mutex
+var _clusterSnapshots = make(map[string]*ClusterSnapshot)
+var _clusterWanted = make(map[string]bool)
+
+// Signalled whenever _clusterWanted changes
+var _clusterWake = make(chan int, 1)
+
+// Identifies this edge instance's registrations
+var _clusterEdgeId string
+
+// Redis connection used by a poller instance (only accessed from the main loop goroutine)
+var _clusterPollerConn *RedisConn = nil
+
+
+func clusterInit() {
+	switch _config.ClusterRole {
+	case CLUSTER_ROLE_POLLER:
+		log.Println("Cluster role: poller (Redis at " + _config.ClusterRedis + ")")
+	case CLUSTER_ROLE_EDGE:
+		_clusterEdgeId = clusterNewEdgeId()
+		log.Println("Cluster role: edge " + _clusterEdgeId + " (Redis at " + _config.ClusterRedis + ")")
+		go clusterEdgeMain()
+	}
+}
+
+func clusterNewEdgeId() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Println(err)
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func clusterEdgesKey() string {
+	return _config.ClusterPrefix + "edges"
+}
+
+func clusterEdgeKey(edgeId string) string {
+	return _config.ClusterPrefix + "edge:" + edgeId
+}
+
+func clusterBoatChannel(boatKey string) string {
+	return _config.ClusterPrefix + "boat:" + boatKey
+}
+
+func clusterBoatKeyFromChannel(channel string) string {
+	return strings.TrimPrefix(channel, _config.ClusterPrefix + "boat:")
+}
+
+
+// Called (with _lock held) whenever a boat starts being tracked locally.
+func clusterTrack(boatKey string) {
+	clusterSetWanted(boatKey, true)
+}
+
+// Called (with _lock held) whenever a boat stops being tracked locally.
+func clusterUntrack(boatKey string) {
+	clusterSetWanted(boatKey, false)
+}
+
+func clusterSetWanted(boatKey string, wanted bool) {
+	if _config.ClusterRole != CLUSTER_ROLE_EDGE {
+		return
+	}
+
+	_clusterLock.Lock()
+	if wanted {
+		_clusterWanted[boatKey] = true
+	} else {
+		delete(_clusterWanted, boatKey)
+		delete(_clusterSnapshots, boatKey)
+	}
+	_clusterLock.Unlock()
+
+	select {
+	case _clusterWake <- 0:
+	default:
+		// Already signalled
+	}
+}
+
+
+func clusterEdgeMain() {
+	for {
+		cmdConn, err := redisDial(_config.ClusterRedis)
+		if err != nil {
+			log.Println(err)
+			time.Sleep(CLUSTER_RECONNECT_DELAY)
+			continue
+		}
+
+		subConn, err := redisDial(_config.ClusterRedis)
+		if err != nil {
+			log.Println(err)
+			cmdConn.Close()
+			time.Sleep(CLUSTER_RECONNECT_DELAY)
+			continue
+		}
+
+		log.Println("Connected to Redis for cluster edge role.")
+
+		readerDone := make(chan int)
+		go clusterEdgeReader(subConn, readerDone)
+
+		// Start over with our registrations and subscriptions after (re)connecting, as Redis may
+		// have restarted (or our registrations expired) in the meantime.
+		_, err = cmdConn.Do("DEL", clusterEdgeKey(_clusterEdgeId))
+		if err != nil {
+			log.Println(err)
+		} else {
+			clusterEdgeServe(cmdConn, subConn, make(map[string]bool), readerDone)
+		}
+
+		cmdConn.Close()
+		subConn.Close()
+		<-readerDone
+
+		time.Sleep(CLUSTER_RECONNECT_DELAY)
+	}
+}
+
+func clusterEdgeServe(cmdConn *RedisConn, subConn *RedisConn, subscribed map[string]bool, readerDone chan int) {
+	ticker := time.NewTicker(CLUSTER_REG_REFRESH_INTERVAL)
+	defer ticker.Stop()
+
+	err := clusterEdgeSync(cmdConn, subConn, subscribed)
+
+	for err == nil {
+		select {
+		case <-_clusterWake:
+			err = clusterEdgeSync(cmdConn, subConn, subscribed)
+
+		case <-ticker.C:
+			err = clusterEdgeRegister(cmdConn, subscribed)
+
+		case <-readerDone:
+			return
+		}
+	}
+
+	log.Println(err)
+}
+
+// Brings our subscriptions and registrations in line with the boat keys tracked locally. A boat key
+// only counts as subscribed once both its subscription and its registration have been sent successfully.
+func clusterEdgeSync(cmdConn *RedisConn, subConn *RedisConn, subscribed map[string]bool) error {
+	_clusterLock.Lock()
+	wanted := make(map[string]bool, len(_clusterWanted))
+	for boatKey, _ := range _clusterWanted {
+		wanted[boatKey] = true
+	}
+	_clusterLock.Unlock()
+
+	for boatKey, _ := range subscribed {
+		if wanted[boatKey] {
+			continue
+		}
+
+		if err := subConn.Send("UNSUBSCRIBE", clusterBoatChannel(boatKey)); err != nil {
+			return err
+		}
+		if _, err := cmdConn.Do("SREM", clusterEdgeKey(_clusterEdgeId), boatKey); err != nil {
+			return err
+		}
+
+		delete(subscribed, boatKey)
+	}
+
+	added := make(map[string]bool)
+	for boatKey, _ := range wanted {
+		if subscribed[boatKey] {
+			continue
+		}
+
+		if err := subConn.Send("SUBSCRIBE", clusterBoatChannel(boatKey)); err != nil {
+			return err
+		}
+
+		added[boatKey] = true
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	if err := clusterEdgeRegister(cmdConn, added); err != nil {
+		return err
+	}
+
+	for boatKey, _ := range added {
+		subscribed[boatKey] = true
+	}
+
+	return nil
+}
+
+// Atomically (re-)registers the given boat keys in our set, and refreshes its expiry and our entry in the edge registry.
+func clusterEdgeRegister(cmdConn *RedisConn, boatKeys map[string]bool) error {
+	edgeKey := clusterEdgeKey(_clusterEdgeId)
+
+	cmds := [][]string {
+		{ "MULTI" },
+	}
+
+	if len(boatKeys) > 0 {
+		sadd := []string { "SADD", edgeKey }
+		for boatKey, _ := range boatKeys {
+			sadd = append(sadd, boatKey)
+		}
+		cmds = append(cmds, sadd)
+	}
+
+	cmds = append(cmds,
+		[]string { "EXPIRE", edgeKey, strconv.Itoa(int(CLUSTER_REG_TTL / time.Second)) },
+		[]string { "ZADD", clusterEdgesKey(), strconv.FormatInt(time.Now().Unix(), 10), _clusterEdgeId },
+		[]string { "EXEC" },
+	)
+
+	for _, cmd := range cmds {
+		if _, err := cmdConn.Do(cmd...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func clusterEdgeReader(subConn *RedisConn, done chan int) {
+	for {
+		reply, err := subConn.ReadReply()
+		if err != nil {
+			log.Println(err)
+			break
+		}
+
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) != 3 {
+			continue
+		}
+
+		kind, _ := arr[0].(string)
+		if kind != "message" {
+			continue // Subscribe/unsubscribe confirmation
+		}
+
+		channel, _ := arr[1].(string)
+		payload, _ := arr[2].(string)
+
+		snapshot := &ClusterSnapshot {
+			Received: time.Now(),
+		}
+
+		if payload == CLUSTER_NOBOAT_PAYLOAD {
+			snapshot.NoBoat = true
+		} else if json.Unmarshal([]byte(payload), &snapshot.Data) != nil {
+			log.Println("Invalid snapshot received on channel: " + channel)
+			continue
+		}
+
+		boatKey := clusterBoatKeyFromChannel(channel)
+
+		_clusterLock.Lock()
+		if _clusterWanted[boatKey] {
+			_clusterSnapshots[boatKey] = snapshot
+		}
+		_clusterLock.Unlock()
+	}
+
+	close(done)
+}
+
+// Returns the latest (non-stale) snapshots received from the poller, in the
+// same form as would be returned by polling the simulator directly.
+func clusterEdgeResps() (map[string]BoatDataLiveRespMsg, map[string]bool) {
+	resps := make(map[string]BoatDataLiveRespMsg)
+	noBoats := make(map[string]bool)
+
+	now := time.Now()
+
+	_clusterLock.Lock()
+	defer _clusterLock.Unlock()
+
+	for boatKey, snapshot := range _clusterSnapshots {
+		if snapshot.NoBoat {
+			noBoats[boatKey] = true
+		} else if now.Sub(snapshot.Received) < CLUSTER_STALE_TIMEOUT {
+			// Delivery latency is measured from arrival here, but any embedded timestamp is the poller's.
+			data := snapshot.Data
+			data.ArrivedAt = snapshot.Received
+			resps[boatKey] = data
+		}
+	}
+
+	return resps, noBoats
+}
+
+
+func clusterPollerConnect() bool {
+	if _clusterPollerConn != nil {
+		return true
+	}
+
+	conn, err := redisDial(_config.ClusterRedis)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	_clusterPollerConn = conn
+	return true
+}
+
+func clusterPollerDisconnect() {
+	_clusterPollerConn.Close()
+	_clusterPollerConn = nil
+}
+
+// Returns the boat keys that edge instances currently want polled.
+func clusterPollerKeys() []string {
+	if !clusterPollerConnect() {
+		return nil
+	}
+
+	conn := _clusterPollerConn
+
+	// Edges which haven't refreshed for a long time are gone, so prune them from the registry.
+	pruneBefore := time.Now().Add(-CLUSTER_EDGE_PRUNE_AGE).Unix()
+	_, err := conn.Do("ZREMRANGEBYSCORE", clusterEdgesKey(), "-inf", "(" + strconv.FormatInt(pruneBefore, 10))
+	if err != nil {
+		log.Println(err)
+		clusterPollerDisconnect()
+		return nil
+	}
+
+	reply, err := conn.Do("ZRANGE", clusterEdgesKey(), "0", "-1")
+	if err != nil {
+		log.Println(err)
+		clusterPollerDisconnect()
+		return nil
+	}
+
+	edgeIds, _ := reply.([]interface{})
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, edgeId := range edgeIds {
+		id, _ := edgeId.(string)
+
+		// An expired registration set simply reads as empty.
+		reply, err := conn.Do("SMEMBERS", clusterEdgeKey(id))
+		if err != nil {
+			log.Println(err)
+			clusterPollerDisconnect()
+			return keys
+		}
+
+		members, _ := reply.([]interface{})
+		for _, member := range members {
+			boatKey, _ := member.(string)
+			if !seen[boatKey] && _boatKeyRegexp.MatchString(boatKey) {
+				seen[boatKey] = true
+				keys = append(keys, boatKey)
+			}
+		}
+	}
+
+	return keys
+}
+
+// Publishes this iteration's snapshots (and "noboat" results) to the per-boat channels.
+func clusterPublish(resps map[string]BoatDataLiveRespMsg, noBoats map[string]bool) {
+	if len(resps) == 0 && len(noBoats) == 0 {
+		return
+	}
+
+	if !clusterPollerConnect() {
+		return
+	}
+
+	conn := _clusterPollerConn
+	err := conn.conn.SetDeadline(time.Now().Add(CONN_RW_TIMEOUT))
+
+	// Pipeline all publishes, then collect all replies.
+	sent := 0
+	for boatKey, resp := range resps {
+		if err != nil {
+			break
+		}
+
+		payload, _ := json.Marshal(resp)
+		err = conn.Send("PUBLISH", clusterBoatChannel(boatKey), string(payload))
+		sent++
+	}
+	for boatKey, _ := range noBoats {
+		if err != nil {
+			break
+		}
+
+		err = conn.Send("PUBLISH", clusterBoatChannel(boatKey), CLUSTER_NOBOAT_PAYLOAD)
+		sent++
+	}
+
+	for i := 0; i < sent && err == nil; i++ {
+		_, err = conn.ReadReply()
+	}
+
+	if err != nil {
+		log.Println(err)
+		clusterPollerDisconnect()
+	}
+}
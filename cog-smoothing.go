@@ -0,0 +1,100 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"math"
+)
+
+
+// Course over ground smoothing:
+//
+// The simulator's COG jitters when a boat is nearly stationary, which makes
+// course arrows on maps spin. A subscription request (bdl, bdl_g, bdl_x,
+// group_all or gdl) may add "smooth_cog":<n>, to have the COG of the boats in
+// its live data (other than "others", which don't include COG) replaced with
+// an exponential moving average over roughly the last <n> iterations (2 to
+// COG_SMOOTHING_MAX), with a smoothing factor of 2 / (<n> + 1). Courses are
+// averaged as unit vectors, so that e.g. 359 and 1 average to 0, not 180.
+// Smoothing state is kept per subscription, so starts afresh on reconnecting.
+
+// Maximum number of iterations to smooth COG over
+const COG_SMOOTHING_MAX = 60
+
+type CogSmoother struct {
+	Iters int
+	alpha float64
+	avgs map[string][2]float64 // Smoothed course unit vectors (east, north components), by boat key
+}
+
+
+// Checks the COG smoothing requested by a client, returning a smoother (nil if not requested) and whether the request is valid.
+func reqCogSmoothing(req *ReqMsg, conn *WsConn) (*CogSmoother, bool) {
+	if req.SmoothCog == 0 || req.SmoothCog == 1 {
+		return nil, true
+	}
+
+	if req.SmoothCog < 0 || req.SmoothCog > COG_SMOOTHING_MAX {
+		log.Println("Client (" + conn.RemoteIp + ") requested invalid COG smoothing")
+
+		sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, "Invalid COG smoothing", COG_SMOOTHING_MAX)
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid COG smoothing")
+		return nil, false
+	}
+
+	return newCogSmoother(req.SmoothCog), true
+}
+
+func newCogSmoother(iters int) *CogSmoother {
+	return &CogSmoother {
+		Iters: iters,
+		alpha: 2.0 / float64(iters + 1),
+		avgs: make(map[string][2]float64),
+	}
+}
+
+// Returns a boat's data with its COG smoothed, updating the smoothing state (once per iteration).
+// Data is returned unchanged if smoothing wasn't requested (s is nil).
+func (s *CogSmoother) smooth(boatKey string, data BoatDataLiveRespMsg) BoatDataLiveRespMsg {
+	if s == nil {
+		return data
+	}
+
+	rad := data.Cog * math.Pi / 180.0
+	east := math.Sin(rad)
+	north := math.Cos(rad)
+
+	avg, exists := s.avgs[boatKey]
+	if exists {
+		east = avg[0] + s.alpha * (east - avg[0])
+		north = avg[1] + s.alpha * (north - avg[1])
+	}
+	s.avgs[boatKey] = [2]float64 { east, north }
+
+	cog := math.Atan2(east, north) * 180.0 / math.Pi
+	if cog < 0.0 {
+		cog += 360.0
+	}
+	cog = math.Round(cog * 10.0) / 10.0 // To nearest 0.1 degrees
+	if cog >= 360.0 {
+		cog -= 360.0
+	}
+
+	data.Cog = cog
+	return data
+}
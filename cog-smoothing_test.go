@@ -0,0 +1,69 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+
+func TestCogSmoother(t *testing.T) {
+	var none *CogSmoother = nil
+	if data := none.smooth("a", BoatDataLiveRespMsg { Cog: 123.45 }); data.Cog != 123.45 {
+		t.Errorf("COG changed without smoothing: %g", data.Cog)
+	}
+
+	s := newCogSmoother(3) // alpha = 0.5
+
+	if data := s.smooth("a", BoatDataLiveRespMsg { Cog: 350.0 }); data.Cog != 350.0 {
+		t.Errorf("First COG not passed through: %g", data.Cog)
+	}
+
+	// Averaged across north, rather than through south.
+	if data := s.smooth("a", BoatDataLiveRespMsg { Cog: 10.0 }); data.Cog != 0.0 {
+		t.Errorf("Unexpected smoothed COG across north: %g", data.Cog)
+	}
+
+	// Boats are smoothed independently.
+	if data := s.smooth("b", BoatDataLiveRespMsg { Cog: 180.0 }); data.Cog != 180.0 {
+		t.Errorf("Unexpected COG for other boat: %g", data.Cog)
+	}
+
+	// Converges on a steady course.
+	var data BoatDataLiveRespMsg
+	for i := 0; i < 20; i++ {
+		data = s.smooth("a", BoatDataLiveRespMsg { Cog: 90.0, Sog: 6.0 })
+	}
+	if math.Abs(data.Cog - 90.0) > 0.1 || data.Sog != 6.0 {
+		t.Errorf("Smoothed COG not converged: %+v", data)
+	}
+}
+
+func TestReqCogSmoothing(t *testing.T) {
+	for _, n := range []int { 0, 1 } {
+		s, ok := reqCogSmoothing(&ReqMsg { SmoothCog: n }, nil)
+		if !ok || s != nil {
+			t.Errorf("Unexpected smoother for %d iterations!", n)
+		}
+	}
+
+	s, ok := reqCogSmoothing(&ReqMsg { SmoothCog: COG_SMOOTHING_MAX }, nil)
+	if !ok || s == nil || s.Iters != COG_SMOOTHING_MAX {
+		t.Errorf("Unexpected smoother for maximum iterations!")
+	}
+}
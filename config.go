@@ -0,0 +1,725 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+// Every option may also be given as an environment variable, named after the
+// option with this prefix, in upper case and with "_" instead of "-" (e.g.
+// SNSW_QUEUE_SIZE for -queue-size), as may the positional arguments (as
+// SNSW_LISTEN_ADDR and SNSW_SIM_ADDR). Options given on the command line take
+// precedence over environment variables, which take precedence over defaults.
+const ENV_PREFIX = "SNSW_"
+
+const ENV_LISTEN_ADDR = ENV_PREFIX + "LISTEN_ADDR"
+const ENV_SIM_ADDR = ENV_PREFIX + "SIM_ADDR"
+
+
+type Config struct {
+	ListenHostPort string
+	ConnectHostPort string
+
+	// Additional listeners (see listeners.go)
+	Listeners []ListenerSpec
+	TlsCert string
+	TlsKey string
+
+	// Relay to dial out to, and serve client connections through (see relay.go)
+	Relay string
+	RelayToken string
+
+	// Named simulators, besides the default one (see sims.go)
+	Sims map[string]string
+
+	// Clustering (see cluster.go)
+	ClusterRole string
+	ClusterRedis string
+	ClusterPrefix string
+
+	// Main loop poll interval (see tick.go)
+	PollInterval time.Duration
+	AdaptiveTick bool
+
+	// Load shedding when iterations run over budget (see shedding.go)
+	ShedPolicy string
+	ShedBudget float64
+
+	// High-frequency mode (see hf.go)
+	HfRate int
+	HfDist float64
+
+	// Live data messages per poll interval with interpolated data (0 to disable; see interp.go)
+	InterpRate int
+
+	// Relay boat events from the simulator (see boat-events.go)
+	Events bool
+
+	// Shared streams for duplicate subscriptions (see shared-streams.go)
+	SharedStreams bool
+	CoalesceDuplicates bool
+
+	// Resumable sessions (see session.go)
+	SessionGrace time.Duration
+
+	// Iterations without simulator data before closing connections (see sim-outage.go)
+	SimGrace int
+	MaxStaleness time.Duration
+
+	// Number of recent samples per boat sent on subscribing (see history.go)
+	HistorySize int
+
+	// Track recorder (see recorder.go)
+	RecordDir string
+	RecordFormat string
+	RecordRotate time.Duration
+	RecordRetention time.Duration
+
+	// Geofences applying to every subscription by boat key (see geofence.go)
+	GeofencesFile string
+	Geofences []Geofence
+
+	// Address to also send each tracked boat's live data to over UDP, and its format (see udp-output.go)
+	UdpOutput string
+	UdpOutputFormat string
+
+	// File to save the tracked boats to, and pre-warm from on startup (see watch-list.go)
+	WatchListFile string
+
+	// Distance (NM) as if seen from which bdl and bdl_x streams are rounded (0 for full precision; see precision.go)
+	BdlPrecisionDist float64
+
+	// Rounding by distance (see precision.go)
+	Precision *PrecisionPolicy
+
+	// Tracing (see tracing.go)
+	TraceEndpoint string
+	TraceSampleRatio float64
+
+	// Number of workers fetching group membership for bdl_g subscriptions (see group-fetch.go)
+	GroupFetchWorkers int
+
+	// Check boat keys against the simulator on subscribing (see key-validation.go)
+	ValidateKeys bool
+
+	// Cooldown for boat keys after a "noboat" response (see deleted-boats.go; 0 for none)
+	NoboatCooldown time.Duration
+
+	// Maximum number of connections subscribed to any one boat key (0 for no limit)
+	MaxSubscribersPerKey int
+
+	// Reconnect tokens (see reconnect.go)
+	ReconnectTtl time.Duration
+	ReconnectSecret string
+
+	// Memory guardrails (see memory.go)
+	MemoryLimit int64 // MiB (0 to leave it to GOMEMLIMIT)
+	MaxTrackedBoats int
+	MaxSessions int
+
+	// Request limits (see req-limits.go)
+	MaxReqSize int
+	MaxUnknownCmds int
+
+	// Spectator ID resolution (see spectator.go)
+	SpectatorMapFile string
+	SpectatorSimLookup bool
+
+	// File mapping group IDs to boat keys and tokens (see group-id.go)
+	GroupMapFile string
+
+	// Webhooks (see webhooks.go)
+	WebhooksFile string
+	WebhookSecret string
+
+	// Operational alerts (see alerts.go)
+	AlertWebhook string
+	AlertConns []int
+
+	// Boat control actions forwarded to the simulator, or nil if none (see boat-actions.go)
+	Actions map[string]bool
+
+	// Token required for spectator map requests (see map-area.go)
+	MapToken string
+
+	// Outbound message queueing (see ws-conn.go)
+	QueueSize int
+	QueuePolicy string
+	QueuePolicyOverrides map[string]string
+	WriteTimeout time.Duration
+
+	// WebSocket upgrader settings (see wsUpgrade)
+	WsReadBufferSize int
+	WsWriteBufferSize int
+	WsWriteBufferPool bool
+	WsCompression bool
+
+	// Number of iterations between time sync messages sent on every connection (0 to disable; see time-sync.go)
+	TimeSyncInterval int
+
+	// Number of iterations between stats messages sent to each client about its own connection (0 to disable; see conn-stats.go)
+	ClientStatsInterval int
+
+	// Maximum connection lifetime (0 for no limit; see lifetime.go)
+	MaxConnLifetime time.Duration
+
+	// Maximum subscription lifetime (0 for no limit; see lifetime.go)
+	MaxSubLifetime time.Duration
+
+	// Token required for admin-only requests (disabled if empty; see group-all.go)
+	AdminToken string
+
+	// Include boat data arrival times in live data messages (see latency.go)
+	EmbedTimestamps bool
+
+	// Proxies trusted to give the client's IP address in X-Forwarded-For (see client-ip.go)
+	TrustedProxies []*net.IPNet
+
+	// Client IP address allow/deny lists (see ip-filter.go)
+	AllowIps []*net.IPNet
+	DenyIps []*net.IPNet
+
+	// Abuse detection (see abuse.go)
+	BanInvalidKeys int
+	BanConnects int
+	BanDuration time.Duration
+
+	// Origins allowed to make cross-origin requests to the REST endpoints (see cors.go)
+	CorsOrigins []string
+
+	// Authentication required on upgrade requests, per endpoint (nil for none; see auth.go)
+	AuthWs *AuthSpec
+	AuthReplay *AuthSpec
+
+	// Log boat keys verbatim, rather than hashed (see log-redact.go)
+	LogKeys bool
+
+	// Use an embedded fake simulator (see mock-sim.go)
+	MockSim bool
+
+	// Statistics (see stats.go)
+	StatsInterval int
+	StatsSinks []string
+	StatsdHostPort string
+	StatsdPrefix string
+	StatsFile string // File to keep lifetime totals in, across restarts (see stats-file.go)
+
+	// Listener for operational endpoints (see admin.go)
+	AdminListenHostPort string
+}
+
+var _config *Config = defaultConfig()
+
+func defaultConfig() *Config {
+	return &Config {
+		Sims: make(map[string]string),
+		ClusterRole: CLUSTER_ROLE_NONE,
+		ClusterRedis: "localhost:6379",
+		ClusterPrefix: "snsw:",
+		PollInterval: time.Second,
+		AdaptiveTick: false,
+		ShedPolicy: SHED_POLICY_NONE,
+		ShedBudget: 0.9,
+		HfRate: 0,
+		HfDist: 0.5,
+		InterpRate: 0,
+		Events: false,
+		SharedStreams: false,
+		CoalesceDuplicates: false,
+		SessionGrace: 60 * time.Second,
+		SimGrace: 5,
+		MaxStaleness: 0,
+		HistorySize: 0,
+		RecordDir: "",
+		RecordFormat: RECORD_FORMAT_GEOJSON,
+		RecordRotate: 24 * time.Hour,
+		RecordRetention: 0,
+		GeofencesFile: "",
+		UdpOutput: "",
+		UdpOutputFormat: UDP_OUTPUT_FORMAT_NMEA,
+		WatchListFile: "",
+		BdlPrecisionDist: 0,
+		Precision: defaultPrecisionPolicy(),
+		GroupFetchWorkers: 4,
+		TraceEndpoint: "",
+		TraceSampleRatio: 1.0,
+		ValidateKeys: true,
+		NoboatCooldown: 1 * time.Minute,
+		MaxSubscribersPerKey: 0,
+		ReconnectTtl: 0,
+		ReconnectSecret: "",
+		MemoryLimit: 0,
+		MaxTrackedBoats: 0,
+		MaxSessions: 10000,
+		MaxReqSize: REQ_MAX_SIZE,
+		MaxUnknownCmds: 10,
+		SpectatorMapFile: "",
+		SpectatorSimLookup: false,
+		GroupMapFile: "",
+		WebhooksFile: "",
+		WebhookSecret: "",
+		AlertWebhook: "",
+		AlertConns: nil,
+		Actions: nil,
+		MapToken: "",
+		QueueSize: 8,
+		QueuePolicy: QUEUE_POLICY_DISCONNECT,
+		QueuePolicyOverrides: make(map[string]string),
+		WriteTimeout: 10 * time.Second,
+		WsReadBufferSize: 1024,
+		WsWriteBufferSize: 4096,
+		WsWriteBufferPool: false,
+		WsCompression: false,
+		TimeSyncInterval: 0,
+		ClientStatsInterval: 0,
+		MaxConnLifetime: 0,
+		MaxSubLifetime: 0,
+		AdminToken: "",
+		EmbedTimestamps: false,
+		TrustedProxies: make([]*net.IPNet, 0),
+		AllowIps: make([]*net.IPNet, 0),
+		DenyIps: make([]*net.IPNet, 0),
+		BanInvalidKeys: 0,
+		BanConnects: 0,
+		BanDuration: 1 * time.Minute,
+		CorsOrigins: make([]string, 0),
+		LogKeys: false,
+		MockSim: false,
+		StatsInterval: 60,
+		StatsSinks: []string { STATS_SINK_LOG },
+		StatsdHostPort: "localhost:8125",
+		StatsdPrefix: "snsw.",
+		StatsFile: "",
+		AdminListenHostPort: "",
+		Listeners: make([]ListenerSpec, 0),
+		TlsCert: "",
+		TlsKey: "",
+		Relay: "",
+		RelayToken: "",
+	}
+}
+
+func parseArgs(args []string) (*Config, error) {
+	return parseArgsEnv(args, os.LookupEnv)
+}
+
+func envName(flagName string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func parseArgsEnv(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("sailnavsim-snsw", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.StringVar(&cfg.ClusterRole, "cluster-role", cfg.ClusterRole, "Cluster role: \"none\", \"poller\", or \"edge\"")
+	fs.StringVar(&cfg.ClusterRedis, "cluster-redis", cfg.ClusterRedis, "Redis host:port used for cluster fan-out")
+	fs.StringVar(&cfg.ClusterPrefix, "cluster-prefix", cfg.ClusterPrefix, "Prefix for Redis keys and channels used for cluster fan-out")
+
+	fs.DurationVar(&cfg.PollInterval, "poll-interval", cfg.PollInterval, "Time between main loop iterations, each polling the simulator and sending live data")
+	fs.BoolVar(&cfg.AdaptiveTick, "adaptive-tick", cfg.AdaptiveTick, "Keep iterations aligned to multiples of the poll interval, skipping ticks missed by overrunning iterations")
+	fs.StringVar(&cfg.ShedPolicy, "shed-policy", cfg.ShedPolicy, "How to shed load while iterations run over budget: \"none\", \"degrade-groups\", \"slow-low-priority\" or \"skip-alternate\"")
+	fs.Float64Var(&cfg.ShedBudget, "shed-budget", cfg.ShedBudget, "Iteration time budget, as a fraction of the poll interval, beyond which load is shed (see -shed-policy)")
+	fs.IntVar(&cfg.HfRate, "hf-rate", cfg.HfRate, "Live data messages per poll interval for bdl_g subscriptions in high-frequency mode (0 to disable)")
+	fs.IntVar(&cfg.InterpRate, "interp-rate", cfg.InterpRate, "Live data messages per poll interval, with interpolated positions in between, for subscriptions asking for them (0 to disable)")
+	fs.Float64Var(&cfg.HfDist, "hf-dist", cfg.HfDist, "Distance (NM) to another boat in the group within which high-frequency mode is on")
+	fs.BoolVar(&cfg.Events, "events", cfg.Events, "Relay boat events (e.g. finished, aground) from the simulator to subscribed connections")
+	fs.BoolVar(&cfg.SharedStreams, "shared-streams", cfg.SharedStreams, "Marshal (and compress) live data just once for connections subscribed to the same boat with the same options")
+	fs.BoolVar(&cfg.CoalesceDuplicates, "coalesce-duplicates", cfg.CoalesceDuplicates, "Use the \"conflate\" queue policy for connections from the same remote IP subscribed to the same boat")
+	fs.DurationVar(&cfg.SessionGrace, "session-grace", cfg.SessionGrace, "How long a disconnected session may be resumed for")
+	fs.IntVar(&cfg.SimGrace, "sim-grace", cfg.SimGrace, "Number of consecutive iterations without simulator data for a boat (other than \"noboat\") before closing its connections")
+	fs.DurationVar(&cfg.MaxStaleness, "max-staleness", cfg.MaxStaleness, "How long to keep sending each boat's last known data during simulator outages (0 to disable)")
+	fs.IntVar(&cfg.HistorySize, "history-size", cfg.HistorySize, "Number of recent samples of each boat's data to send in one burst on subscribing (0 to disable)")
+	fs.StringVar(&cfg.RecordDir, "record-dir", cfg.RecordDir, "Directory to record boat tracks to (recording disabled if empty)")
+	fs.StringVar(&cfg.RecordFormat, "record-format", cfg.RecordFormat, "Track recording format: \"geojson\" or \"gpx\"")
+	fs.DurationVar(&cfg.RecordRotate, "record-rotate", cfg.RecordRotate, "Age after which a new track file is started for a boat (0 to never rotate)")
+	fs.DurationVar(&cfg.RecordRetention, "record-retention", cfg.RecordRetention, "Age after which track files are deleted (0 to keep forever)")
+	fs.StringVar(&cfg.GeofencesFile, "geofences-file", cfg.GeofencesFile, "JSON file of geofences applying to every subscription by boat key (none if empty)")
+	fs.StringVar(&cfg.UdpOutput, "udp-output", cfg.UdpOutput, "Host:port to also send each tracked boat's live data to over UDP (disabled if empty)")
+	fs.StringVar(&cfg.UdpOutputFormat, "udp-output-format", cfg.UdpOutputFormat, "UDP output format: \"nmea\" or \"json\"")
+	fs.StringVar(&cfg.WatchListFile, "watch-list", cfg.WatchListFile, "File to periodically save tracked boats and groups to, and pre-warm from on startup (disabled if empty)")
+	fs.IntVar(&cfg.MaxReqSize, "max-req-size", cfg.MaxReqSize, "Maximum size (bytes) of a request message from a client, beyond which its connection is closed")
+	fs.IntVar(&cfg.MaxUnknownCmds, "max-unknown-cmds", cfg.MaxUnknownCmds, "Number of unknown commands after which a connection is closed (0 for no limit)")
+	fs.Float64Var(&cfg.BdlPrecisionDist, "bdl-precision-dist", cfg.BdlPrecisionDist, "Round positions and courses in bdl and bdl_x streams as if seen from another boat this far away (NM; 0 for full precision)")
+	precisionPolicy := fs.String("precision-policy", "", "JSON file of rounding rules by distance for positions, courses and speeds (built-in rules if empty)")
+	fs.StringVar(&cfg.TraceEndpoint, "otlp-endpoint", cfg.TraceEndpoint, "OTLP/HTTP endpoint to export traces to, e.g. \"http://localhost:4318/v1/traces\" (tracing disabled if empty)")
+	fs.Float64Var(&cfg.TraceSampleRatio, "trace-sample", cfg.TraceSampleRatio, "Fraction of traces (iterations, upgrades, subscriptions and group fetches) to record")
+	fs.IntVar(&cfg.GroupFetchWorkers, "group-fetch-workers", cfg.GroupFetchWorkers, "Number of workers fetching group membership from the simulator for bdl_g subscriptions")
+	fs.BoolVar(&cfg.ValidateKeys, "validate-keys", cfg.ValidateKeys, "Check boat keys not already known against the simulator on subscribing (use -validate-keys=false to leave it to the first poll)")
+	fs.DurationVar(&cfg.NoboatCooldown, "noboat-cooldown", cfg.NoboatCooldown, "How long subscriptions to a boat key are rejected after the simulator doesn't know it, doubling with each further \"noboat\" (0 for no cooldown)")
+	fs.IntVar(&cfg.MaxSubscribersPerKey, "max-subscribers-per-key", cfg.MaxSubscribersPerKey, "Maximum number of connections subscribed to any one boat key (0 for no limit)")
+	fs.DurationVar(&cfg.ReconnectTtl, "reconnect-ttl", cfg.ReconnectTtl, "How long reconnect tokens sent to bdl, bdl_g and bdl_x subscriptions stay valid (0 to not send them)")
+	fs.StringVar(&cfg.ReconnectSecret, "reconnect-secret", cfg.ReconnectSecret, "Secret to derive the reconnect token key from, shared by instances that should accept each other's tokens (random if empty)")
+	fs.Int64Var(&cfg.MemoryLimit, "memory-limit", cfg.MemoryLimit, "Soft memory limit (MiB), overriding GOMEMLIMIT, near which new subscriptions are rejected (0 to leave it to GOMEMLIMIT, if set)")
+	fs.IntVar(&cfg.MaxTrackedBoats, "max-tracked-boats", cfg.MaxTrackedBoats, "Maximum number of boats polled from the simulator, beyond which subscriptions to other boats are rejected (0 for no limit)")
+	fs.IntVar(&cfg.MaxSessions, "max-sessions", cfg.MaxSessions, "Maximum number of resumable sessions kept, beyond which subscriptions go without one (0 for no limit)")
+	fs.StringVar(&cfg.SpectatorMapFile, "spectator-map", cfg.SpectatorMapFile, "File mapping public spectator IDs to boat keys")
+	sims := fs.String("sims", "", "Named simulators, besides the default one, as \"<name>=<host:port>[,...]\"")
+	fs.BoolVar(&cfg.SpectatorSimLookup, "spectator-sim-lookup", cfg.SpectatorSimLookup, "Resolve spectator IDs (not found in the spectator map file) via the simulator")
+	fs.StringVar(&cfg.WebhooksFile, "webhooks", cfg.WebhooksFile, "File listing webhooks to POST selected events to (disabled if empty)")
+	fs.StringVar(&cfg.WebhookSecret, "webhook-secret", cfg.WebhookSecret, "Secret to sign webhook requests with (HMAC-SHA256, in an X-Snsw-Signature header; unsigned if empty)")
+	fs.StringVar(&cfg.AlertWebhook, "alert-webhook", cfg.AlertWebhook, "Slack or Discord webhook URL to post operational alerts (simulator outages and connection counts) to (disabled if empty)")
+	actions := fs.String("actions", "", "Boat control actions clients may forward to the simulator, as \"<action>[,...]\" (actions: course, trim, anchor)")
+	alertConns := fs.String("alert-conns", "", "Connection counts to alert on reaching, as \"<n>[,...]\" (with -alert-webhook)")
+	fs.StringVar(&cfg.GroupMapFile, "group-map", cfg.GroupMapFile, "File mapping group IDs to boat keys (and tokens), for gdl requests (disabled if empty)")
+	fs.StringVar(&cfg.MapToken, "map-token", cfg.MapToken, "Token required for spectator map requests (\"map\" and /v1/map; only the admin token is accepted if empty)")
+	fs.IntVar(&cfg.QueueSize, "queue-size", cfg.QueueSize, "Maximum number of live data messages queued for sending on each connection")
+	fs.StringVar(&cfg.QueuePolicy, "queue-policy", cfg.QueuePolicy, "Policy when a connection's queue is full: \"drop-oldest\", \"coalesce\", \"disconnect\", or \"conflate\" (also replacing queued data for the same boat before then)")
+	fs.IntVar(&cfg.WsReadBufferSize, "ws-read-buffer-size", cfg.WsReadBufferSize, "WebSocket read buffer size (bytes) per connection")
+	fs.IntVar(&cfg.WsWriteBufferSize, "ws-write-buffer-size", cfg.WsWriteBufferSize, "WebSocket write buffer size (bytes) per connection")
+	fs.BoolVar(&cfg.WsWriteBufferPool, "ws-write-buffer-pool", cfg.WsWriteBufferPool, "Share WebSocket write buffers between connections, holding them only while writing")
+	fs.BoolVar(&cfg.WsCompression, "ws-compression", cfg.WsCompression, "Negotiate per-message compression with clients that support it")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "Maximum time allowed for writing each message to a client, after which the connection is closed")
+	fs.IntVar(&cfg.TimeSyncInterval, "time-sync-interval", cfg.TimeSyncInterval, "Number of iterations (poll intervals) between time sync messages sent on every connection (0 to disable)")
+	fs.IntVar(&cfg.ClientStatsInterval, "client-stats-interval", cfg.ClientStatsInterval, "Number of iterations (poll intervals) between stats messages sent to each client about its own connection (0 to disable)")
+	fs.DurationVar(&cfg.MaxConnLifetime, "max-conn-lifetime", cfg.MaxConnLifetime, "Maximum connection lifetime, after which clients must reconnect (0 for no limit)")
+	fs.DurationVar(&cfg.MaxSubLifetime, "max-sub-lifetime", cfg.MaxSubLifetime, "Maximum subscription lifetime (including resumed sessions and reconnect tokens), after which clients must subscribe again (0 for no limit)")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "Token required for admin-only requests, e.g. \"group_all\" (admin requests disabled if empty)")
+	fs.BoolVar(&cfg.EmbedTimestamps, "embed-timestamps", cfg.EmbedTimestamps, "Include each boat's data arrival time (\"ts\") in live data messages")
+	corsOrigins := fs.String("cors-origins", "", "Comma-separated origins (e.g. \"https://example.com\", or \"*\" for any) allowed to make cross-origin requests to the REST endpoints")
+	allowIps := fs.String("allow-ips", "", "Comma-separated addresses or CIDR ranges of clients allowed to connect (any if empty)")
+	denyIps := fs.String("deny-ips", "", "Comma-separated addresses or CIDR ranges of clients not allowed to connect, even if in -allow-ips")
+	fs.IntVar(&cfg.BanInvalidKeys, "ban-invalid-keys", cfg.BanInvalidKeys, "Number of invalid or unknown boat keys (or spectator or group IDs) from a client IP address within a minute, at which it's temporarily banned (0 to disable)")
+	fs.IntVar(&cfg.BanConnects, "ban-connects", cfg.BanConnects, "Number of connections from a client IP address, or subscriptions to a boat key, allowed within a minute, beyond which it's temporarily banned (0 to disable)")
+	fs.DurationVar(&cfg.BanDuration, "ban-duration", cfg.BanDuration, "Duration of a first ban, doubling with each further ban within a day")
+	trustedProxies := fs.String("trusted-proxies", "", "Comma-separated addresses or CIDR ranges of reverse proxies trusted to set X-Forwarded-For")
+	authWs := fs.String("auth", "", "Authorization required on /v1/ws upgrade requests: \"bearer:<token>\" or \"basic:<user>:<password>\" (none if empty)")
+	authReplay := fs.String("auth-replay", "", "Authorization required on /v1/ws/replay upgrade requests (defaults to that of -auth)")
+	fs.BoolVar(&cfg.LogKeys, "log-keys", cfg.LogKeys, "Log boat keys verbatim, rather than hashed (for development only)")
+	fs.BoolVar(&cfg.MockSim, "mock-sim", cfg.MockSim, "Use an embedded fake simulator (for development), instead of connecting to one")
+	fs.IntVar(&cfg.StatsInterval, "stats-interval", cfg.StatsInterval, "Number of iterations (poll intervals) between statistics reports")
+	statsSinks := fs.String("stats-sinks", STATS_SINK_LOG, "Comma-separated stats sinks: \"log\", \"statsd\", and/or \"prometheus\"")
+	fs.StringVar(&cfg.StatsdHostPort, "statsd", cfg.StatsdHostPort, "Host:port of statsd server, for the \"statsd\" stats sink")
+	fs.StringVar(&cfg.StatsdPrefix, "statsd-prefix", cfg.StatsdPrefix, "Prefix for statsd metric names")
+	fs.StringVar(&cfg.StatsFile, "stats-file", cfg.StatsFile, "File to keep lifetime connection and message totals in, across restarts (none if empty)")
+	listeners := fs.String("listen", "", "Additional listeners serving the same endpoints, as \"[tls:|h2:|unix:]<address>[,...]\"")
+	fs.StringVar(&cfg.TlsCert, "tls-cert", cfg.TlsCert, "Certificate (PEM) file for tls: and h2: listeners")
+	fs.StringVar(&cfg.TlsKey, "tls-key", cfg.TlsKey, "Private key (PEM) file for tls: and h2: listeners")
+	fs.StringVar(&cfg.Relay, "relay", cfg.Relay, "WebSocket URL of a relay to dial out to, serving the client connections it tunnels (disabled if empty)")
+	fs.StringVar(&cfg.RelayToken, "relay-token", cfg.RelayToken, "Bearer token to authenticate to the relay with")
+	fs.StringVar(&cfg.AdminListenHostPort, "admin-listen", cfg.AdminListenHostPort, "Host:port to serve admin endpoints (metrics and debugging) on, separately from the public listener (none if empty)")
+	fs.StringVar(&cfg.AdminListenHostPort, "metrics-listen", cfg.AdminListenHostPort, "Alias for -admin-listen")
+	queuePolicyOverrides := fs.String("queue-policy-overrides", "", "Per-connection-type queue policies, as \"<type>=<policy>[,...]\" (types: bdl, bdl_g, spectator, replay, group_all)")
+
+	// Apply environment variables first, so that the command line overrides them.
+	var envErr error
+	fs.VisitAll(func (f *flag.Flag) {
+		value, exists := lookupEnv(envName(f.Name))
+		if exists && envErr == nil {
+			if err := fs.Set(f.Name, value); err != nil {
+				envErr = errors.New("ERROR: Invalid value for " + envName(f.Name) + ": " + err.Error())
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	positional := fs.Args()
+	if len(positional) == 0 {
+		if listen, exists := lookupEnv(ENV_LISTEN_ADDR); exists {
+			positional = append(positional, listen)
+		}
+		if sim, exists := lookupEnv(ENV_SIM_ADDR); exists && !cfg.MockSim {
+			positional = append(positional, sim)
+		}
+	}
+
+	if cfg.MockSim {
+		if len(positional) != 1 {
+			return nil, errors.New("ERROR: Program requires one argument with -mock-sim: listenHostPort (or " + ENV_LISTEN_ADDR + ")")
+		}
+
+		cfg.ListenHostPort = positional[0]
+	} else {
+		if len(positional) != 2 {
+			return nil, errors.New("ERROR: Program requires two arguments: listenHostPort, connectHostPort (or " + ENV_LISTEN_ADDR + " and " + ENV_SIM_ADDR + ")")
+		}
+
+		cfg.ListenHostPort = positional[0]
+		cfg.ConnectHostPort = positional[1]
+	}
+
+	switch cfg.ClusterRole {
+	case CLUSTER_ROLE_NONE, CLUSTER_ROLE_POLLER, CLUSTER_ROLE_EDGE:
+	default:
+		return nil, errors.New("ERROR: Invalid cluster role: " + cfg.ClusterRole)
+	}
+
+	cfg.Sims, err = parseSims(*sims)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	if len(cfg.Sims) > 0 && cfg.ClusterRole != CLUSTER_ROLE_NONE {
+		return nil, errors.New("ERROR: Named simulators aren't supported with clustering")
+	}
+
+	cfg.AlertConns, err = parseAlertConns(*alertConns)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.Actions, err = parseActions(*actions)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	if cfg.Actions != nil && cfg.ClusterRole == CLUSTER_ROLE_EDGE {
+		return nil, errors.New("ERROR: Boat actions aren't supported on cluster edge instances")
+	}
+
+	if cfg.Relay != "" {
+		if u, err := url.Parse(cfg.Relay); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			return nil, errors.New("ERROR: Invalid relay URL: " + cfg.Relay)
+		}
+	}
+
+	if cfg.AlertWebhook != "" {
+		if u, err := url.Parse(cfg.AlertWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("ERROR: Invalid alert webhook URL: " + cfg.AlertWebhook)
+		}
+	}
+
+	if cfg.BanInvalidKeys < 0 || cfg.BanConnects < 0 {
+		return nil, errors.New("ERROR: Ban thresholds must not be negative")
+	}
+
+	if cfg.BanDuration <= 0 {
+		return nil, errors.New("ERROR: Ban duration must be positive")
+	}
+
+	if cfg.WsReadBufferSize < 1 || cfg.WsWriteBufferSize < 1 {
+		return nil, errors.New("ERROR: WebSocket buffer sizes must be positive")
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		return nil, errors.New("ERROR: Write timeout must be positive")
+	}
+
+	if cfg.MaxReqSize < 256 || cfg.MaxReqSize > 65536 {
+		return nil, errors.New("ERROR: Maximum request size must be between 256 and 65536")
+	}
+
+	if cfg.MaxUnknownCmds < 0 {
+		return nil, errors.New("ERROR: Maximum unknown commands must not be negative")
+	}
+
+	if cfg.QueueSize < 1 {
+		return nil, errors.New("ERROR: Queue size must be at least 1")
+	}
+
+	if !isValidShedPolicy(cfg.ShedPolicy) {
+		return nil, errors.New("ERROR: Invalid shed policy: " + cfg.ShedPolicy)
+	}
+
+	if cfg.ShedBudget <= 0.0 || cfg.ShedBudget > 1.0 {
+		return nil, errors.New("ERROR: Shed budget must be greater than 0 and at most 1")
+	}
+
+	if !isValidQueuePolicy(cfg.QueuePolicy) {
+		return nil, errors.New("ERROR: Invalid queue policy: " + cfg.QueuePolicy)
+	}
+
+	cfg.QueuePolicyOverrides, err = parseQueuePolicyOverrides(*queuePolicyOverrides)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.TrustedProxies, err = parseTrustedProxies(*trustedProxies)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.AllowIps, err = parseIpRanges(*allowIps, "allowed client")
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.DenyIps, err = parseIpRanges(*denyIps, "denied client")
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.CorsOrigins, err = parseCorsOrigins(*corsOrigins)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.AuthWs, err = parseAuthSpec(*authWs)
+	if err != nil {
+		return nil, errors.New("ERROR: -auth: " + err.Error())
+	}
+
+	cfg.AuthReplay = cfg.AuthWs
+	if *authReplay != "" {
+		cfg.AuthReplay, err = parseAuthSpec(*authReplay)
+		if err != nil {
+			return nil, errors.New("ERROR: -auth-replay: " + err.Error())
+		}
+	}
+
+	if cfg.PollInterval < POLL_INTERVAL_MIN || cfg.PollInterval > POLL_INTERVAL_MAX {
+		return nil, errors.New("ERROR: Poll interval must be between " + POLL_INTERVAL_MIN.String() + " and " + POLL_INTERVAL_MAX.String())
+	}
+
+	if cfg.BdlPrecisionDist < 0 || cfg.BdlPrecisionDist > BDL_PRECISION_DIST_MAX {
+		return nil, errors.New("ERROR: bdl precision distance must be between 0 and " + strconv.FormatFloat(BDL_PRECISION_DIST_MAX, 'g', -1, 64) + " NM")
+	}
+
+	cfg.Precision, err = loadPrecisionPolicy(*precisionPolicy)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	if cfg.TraceEndpoint != "" {
+		u, err := url.Parse(cfg.TraceEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("ERROR: Invalid OTLP endpoint: " + cfg.TraceEndpoint)
+		}
+	}
+
+	if cfg.TraceSampleRatio < 0 || cfg.TraceSampleRatio > 1 {
+		return nil, errors.New("ERROR: Trace sample ratio must be between 0 and 1")
+	}
+
+	if cfg.NoboatCooldown < 0 {
+		return nil, errors.New("ERROR: \"noboat\" cooldown must not be negative")
+	}
+
+	if cfg.ReconnectTtl < 0 {
+		return nil, errors.New("ERROR: Reconnect token TTL must not be negative")
+	}
+
+	if cfg.MemoryLimit < 0 || cfg.MaxTrackedBoats < 0 || cfg.MaxSessions < 0 {
+		return nil, errors.New("ERROR: Memory limit, maximum tracked boats and maximum sessions must not be negative")
+	}
+
+	if cfg.GroupFetchWorkers < 1 || cfg.GroupFetchWorkers > GROUP_FETCH_WORKERS_MAX {
+		return nil, errors.New("ERROR: Number of group fetch workers must be between 1 and " + strconv.Itoa(GROUP_FETCH_WORKERS_MAX))
+	}
+
+	if cfg.HfRate < 0 || cfg.HfRate > HF_RATE_MAX {
+		return nil, errors.New("ERROR: High-frequency rate must be between 0 and " + strconv.Itoa(HF_RATE_MAX))
+	}
+
+	if cfg.HfRate > 0 && cfg.PollInterval / time.Duration(cfg.HfRate) < HF_INTERVAL_MIN {
+		return nil, errors.New("ERROR: High-frequency rate too high for the poll interval (must be no more often than every " + HF_INTERVAL_MIN.String() + ")")
+	}
+
+	if cfg.HfRate > 0 && cfg.ClusterRole != CLUSTER_ROLE_NONE {
+		return nil, errors.New("ERROR: High-frequency mode isn't supported with clustering")
+	}
+
+	if cfg.InterpRate < 0 || cfg.InterpRate > INTERP_RATE_MAX {
+		return nil, errors.New("ERROR: Interpolation rate must be between 0 and " + strconv.Itoa(INTERP_RATE_MAX))
+	}
+
+	if cfg.InterpRate > 0 && cfg.PollInterval / time.Duration(cfg.InterpRate) < HF_INTERVAL_MIN {
+		return nil, errors.New("ERROR: Interpolation rate too high for the poll interval (must be no more often than every " + HF_INTERVAL_MIN.String() + ")")
+	}
+
+	if cfg.Events && cfg.ClusterRole != CLUSTER_ROLE_NONE {
+		return nil, errors.New("ERROR: Boat events aren't supported with clustering")
+	}
+
+	if cfg.HfDist <= 0.0 || cfg.HfDist > GROUP_VISIBILITY_DIST {
+		return nil, errors.New("ERROR: High-frequency distance must be more than 0 and at most " + strconv.FormatFloat(GROUP_VISIBILITY_DIST, 'g', -1, 64))
+	}
+
+	if cfg.SimGrace < 0 {
+		return nil, errors.New("ERROR: Simulator grace must not be negative")
+	}
+
+	if cfg.HistorySize < 0 || cfg.HistorySize > HISTORY_MAX_SIZE {
+		return nil, errors.New("ERROR: History size must be between 0 and " + strconv.Itoa(HISTORY_MAX_SIZE))
+	}
+
+	if cfg.StatsInterval < 1 {
+		return nil, errors.New("ERROR: Stats interval must be at least 1")
+	}
+
+	cfg.StatsSinks, err = parseStatsSinks(*statsSinks)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	cfg.Listeners, err = parseListeners(*listeners)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	for _, spec := range cfg.Listeners {
+		if spec.isTls() && (cfg.TlsCert == "" || cfg.TlsKey == "") {
+			return nil, errors.New("ERROR: tls: and h2: listeners require -tls-cert and -tls-key")
+		}
+		if spec.Kind != LISTENER_UNIX && spec.Addr == cfg.AdminListenHostPort {
+			return nil, errors.New("ERROR: The admin listener must be separate from the public ones")
+		}
+	}
+
+	if cfg.AdminListenHostPort != "" && cfg.AdminListenHostPort == cfg.ListenHostPort {
+		return nil, errors.New("ERROR: The admin listener must be separate from the public one")
+	}
+
+	for _, sink := range cfg.StatsSinks {
+		if sink == STATS_SINK_PROMETHEUS && cfg.AdminListenHostPort == "" {
+			return nil, errors.New("ERROR: The \"prometheus\" stats sink requires -admin-listen")
+		}
+	}
+
+	switch cfg.RecordFormat {
+	case RECORD_FORMAT_GEOJSON, RECORD_FORMAT_GPX:
+	default:
+		return nil, errors.New("ERROR: Invalid track recording format: " + cfg.RecordFormat)
+	}
+
+	cfg.Geofences, err = loadGeofencesFile(cfg.GeofencesFile)
+	if err != nil {
+		return nil, errors.New("ERROR: Invalid geofences file: " + err.Error())
+	}
+
+	switch cfg.UdpOutputFormat {
+	case UDP_OUTPUT_FORMAT_NMEA, UDP_OUTPUT_FORMAT_JSON:
+	default:
+		return nil, errors.New("ERROR: Invalid UDP output format: " + cfg.UdpOutputFormat)
+	}
+
+	if cfg.UdpOutput != "" {
+		if _, _, err := net.SplitHostPort(cfg.UdpOutput); err != nil {
+			return nil, errors.New("ERROR: Invalid UDP output address: " + cfg.UdpOutput)
+		}
+	}
+
+	return cfg, nil
+}
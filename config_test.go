@@ -0,0 +1,117 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func testLookupEnv(env map[string]string) func(string) (string, bool) {
+	return func (name string) (string, bool) {
+		value, exists := env[name]
+		return value, exists
+	}
+}
+
+func TestParseArgsEnv(t *testing.T) {
+	env := map[string]string {
+		"SNSW_LISTEN_ADDR": "0.0.0.0:8080",
+		"SNSW_SIM_ADDR": "sim:9000",
+		"SNSW_QUEUE_SIZE": "16",
+		"SNSW_SESSION_GRACE": "2m",
+		"SNSW_LOG_KEYS": "true",
+	}
+
+	cfg, err := parseArgsEnv([]string {}, testLookupEnv(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenHostPort != "0.0.0.0:8080" || cfg.ConnectHostPort != "sim:9000" {
+		t.Errorf("Unexpected listen/connect addresses from environment: %s, %s", cfg.ListenHostPort, cfg.ConnectHostPort)
+	}
+	if cfg.QueueSize != 16 || cfg.SessionGrace != 2 * time.Minute || !cfg.LogKeys {
+		t.Errorf("Options not taken from environment!")
+	}
+
+	// The command line takes precedence.
+	cfg, err = parseArgsEnv([]string { "-queue-size", "4", "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.ListenHostPort != "127.0.0.1:80" || cfg.ConnectHostPort != "127.0.0.1:90" || cfg.QueueSize != 4 {
+		t.Errorf("Command line didn't take precedence over environment!")
+	}
+	if cfg.SessionGrace != 2 * time.Minute {
+		t.Errorf("Option not given on command line not taken from environment!")
+	}
+
+	// Without either, the defaults apply.
+	cfg, err = parseArgsEnv([]string { "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.QueueSize != defaultConfig().QueueSize {
+		t.Errorf("Default not applied!")
+	}
+}
+
+func TestParseArgsEnvErrors(t *testing.T) {
+	_, err := parseArgsEnv([]string {}, testLookupEnv(map[string]string { "SNSW_QUEUE_SIZE": "lots", "SNSW_LISTEN_ADDR": ":80", "SNSW_SIM_ADDR": ":90" }))
+	if err == nil {
+		t.Errorf("Invalid environment variable value accepted!")
+	}
+
+	_, err = parseArgsEnv([]string {}, testLookupEnv(map[string]string { "SNSW_LISTEN_ADDR": ":80" }))
+	if err == nil {
+		t.Errorf("Missing simulator address accepted!")
+	}
+
+	_, err = parseArgsEnv([]string { "-mock-sim" }, testLookupEnv(map[string]string { "SNSW_LISTEN_ADDR": ":80", "SNSW_SIM_ADDR": ":90" }))
+	if err != nil {
+		t.Errorf("Simulator address from environment not ignored with -mock-sim: %s", err)
+	}
+
+	_, err = parseArgsEnv([]string { "-otlp-endpoint", "localhost:4318", "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(nil))
+	if err == nil {
+		t.Errorf("OTLP endpoint without scheme accepted!")
+	}
+
+	_, err = parseArgsEnv([]string { "-udp-output-format", "csv", "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(nil))
+	if err == nil {
+		t.Errorf("Invalid UDP output format accepted!")
+	}
+
+	_, err = parseArgsEnv([]string { "-udp-output", "10110", "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(nil))
+	if err == nil {
+		t.Errorf("UDP output address without port accepted!")
+	}
+
+	_, err = parseArgsEnv([]string { "-actions", "course,tack", "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(nil))
+	if err == nil {
+		t.Errorf("Invalid action accepted!")
+	}
+
+	_, err = parseArgsEnv([]string { "-geofences-file", "/nonexistent/geofences.json", "127.0.0.1:80", "127.0.0.1:90" }, testLookupEnv(nil))
+	if err == nil {
+		t.Errorf("Missing geofences file accepted!")
+	}
+}
@@ -0,0 +1,44 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+
+// Panic recovery:
+//
+// A panic while handling a connection's requests (or replaying to it) is
+// recovered from, so that it only affects that connection: the panic is
+// logged with its stack trace, and the connection is closed with an internal
+// error (1011) close code. Its subscription (if any) is then released by the
+// connection's handler as it returns (see wsHandler), as for any other closed
+// connection. Code that may panic while holding _lock must release it with
+// defer, since releasing the subscription takes _lock.
+
+// Must be deferred directly by the goroutine handling the connection.
+func recoverConnPanic(conn *WsConn) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	log.Printf("Recovered from panic on connection from %s: %v\n%s", conn.RemoteIp, r, debug.Stack())
+	conn.CloseWithError()
+}
@@ -0,0 +1,105 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+
+// Per-connection statistics:
+//
+// If enabled (-client-stats-interval), every subscribed connection is
+// periodically sent its own delivery statistics, so that client apps can warn
+// users about poor connectivity:
+//
+// {"type":"stats","dropped":<n>,"coalesced":<n>,"conflated":<n>,"queued":<n>,"rtt":<ms>}
+//
+// "dropped", "coalesced" and "conflated" are the numbers of live data messages
+// so far not sent to the client due to its queue policy (see ws-conn.go), and
+// "queued" is the number of messages waiting to be sent. "rtt" is the most
+// recently measured round trip time, from a WebSocket ping sent along with each
+// stats message (so it's absent from the first one, or if the client hasn't
+// answered any ping yet).
+
+type ConnStatsMsg struct {
+	Type string `json:"type"`
+	Dropped uint64 `json:"dropped"`
+	Coalesced uint64 `json:"coalesced"`
+	Conflated uint64 `json:"conflated"`
+	Queued int `json:"queued"`
+	Rtt float64 `json:"rtt,omitempty"` // Milliseconds
+}
+
+
+// Called (with _lock held) once per main loop iteration, to send stats (and pings) every -client-stats-interval iterations.
+func updateConnStats(iterCount int64) {
+	if _config.ClientStatsInterval <= 0 || iterCount % int64(_config.ClientStatsInterval) != 0 {
+		return
+	}
+
+	for conn := range _conns {
+		conn.SendJSON(conn.stats()) // Any failure will be picked up when next sending live data.
+		conn.SendPing()
+	}
+}
+
+func (wc *WsConn) stats() *ConnStatsMsg {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	msg := &ConnStatsMsg {
+		Type: "stats",
+		Dropped: wc.Dropped,
+		Coalesced: wc.Coalesced,
+		Conflated: wc.Conflated,
+		Queued: len(wc.queue),
+	}
+
+	rttUs := atomic.LoadInt64(&wc.rttUs)
+	if rttUs > 0 {
+		msg.Rtt = math.Round(float64(rttUs) / 10.0) / 100.0 // To nearest 10us
+	}
+
+	return msg
+}
+
+// Ping payload: the time the ping was written (Unix time in ns), for measuring RTT once answered.
+func pingPayload(now time.Time) []byte {
+	return []byte(strconv.FormatInt(now.UnixNano(), 10))
+}
+
+// Handles a pong from the client (on its reader goroutine), measuring RTT from the ping it answers.
+func (wc *WsConn) pongHandler(appData string) error {
+	sent, err := strconv.ParseInt(appData, 10, 64)
+	if err != nil {
+		return nil // Not one of ours (clients may send unsolicited pongs).
+	}
+
+	rttUs := time.Now().Sub(time.Unix(0, sent)).Microseconds()
+	if rttUs < 0 {
+		return nil
+	} else if rttUs == 0 {
+		rttUs = 1 // Since 0 means not measured
+	}
+
+	atomic.StoreInt64(&wc.rttUs, rttUs)
+	return nil
+}
@@ -0,0 +1,108 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+
+// CORS:
+//
+// Browsers don't apply CORS to WebSocket upgrades (which are accepted from any
+// origin), but they do to plain HTTP requests, such as those to the REST
+// endpoints served alongside the WebSocket ones (/v1/version, and those on the
+// admin listener; see admin.go). Requests from the origins given with -cors-origins ("*" for any)
+// are answered with the CORS headers allowing browsers to read responses, and
+// preflight (OPTIONS) requests from them are answered directly, without
+// reaching the endpoint. Preflight requests from other origins are refused.
+
+const CORS_ALLOW_METHODS = "GET, POST, DELETE"
+const CORS_ALLOW_HEADERS = "Authorization, Content-Type"
+const CORS_MAX_AGE = "600" // Seconds preflight responses may be cached for
+
+
+// Parses the comma-separated list of allowed origins.
+func parseCorsOrigins(s string) ([]string, error) {
+	origins := make([]string, 0)
+	if s == "" {
+		return origins, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "*" {
+			u, err := url.Parse(item)
+			if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+				return nil, errors.New("Invalid CORS origin: " + item)
+			}
+			item = u.Scheme + "://" + u.Host
+		}
+
+		origins = append(origins, item)
+	}
+
+	return origins, nil
+}
+
+func isCorsOriginAllowed(origin string) bool {
+	for _, allowed := range _config.CorsOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// Wraps a handler with CORS handling (see above).
+func withCors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := isCorsOriginAllowed(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !allowed {
+			if preflight {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", CORS_ALLOW_METHODS)
+			w.Header().Set("Access-Control-Allow-Headers", CORS_ALLOW_HEADERS)
+			w.Header().Set("Access-Control-Max-Age", CORS_MAX_AGE)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+
+func TestCors(t *testing.T) {
+	prevOrigins := _config.CorsOrigins
+	_config.CorsOrigins = []string { "https://example.com" }
+	defer func() { _config.CorsOrigins = prevOrigins }()
+
+	reached := 0
+	h := withCors(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) { reached++ }))
+
+	serve := func (method string, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/metrics", nil)
+		r.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodOptions, "https://example.com")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" || w.Header().Get("Access-Control-Allow-Methods") == "" || reached != 0 {
+		t.Errorf("Unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	w = serve(http.MethodGet, "https://example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" || reached != 1 {
+		t.Errorf("Allowed origin not allowed: %v", w.Header())
+	}
+
+	if w = serve(http.MethodOptions, "https://evil.example"); w.Code != http.StatusForbidden || reached != 1 {
+		t.Errorf("Preflight from other origin not refused: %d", w.Code)
+	}
+
+	if w = serve(http.MethodGet, "https://evil.example"); w.Header().Get("Access-Control-Allow-Origin") != "" || reached != 2 {
+		t.Errorf("Other origin allowed: %v", w.Header())
+	}
+}
+
+func TestParseCorsOrigins(t *testing.T) {
+	origins, err := parseCorsOrigins("https://example.com/, *, http://localhost:8080")
+	if err != nil || len(origins) != 3 || origins[0] != "https://example.com" || origins[1] != "*" || origins[2] != "http://localhost:8080" {
+		t.Errorf("Unexpected origins: %v (%v)", origins, err)
+	}
+
+	for _, s := range []string { "example.com", "https://example.com/path" } {
+		if _, err := parseCorsOrigins(s); err == nil {
+			t.Errorf("Invalid origin accepted: %s", s)
+		}
+	}
+}
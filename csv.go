@@ -0,0 +1,83 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"strconv"
+	"github.com/gorilla/websocket"
+)
+
+
+// CSV output:
+//
+// On connections with the SUBPROTOCOL_CSV subprotocol (see wire-format.go),
+// each live data message for a single boat (bdl, bdl_x, or the subscribed
+// boat of bdl_g) is sent as one compact text message, "lat,lon,ctw,stw,cog,sog"
+// (e.g. "49.2758,-123.1852,270.5,6.2,268.1,6.4"), for small clients (e.g.
+// microcontroller instrument displays) that can't easily parse JSON. Values
+// are as in JSON (precision, units), and no other fields are sent.
+//
+// Other messages (acknowledgements, errors, and messages for several boats,
+// e.g. group_all) are sent as JSON, which clients can tell apart by their
+// leading "{".
+
+type CsvEncoder struct {}
+
+
+func (*CsvEncoder) Encode(data []byte) (int, [][]byte, error) {
+	msgs, err := splitEncMsgs(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	out := make([][]byte, 0, len(msgs))
+	for _, m := range msgs {
+		live, err := decodeEncLiveMsg(m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if live == nil || live.Boats != nil {
+			out = append(out, m)
+			continue
+		}
+
+		own := &live.BoatDataLiveRespMsg
+		if live.You != nil {
+			own = live.You
+		}
+		out = append(out, appendCsvBoat(nil, own))
+	}
+
+	return websocket.TextMessage, out, nil
+}
+
+func (*CsvEncoder) DecodeReq(data []byte, maxDepth int) ([]byte, error) {
+	return data, nil
+}
+
+// Appends a boat's "lat,lon,ctw,stw,cog,sog" line (with no line ending).
+func appendCsvBoat(buf []byte, b *BoatDataLiveRespMsg) []byte {
+	for i, v := range []float64 { b.Lat, b.Lon, b.Ctw, b.Stw, b.Cog, b.Sog } {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendFloat(buf, v, 'f', -1, 64)
+	}
+	return buf
+}
@@ -0,0 +1,140 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+
+// Deleted boats:
+//
+// A "noboat" response means the simulator doesn't know the boat (e.g. it was
+// deleted), unlike a failed or timed out request, which may just be a short
+// outage (see sim-outage.go). Connections subscribed to a boat that disappears
+// while being streamed are sent a terminal "boat_deleted" error before being
+// closed (with close code 4004), and the boat key is put on cooldown for
+// -noboat-cooldown: subscriptions to it are rejected as for unknown boats,
+// without asking the simulator, and it's left out of polls for group members.
+// Each further "noboat" for the same key (e.g. as clients keep re-subscribing
+// once the cooldown is over) doubles the cooldown, up to NOBOAT_COOLDOWN_MAX,
+// while any data for it starts over.
+
+const ERR_BOAT_DELETED = "boat_deleted"
+
+const NOBOAT_COOLDOWN_MAX = 1 * time.Hour
+
+type NoboatEntry struct {
+	Count int // Consecutive "noboat" responses
+	Until time.Time // End of the cooldown
+}
+
+// Boat keys with "noboat" responses (guarded by _lock)
+var _noboatKeys = make(map[string]*NoboatEntry)
+
+var _countBoatDeletedCloses int64 = 0 // Guarded by _lock
+var _countNoboatRejects int64 = 0 // Guarded by _lock
+
+
+// Notes (with _lock held) a "noboat" response for a boat key, starting or extending its cooldown.
+func noteNoboat(boatKey string, now time.Time) {
+	if _config.NoboatCooldown <= 0 {
+		return
+	}
+
+	entry, exists := _noboatKeys[boatKey]
+	if !exists {
+		entry = &NoboatEntry {}
+		_noboatKeys[boatKey] = entry
+	}
+
+	entry.Count++
+
+	cooldown := _config.NoboatCooldown
+	for i := 1; i < entry.Count && cooldown < NOBOAT_COOLDOWN_MAX; i++ {
+		cooldown *= 2
+	}
+	cooldown = min(cooldown, max(NOBOAT_COOLDOWN_MAX, _config.NoboatCooldown))
+	entry.Until = now.Add(cooldown)
+
+	log.Println("Boat key on cooldown for " + cooldown.String() + " after " + strconv.Itoa(entry.Count) + " consecutive \"noboat\" response(s): " + boatKey)
+}
+
+// Notes (with _lock held) this iteration's boat data, forgetting any "noboat" responses for the
+// boats with data, and any keys whose cooldown ended long enough ago that they're no longer counted.
+func updateNoboatKeys(resps map[string]BoatDataLiveRespMsg, noBoats map[string]bool, now time.Time) {
+	for boatKey, _ := range noBoats {
+		noteNoboat(boatKey, now)
+	}
+
+	if len(_noboatKeys) == 0 {
+		return
+	}
+
+	for boatKey, entry := range _noboatKeys {
+		if _, exists := resps[boatKey]; exists || now.Sub(entry.Until) > NOBOAT_COOLDOWN_MAX {
+			delete(_noboatKeys, boatKey)
+		}
+	}
+}
+
+// Whether (with _lock held) a boat key is on cooldown after a "noboat" response.
+func isNoboatKey(boatKey string, now time.Time) bool {
+	entry, exists := _noboatKeys[boatKey]
+	return exists && now.Before(entry.Until)
+}
+
+// Number of boat keys (with _lock held) currently on cooldown.
+func countNoboatKeys(now time.Time) int {
+	n := 0
+	for _, entry := range _noboatKeys {
+		if now.Before(entry.Until) {
+			n++
+		}
+	}
+	return n
+}
+
+// Rejects a subscription (as for an unknown boat) to a boat key on cooldown, returning whether
+// it was rejected. Must be called without _lock held.
+func rejectIfNoboatKey(conn *WsConn, boatKey string) bool {
+	_lock.Lock()
+	noboat := isNoboatKey(boatKey, time.Now())
+	if noboat {
+		_countNoboatRejects++
+	}
+	_lock.Unlock()
+
+	if !noboat {
+		return false
+	}
+
+	log.Println("Client (" + conn.RemoteIp + ") sent boat key on \"noboat\" cooldown: " + boatKey)
+	rejectUnknownBoat(conn)
+	return true
+}
+
+// Closes (with _lock held) a connection whose boat has disappeared from the simulator.
+func closeBoatDeleted(conn *WsConn) {
+	_countBoatDeletedCloses++
+
+	sendErrorMsg(conn, ERR_BOAT_DELETED, "Boat deleted")
+	conn.CloseWithReason(CLOSE_BOAT_DELETED, "No such boat")
+}
@@ -0,0 +1,111 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+
+// Draining:
+//
+// Before maintenance, the connector can be put into "drain" mode via the
+// admin listener. Existing subscriptions continue to be served, but new ones
+// (including resumed sessions and replays) are rejected with an error telling
+// the client when to retry, so that traffic shifts to other instances:
+//
+// {"type":"error","error":"draining","msg":"...","retry_after":<seconds>}
+//
+// - POST /drain[?retry_after=<seconds>]: Start draining.
+// - DELETE /drain: Stop draining.
+// - GET /drain: Current state, with the number of remaining subscriptions.
+
+const ERR_DRAINING = "draining"
+
+const DRAIN_DEFAULT_RETRY_AFTER = 5 // Seconds
+
+type DrainStatusMsg struct {
+	Draining bool `json:"draining"`
+	RetryAfter int64 `json:"retry_after,omitempty"`
+	Conns int `json:"conns"`
+	Sessions int `json:"sessions"`
+}
+
+var _draining atomic.Bool
+var _drainRetryAfter atomic.Int64
+
+
+// Rejects a subscription request (closing the connection) if draining, returning whether it was rejected.
+func rejectIfDraining(conn *WsConn) bool {
+	if !_draining.Load() {
+		return false
+	}
+
+	sendRetryErrorMsg(conn, ERR_DRAINING, "Server is draining; please reconnect", int(_drainRetryAfter.Load()))
+	conn.CloseWithReason(CLOSE_SERVER_SHUTDOWN, "Server draining")
+	return true
+}
+
+func drainHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		retryAfter := int64(DRAIN_DEFAULT_RETRY_AFTER)
+		if s := r.URL.Query().Get("retry_after"); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v < 0 {
+				http.Error(w, "Invalid retry_after", http.StatusBadRequest)
+				return
+			}
+			retryAfter = v
+		}
+
+		_drainRetryAfter.Store(retryAfter)
+		if !_draining.Swap(true) {
+			log.Println("Draining: rejecting new subscriptions (retry after " + strconv.FormatInt(retryAfter, 10) + "s).")
+		}
+
+	case http.MethodDelete:
+		if _draining.Swap(false) {
+			log.Println("No longer draining.")
+		}
+
+	case http.MethodGet:
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := &DrainStatusMsg {
+		Draining: _draining.Load(),
+	}
+	if status.Draining {
+		status.RetryAfter = _drainRetryAfter.Load()
+	}
+
+	_lock.Lock()
+	status.Conns = len(_conns)
+	status.Sessions = len(_sessions)
+	_lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
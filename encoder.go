@@ -0,0 +1,124 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+)
+
+
+// Encoders:
+//
+// Each connection has an Encoder for its wire format (see wire-format.go),
+// selected by its subprotocol, which turns the JSON messages queued for it
+// into what's actually written, in its writer. Everything before that
+// (building messages, the hub's fan-out, queueing, batching) only ever deals
+// with JSON, so adding a wire format only takes an Encoder, registered in
+// _encoders (and its subprotocol, in _subprotocols).
+//
+// Formats meant for other software or small clients (NMEA, Signal K and CSV)
+// only convert live data messages, and send other messages (acknowledgements,
+// errors, etc.) as the usual JSON.
+
+type Encoder interface {
+	// Converts a queued message (or, for batching connections, a JSON array of
+	// them; see batching.go) to the WebSocket messages to write, of the returned
+	// type, which may be none.
+	Encode(data []byte) (int, [][]byte, error)
+
+	// Converts a binary request message to JSON (text requests always being JSON).
+	DecodeReq(data []byte, maxDepth int) ([]byte, error)
+}
+
+type JsonEncoder struct {}
+type MsgpackEncoder struct {}
+
+// A live data message, as decoded by encoders converting it to some other format
+type EncLiveMsg struct {
+	Type string `json:"type"`
+	BoatDataLiveRespMsg // bdl, bdl_x
+	*BoatDataExt // bdl_x
+	You *BoatDataLiveRespMsg `json:"you"` // bdl_g
+	Others map[string][]float64 `json:"others"` // bdl_g
+	Boats map[string]BoatDataLiveRespMsg `json:"boats"` // group_all, gdl
+}
+
+var _jsonEncoder = &JsonEncoder {}
+
+// By subprotocol
+var _encoders = map[string]Encoder {
+	SUBPROTOCOL_JSON: _jsonEncoder,
+	SUBPROTOCOL_MSGPACK: &MsgpackEncoder {},
+	SUBPROTOCOL_NMEA: &NmeaEncoder {},
+	SUBPROTOCOL_SIGNALK: &SignalKEncoder {},
+	SUBPROTOCOL_CSV: &CsvEncoder {},
+}
+
+
+// Returns the encoder for the subprotocol negotiated for a connection (JSON, if none).
+func encoderFor(subprotocol string) Encoder {
+	enc, exists := _encoders[subprotocol]
+	if !exists {
+		return _jsonEncoder
+	}
+	return enc
+}
+
+func (*JsonEncoder) Encode(data []byte) (int, [][]byte, error) {
+	return websocket.TextMessage, [][]byte { data }, nil
+}
+
+func (*JsonEncoder) DecodeReq(data []byte, maxDepth int) ([]byte, error) {
+	return data, nil
+}
+
+func (*MsgpackEncoder) Encode(data []byte) (int, [][]byte, error) {
+	m, err := jsonToMsgpack(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return websocket.BinaryMessage, [][]byte { m }, nil
+}
+
+func (*MsgpackEncoder) DecodeReq(data []byte, maxDepth int) ([]byte, error) {
+	return msgpackToJson(data, maxDepth)
+}
+
+// Splits a queued message into the messages it holds (more than one if it's a batch).
+func splitEncMsgs(data []byte) ([]json.RawMessage, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		return []json.RawMessage { data }, nil
+	}
+
+	var msgs []json.RawMessage
+	err := json.Unmarshal(data, &msgs)
+	return msgs, err
+}
+
+// Decodes a message for conversion, returning nil if it isn't live data (and so is to be sent as JSON).
+func decodeEncLiveMsg(data []byte) (*EncLiveMsg, error) {
+	var m EncLiveMsg
+	err := json.Unmarshal(data, &m)
+	if err != nil || m.Type != "" {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,159 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+func TestEncoderFor(t *testing.T) {
+	if encoderFor("") != _jsonEncoder || encoderFor("other") != _jsonEncoder {
+		t.Fatal("Expected JSON by default")
+	}
+	if _, ok := encoderFor(SUBPROTOCOL_MSGPACK).(*MsgpackEncoder); !ok {
+		t.Fatal("Expected MessagePack encoder")
+	}
+	if _, ok := encoderFor(SUBPROTOCOL_NMEA).(*NmeaEncoder); !ok {
+		t.Fatal("Expected NMEA encoder")
+	}
+	if _, ok := encoderFor(SUBPROTOCOL_SIGNALK).(*SignalKEncoder); !ok {
+		t.Fatal("Expected Signal K encoder")
+	}
+	if _, ok := encoderFor(SUBPROTOCOL_CSV).(*CsvEncoder); !ok {
+		t.Fatal("Expected CSV encoder")
+	}
+}
+
+func TestEncoderPassthrough(t *testing.T) {
+	msg := `{"type":"subscribed","version":1}`
+
+	for _, enc := range []Encoder { &NmeaEncoder {}, &SignalKEncoder {}, &CsvEncoder {} } {
+		msgType, out, err := enc.Encode([]byte(msg))
+		if err != nil || msgType != websocket.TextMessage || len(out) != 1 || string(out[0]) != msg {
+			t.Fatalf("Unexpected: %d %q %v", msgType, out, err)
+		}
+	}
+}
+
+func TestNmeaSentences(t *testing.T) {
+	if s := nmeaSentence("GPGLL,4916.45,N,12311.12,W,225444,A"); s != "$GPGLL,4916.45,N,12311.12,W,225444,A*31" {
+		t.Fatalf("Unexpected checksum: %s", s)
+	}
+	if p := nmeaLatLon(-33.5, 151.25); p != "3330.0000,S,15115.0000,E" {
+		t.Fatalf("Unexpected position: %s", p)
+	}
+	if p := nmeaLatLon(49.99999999, -0.5); p != "5000.0000,N,00030.0000,W" {
+		t.Fatalf("Unexpected position: %s", p)
+	}
+
+	m, err := decodeEncLiveMsg([]byte(`{"lat":-33.5,"lon":151.25,"ctw":90,"stw":6,"cog":95,"sog":6.5,"lws":12,"ha":-45}`))
+	if err != nil || m == nil {
+		t.Fatalf("Unexpected: %v %v", m, err)
+	}
+
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	s := createNmeaSentences(m, now)
+	if len(s) != 3 {
+		t.Fatalf("Unexpected sentences: %q", s)
+	}
+	if !strings.HasPrefix(s[0], "$GPRMC,050607.00,A,3330.0000,S,15115.0000,E,6.5,95.0,040326,,,A*") {
+		t.Fatalf("Unexpected RMC: %s", s[0])
+	}
+	if !strings.HasPrefix(s[1], "$IIVHW,90.0,T,,M,6.0,N,11.1,K*") {
+		t.Fatalf("Unexpected VHW: %s", s[1])
+	}
+	if !strings.HasPrefix(s[2], "$IIMWV,315.0,T,12.0,N,A*") {
+		t.Fatalf("Unexpected MWV: %s", s[2])
+	}
+
+	m, err = decodeEncLiveMsg([]byte(`{"you":{"lat":1,"lon":2},"others":{"b":[1.1,2.1,45]}}`))
+	if err != nil || m == nil {
+		t.Fatalf("Unexpected: %v %v", m, err)
+	}
+	s = createNmeaSentences(m, now)
+	if len(s) != 4 || !strings.HasPrefix(s[3], "!AIVDM,") {
+		t.Fatalf("Unexpected sentences: %q", s)
+	}
+
+	_, out, err := (&NmeaEncoder {}).Encode([]byte(`[{"boats":{"a":{"lat":1,"lon":2},"b":{"lat":3,"lon":4}}},{"type":"pong"}]`))
+	if err != nil || len(out) != 2 || strings.Count(string(out[0]), "!AIVDM,") != 2 || !strings.HasSuffix(string(out[0]), "\r\n") || string(out[1]) != `{"type":"pong"}` {
+		t.Fatalf("Unexpected: %q %v", out, err)
+	}
+}
+
+func TestSignalKDeltas(t *testing.T) {
+	_, out, err := (&SignalKEncoder {}).Encode([]byte(`{"you":{"lat":10,"lon":-20,"ctw":180,"stw":10,"cog":90,"sog":10,"lws":20,"ha":270},"others":{"b":[1,2,90,5],"a":[3,4,180]}}`))
+	if err != nil || len(out) != 3 {
+		t.Fatalf("Unexpected: %q %v", out, err)
+	}
+
+	var deltas []SignalKDelta
+	for _, d := range out {
+		var delta SignalKDelta
+		if err := json.Unmarshal(d, &delta); err != nil {
+			t.Fatal(err)
+		}
+		deltas = append(deltas, delta)
+	}
+
+	if deltas[0].Context != SIGNALK_SELF || deltas[1].Context != signalKContext("a") || deltas[2].Context != signalKContext("b") {
+		t.Fatalf("Unexpected contexts: %v", deltas)
+	}
+	if len(deltas[1].Updates[0].Values) != 2 || len(deltas[2].Updates[0].Values) != 3 {
+		t.Fatalf("Unexpected values: %v", deltas)
+	}
+
+	values := map[string]interface{} {}
+	for _, v := range deltas[0].Updates[0].Values {
+		values[v.Path] = v.Value
+	}
+
+	pos := values["navigation.position"].(map[string]interface{})
+	if pos["latitude"] != 10.0 || pos["longitude"] != -20.0 {
+		t.Fatalf("Unexpected position: %v", pos)
+	}
+	if !approxEq(values["navigation.courseOverGroundTrue"].(float64), 1.570796) ||
+		!approxEq(values["navigation.speedOverGround"].(float64), 5.144444) ||
+		!approxEq(values["environment.wind.speedTrue"].(float64), 10.288889) ||
+		!approxEq(values["environment.wind.angleTrueWater"].(float64), -1.570796) {
+		t.Fatalf("Unexpected values: %v", values)
+	}
+	if _, err := time.Parse(time.RFC3339, deltas[0].Updates[0].Timestamp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func approxEq(a float64, b float64) bool {
+	return math.Abs(a - b) < 1e-6
+}
+
+func TestCsvEncoder(t *testing.T) {
+	_, out, err := (&CsvEncoder {}).Encode([]byte(`[{"lat":49.2758,"lon":-123.1852,"ctw":270.5,"stw":6.2,"cog":268,"sog":6.4,"lws":10,"ha":30},{"you":{"lat":-1.5,"lon":2},"others":{"b":[1,2,3]}},{"boats":{"a":{"lat":1}}}]`))
+	if err != nil || len(out) != 3 {
+		t.Fatalf("Unexpected: %q %v", out, err)
+	}
+	if string(out[0]) != "49.2758,-123.1852,270.5,6.2,268,6.4" || string(out[1]) != "-1.5,2,0,0,0,0" || string(out[2]) != `{"boats":{"a":{"lat":1}}}` {
+		t.Fatalf("Unexpected: %q", out)
+	}
+}
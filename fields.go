@@ -0,0 +1,202 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+)
+
+
+// Field selection:
+//
+// A bdl, bdl_g or bdl_x request may list the fields of live boat data that
+// the client wants (e.g. "fields":["lat","lon","sog"]), so that minimal
+// trackers don't pay for data they don't use. Only those fields are then sent
+// for the subscribed boat (for bdl_g, in "you", with "others" unchanged), plus
+// "age" for last known data, "interp" for interpolated data, "wpt" for
+// waypoints (see waypoint.go) and "seq" for sessions, which are never left
+// out.
+// Without "fields", all fields are sent as usual.
+
+const ERR_INVALID_FIELDS = "invalid_fields"
+
+// Fields that may be selected, in the order they're listed in a subscription acknowledgement.
+var _boatDataFields = []string { "lat", "lon", "ctw", "stw", "cog", "sog", "lws", "ha", "ts" }
+var _boatDataExtFields = []string { "hdg", "heel", "heave", "leeway", "rudder", "cur_set", "cur_drift", "sail" }
+
+// Live data message with only the selected fields
+type FieldsMsg map[string]interface{}
+
+
+// Checks the fields requested by a client, returning the set of them (nil if all fields are wanted) and whether they're all valid.
+func parseFields(fields []string, extended bool) (map[string]bool, bool) {
+	if len(fields) == 0 {
+		return nil, true
+	}
+
+	valid := make(map[string]bool)
+	for _, name := range _boatDataFields {
+		valid[name] = true
+	}
+	if extended {
+		for _, name := range _boatDataExtFields {
+			valid[name] = true
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range fields {
+		if !valid[name] {
+			return nil, false
+		}
+		selected[name] = true
+	}
+
+	return selected, true
+}
+
+// Rejects a subscription request (closing the connection) if it asks for invalid fields, otherwise returning the set of fields to send.
+func reqFields(req *ReqMsg, conn *WsConn, extended bool) (map[string]bool, bool) {
+	fields, ok := parseFields(req.Fields, extended)
+	if !ok {
+		log.Println("Client (" + conn.RemoteIp + ") requested invalid fields")
+
+		sendErrorMsg(conn, ERR_INVALID_FIELDS, "Invalid or unsupported field requested")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid fields")
+		return nil, false
+	}
+
+	return fields, true
+}
+
+// Lists a set of selected fields in the usual order, for the subscription acknowledgement.
+func listFields(fields map[string]bool) []string {
+	if fields == nil {
+		return nil
+	}
+
+	list := make([]string, 0, len(fields))
+	for _, name := range _boatDataFields {
+		if fields[name] {
+			list = append(list, name)
+		}
+	}
+	for _, name := range _boatDataExtFields {
+		if fields[name] {
+			list = append(list, name)
+		}
+	}
+
+	return list
+}
+
+// Reduces a live data message to the fields selected for a connection (or session), if any.
+func selectFields(connCtx *ConnCtx, msg interface{}) interface{} {
+	if connCtx.Fields == nil {
+		return msg
+	}
+
+	switch m := msg.(type) {
+	case BoatDataLiveRespMsg:
+		return selectBoatDataFields(&m, nil, connCtx.Fields)
+	case *BoatDataExtRespMsg:
+		return selectBoatDataFields(&m.BoatDataLiveRespMsg, m.BoatDataExt, connCtx.Fields)
+	case *BoatGroupRespMsg:
+		fm := FieldsMsg {
+			"you": selectBoatDataFields(&m.ThisBoat, nil, connCtx.Fields),
+			"others": m.OtherBoats,
+		}
+		if m.Ais != nil {
+			fm["ais"] = m.Ais
+		}
+		return fm
+	}
+
+	return msg
+}
+
+func selectBoatDataFields(data *BoatDataLiveRespMsg, ext *BoatDataExt, fields map[string]bool) FieldsMsg {
+	msg := make(FieldsMsg, len(fields) + 1)
+
+	for name := range fields {
+		value, exists := boatDataField(data, ext, name)
+		if exists {
+			msg[name] = value
+		}
+	}
+
+	if data.Age != 0 {
+		msg["age"] = data.Age
+	}
+	if data.Interp {
+		msg["interp"] = true
+	}
+	if data.Wpt != nil {
+		msg["wpt"] = data.Wpt
+	}
+
+	return msg
+}
+
+// Returns the value of a field of live boat data, and whether it's present.
+func boatDataField(data *BoatDataLiveRespMsg, ext *BoatDataExt, name string) (interface{}, bool) {
+	switch name {
+	case "lat":
+		return data.Lat, true
+	case "lon":
+		return data.Lon, true
+	case "ctw":
+		return data.Ctw, true
+	case "stw":
+		return data.Stw, true
+	case "cog":
+		return data.Cog, true
+	case "sog":
+		return data.Sog, true
+	case "lws":
+		return data.Lws, true
+	case "ha":
+		return data.Ha, true
+	case "ts":
+		return data.Ts, data.Ts != 0
+	}
+
+	if ext == nil {
+		return nil, false
+	}
+
+	switch name {
+	case "hdg":
+		return ext.Hdg, true
+	case "heel":
+		return ext.Heel, true
+	case "heave":
+		return ext.Heave, true
+	case "leeway":
+		return ext.Leeway, true
+	case "rudder":
+		return ext.Rudder, true
+	case "cur_set":
+		return ext.CurSet, true
+	case "cur_drift":
+		return ext.CurDrift, true
+	case "sail":
+		return ext.Sail, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,80 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+
+func TestParseFields(t *testing.T) {
+	fields, ok := parseFields(nil, false)
+	if !ok || fields != nil {
+		t.Errorf("Expected all fields for no selection!")
+	}
+
+	fields, ok = parseFields([]string { "sog", "lat", "lon", "lat" }, false)
+	if !ok || len(fields) != 3 || !fields["lat"] || !fields["lon"] || !fields["sog"] {
+		t.Errorf("Unexpected selected fields: %v", fields)
+	}
+
+	list := listFields(fields)
+	if len(list) != 3 || list[0] != "lat" || list[1] != "lon" || list[2] != "sog" {
+		t.Errorf("Unexpected listed fields: %v", list)
+	}
+
+	if _, ok := parseFields([]string { "lat", "hdg" }, false); ok {
+		t.Errorf("Extended field accepted without extended data!")
+	}
+	if _, ok := parseFields([]string { "lat", "hdg" }, true); !ok {
+		t.Errorf("Extended field rejected with extended data!")
+	}
+	if _, ok := parseFields([]string { "lat", "age" }, true); ok {
+		t.Errorf("Unknown field accepted!")
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	fields, _ := parseFields([]string { "lat", "lon", "ts", "hdg" }, true)
+	connCtx := &ConnCtx { Fields: fields }
+
+	resp := BoatDataLiveRespMsg { Lat: 1.5, Lon: -2.5, Sog: 6.0, Age: 30 }
+	expectFieldsJSON(t, selectFields(connCtx, resp), `{"age":30,"lat":1.5,"lon":-2.5}`)
+
+	ext := &BoatDataExtRespMsg { BoatDataLiveRespMsg: resp, BoatDataExt: &BoatDataExt { Hdg: 95.0, Heel: 12.0 } }
+	ext.Age = 0
+	ext.Ts = 1234
+	expectFieldsJSON(t, selectFields(connCtx, ext), `{"hdg":95,"lat":1.5,"lon":-2.5,"ts":1234}`)
+
+	group := &BoatGroupRespMsg { ThisBoat: resp, OtherBoats: map[string][]float64 { "A": { 1.0, 2.0, 90.0 } } }
+	expectFieldsJSON(t, selectFields(connCtx, group), `{"others":{"A":[1,2,90]},"you":{"age":30,"lat":1.5,"lon":-2.5}}`)
+
+	// No selection
+	expectFieldsJSON(t, selectFields(&ConnCtx {}, resp), `{"lat":1.5,"lon":-2.5,"ctw":0,"stw":0,"cog":0,"sog":6,"lws":0,"ha":0,"age":30}`)
+}
+
+func expectFieldsJSON(t *testing.T, msg interface{}, expected string) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != expected {
+		t.Errorf("Expected %s, but got %s", expected, data)
+	}
+}
@@ -0,0 +1,328 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"strconv"
+)
+
+
+// Geofencing alerts:
+//
+// A subscription by boat key (bdl, bdl_g or bdl_x) may register geofences,
+// for e.g. finish lines, exclusion zones or shallow areas, with
+// "geofences":[...] in its request, or replace them later with
+// {"cmd":"geofences","geofences":[...]}. Each geofence is either a circle
+// or a polygon:
+//
+// {"id":"mark","circle":{"lat":<deg>,"lon":<deg>,"radius":<nm>}}
+// {"id":"tss","polygon":[[<lat>,<lon>],...]}
+//
+// Operators may also give geofences applying to every such subscription in a
+// JSON file (an array of the same objects; see -geofences-file), which
+// clients can't replace.
+//
+// Every iteration, each subscribed boat's (full precision) position is
+// checked against the subscription's geofences, and the client is sent
+// {"type":"alert","alert":"geofence","id":"...","event":"..."} when the boat
+// enters ("enter") or exits ("exit") one. If the boat is already inside a
+// geofence when it's first checked, "inside" is sent instead of "enter".
+// State is kept per subscription, so starts afresh on reconnecting, unless
+// the subscription is restored with a reconnect token (see reconnect.go),
+// which also keeps the subscription's own geofences. Last known data (see sim-outage.go) isn't checked, and spectators can't register
+// geofences (operator geofences don't apply to them).
+
+const GEOFENCES_MAX = 16 // Per subscription, from its client
+const GEOFENCE_ID_MAX_LEN = 64
+const GEOFENCE_POLYGON_MAX_VERTICES = 64
+const GEOFENCE_RADIUS_MAX = 1000.0 // nm
+
+const GEOFENCE_EVENT_ENTER = "enter"
+const GEOFENCE_EVENT_EXIT = "exit"
+const GEOFENCE_EVENT_INSIDE = "inside"
+
+const EARTH_RADIUS_NM = 3440.065
+
+type Geofence struct {
+	Id string `json:"id"`
+	Circle *GeofenceCircle `json:"circle,omitempty"`
+	Polygon [][]float64 `json:"polygon,omitempty"` // [lat, lon] vertices
+}
+
+type GeofenceCircle struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Radius float64 `json:"radius"` // nm
+}
+
+type GeofenceState struct {
+	Fences []Geofence // Operator geofences first, then the client's
+	operator int // Number of operator geofences
+	inside map[string]bool // By geofence ID, once checked
+}
+
+type GeofenceAlertMsg struct {
+	Type string `json:"type"`
+	Alert string `json:"alert"`
+	Id string `json:"id"`
+	Event string `json:"event"`
+}
+
+
+// Loads the operator geofences file, if any.
+func loadGeofencesFile(path string) ([]Geofence, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fences []Geofence
+	err = json.Unmarshal(data, &fences)
+	if err != nil {
+		return nil, err
+	}
+
+	return fences, validateGeofences(fences, nil)
+}
+
+// Checks geofences, including that their IDs are unique (also among the given others).
+func validateGeofences(fences []Geofence, others []Geofence) error {
+	ids := make(map[string]bool)
+	for _, f := range others {
+		ids[f.Id] = true
+	}
+
+	for _, f := range fences {
+		if f.Id == "" || len(f.Id) > GEOFENCE_ID_MAX_LEN {
+			return errors.New("Invalid geofence ID")
+		}
+		if ids[f.Id] {
+			return errors.New("Duplicate geofence ID: " + f.Id)
+		}
+		ids[f.Id] = true
+
+		if (f.Circle == nil) == (f.Polygon == nil) {
+			return errors.New("Geofence needs either a circle or a polygon: " + f.Id)
+		}
+
+		if f.Circle != nil {
+			if !validLatLon(f.Circle.Lat, f.Circle.Lon) || !(f.Circle.Radius > 0.0 && f.Circle.Radius <= GEOFENCE_RADIUS_MAX) {
+				return errors.New("Invalid geofence circle: " + f.Id)
+			}
+			continue
+		}
+
+		if len(f.Polygon) < 3 || len(f.Polygon) > GEOFENCE_POLYGON_MAX_VERTICES {
+			return errors.New("Geofence polygon needs 3 to " + strconv.Itoa(GEOFENCE_POLYGON_MAX_VERTICES) + " vertices: " + f.Id)
+		}
+		for _, v := range f.Polygon {
+			if len(v) != 2 || !validLatLon(v[0], v[1]) {
+				return errors.New("Invalid geofence polygon vertex: " + f.Id)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validLatLon(lat float64, lon float64) bool {
+	return isFinite(lat) && isFinite(lon) && lat >= -90.0 && lat <= 90.0 && lon >= -180.0 && lon <= 180.0
+}
+
+// Checks the geofences requested by a client (along with the operator's), returning their state (nil if
+// there are none) and whether the request is valid.
+func reqGeofences(req *ReqMsg, conn *WsConn, spectator bool) (*GeofenceState, bool) {
+	if spectator {
+		if len(req.Geofences) > 0 {
+			log.Println("Client (" + conn.RemoteIp + ") requested geofences as a spectator")
+
+			sendErrorMsg(conn, ERR_INVALID_REQUEST, "Geofences not available to spectators")
+			conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid geofences")
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if err := checkReqGeofences(req.Geofences); err != nil {
+		log.Println("Client (" + conn.RemoteIp + ") requested invalid geofences")
+
+		sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, err.Error(), GEOFENCES_MAX)
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid geofences")
+		return nil, false
+	}
+
+	return newGeofenceState(req.Geofences), true
+}
+
+func checkReqGeofences(fences []Geofence) error {
+	if len(fences) > GEOFENCES_MAX {
+		return errors.New("Too many geofences")
+	}
+	return validateGeofences(fences, _config.Geofences)
+}
+
+func newGeofenceState(fences []Geofence) *GeofenceState {
+	if len(_config.Geofences) == 0 && len(fences) == 0 {
+		return nil
+	}
+
+	all := make([]Geofence, 0, len(_config.Geofences) + len(fences))
+	all = append(all, _config.Geofences...)
+	all = append(all, fences...)
+
+	return &GeofenceState { Fences: all, operator: len(_config.Geofences), inside: make(map[string]bool) }
+}
+
+// Returns the client's own geofences.
+func (s *GeofenceState) own() []Geofence {
+	return s.Fences[s.operator:]
+}
+
+// Restores whether the boat was inside geofences (by ID), for those still present.
+func (s *GeofenceState) restoreInside(inside map[string]bool) {
+	if s == nil {
+		return
+	}
+
+	for _, f := range s.Fences {
+		if wasInside, checked := inside[f.Id]; checked {
+			s.inside[f.Id] = wasInside
+		}
+	}
+}
+
+// Replaces a subscription's own geofences (keeping the operator's).
+func wsReqGeofences(conn *WsConn, req *ReqMsg) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	connCtx, exists := _conns[conn]
+	if !exists || connCtx.Spectator || connCtx.GroupAll {
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Not subscribed by boat key")
+		return
+	}
+
+	if err := checkReqGeofences(req.Geofences); err != nil {
+		sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, err.Error(), GEOFENCES_MAX)
+		return
+	}
+
+	state := newGeofenceState(req.Geofences)
+	if connCtx.Geofences != nil {
+		// Geofences kept (by ID) keep their state, so that e.g. adding one doesn't repeat "inside" alerts for the others.
+		state.restoreInside(connCtx.Geofences.inside)
+	}
+
+	connCtx.Geofences = state
+	_conns[conn] = connCtx
+
+	sendReconnectToken(conn, &connCtx)
+}
+
+// Called (with _lock held) once per iteration, to check subscribed boats' positions against their subscriptions' geofences.
+func updateGeofences(resps map[string]BoatDataLiveRespMsg) {
+	for conn, connCtx := range _conns {
+		if connCtx.Geofences == nil {
+			continue
+		}
+
+		resp, exists := resps[connCtx.BoatKey]
+		if !exists || resp.LastKnown {
+			continue
+		}
+
+		alerted := false
+		for _, f := range connCtx.Geofences.Fences {
+			if event := connCtx.Geofences.check(&f, resp.Lat, resp.Lon); event != "" {
+				conn.SendJSON(&GeofenceAlertMsg { Type: "alert", Alert: "geofence", Id: f.Id, Event: event })
+				alerted = true
+			}
+		}
+
+		if alerted {
+			// So that resuming doesn't repeat (or miss) alerts.
+			sendReconnectToken(conn, &connCtx)
+		}
+	}
+}
+
+// Updates whether the boat is inside a geofence, returning the event to alert on, if any.
+func (s *GeofenceState) check(f *Geofence, lat float64, lon float64) string {
+	inside := f.contains(lat, lon)
+	wasInside, checked := s.inside[f.Id]
+	s.inside[f.Id] = inside
+
+	if !checked {
+		if inside {
+			return GEOFENCE_EVENT_INSIDE
+		}
+		return ""
+	}
+
+	if inside && !wasInside {
+		return GEOFENCE_EVENT_ENTER
+	} else if !inside && wasInside {
+		return GEOFENCE_EVENT_EXIT
+	}
+	return ""
+}
+
+func (f *Geofence) contains(lat float64, lon float64) bool {
+	if f.Circle != nil {
+		return greatCircleDistance(lat, lon, f.Circle.Lat, f.Circle.Lon) <= f.Circle.Radius
+	}
+
+	// Ray casting, with longitudes taken relative to the first vertex (so that polygons may cross the antimeridian).
+	from := f.Polygon[0][1]
+	x := relLon(lon, from)
+	inside := false
+	n := len(f.Polygon)
+	for i, j := 0, n - 1; i < n; j, i = i, i + 1 {
+		yi, xi := f.Polygon[i][0], relLon(f.Polygon[i][1], from)
+		yj, xj := f.Polygon[j][0], relLon(f.Polygon[j][1], from)
+		if (yi > lat) != (yj > lat) && x < xi + (lat - yi) * (xj - xi) / (yj - yi) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Returns a longitude relative to another, in [-180, 180).
+func relLon(lon float64, from float64) float64 {
+	return normalizeLon(lon - from)
+}
+
+// Returns the great-circle distance (in nm) between two positions.
+func greatCircleDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	p1 := lat1 * math.Pi / 180.0
+	p2 := lat2 * math.Pi / 180.0
+	dp := p2 - p1
+	dl := (lon2 - lon1) * math.Pi / 180.0
+
+	a := math.Sin(dp / 2.0) * math.Sin(dp / 2.0) + math.Cos(p1) * math.Cos(p2) * math.Sin(dl / 2.0) * math.Sin(dl / 2.0)
+	return 2.0 * EARTH_RADIUS_NM * math.Asin(math.Sqrt(math.Min(a, 1.0)))
+}
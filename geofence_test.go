@@ -0,0 +1,128 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"testing"
+)
+
+
+func TestGeofenceContains(t *testing.T) {
+	circle := &Geofence { Id: "c", Circle: &GeofenceCircle { Lat: 45.0, Lon: -30.0, Radius: 10.0 } }
+	if !circle.contains(45.0, -30.0) || !circle.contains(45.16, -30.0) || circle.contains(45.17, -30.0) {
+		t.Error("Unexpected circle containment")
+	}
+
+	square := &Geofence { Id: "s", Polygon: [][]float64 { { 10.0, 10.0 }, { 10.0, 11.0 }, { 11.0, 11.0 }, { 11.0, 10.0 } } }
+	if !square.contains(10.5, 10.5) || square.contains(10.5, 11.5) || square.contains(11.5, 10.5) || square.contains(10.5, -169.5) {
+		t.Error("Unexpected polygon containment")
+	}
+
+	// Across the antimeridian
+	dateline := &Geofence { Id: "d", Polygon: [][]float64 { { -1.0, 179.0 }, { -1.0, -179.0 }, { 1.0, -179.0 }, { 1.0, 179.0 } } }
+	if !dateline.contains(0.0, 179.5) || !dateline.contains(0.0, -179.5) || dateline.contains(0.0, 0.0) || dateline.contains(0.0, 178.5) {
+		t.Error("Unexpected polygon containment across the antimeridian")
+	}
+}
+
+func TestValidateGeofences(t *testing.T) {
+	valid := []Geofence {
+		{ Id: "c", Circle: &GeofenceCircle { Lat: 45.0, Lon: -30.0, Radius: 10.0 } },
+		{ Id: "p", Polygon: [][]float64 { { 1.0, 1.0 }, { 1.0, 2.0 }, { 2.0, 2.0 } } },
+	}
+	if err := validateGeofences(valid, nil); err != nil {
+		t.Errorf("Valid geofences rejected: %s", err)
+	}
+	if validateGeofences(valid[:1], valid) == nil {
+		t.Error("Duplicate geofence ID accepted")
+	}
+
+	invalid := []Geofence {
+		{ Id: "", Circle: &GeofenceCircle { Radius: 1.0 } },
+		{ Id: "none" },
+		{ Id: "both", Circle: &GeofenceCircle { Radius: 1.0 }, Polygon: [][]float64 { { 1.0, 1.0 }, { 1.0, 2.0 }, { 2.0, 2.0 } } },
+		{ Id: "radius", Circle: &GeofenceCircle { Radius: 0.0 } },
+		{ Id: "lat", Circle: &GeofenceCircle { Lat: 91.0, Radius: 1.0 } },
+		{ Id: "short", Polygon: [][]float64 { { 1.0, 1.0 }, { 1.0, 2.0 } } },
+		{ Id: "vertex", Polygon: [][]float64 { { 1.0, 1.0 }, { 1.0, 2.0 }, { 2.0 } } },
+	}
+	for _, f := range invalid {
+		if validateGeofences([]Geofence { f }, nil) == nil {
+			t.Errorf("Invalid geofence accepted: %+v", f)
+		}
+	}
+}
+
+func TestUpdateGeofences(t *testing.T) {
+	boatKey := "f3000000000000000000000000000000"
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	wsReqGeofences(wc, &ReqMsg {})
+	expectQueued(t, wc, `{"type":"error","error":"invalid_request","msg":"Not subscribed by boat key"}`)
+	wc.queue = nil
+
+	fences := []Geofence {
+		{ Id: "a", Circle: &GeofenceCircle { Lat: 0.0, Lon: 0.0, Radius: 6.0 } },
+		{ Id: "b", Circle: &GeofenceCircle { Lat: 0.0, Lon: 1.0, Radius: 6.0 } },
+	}
+
+	_lock.Lock()
+	_conns[wc] = ConnCtx { BoatKey: boatKey, Geofences: newGeofenceState(fences[:1]) }
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		delete(_conns, wc)
+		_lock.Unlock()
+	}()
+
+	iterate := func (lat float64, lon float64, lastKnown bool) {
+		_lock.Lock()
+		updateGeofences(map[string]BoatDataLiveRespMsg { boatKey: { Lat: lat, Lon: lon, LastKnown: lastKnown } })
+		_lock.Unlock()
+	}
+
+	iterate(0.0, 0.0, false)
+	iterate(0.0, 0.05, false)
+	iterate(0.0, 0.5, true)
+	iterate(0.0, 0.5, false)
+	iterate(0.0, 0.0, false)
+	expectQueued(t, wc,
+		`{"type":"alert","alert":"geofence","id":"a","event":"inside"}`,
+		`{"type":"alert","alert":"geofence","id":"a","event":"exit"}`,
+		`{"type":"alert","alert":"geofence","id":"a","event":"enter"}`)
+	wc.queue = nil
+
+	// Replacing geofences keeps the state of those kept.
+	wsReqGeofences(wc, &ReqMsg { Geofences: fences })
+	iterate(0.0, 0.0, false)
+	iterate(0.0, 0.95, false)
+	expectQueued(t, wc,
+		`{"type":"alert","alert":"geofence","id":"a","event":"exit"}`,
+		`{"type":"alert","alert":"geofence","id":"b","event":"enter"}`)
+	wc.queue = nil
+
+	wsReqGeofences(wc, &ReqMsg { Geofences: []Geofence { { Id: "x" } } })
+	expectQueued(t, wc, `{"type":"error","error":"invalid_request","msg":"Geofence needs either a circle or a polygon: x","limit":16}`)
+
+	wsReqGeofences(wc, &ReqMsg {})
+	_lock.Lock()
+	if _conns[wc].Geofences != nil {
+		t.Error("Geofences not removed")
+	}
+	_lock.Unlock()
+}
@@ -0,0 +1,157 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"time"
+)
+
+
+// Whole-group subscriptions:
+//
+// A "group_all" request (e.g. for race committees) streams the full-precision
+// data of every boat in the group of the given boat, regardless of distance.
+// It must carry the admin token configured with -admin-token, and is disabled
+// if no admin token is configured.
+
+const ERR_UNAUTHORIZED = "unauthorized"
+
+type GroupAllRespMsg struct {
+	Boats map[string]BoatDataLiveRespMsg `json:"boats"`
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+
+func isAdminToken(token string) bool {
+	if _config.AdminToken == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(_config.AdminToken)) == 1
+}
+
+func wsReqGroupAll(req *ReqMsg, conn *WsConn) {
+	if rejectIfDraining(conn) || rejectIfMemoryPressure(conn) {
+		return
+	}
+
+	if !isAdminToken(req.Admin) {
+		log.Println("Client (" + conn.RemoteIp + ") sent group_all request without valid admin token!")
+
+		sendErrorMsg(conn, ERR_UNAUTHORIZED, "Valid admin token required")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Valid admin token required")
+		return
+	}
+
+	if !_boatKeyRegexp.MatchString(req.BoatKey) {
+		log.Println("Client (" + conn.RemoteIp + ") sent invalid boat key!")
+		conn.CloseWithReason(CLOSE_INVALID_KEY, "Invalid boat key")
+		return
+	}
+
+	if rejectIfRefusedKey(conn, req.BoatKey) {
+		return
+	}
+
+	cogSmoother, ok := reqCogSmoothing(req, conn)
+	if !ok {
+		return
+	}
+
+	units, ok := reqUnits(req, conn)
+	if !ok {
+		return
+	}
+
+	priority, ok := reqPriority(req, conn, PRIORITY_HIGH)
+	if !ok {
+		return
+	}
+
+	groupBoats := getBoatsInGroup(context.Background(), req.Sim, req.BoatKey)
+	if groupBoats == nil {
+		conn.CloseWithReason(CLOSE_BACKEND_UNREACHABLE, "Group lookup failed")
+		return
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	if _, exists := _conns[conn]; exists {
+		// Don't allow more than one subscription per connection.
+		conn.CloseWithReason(CLOSE_DUPLICATE_SUBSCRIBE, "Already subscribed")
+		return
+	}
+
+	if rejectIfTooManySubscribers(conn, req.BoatKey) {
+		return
+	}
+
+	connCtx := ConnCtx {
+		BoatKey: req.BoatKey,
+		GroupBoats: groupBoats,
+		GroupAll: true,
+		Sim: req.Sim,
+		CogSmoother: cogSmoother,
+		Units: units,
+		Priority: priority,
+		SubscribedAt: time.Now(),
+	}
+	if !trackConnCtx(&connCtx) {
+		rejectTooManyTracked(conn, req.BoatKey)
+		return
+	}
+
+	_conns[conn] = connCtx
+	conn.SetType(CONN_TYPE_GROUP_ALL)
+
+	_countConns++
+
+	addConnToKey(req.BoatKey, conn)
+
+	sendSubscribedMsg(conn, &connCtx)
+
+	if req.Session {
+		connCtx.Session = startSession(conn, &connCtx)
+		_conns[conn] = connCtx
+	}
+}
+
+func createGroupAllRespMsg(connCtx *ConnCtx, resps map[string]BoatDataLiveRespMsg) *GroupAllRespMsg {
+	boats := make(map[string]BoatDataLiveRespMsg)
+
+	for e := connCtx.GroupBoats.Front(); e != nil; e = e.Next() {
+		boat := e.Value.(*BoatInfo)
+
+		data, exists := resps[boat.BoatKey]
+		if exists {
+			data = connCtx.CogSmoother.smooth(boat.BoatKey, data)
+			if connCtx.Spectator {
+				data = coarsenBoatData(data) // Subscribed by group ID (see group-id.go)
+			}
+			data = connCtx.Units.convert(data)
+			boats[boat.FriendlyName] = data
+		}
+	}
+
+	return &GroupAllRespMsg {
+		Boats: boats,
+	}
+}
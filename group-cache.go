@@ -0,0 +1,122 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+
+// Group membership cache:
+//
+// When many boats of a group subscribe at about the same time, they'd each
+// ask the simulator for the same group's members. Instead, concurrent lookups
+// for a boat share one simulator request, and the result is cached briefly
+// for every member of the group, so that lookups for other members are
+// answered without asking the simulator again.
+//
+// Cached lists are shared between connections, so must never be modified.
+
+const GROUP_CACHE_TTL = 10 * time.Second
+
+type GroupCacheEntry struct {
+	Ready chan int // Closed once Boats has been fetched (and is nil on failure)
+	Boats *list.List
+	Expires time.Time
+}
+
+var _groupCacheLock sync.Mutex
+var _groupCache = make(map[string]*GroupCacheEntry) // By simulator and boat key
+
+
+// Gets the boats in a boat's group, from the cache or else from the simulator.
+func getBoatsInGroup(ctx context.Context, sim string, boatKey string) *list.List {
+	cacheKey := sim + "/" + boatKey
+	now := time.Now()
+
+	_groupCacheLock.Lock()
+	for k, e := range _groupCache {
+		if e.Boats != nil && now.After(e.Expires) {
+			delete(_groupCache, k)
+		}
+	}
+
+	entry, exists := _groupCache[cacheKey]
+	if !exists {
+		entry = &GroupCacheEntry {
+			Ready: make(chan int),
+		}
+		_groupCache[cacheKey] = entry
+	}
+	_groupCacheLock.Unlock()
+
+	if exists {
+		<-entry.Ready
+		return entry.Boats
+	}
+
+	boats := simClient(sim).GetGroupMembers(ctx, boatKey)
+
+	_groupCacheLock.Lock()
+	if boats == nil {
+		// Don't cache failures.
+		delete(_groupCache, cacheKey)
+	} else {
+		entry.Boats = boats
+		entry.Expires = time.Now().Add(GROUP_CACHE_TTL)
+
+		// Also cache for the other members of the group (unless they're being fetched already).
+		cacheGroupMembersLocked(sim, entry)
+	}
+	_groupCacheLock.Unlock()
+
+	close(entry.Ready)
+
+	return boats
+}
+
+// Caches an entry for every member of its group not already cached (or being fetched). Caller must hold _groupCacheLock.
+func cacheGroupMembersLocked(sim string, entry *GroupCacheEntry) int {
+	n := 0
+	for e := entry.Boats.Front(); e != nil; e = e.Next() {
+		memberKey := sim + "/" + e.Value.(*BoatInfo).BoatKey
+		if _, exists := _groupCache[memberKey]; !exists {
+			_groupCache[memberKey] = entry
+			n++
+		}
+	}
+
+	return n
+}
+
+// Caches a group's members (e.g. from the watch list) until the given time, returning whether they were cached for any member.
+func cacheGroup(sim string, boats *list.List, expires time.Time) bool {
+	entry := &GroupCacheEntry {
+		Ready: make(chan int),
+		Boats: boats,
+		Expires: expires,
+	}
+	close(entry.Ready)
+
+	_groupCacheLock.Lock()
+	defer _groupCacheLock.Unlock()
+
+	return cacheGroupMembersLocked(sim, entry) > 0
+}
@@ -0,0 +1,157 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"log"
+)
+
+
+// Group membership fetching:
+//
+// Looking up a boat's group takes a simulator request, which may be slow, so
+// a bdl_g subscription doesn't wait for it: the client is subscribed (and sent
+// "subscribed", with "group_pending":true instead of "group") straight away,
+// with a placeholder group of just its own boat, and the lookup is queued for
+// one of -group-fetch-workers workers. Once the group is known, it replaces
+// the placeholder (so that other boats are included from the next iteration
+// on), and the client is sent:
+//
+// {"type":"group_ready","group":<n>}
+//
+// If the lookup fails, the connection is closed (with close code 4003), as is
+// one subscribing while GROUP_FETCH_QUEUE_SIZE lookups are already waiting.
+// If tracking the group's boats would exceed -max-tracked-boats, the
+// subscription is rejected as overloaded instead (see memory.go).
+
+const GROUP_FETCH_QUEUE_SIZE = 1024
+const GROUP_FETCH_WORKERS_MAX = 64
+
+type GroupFetchJob struct {
+	Conn *WsConn
+	Sim string
+	BoatKey string
+	Pending *list.List // Placeholder group, identifying the subscription to update
+}
+
+type GroupReadyMsg struct {
+	Type string `json:"type"`
+	Group int `json:"group"`
+}
+
+var _groupFetchJobs = make(chan *GroupFetchJob, GROUP_FETCH_QUEUE_SIZE)
+
+
+func groupFetchInit() {
+	for i := 0; i < _config.GroupFetchWorkers; i++ {
+		go groupFetchWorker()
+	}
+}
+
+// Returns a placeholder group, of just the given boat, for a subscription whose group is being fetched.
+func pendingGroup(boatKey string) *list.List {
+	boats := list.New()
+	boats.PushBack(&BoatInfo { BoatKey: boatKey })
+	return boats
+}
+
+// Queues the lookup of a subscription's group (see above). Must be called with _lock held.
+func fetchGroup(conn *WsConn, connCtx *ConnCtx) {
+	select {
+	case _groupFetchJobs <- &GroupFetchJob { conn, connCtx.Sim, connCtx.BoatKey, connCtx.GroupBoats }:
+	default:
+		log.Println("Group lookup queue full; closing connection from " + conn.RemoteIp)
+		conn.CloseWithReason(CLOSE_BACKEND_UNREACHABLE, "Group lookup busy")
+	}
+}
+
+func groupFetchWorker() {
+	for job := range _groupFetchJobs {
+		ctx, span := startSpan(context.Background(), "group.fetch")
+		span.SetAttr("sim", job.Sim)
+
+		boats := getBoatsInGroup(ctx, job.Sim, job.BoatKey)
+		if boats == nil {
+			span.SetError("Group lookup failed")
+		} else {
+			span.SetAttr("boats", boats.Len())
+		}
+
+		_lock.Lock()
+		applyGroup(job, boats)
+		_lock.Unlock()
+
+		span.Finish()
+	}
+}
+
+// Replaces a subscription's placeholder group with the fetched one (or closes the connection if
+// the lookup failed), whether it's still on its connection or in a detached session. Caller must
+// hold _lock.
+func applyGroup(job *GroupFetchJob, boats *list.List) {
+	var conn *WsConn = nil
+	var session *Session = nil
+
+	connCtx, exists := _conns[job.Conn]
+	if exists && connCtx.GroupBoats == job.Pending {
+		conn = job.Conn
+		session = connCtx.Session
+	} else {
+		for _, s := range _sessions {
+			if s.Sub.GroupBoats == job.Pending {
+				session = s
+				break
+			}
+		}
+	}
+
+	if conn == nil && session == nil {
+		return // Unsubscribed in the meantime.
+	}
+
+	if boats == nil {
+		if conn != nil {
+			conn.CloseWithReason(CLOSE_BACKEND_UNREACHABLE, "Group lookup failed")
+		}
+		return // A detached session keeps its placeholder group.
+	}
+
+	// Track the group's boats before untracking the placeholder's, so that the subscribed boat stays tracked.
+	if !trackBoats(job.Sim, boats) {
+		if conn != nil {
+			rejectTooManyTracked(conn, job.BoatKey)
+		}
+		return // As for a failed lookup, a detached session keeps its placeholder group.
+	}
+	untrackBoats(job.Pending)
+
+	if conn != nil {
+		connCtx.GroupBoats = boats
+		connCtx.GroupPending = false
+		_conns[conn] = connCtx
+
+		conn.SendJSON(&GroupReadyMsg { Type: "group_ready", Group: boats.Len() })
+		sendReconnectToken(conn, &connCtx)
+	}
+
+	if session != nil {
+		session.Sub.GroupBoats = boats
+		session.Sub.GroupPending = false
+	}
+}
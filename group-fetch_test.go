@@ -0,0 +1,59 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"testing"
+)
+
+
+func TestApplyGroup(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	connCtx := ConnCtx { BoatKey: "apply-me", GroupBoats: pendingGroup("apply-me"), GroupPending: true }
+	_conns[wc] = connCtx
+	trackConnCtx(&connCtx)
+	defer delete(_conns, wc)
+
+	boats := list.New()
+	boats.PushBack(&BoatInfo { "apply-me", "Me" })
+	boats.PushBack(&BoatInfo { "apply-other", "Other" })
+
+	job := &GroupFetchJob { wc, "", "apply-me", connCtx.GroupBoats }
+	applyGroup(job, boats)
+
+	connCtx = _conns[wc]
+	if connCtx.GroupBoats != boats || connCtx.GroupPending {
+		t.Errorf("Group not applied: %+v", connCtx)
+	}
+	expectQueued(t, wc, `{"type":"group_ready","group":2}`)
+
+	if _trackedBoats["apply-me"].RefCount != 1 || _trackedBoats["apply-other"].RefCount != 1 {
+		t.Errorf("Group's boats not tracked once each")
+	}
+
+	// Applying again (e.g. once resubscribed) has no effect.
+	applyGroup(job, list.New())
+	if _conns[wc].GroupBoats != boats {
+		t.Errorf("Group applied to another subscription")
+	}
+
+	untrackConnCtx(&connCtx)
+}
@@ -0,0 +1,244 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Subscriptions by group ID:
+//
+// A "gdl" request ({"cmd":"gdl","group":"<group_id>","token":"<token>"})
+// subscribes to every boat in a group (e.g. a race) by its public group ID,
+// rather than by the key of one of its boats, for e.g. spectator maps. Group
+// IDs are resolved via a mapping file (-group-map), with lines of
+// "<group_id>,<boat_key>[,<token>]", where the boat key is that of any boat in
+// the group, and the token (if given) is what clients need to present. The
+// admin token (-admin-token) is accepted for any group. The data is sent as for
+// "group_all" (see group-all.go), but at spectator precision (see spectator.go).
+
+const GROUP_MAP_CHECK_INTERVAL = 10 * time.Second
+
+const ERR_UNKNOWN_GROUP_ID = "unknown_group_id"
+
+// The "group" request field: either a flag (e.g. for replaying a whole group), or a group ID (for "gdl").
+type ReqGroup struct {
+	Flag bool
+	Id string
+}
+
+type GroupMapEntry struct {
+	BoatKey string
+	Token string // "" if only the admin token is accepted
+}
+
+var _groupIdRegexp *regexp.Regexp = regexp.MustCompile("^[0-9A-Za-z_-]{1,64}$")
+
+var _groupMapLock sync.Mutex
+var _groupMap = make(map[string]GroupMapEntry)
+var _groupMapModTime time.Time
+var _groupMapChecked time.Time
+
+
+func (g *ReqGroup) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &g.Id)
+	}
+
+	return json.Unmarshal(data, &g.Flag)
+}
+
+func wsReqGroupById(req *ReqMsg, conn *WsConn) {
+	if rejectIfDraining(conn) || rejectIfMemoryPressure(conn) {
+		return
+	}
+
+	entry, exists := lookupGroupMap(req.Group.Id)
+	if !exists {
+		log.Println("Client (" + conn.RemoteIp + ") sent unknown group ID: " + req.Group.Id)
+		noteInvalidKey(conn.RemoteIp) // See abuse.go.
+
+		sendErrorMsg(conn, ERR_UNKNOWN_GROUP_ID, "Unknown group ID")
+		conn.CloseWithReason(CLOSE_INVALID_KEY, "Unknown group ID")
+		return
+	}
+
+	if !isGroupToken(req.Token, entry.Token) {
+		log.Println("Client (" + conn.RemoteIp + ") sent gdl request without valid token for group: " + req.Group.Id)
+
+		sendErrorMsg(conn, ERR_UNAUTHORIZED, "Valid group token required")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Valid group token required")
+		return
+	}
+
+	if rejectIfRefusedKey(conn, entry.BoatKey) {
+		return
+	}
+
+	cogSmoother, ok := reqCogSmoothing(req, conn)
+	if !ok {
+		return
+	}
+
+	units, ok := reqUnits(req, conn)
+	if !ok {
+		return
+	}
+
+	priority, ok := reqPriority(req, conn, PRIORITY_LOW)
+	if !ok {
+		return
+	}
+
+	groupBoats := getBoatsInGroup(context.Background(), req.Sim, entry.BoatKey)
+	if groupBoats == nil {
+		conn.CloseWithReason(CLOSE_BACKEND_UNREACHABLE, "Group lookup failed")
+		return
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	if _, exists := _conns[conn]; exists {
+		// Don't allow more than one subscription per connection.
+		conn.CloseWithReason(CLOSE_DUPLICATE_SUBSCRIBE, "Already subscribed")
+		return
+	}
+
+	if rejectIfTooManySubscribers(conn, entry.BoatKey) {
+		return
+	}
+
+	connCtx := ConnCtx {
+		BoatKey: entry.BoatKey,
+		GroupBoats: groupBoats,
+		GroupAll: true,
+		Spectator: true,
+		Sim: req.Sim,
+		CogSmoother: cogSmoother,
+		Units: units,
+		Priority: priority,
+		SubscribedAt: time.Now(),
+	}
+	if !trackConnCtx(&connCtx) {
+		rejectTooManyTracked(conn, entry.BoatKey)
+		return
+	}
+
+	_conns[conn] = connCtx
+	conn.SetType(CONN_TYPE_GROUP_ALL)
+
+	_countConns++
+
+	addConnToKey(entry.BoatKey, conn)
+
+	sendSubscribedMsg(conn, &connCtx)
+
+	if req.Session {
+		connCtx.Session = startSession(conn, &connCtx)
+		_conns[conn] = connCtx
+	}
+}
+
+func isGroupToken(token string, groupToken string) bool {
+	if isAdminToken(token) {
+		return true
+	}
+
+	return groupToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(groupToken)) == 1
+}
+
+func lookupGroupMap(groupId string) (GroupMapEntry, bool) {
+	if _config.GroupMapFile == "" || !_groupIdRegexp.MatchString(groupId) {
+		return GroupMapEntry {}, false
+	}
+
+	_groupMapLock.Lock()
+	defer _groupMapLock.Unlock()
+
+	// (Re)load the mapping file if it's changed since it was last loaded.
+	now := time.Now()
+	if now.Sub(_groupMapChecked) >= GROUP_MAP_CHECK_INTERVAL {
+		_groupMapChecked = now
+
+		info, err := os.Stat(_config.GroupMapFile)
+		if err != nil {
+			log.Println(err)
+		} else if !info.ModTime().Equal(_groupMapModTime) {
+			m := loadGroupMap(_config.GroupMapFile)
+			if m != nil {
+				_groupMap = m
+				_groupMapModTime = info.ModTime()
+			}
+		}
+	}
+
+	entry, exists := _groupMap[groupId]
+	return entry, exists
+}
+
+func loadGroupMap(path string) map[string]GroupMapEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer f.Close()
+
+	m := make(map[string]GroupMapEntry)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s := strings.Split(line, ",")
+		if len(s) < 2 || len(s) > 3 || !_groupIdRegexp.MatchString(s[0]) || !_boatKeyRegexp.MatchString(s[1]) {
+			log.Println("Ignoring invalid line in group map file for group ID: " + s[0]) // (Not the whole line, which may have a token.)
+			continue
+		}
+
+		entry := GroupMapEntry {
+			BoatKey: s[1],
+		}
+		if len(s) == 3 {
+			entry.Token = s[2]
+		}
+
+		m[s[0]] = entry
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	log.Printf("Loaded %d group IDs from %s\n", len(m), path)
+	return m
+}
@@ -0,0 +1,66 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+
+func TestReqGroup(t *testing.T) {
+	req, err := decodeReqMsg([]byte(`{"cmd":"gdl","group":"race-1"}`))
+	if err != nil || req.Group.Id != "race-1" || req.Group.Flag {
+		t.Errorf("Group ID not decoded: %+v (%v)", req, err)
+	}
+
+	req, err = decodeReqMsg([]byte(`{"cmd":"replay","group":true}`))
+	if err != nil || req.Group.Id != "" || !req.Group.Flag {
+		t.Errorf("Group flag not decoded: %+v (%v)", req, err)
+	}
+
+	_, err = decodeReqMsg([]byte(`{"cmd":"gdl","group":5}`))
+	if err == nil {
+		t.Errorf("Invalid group accepted!")
+	}
+}
+
+func TestLoadGroupMap(t *testing.T) {
+	boatKey := mockSimKey("group-map")
+
+	path := filepath.Join(t.TempDir(), "groups")
+	err := os.WriteFile(path, []byte("# Races\n\nrace-1," + boatKey + ",secret\nrace-2," + boatKey + "\nrace 3," + boatKey + "\nrace-4,nokey\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := loadGroupMap(path)
+	if len(m) != 2 {
+		t.Fatalf("Unexpected group map: %+v", m)
+	}
+	if m["race-1"].BoatKey != boatKey || m["race-1"].Token != "secret" {
+		t.Errorf("Unexpected entry with token: %+v", m["race-1"])
+	}
+	if m["race-2"].BoatKey != boatKey || m["race-2"].Token != "" {
+		t.Errorf("Unexpected entry without token: %+v", m["race-2"])
+	}
+
+	if !isGroupToken("secret", "secret") || isGroupToken("wrong", "secret") || isGroupToken("", "") {
+		t.Errorf("Unexpected group token check!")
+	}
+}
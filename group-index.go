@@ -0,0 +1,141 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"math"
+)
+
+
+// Group spatial indexing:
+//
+// For bdl_g subscriptions, only the other boats in the group within
+// GROUP_VISIBILITY_DIST are included in each live data message. Rather than
+// checking the distance to every other boat in the group for every subscriber
+// (which is quadratic for large groups where many boats are subscribed), the
+// group's boats are placed into a grid once per iteration, and only the boats
+// in the grid cells around the subscriber's boat are checked.
+
+// Size of a grid cell (degrees), in both latitude and longitude.
+const GROUP_INDEX_CELL_DEG = GROUP_VISIBILITY_DIST / 60.0
+
+const GROUP_INDEX_LON_CELLS = int(360.0 / GROUP_INDEX_CELL_DEG)
+
+type GroupIndexEntry struct {
+	BoatKey string
+	FriendlyName string
+	Data *BoatDataLiveRespMsg
+}
+
+type GroupIndexCell struct {
+	Lat int
+	Lon int
+}
+
+type GroupIndex struct {
+	Cells map[GroupIndexCell][]GroupIndexEntry
+}
+
+// Group indexes built for one iteration's boat data, by group membership list.
+type GroupIndexes struct {
+	Resps map[string]BoatDataLiveRespMsg
+	Indexes map[*list.List]*GroupIndex
+}
+
+
+func newGroupIndexes(resps map[string]BoatDataLiveRespMsg) *GroupIndexes {
+	return &GroupIndexes {
+		Resps: resps,
+		Indexes: make(map[*list.List]*GroupIndex),
+	}
+}
+
+// Returns the index for a group's boats, building it if needed. Subscribers in the same group share a (cached) membership list, and so also an index.
+func (gi *GroupIndexes) get(boats *list.List) *GroupIndex {
+	index, exists := gi.Indexes[boats]
+	if !exists {
+		index = newGroupIndex(boats, gi.Resps)
+		gi.Indexes[boats] = index
+	}
+
+	return index
+}
+
+func newGroupIndex(boats *list.List, resps map[string]BoatDataLiveRespMsg) *GroupIndex {
+	index := &GroupIndex {
+		Cells: make(map[GroupIndexCell][]GroupIndexEntry),
+	}
+
+	for e := boats.Front(); e != nil; e = e.Next() {
+		boat := e.Value.(*BoatInfo)
+
+		data, exists := resps[boat.BoatKey]
+		if !exists {
+			continue // Data for boat is missing.
+		}
+
+		cell := groupIndexCell(data.Lat, data.Lon)
+		index.Cells[cell] = append(index.Cells[cell], GroupIndexEntry { boat.BoatKey, boat.FriendlyName, &data })
+	}
+
+	return index
+}
+
+func groupIndexCell(lat float64, lon float64) GroupIndexCell {
+	return GroupIndexCell {
+		Lat: int(math.Floor(lat / GROUP_INDEX_CELL_DEG)),
+		Lon: groupIndexLonCell(int(math.Floor(lon / GROUP_INDEX_CELL_DEG))),
+	}
+}
+
+// Wraps a longitude cell number around the 180 degree meridian.
+func groupIndexLonCell(n int) int {
+	n %= GROUP_INDEX_LON_CELLS
+	if n < 0 {
+		n += GROUP_INDEX_LON_CELLS
+	}
+
+	return n
+}
+
+// Calls f for every boat in the index that may be within GROUP_VISIBILITY_DIST of the given position (and possibly some further away).
+func (index *GroupIndex) forNearby(lat float64, lon float64, f func(entry *GroupIndexEntry)) {
+	center := groupIndexCell(lat, lon)
+
+	// Boats within GROUP_VISIBILITY_DIST are at most one cell away in latitude,
+	// but may be several cells away in longitude closer to the poles (though
+	// no more than roughCloseDistance allows, since it caps latitude at 89).
+	maxLat := math.Min(math.Abs(lat) + GROUP_INDEX_CELL_DEG, 89.0)
+	lonCells := 1 + int(math.Ceil(1.0 / math.Cos(maxLat * math.Pi / 180.0)))
+
+	lonFrom := center.Lon - lonCells
+	lonTo := center.Lon + lonCells
+	if lonTo - lonFrom + 1 >= GROUP_INDEX_LON_CELLS {
+		lonFrom = 0
+		lonTo = GROUP_INDEX_LON_CELLS - 1
+	}
+
+	for latCell := center.Lat - 1; latCell <= center.Lat + 1; latCell++ {
+		for lonCell := lonFrom; lonCell <= lonTo; lonCell++ {
+			entries := index.Cells[GroupIndexCell { latCell, groupIndexLonCell(lonCell) }]
+			for i := range entries {
+				f(&entries[i])
+			}
+		}
+	}
+}
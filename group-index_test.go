@@ -0,0 +1,137 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Creates a group of boats scattered (with random courses) around the given position, within spread degrees.
+func testGroup(n int, lat float64, lon float64, spread float64) (*list.List, map[string]BoatDataLiveRespMsg) {
+	boats := list.New()
+	resps := make(map[string]BoatDataLiveRespMsg)
+
+	for i := 0; i < n; i++ {
+		boatKey := fmt.Sprintf("%032x", i)
+		boats.PushBack(&BoatInfo { BoatKey: boatKey, FriendlyName: fmt.Sprintf("Boat %d", i) })
+
+		boatLat := math.Max(-90.0, math.Min(90.0, lat + (rand.Float64() * 2.0 - 1.0) * spread))
+		boatLon := lon + (rand.Float64() * 2.0 - 1.0) * spread
+		if boatLon >= 180.0 {
+			boatLon -= 360.0
+		} else if boatLon < -180.0 {
+			boatLon += 360.0
+		}
+
+		resps[boatKey] = BoatDataLiveRespMsg { Lat: boatLat, Lon: boatLon, Ctw: rand.Float64() * 360.0 }
+	}
+
+	return boats, resps
+}
+
+// The other boats that should be visible from a boat, found by checking every boat in the group.
+func testVisibleBoats(thisBoatKey string, boats *list.List, resps map[string]BoatDataLiveRespMsg) map[string]bool {
+	visible := make(map[string]bool)
+	thisBoatData := resps[thisBoatKey]
+
+	for e := boats.Front(); e != nil; e = e.Next() {
+		boat := e.Value.(*BoatInfo)
+		data, exists := resps[boat.BoatKey]
+		if !exists || boat.BoatKey == thisBoatKey {
+			continue
+		}
+
+		if roughCloseDistance(thisBoatData.Lat, thisBoatData.Lon, data.Lat, data.Lon) <= GROUP_VISIBILITY_DIST {
+			visible[boat.FriendlyName] = true
+		}
+	}
+
+	return visible
+}
+
+func TestGroupIndexMatchesAllBoats(t *testing.T) {
+	// Open ocean, across the 180 degree meridian, across the prime meridian and equator, and near both poles.
+	centers := [][2]float64 {
+		{ 45.0, -30.0 },
+		{ 10.0, 179.9 },
+		{ 0.0, 0.0 },
+		{ 88.5, 60.0 },
+		{ -89.5, -120.0 },
+	}
+
+	for _, center := range centers {
+		boats, resps := testGroup(500, center[0], center[1], 1.0)
+		delete(resps, fmt.Sprintf("%032x", 7)) // One boat with missing data
+
+		indexes := newGroupIndexes(resps)
+
+		for e := boats.Front(); e != nil; e = e.Next() {
+			boatKey := e.Value.(*BoatInfo).BoatKey
+			if _, exists := resps[boatKey]; !exists {
+				continue
+			}
+
+			connCtx := &ConnCtx { BoatKey: boatKey, GroupBoats: boats }
+			msg := createBoatGroupRespMsg(connCtx, resps, indexes.get(boats))
+			expected := testVisibleBoats(boatKey, boats, resps)
+
+			if len(msg.OtherBoats) != len(expected) {
+				t.Fatalf("Boat near (%f,%f) sees %d other boats, but expected %d!", center[0], center[1], len(msg.OtherBoats), len(expected))
+			}
+			for name := range msg.OtherBoats {
+				if !expected[name] {
+					t.Fatalf("Boat near (%f,%f) unexpectedly sees %s!", center[0], center[1], name)
+				}
+			}
+		}
+
+		if len(indexes.Indexes) != 1 {
+			t.Errorf("Expected one index to be built for the group, but got %d!", len(indexes.Indexes))
+		}
+	}
+}
+
+// One iteration's worth of bdl_g messages for a 1000-boat group with every boat subscribed.
+func BenchmarkCreateBoatGroupRespMsg1000(b *testing.B) {
+	boats, resps := testGroup(1000, 45.0, -30.0, 2.0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		indexes := newGroupIndexes(resps)
+		for e := boats.Front(); e != nil; e = e.Next() {
+			connCtx := &ConnCtx { BoatKey: e.Value.(*BoatInfo).BoatKey, GroupBoats: boats }
+			createBoatGroupRespMsg(connCtx, resps, indexes.get(boats))
+		}
+	}
+}
+
+// As above, but checking the distance to every other boat in the group for comparison.
+func BenchmarkVisibleBoatsAllPairs1000(b *testing.B) {
+	boats, resps := testGroup(1000, 45.0, -30.0, 2.0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for e := boats.Front(); e != nil; e = e.Next() {
+			testVisibleBoats(e.Value.(*BoatInfo).BoatKey, boats, resps)
+		}
+	}
+}
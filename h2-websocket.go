@@ -0,0 +1,201 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// WebSockets over HTTP/2:
+//
+// On h2: listeners (see listeners.go), HTTP/2 is negotiated with clients that
+// support it, and WebSocket connections can be made as extended CONNECT
+// streams (RFC 8441), so that a client (or a proxy) multiplexing several
+// streams needs just one connection. Go's HTTP/2 server only accepts extended
+// CONNECT with GODEBUG=http2xconnect=1 in the environment; without it, clients
+// fall back to WebSockets over HTTP/1.1.
+//
+// The WebSocket library only upgrades HTTP/1.1 connections, so an extended
+// CONNECT request is presented to it as an HTTP/1.1 upgrade request, on a
+// "connection" made of the request and response streams. The library's
+// handshake response is turned into the stream's 200 response (with any
+// negotiated subprotocol and extensions), and everything after it is passed
+// through as is.
+
+const H2_XCONNECT_GODEBUG = "http2xconnect=1"
+
+// A WebSocket connection's underlying "connection", for an extended CONNECT stream
+type H2WsConn struct {
+	w http.ResponseWriter
+	r *http.Request
+	rc *http.ResponseController
+
+	lock sync.Mutex
+	handshaken bool
+	closed bool
+}
+
+// Presents an H2WsConn to the WebSocket library as a hijackable response
+type H2WsResponseWriter struct {
+	http.ResponseWriter
+	conn *H2WsConn
+}
+
+type h2WsAddr string
+
+
+func h2XconnectEnabled() bool {
+	return strings.Contains(os.Getenv("GODEBUG"), H2_XCONNECT_GODEBUG)
+}
+
+func isH2WebSocket(r *http.Request) bool {
+	return r.ProtoMajor == 2 && r.Method == http.MethodConnect && r.Header.Get(":protocol") == "websocket"
+}
+
+// Wraps a WebSocket upgrade handler to also accept extended CONNECT requests over HTTP/2.
+func withH2WebSocket(handler http.HandlerFunc) http.HandlerFunc {
+	return func (w http.ResponseWriter, r *http.Request) {
+		if !isH2WebSocket(r) {
+			handler(w, r)
+			return
+		}
+
+		conn := &H2WsConn { w: w, r: r, rc: http.NewResponseController(w) }
+		defer conn.Close() // The stream can't be written to once the handler has returned.
+
+		handler(&H2WsResponseWriter { w, conn }, h2UpgradeRequest(r))
+	}
+}
+
+// Returns the HTTP/1.1 upgrade request equivalent to an extended CONNECT request.
+func h2UpgradeRequest(r *http.Request) *http.Request {
+	up := r.Clone(r.Context())
+	up.Method = http.MethodGet
+	up.Header.Del(":protocol")
+	up.Header.Set("Connection", "Upgrade")
+	up.Header.Set("Upgrade", "websocket")
+
+	// There's no key with extended CONNECT, since the stream can't be mistaken for anything else.
+	var key [16]byte
+	rand.Read(key[:])
+	up.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key[:]))
+
+	return up
+}
+
+func (hw *H2WsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hw.conn, bufio.NewReadWriter(bufio.NewReader(hw.conn), bufio.NewWriter(hw.conn)), nil
+}
+
+func (c *H2WsConn) Read(p []byte) (int, error) {
+	return c.r.Body.Read(p)
+}
+
+func (c *H2WsConn) Write(p []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	if !c.handshaken {
+		// The library's handshake response, to be sent as the stream's response instead.
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(p)), c.r)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			return 0, errors.New("Unexpected WebSocket handshake response: " + resp.Status)
+		}
+
+		for _, name := range []string { "Sec-WebSocket-Protocol", "Sec-WebSocket-Extensions" } {
+			if v := resp.Header.Values(name); len(v) > 0 {
+				c.w.Header()[http.CanonicalHeaderKey(name)] = v
+			}
+		}
+		c.w.WriteHeader(http.StatusOK)
+		c.handshaken = true
+	} else if _, err := c.w.Write(p); err != nil {
+		return 0, err
+	}
+
+	return len(p), c.rc.Flush()
+}
+
+func (c *H2WsConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.r.Body.Close()
+}
+
+func (c *H2WsConn) LocalAddr() net.Addr {
+	if addr, ok := c.r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return addr
+	}
+	return h2WsAddr("")
+}
+
+func (c *H2WsConn) RemoteAddr() net.Addr {
+	return h2WsAddr(c.r.RemoteAddr)
+}
+
+func (c *H2WsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *H2WsConn) SetReadDeadline(t time.Time) error {
+	return ignoreNotSupported(c.rc.SetReadDeadline(t))
+}
+
+func (c *H2WsConn) SetWriteDeadline(t time.Time) error {
+	return ignoreNotSupported(c.rc.SetWriteDeadline(t))
+}
+
+func ignoreNotSupported(err error) error {
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+func (a h2WsAddr) Network() string {
+	return "tcp"
+}
+
+func (a h2WsAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,123 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/gorilla/websocket"
+)
+
+
+// A streaming response, as written to an HTTP/2 stream
+type testStreamWriter struct {
+	header http.Header
+	status chan int
+	body *io.PipeWriter
+}
+
+func (w *testStreamWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *testStreamWriter) WriteHeader(status int) {
+	w.status <- status
+}
+
+func (w *testStreamWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+func (w *testStreamWriter) Flush() {
+}
+
+
+func TestH2WebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader { Subprotocols: []string { "snsw" } }
+	handler := withH2WebSocket(func (w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		msgType, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.WriteMessage(msgType, msg)
+	})
+
+	reqBody, reqWriter := io.Pipe()
+	respReader, respBody := io.Pipe()
+
+	r := httptest.NewRequest(http.MethodConnect, "/v1/ws", reqBody)
+	r.ProtoMajor = 2
+	r.ProtoMinor = 0
+	r.Header.Set(":protocol", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Protocol", "snsw")
+
+	w := &testStreamWriter { header: make(http.Header), status: make(chan int, 1), body: respBody }
+	done := make(chan struct{})
+	go func() {
+		handler(w, r)
+		respBody.Close()
+		close(done)
+	}()
+
+	if status := <-w.status; status != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d", status)
+	}
+	if p := w.header.Get("Sec-WebSocket-Protocol"); p != "snsw" {
+		t.Errorf("Expected subprotocol snsw, but got %q", p)
+	}
+
+	// A masked text frame from the client, and its unmasked echo from the server
+	mask := []byte { 1, 2, 3, 4 }
+	frame := []byte { 0x81, 0x82, mask[0], mask[1], mask[2], mask[3], 'h' ^ mask[0], 'i' ^ mask[1] }
+	reqWriter.Write(frame)
+
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(respReader, echo); err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) != "\x81\x02hi" {
+		t.Errorf("Unexpected echo: %q", echo)
+	}
+
+	reqWriter.Close()
+	<-done
+}
+
+func TestH2WebSocketPassthrough(t *testing.T) {
+	handler := withH2WebSocket(func (w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method)
+	})
+
+	for _, method := range []string { http.MethodGet, http.MethodConnect } {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, "/v1/ws", nil))
+		if w.Body.String() != method {
+			t.Errorf("Expected %s request to be passed through, but got %s", method, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,176 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+
+// High-frequency mode:
+//
+// During starts and mark roundings, one live data message per poll interval
+// is too coarse. With -hf-rate set (to a rate the simulator can keep up
+// with), a bdl_g request may include "hf":true, and its subscription
+// acknowledgement then includes the rate granted ("hf") and the distance
+// within which it applies ("hf_dist").
+//
+// Whenever another boat in the subscription's group is within -hf-dist of its
+// own boat (as of the latest iteration), the subscription is sent
+// {"type":"hf","active":true} and high-frequency mode is on: between
+// iterations, the main loop also polls the simulator for just the boats in
+// close quarters (its own boat and those nearby), -hf-rate times per poll
+// interval in all, and sends the subscription live data with their fresh
+// data (and the latest iteration's data for any other boats). Once no other
+// boat is within -hf-dist any more, {"type":"hf","active":false} is sent, and
+// live data is back to once per poll interval.
+
+const HF_RATE_MAX = 10
+const HF_INTERVAL_MIN = 50 * time.Millisecond
+
+type HfState struct {
+	Active bool
+}
+
+type HfMsg struct {
+	Type string `json:"type"`
+	Active bool `json:"active"`
+}
+
+// Connections in high-frequency mode, and the boats to poll between iterations for them
+var _hfConns = make(map[*WsConn]bool)
+var _hfBoats = make(map[string]bool)
+
+
+// Returns the high-frequency mode state for a subscription, or nil if it wasn't requested or isn't available.
+func reqHf(req *ReqMsg, withGroup bool) *HfState {
+	if !req.Hf || !withGroup || _config.HfRate == 0 {
+		return nil
+	}
+
+	return &HfState {}
+}
+
+// Returns the other boats in a subscription's group within -hf-dist of its own boat.
+func hfNearBoats(connCtx *ConnCtx, resps map[string]BoatDataLiveRespMsg, index *GroupIndex) []string {
+	thisBoatData, exists := resps[connCtx.BoatKey]
+	if !exists {
+		return nil
+	}
+
+	var near []string
+	index.forNearby(thisBoatData.Lat, thisBoatData.Lon, func(entry *GroupIndexEntry) {
+		if entry.BoatKey == connCtx.BoatKey || entry.Data.LastKnown {
+			return
+		}
+
+		if roughCloseDistance(thisBoatData.Lat, thisBoatData.Lon, entry.Data.Lat, entry.Data.Lon) <= _config.HfDist {
+			near = append(near, entry.BoatKey)
+		}
+	})
+
+	return near
+}
+
+// Called (with _lock held) once per iteration to switch subscriptions in and out of high-frequency
+// mode, and work out which boats to poll between iterations.
+func updateHf(resps map[string]BoatDataLiveRespMsg, groupIndexes *GroupIndexes) {
+	if _config.HfRate == 0 {
+		return
+	}
+
+	_hfConns = make(map[*WsConn]bool)
+	_hfBoats = make(map[string]bool)
+
+	for conn, connCtx := range _conns {
+		if connCtx.Hf == nil {
+			continue
+		}
+
+		near := hfNearBoats(&connCtx, resps, groupIndexes.get(connCtx.GroupBoats))
+
+		active := len(near) > 0
+		if active != connCtx.Hf.Active {
+			connCtx.Hf.Active = active
+			conn.SendJSON(&HfMsg { Type: "hf", Active: active })
+		}
+
+		if !active {
+			continue
+		}
+
+		_hfConns[conn] = true
+		_hfBoats[connCtx.BoatKey] = true
+		for _, boatKey := range near {
+			_hfBoats[boatKey] = true
+		}
+	}
+}
+
+// Polls the simulator for the boats in close quarters, and sends their fresh data to the connections in high-frequency mode.
+func hfSubTick() {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	if len(_hfConns) == 0 {
+		return
+	}
+
+	reqsBySim := make(map[string][]SimBoatDataReq)
+	for boatKey, _ := range _hfBoats {
+		entry, exists := _trackedBoats[boatKey]
+		if !exists {
+			continue
+		}
+		reqsBySim[entry.Sim] = append(reqsBySim[entry.Sim], SimBoatDataReq { boatKey, entry.ExtRefCount > 0 })
+	}
+
+	fresh, _ := pollSims(context.Background(), reqsBySim) // Any "noboat" will be picked up by the next iteration.
+	if len(fresh) == 0 {
+		return
+	}
+
+	// The latest iteration's data, updated with the fresh data
+	resps := make(map[string]BoatDataLiveRespMsg, len(_latestResps))
+	for boatKey, resp := range _latestResps {
+		resps[boatKey] = resp
+	}
+	for boatKey, resp := range fresh {
+		resps[boatKey] = resp
+	}
+	groupIndexes := newGroupIndexes(resps)
+
+	for conn, _ := range _hfConns {
+		connCtx, exists := _conns[conn]
+		if !exists {
+			continue // Removed since the latest iteration.
+		}
+
+		resp, exists := fresh[connCtx.BoatKey]
+		if !exists {
+			continue
+		}
+
+		msg := formatLiveMsg(conn, resp, resps, groupIndexes, nil)
+		if msg != nil {
+			conn.SendLiveAt(connCtx.BoatKey, msg.Data, msg.Arrived) // Any failure will be picked up by the next iteration.
+		}
+
+		_countMsgs++
+	}
+}
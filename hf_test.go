@@ -0,0 +1,50 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"testing"
+)
+
+
+func TestHfNearBoats(t *testing.T) {
+	boats := list.New()
+	for _, key := range []string { "me", "near", "far", "stale" } {
+		boats.PushBack(&BoatInfo { key, key })
+	}
+
+	resps := map[string]BoatDataLiveRespMsg {
+		"me": { Lat: 45.0, Lon: -30.0 },
+		"near": { Lat: 45.005, Lon: -30.0 }, // 0.3 NM away
+		"far": { Lat: 45.1, Lon: -30.0 }, // 6 NM away
+		"stale": { Lat: 45.0, Lon: -30.001, LastKnown: true },
+	}
+
+	connCtx := &ConnCtx { BoatKey: "me", GroupBoats: boats, Hf: &HfState {} }
+
+	near := hfNearBoats(connCtx, resps, newGroupIndex(boats, resps))
+	if len(near) != 1 || near[0] != "near" {
+		t.Errorf("Unexpected boats in close quarters: %v", near)
+	}
+
+	// No data for our own boat, so no boats in close quarters.
+	delete(resps, "me")
+	if near = hfNearBoats(connCtx, resps, newGroupIndex(boats, resps)); len(near) != 0 {
+		t.Errorf("Unexpected boats in close quarters without own boat: %v", near)
+	}
+}
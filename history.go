@@ -0,0 +1,104 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+// History buffering:
+//
+// With -history-size <n>, the most recent <n> samples (one per iteration) of
+// each tracked boat's data are kept, and sent in one burst to each new bdl,
+// bdl_g or bdl_x subscription right after its acknowledgement, so that
+// reconnecting clients see their boat already moving, rather than waiting for
+// the next iteration (and then for a few more to see where it's heading):
+//
+// {"type":"history","samples":[<data>,...]}
+//
+// Samples (oldest first) are of the subscribed boat only, formatted as its own
+// live data would be for the subscription (i.e. with its fields, units, COG
+// smoothing and precision), and always include "ts" (unless not among its
+// selected fields). Last known data (see sim-outage.go) isn't kept.
+
+// Maximum -history-size (for bounding memory use and burst size)
+const HISTORY_MAX_SIZE = 300
+
+// Maximum number of samples kept across all boats, beyond which boats without samples yet don't get any (see memory.go)
+const HISTORY_MAX_TOTAL_SAMPLES = 200000
+
+type HistoryMsg struct {
+	Type string `json:"type"`
+	Samples []interface{} `json:"samples"`
+}
+
+// Recent samples of each tracked boat's data, oldest first (guarded by _lock)
+var _history = make(map[string][]BoatDataLiveRespMsg)
+
+
+// Called (with _lock held) once per iteration, to remember this iteration's data (before any last known data is added).
+func recordHistory(resps map[string]BoatDataLiveRespMsg) {
+	if _config.HistorySize <= 0 {
+		return
+	}
+
+	total := 0
+	for boatKey, samples := range _history {
+		if _trackedBoats[boatKey] == nil {
+			delete(_history, boatKey)
+		} else {
+			total += len(samples)
+		}
+	}
+
+	for boatKey, resp := range resps {
+		resp.Ts = resp.ArrivedAt.UnixMilli()
+
+		samples := _history[boatKey]
+		if len(samples) == 0 && (total >= HISTORY_MAX_TOTAL_SAMPLES || _memoryPressure.Load()) {
+			continue
+		}
+
+		if len(samples) < _config.HistorySize && total < HISTORY_MAX_TOTAL_SAMPLES {
+			total++
+			samples = append(samples, resp)
+		} else {
+			copy(samples, samples[1:])
+			samples[len(samples) - 1] = resp
+		}
+		_history[boatKey] = samples
+	}
+}
+
+// Called (with _lock held) once a connection has been subscribed, to send it its boat's recent samples.
+func sendHistory(conn *WsConn, connCtx *ConnCtx) {
+	samples := _history[connCtx.BoatKey]
+	if len(samples) == 0 {
+		return
+	}
+
+	// Formatted as live data for the boat alone.
+	boatCtx := *connCtx
+	boatCtx.GroupBoats = nil
+	boatCtx.Session = nil
+
+	msg := &HistoryMsg {
+		Type: "history",
+		Samples: make([]interface{}, len(samples)),
+	}
+	for i, sample := range samples {
+		msg.Samples[i] = createRespMsg(&boatCtx, sample, nil, nil)
+	}
+
+	conn.SendJSON(msg)
+}
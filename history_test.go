@@ -0,0 +1,65 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestRecordHistory(t *testing.T) {
+	prevSize := _config.HistorySize
+	_config.HistorySize = 3
+	defer func() { _config.HistorySize = prevSize }()
+
+	boatKey := mockSimKey("history")
+	untracked := mockSimKey("history-untracked")
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	trackBoat(DEFAULT_SIM, boatKey)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		recordHistory(map[string]BoatDataLiveRespMsg {
+			boatKey: { Lat: float64(i), ArrivedAt: start.Add(time.Duration(i) * time.Second) },
+			untracked: { Lat: float64(i) },
+		})
+	}
+
+	samples := _history[boatKey]
+	if len(samples) != 3 || samples[0].Lat != 2.0 || samples[2].Lat != 4.0 {
+		t.Errorf("Unexpected history: %+v", samples)
+	}
+	if samples[2].Ts != start.Add(4 * time.Second).UnixMilli() {
+		t.Errorf("History sample not timestamped: %+v", samples[2])
+	}
+
+	// Boats no longer tracked are forgotten.
+	if len(_history[untracked]) != 1 {
+		t.Errorf("Unexpected history for untracked boat: %+v", _history[untracked])
+	}
+
+	untrackBoat(boatKey)
+	recordHistory(map[string]BoatDataLiveRespMsg {})
+
+	if len(_history) != 0 {
+		t.Errorf("History not forgotten for untracked boats: %+v", _history)
+	}
+}
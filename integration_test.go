@@ -0,0 +1,618 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"sailnavsim-snsw/client"
+)
+
+
+// Integration test harness:
+//
+// A fake simulator (answering "bd_nc" and "boatgroupmembers" requests from
+// per-test boat data) and a real HTTP server are started once, along with the
+// main loop, and shared by all tests. Since the main loop's state is global,
+// each test uses its own boat keys (see testBoatKey), so tests can run in
+// parallel. Run with "go test -race" for race detector coverage.
+
+const TEST_READ_TIMEOUT = 5 * time.Second
+
+type TestSim struct {
+	lock sync.Mutex
+	boats map[string]string // Boat key to "bd_nc" response fields (after "ok")
+	groups map[string][]*BoatInfo // Boat key to group members
+}
+
+var _testSim = &TestSim {
+	boats: make(map[string]string),
+	groups: make(map[string][]*BoatInfo),
+}
+
+var _testServerOnce sync.Once
+var _testServerUrl string
+
+
+// Starts (only once) the fake simulator, the main loop and the HTTP server, returning the WebSocket URL.
+// Tests calling it must be parallel (t.Parallel() first), so that the main loop isn't started until the
+// sequential tests, some of which change *_config (which is otherwise fixed after startup), are done.
+func testServer(t *testing.T) string {
+	_testServerOnce.Do(func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go _testSim.serve(ln)
+
+		go boatDataLiveMain(ln.Addr().String())
+		groupFetchInit()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/ws", wsHandler)
+		srv := httptest.NewServer(mux)
+
+		_testServerUrl = "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/ws"
+	})
+
+	return _testServerUrl
+}
+
+// Makes a boat key unique to a test and boat number.
+func testBoatKey(t *testing.T, n int) string {
+	return mockSimKey(fmt.Sprintf("%s-%d", t.Name(), n))
+}
+
+func (sim *TestSim) setBoat(boatKey string, lat float64, lon float64, ctw float64) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+
+	sim.boats[boatKey] = fmt.Sprintf("%g,%g,%g,5.5,%g,6,12.5,45", lat, lon, ctw, ctw + 2.0)
+}
+
+func (sim *TestSim) setGroup(members []*BoatInfo) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+
+	for _, m := range members {
+		sim.groups[m.BoatKey] = members
+	}
+}
+
+func (sim *TestSim) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go sim.serveConn(conn)
+	}
+}
+
+func (sim *TestSim) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		s := strings.Split(strings.Trim(line, "\n"), ",")
+
+		sim.lock.Lock()
+		switch s[0] {
+		case "bd_nc":
+			data, exists := sim.boats[s[1]]
+			if exists {
+				fmt.Fprintf(conn, "bd_nc,%s,ok,%s\n", s[1], data)
+			} else {
+				fmt.Fprintf(conn, "bd_nc,%s,noboat\n", s[1])
+			}
+
+		case "boatgroupmembers":
+			fmt.Fprintf(conn, "boatgroupmembers,%s,ok\n", s[1])
+			for _, m := range sim.groups[s[1]] {
+				fmt.Fprintf(conn, "%s,%s\n", m.BoatKey, m.FriendlyName)
+			}
+			fmt.Fprintf(conn, "\n")
+
+		default:
+			fmt.Fprintf(conn, "error\n")
+		}
+		sim.lock.Unlock()
+	}
+}
+
+func testDial(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func testSend(t *testing.T, conn *websocket.Conn, req map[string]interface{}) {
+	err := conn.WriteJSON(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reads the next message from a connection into v, failing the test on error.
+func testRead(t *testing.T, conn *websocket.Conn, v interface{}) {
+	conn.SetReadDeadline(time.Now().Add(TEST_READ_TIMEOUT))
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+}
+
+// Reads the acknowledgement that should follow a successful subscription.
+func testReadSubscribed(t *testing.T, conn *websocket.Conn) SubscribedMsg {
+	var msg SubscribedMsg
+	testRead(t, conn, &msg)
+
+	if msg.Type != "subscribed" || msg.Version != PROTOCOL_VERSION || msg.Interval != 1 {
+		t.Fatalf("Unexpected subscription acknowledgement: %+v", msg)
+	}
+
+	return msg
+}
+
+// Checks that the server closes a connection (without sending anything more).
+func testExpectClosed(t *testing.T, conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(TEST_READ_TIMEOUT))
+
+	_, data, err := conn.ReadMessage()
+	if err == nil {
+		t.Fatalf("Expected connection to be closed, but got: %s", data)
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Fatal("Timed out waiting for connection to be closed")
+	}
+}
+
+// Reads (and skips) messages until the connection is closed, expecting the given close code.
+func testExpectCloseCode(t *testing.T, conn *websocket.Conn, code int) {
+	conn.SetReadDeadline(time.Now().Add(TEST_READ_TIMEOUT))
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("Timed out waiting for connection to be closed")
+		}
+
+		closeErr, ok := err.(*websocket.CloseError)
+		if !ok {
+			t.Fatalf("Expected close code %d, but got: %v", code, err)
+		}
+		if closeErr.Code != code {
+			t.Fatalf("Expected close code %d, but got %d (%s)", code, closeErr.Code, closeErr.Text)
+		}
+		return
+	}
+}
+
+// Waits for a condition on the main loop's state (checked with _lock held).
+func testWaitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(TEST_READ_TIMEOUT)
+	for time.Now().Before(deadline) {
+		_lock.Lock()
+		ok := cond()
+		_lock.Unlock()
+
+		if ok {
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatal("Timed out waiting for " + what)
+}
+
+func TestIntegrationBdl(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	_testSim.setBoat(boatKey, 45.5, -30.25, 123.0)
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey })
+
+	ack := testReadSubscribed(t, conn)
+	if ack.Radius != 0 || ack.Group != 0 {
+		t.Errorf("Unexpected group parameters for bdl: %+v", ack)
+	}
+
+	for i := 0; i < 2; i++ {
+		var msg BoatDataLiveRespMsg
+		testRead(t, conn, &msg)
+
+		expected := BoatDataLiveRespMsg { Lat: 45.5, Lon: -30.25, Ctw: 123.0, Stw: 5.5, Cog: 125.0, Sog: 6.0, Lws: 12.5, Ha: 45.0 }
+		if msg != expected {
+			t.Errorf("Got %+v, expected %+v", msg, expected)
+		}
+	}
+
+	// Data changes are streamed.
+	_testSim.setBoat(boatKey, 45.6, -30.25, 124.0)
+	for i := 0; i < 3; i++ {
+		var msg BoatDataLiveRespMsg
+		testRead(t, conn, &msg)
+		if msg.Lat == 45.6 && msg.Ctw == 124.0 {
+			return
+		}
+	}
+	t.Error("Updated boat data not received")
+}
+
+func TestIntegrationBdlGroup(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	nearKey := testBoatKey(t, 1)
+	farKey := testBoatKey(t, 2)
+	_testSim.setBoat(boatKey, 45.0, -30.0, 90.0)
+	_testSim.setBoat(nearKey, 45.01, -30.0, 180.0) // 0.6 NM away
+	_testSim.setBoat(farKey, 46.0, -30.0, 270.0) // 60 NM away
+	_testSim.setGroup([]*BoatInfo {
+		&BoatInfo { boatKey, "Me" },
+		&BoatInfo { nearKey, "Near" },
+		&BoatInfo { farKey, "Far" },
+	})
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl_g", "key": boatKey })
+
+	ack := testReadSubscribed(t, conn)
+	if ack.Radius != GROUP_VISIBILITY_DIST || ack.Group != 0 || !ack.GroupPending {
+		t.Errorf("Unexpected group parameters for bdl_g: %+v", ack)
+	}
+
+	// The group is fetched after subscribing (see group-fetch.go), so skip any live data until it's ready.
+	var msg BoatGroupRespMsg
+	ready := false
+	for i := 0; i < 3 && !ready; i++ {
+		var groupReady GroupReadyMsg
+		testRead(t, conn, &groupReady)
+		ready = groupReady.Type == "group_ready"
+		if ready && groupReady.Group != 3 {
+			t.Errorf("Unexpected group size: %+v", groupReady)
+		}
+	}
+	if !ready {
+		t.Fatal("Group not ready")
+	}
+
+	testRead(t, conn, &msg)
+
+	if msg.ThisBoat.Lat != 45.0 || msg.ThisBoat.Ctw != 90.0 {
+		t.Errorf("Unexpected data for own boat: %+v", msg.ThisBoat)
+	}
+
+	if len(msg.OtherBoats) != 1 {
+		t.Fatalf("Expected only the nearby boat, got: %+v", msg.OtherBoats)
+	}
+
+	near, exists := msg.OtherBoats["Near"]
+	if !exists {
+		t.Fatalf("Nearby boat missing: %+v", msg.OtherBoats)
+	}
+
+	dist := roughCloseDistance(45.0, -30.0, 45.01, -30.0)
+	if near[0] != roundCoord(45.01, dist) || near[2] != roundCourse(180.0, dist) {
+		t.Errorf("Unexpected (rounded) data for nearby boat: %v", near)
+	}
+}
+
+func TestIntegrationGroupAll(t *testing.T) {
+	url := testServer(t)
+
+	prevToken := _config.AdminToken
+	_config.AdminToken = "test-admin"
+	defer func() { _config.AdminToken = prevToken }()
+
+	boatKey := testBoatKey(t, 0)
+	otherKey := testBoatKey(t, 1)
+	_testSim.setBoat(boatKey, 45.0, -30.0, 90.0)
+	_testSim.setBoat(otherKey, 46.0, -30.0, 270.0)
+	_testSim.setGroup([]*BoatInfo {
+		&BoatInfo { boatKey, "Me" },
+		&BoatInfo { otherKey, "Other" },
+	})
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "group_all", "key": boatKey, "admin": "test-admin" })
+
+	// Acknowledged as any other subscription, before any live data.
+	ack := testReadSubscribed(t, conn)
+	if ack.Radius != 0 || ack.Group != 2 || ack.Priority != "high" {
+		t.Errorf("Unexpected group parameters for group_all: %+v", ack)
+	}
+
+	var msg GroupAllRespMsg
+	testRead(t, conn, &msg)
+	if len(msg.Boats) != 2 || msg.Boats["Other"].Lat != 46.0 {
+		t.Errorf("Unexpected group_all data: %+v", msg)
+	}
+}
+
+func TestIntegrationDisconnect(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	_testSim.setBoat(boatKey, 10.0, 20.0, 30.0)
+
+	conn := testDial(t, url)
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey })
+	testReadSubscribed(t, conn)
+
+	var msg BoatDataLiveRespMsg
+	testRead(t, conn, &msg)
+
+	testWaitFor(t, "boat to be tracked", func() bool { return _trackedBoats[boatKey] != nil })
+
+	conn.Close()
+
+	// Once the main loop notices the connection closed, the boat is no longer tracked.
+	testWaitFor(t, "boat to be untracked", func() bool {
+		_, tracked := _trackedBoats[boatKey]
+		return _hub.Count(boatKey) == 0 && !tracked
+	})
+}
+
+func TestIntegrationNoBoat(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	// Valid, but unknown to the simulator, so rejected before subscribing (see key-validation.go)
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": testBoatKey(t, 0) })
+
+	var msg ErrorMsg
+	testRead(t, conn, &msg)
+	if msg.Type != "error" || msg.Error != ERR_UNKNOWN_BOAT {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	testExpectCloseCode(t, conn, CLOSE_INVALID_KEY)
+}
+
+func TestIntegrationInvalidKey(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": "not-a-boat-key" })
+	testExpectCloseCode(t, conn, CLOSE_INVALID_KEY)
+}
+
+func TestIntegrationSecondSubscription(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	_testSim.setBoat(boatKey, 10.0, 20.0, 30.0)
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	// Only one subscription is allowed per connection.
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey })
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey })
+	testExpectCloseCode(t, conn, CLOSE_DUPLICATE_SUBSCRIBE)
+}
+
+// Many clients subscribing, streaming and disconnecting at once (mostly for the race detector's benefit).
+func TestIntegrationManyClients(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	const NUM_BOATS = 4
+	const NUM_CLIENTS = 40
+
+	members := make([]*BoatInfo, 0, NUM_BOATS)
+	for i := 0; i < NUM_BOATS; i++ {
+		boatKey := testBoatKey(t, i)
+		_testSim.setBoat(boatKey, 45.0 + float64(i) * 0.01, -30.0, 90.0)
+		members = append(members, &BoatInfo { boatKey, fmt.Sprintf("Boat %d", i) })
+	}
+	_testSim.setGroup(members)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, NUM_CLIENTS)
+
+	for i := 0; i < NUM_CLIENTS; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+
+			cmd := "bdl"
+			if i % 2 == 0 {
+				cmd = "bdl_g"
+			}
+
+			err = conn.WriteJSON(map[string]interface{} { "cmd": cmd, "key": members[i % NUM_BOATS].BoatKey })
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			conn.SetReadDeadline(time.Now().Add(TEST_READ_TIMEOUT))
+			for n := 0; n < 1 + i % 3; n++ {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	testWaitFor(t, "all boats to be untracked", func() bool {
+		for _, m := range members {
+			if _, tracked := _trackedBoats[m.BoatKey]; tracked {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// The client library (see client/) against the real server.
+func TestIntegrationClient(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	otherKey := testBoatKey(t, 1)
+	_testSim.setBoat(boatKey, 45.0, -30.0, 90.0)
+	_testSim.setBoat(otherKey, 45.01, -30.0, 180.0)
+	_testSim.setGroup([]*BoatInfo {
+		&BoatInfo { boatKey, "Me" },
+		&BoatInfo { otherKey, "Other" },
+	})
+
+	c, err := client.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.SubscribeBoat(boatKey, &client.SubscribeOptions { Group: true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(TEST_READ_TIMEOUT)
+	var ack *client.SubscribedMsg
+	var ready *client.GroupReadyMsg
+	for {
+		select {
+		case u, ok := <-c.Updates():
+			if !ok {
+				t.Fatalf("Connection closed: %v", c.Err())
+			}
+
+			switch msg := u.(type) {
+			case *client.SubscribedMsg:
+				ack = msg
+			case *client.GroupReadyMsg:
+				ready = msg
+			case *client.GroupMsg:
+				if ready == nil {
+					continue // Group not fetched yet
+				}
+				if ack == nil || !ack.GroupPending || ready.Group != 2 {
+					t.Errorf("Unexpected acknowledgement: %+v, %+v", ack, ready)
+				}
+				if msg.You.Lat != 45.0 || msg.You.Ctw != 90.0 || len(msg.Others) != 1 {
+					t.Errorf("Unexpected group data: %+v", msg)
+				}
+				return
+			}
+
+		case <-timeout:
+			t.Fatal("Timed out waiting for group data")
+		}
+	}
+}
+
+// The client library's close codes match the server's.
+func TestIntegrationClientCloseCode(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	c, err := client.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.SubscribeBoat("not-a-boat-key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(TEST_READ_TIMEOUT)
+	for {
+		select {
+		case _, ok := <-c.Updates():
+			if ok {
+				continue
+			}
+
+			closeErr, isCloseErr := c.Err().(*websocket.CloseError)
+			if !isCloseErr || closeErr.Code != client.CLOSE_INVALID_KEY || client.CLOSE_INVALID_KEY != CLOSE_INVALID_KEY {
+				t.Errorf("Unexpected close error: %v", c.Err())
+			}
+			return
+
+		case <-timeout:
+			t.Fatal("Timed out waiting for connection to be closed")
+		}
+	}
+}
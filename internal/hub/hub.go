@@ -0,0 +1,210 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hub
+
+import (
+	"time"
+)
+
+
+// Live data distribution:
+//
+// Package hub keeps track of which subscribers are subscribed to which boat
+// keys, and distributes each iteration's snapshot of live boat data to them.
+// It knows nothing of WebSockets or of the data itself: subscribers are
+// anything implementing Subscriber (e.g. the connector's WebSocket
+// connections), and messages are formatted for each subscriber by the caller.
+//
+// A Hub isn't safe for concurrent use, so callers must serialize access to it
+// (e.g. with the lock that also guards the rest of their subscription state).
+
+// A subscriber to live data for a boat key (which may be subscribed to more than one)
+type Subscriber interface {
+	// Sends (or queues) a live data message, given when the data arrived (zero if not to be
+	// measured), returning false if the subscriber is closed.
+	SendLiveAt(boatKey string, data []byte, arrived time.Time) bool
+
+	// Closes the subscriber, once anything already sent has been delivered.
+	Close()
+}
+
+// Optionally implemented by subscribers able to send a message shared with other subscribers
+// (see Msg), e.g. one prepared just once for all of them, returning false if the subscriber is closed.
+type SharedSender interface {
+	SendSharedAt(boatKey string, shared interface{}, arrived time.Time) bool
+}
+
+// One iteration's live data, by boat key
+type Snapshot[D any] struct {
+	Data map[string]D
+	NoBoats map[string]bool // Boat keys known to no longer exist (rather than just missing from Data)
+}
+
+// Callbacks for boat keys' data being present or missing (all optional)
+type Options[D any] struct {
+	// Called before sending data for a boat key to its subscribers.
+	Present func(boatKey string, data D, subs []Subscriber)
+
+	// Called when there's no data for a boat key (other than one in NoBoats), returning whether
+	// to keep its subscribers for now (e.g. during a short outage). If nil, they're never kept.
+	Missing func(boatKey string, subs []Subscriber) bool
+
+	// Called for each subscriber dropped for lack of data (noBoat if its boat no longer exists),
+	// which should close it. If nil, the subscriber is just closed.
+	Dropped func(boatKey string, sub Subscriber, noBoat bool)
+}
+
+// A live data message formatted for a subscriber
+type Msg struct {
+	Data []byte
+	Shared interface{} // The same message shared with other subscribers, sent instead of Data by a SharedSender (or nil)
+	Arrived time.Time // When the data arrived (zero if not to be measured)
+}
+
+// Formats the live data message for a subscriber (nil to send nothing).
+type FormatFunc[D any] func(sub Subscriber, boatKey string, data D) *Msg
+
+// A subscriber removed from a boat key while publishing
+type Removal struct {
+	BoatKey string
+	Sub Subscriber
+}
+
+type PublishResult struct {
+	Attempts int // Live data messages handed to subscribers (whether or not they could be sent), not counting skipped ones
+	Removed []Removal // Subscribers dropped or found closed (already unsubscribed and closed)
+	EmptiedKeys []string // Boat keys left without subscribers
+}
+
+type Hub[D any] struct {
+	opts Options[D]
+	subs map[string][]Subscriber
+}
+
+
+func New[D any](opts Options[D]) *Hub[D] {
+	return &Hub[D] {
+		opts: opts,
+		subs: make(map[string][]Subscriber),
+	}
+}
+
+// Subscribes a subscriber to a boat key (which it mustn't already be subscribed to).
+func (h *Hub[D]) Subscribe(boatKey string, sub Subscriber) {
+	h.subs[boatKey] = append(h.subs[boatKey], sub)
+}
+
+// Unsubscribes a subscriber from a boat key, returning whether the boat key is now left without subscribers.
+func (h *Hub[D]) Unsubscribe(boatKey string, sub Subscriber) bool {
+	subs, exists := h.subs[boatKey]
+	if !exists {
+		return false
+	}
+
+	for i, s := range subs {
+		if s == sub {
+			subs = append(subs[:i], subs[i + 1:]...)
+			break // The subscriber will only be subscribed once, so we're done.
+		}
+	}
+
+	if len(subs) == 0 {
+		delete(h.subs, boatKey)
+		return true
+	}
+
+	h.subs[boatKey] = subs
+	return false
+}
+
+// Returns a boat key's subscribers (which mustn't be modified).
+func (h *Hub[D]) Subscribers(boatKey string) []Subscriber {
+	return h.subs[boatKey]
+}
+
+// Returns the number of subscribers to a boat key.
+func (h *Hub[D]) Count(boatKey string) int {
+	return len(h.subs[boatKey])
+}
+
+// Returns the number of boat keys with subscribers.
+func (h *Hub[D]) Keys() int {
+	return len(h.subs)
+}
+
+// Sends a snapshot's data to the subscribers of each boat key, as formatted by format, dropping the
+// subscribers of boat keys without data (see Options), and removing those found to be closed.
+func (h *Hub[D]) Publish(snap *Snapshot[D], format FormatFunc[D]) *PublishResult {
+	result := &PublishResult {}
+
+	for boatKey, subs := range h.subs {
+		data, exists := snap.Data[boatKey]
+		if !exists {
+			noBoat := snap.NoBoats[boatKey]
+			if !noBoat && h.opts.Missing != nil && h.opts.Missing(boatKey, subs) {
+				continue
+			}
+
+			for _, sub := range subs {
+				if h.opts.Dropped != nil {
+					h.opts.Dropped(boatKey, sub, noBoat)
+				} else {
+					sub.Close()
+				}
+				result.Removed = append(result.Removed, Removal { boatKey, sub })
+			}
+
+			continue
+		}
+
+		if h.opts.Present != nil {
+			h.opts.Present(boatKey, data, subs)
+		}
+
+		for _, sub := range subs {
+			msg := format(sub, boatKey, data)
+			if msg == nil {
+				continue // Nothing to send this time (e.g. skipped while shedding load).
+			}
+
+			result.Attempts++
+			if !send(sub, boatKey, msg) {
+				// Closed (e.g. due to an earlier send error), so remove it.
+				sub.Close()
+				result.Removed = append(result.Removed, Removal { boatKey, sub })
+			}
+		}
+	}
+
+	for _, r := range result.Removed {
+		if h.Unsubscribe(r.BoatKey, r.Sub) {
+			result.EmptiedKeys = append(result.EmptiedKeys, r.BoatKey)
+		}
+	}
+
+	return result
+}
+
+func send(sub Subscriber, boatKey string, msg *Msg) bool {
+	if msg.Shared != nil {
+		if ss, ok := sub.(SharedSender); ok {
+			return ss.SendSharedAt(boatKey, msg.Shared, msg.Arrived)
+		}
+	}
+
+	return sub.SendLiveAt(boatKey, msg.Data, msg.Arrived)
+}
@@ -0,0 +1,186 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hub
+
+import (
+	"testing"
+	"time"
+)
+
+
+type testSub struct {
+	Sent []string
+	Closed bool
+	Fail bool
+}
+
+func (s *testSub) SendLiveAt(boatKey string, data []byte, arrived time.Time) bool {
+	if s.Fail || s.Closed {
+		return false
+	}
+
+	s.Sent = append(s.Sent, boatKey + ":" + string(data))
+	return true
+}
+
+func (s *testSub) Close() {
+	s.Closed = true
+}
+
+type testSharedSub struct {
+	testSub
+	Shared []interface{}
+}
+
+func (s *testSharedSub) SendSharedAt(boatKey string, shared interface{}, arrived time.Time) bool {
+	s.Shared = append(s.Shared, shared)
+	return true
+}
+
+func testFormat(sub Subscriber, boatKey string, data string) *Msg {
+	return &Msg { Data: []byte(data) }
+}
+
+
+func TestSubscribe(t *testing.T) {
+	h := New(Options[string] {})
+	a, b := &testSub {}, &testSub {}
+
+	h.Subscribe("K1", a)
+	h.Subscribe("K1", b)
+	h.Subscribe("K2", a)
+
+	if h.Count("K1") != 2 || h.Count("K2") != 1 || h.Count("K3") != 0 || h.Keys() != 2 {
+		t.Errorf("Unexpected subscriber counts!")
+	}
+
+	if h.Unsubscribe("K1", a) || h.Count("K1") != 1 || h.Subscribers("K1")[0] != b {
+		t.Errorf("Subscriber not unsubscribed!")
+	}
+
+	if !h.Unsubscribe("K2", a) || h.Keys() != 1 {
+		t.Errorf("Emptied boat key not removed!")
+	}
+
+	if h.Unsubscribe("K3", a) {
+		t.Errorf("Unknown boat key reported as emptied!")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var present []string
+	h := New(Options[string] {
+		Present: func (boatKey string, data string, subs []Subscriber) { present = append(present, boatKey) },
+	})
+	a, b, c := &testSub {}, &testSub {}, &testSub { Fail: true }
+
+	h.Subscribe("K1", a)
+	h.Subscribe("K1", b)
+	h.Subscribe("K2", b)
+	h.Subscribe("K2", c)
+
+	result := h.Publish(&Snapshot[string] { Data: map[string]string { "K1": "one", "K2": "two", "K3": "three" } }, testFormat)
+
+	if result.Attempts != 4 || len(present) != 2 {
+		t.Errorf("Unexpected attempts (%d) or present boat keys (%v)!", result.Attempts, present)
+	}
+
+	if len(a.Sent) != 1 || a.Sent[0] != "K1:one" || len(b.Sent) != 2 {
+		t.Errorf("Unexpected sent messages: %v %v", a.Sent, b.Sent)
+	}
+
+	if len(result.Removed) != 1 || result.Removed[0].Sub != c || !c.Closed || h.Count("K2") != 1 || len(result.EmptiedKeys) != 0 {
+		t.Errorf("Failed subscriber not removed: %+v", result)
+	}
+}
+
+func TestPublishSkipped(t *testing.T) {
+	h := New(Options[string] {})
+	a, b := &testSub {}, &testSub {}
+
+	h.Subscribe("K1", a)
+	h.Subscribe("K1", b)
+
+	// Subscribers formatted nothing for aren't sent anything, nor counted.
+	result := h.Publish(&Snapshot[string] { Data: map[string]string { "K1": "one" } }, func (sub Subscriber, boatKey string, data string) *Msg {
+		if sub == b {
+			return nil
+		}
+		return testFormat(sub, boatKey, data)
+	})
+
+	if result.Attempts != 1 || len(a.Sent) != 1 || len(b.Sent) != 0 || len(result.Removed) != 0 {
+		t.Errorf("Unexpected attempts (%d) or sent messages: %v %v", result.Attempts, a.Sent, b.Sent)
+	}
+}
+
+func TestPublishMissing(t *testing.T) {
+	keep := true
+	var dropped []bool
+	h := New(Options[string] {
+		Missing: func (boatKey string, subs []Subscriber) bool { return keep },
+		Dropped: func (boatKey string, sub Subscriber, noBoat bool) {
+			dropped = append(dropped, noBoat)
+			sub.Close()
+		},
+	})
+	a, b := &testSub {}, &testSub {}
+
+	h.Subscribe("K1", a)
+	h.Subscribe("K2", b)
+
+	// Missing boat keys are kept, but those that no longer exist aren't.
+	snap := &Snapshot[string] { Data: map[string]string {}, NoBoats: map[string]bool { "K2": true } }
+	result := h.Publish(snap, testFormat)
+
+	if len(result.Removed) != 1 || !b.Closed || a.Closed || len(dropped) != 1 || !dropped[0] {
+		t.Errorf("Unexpected removals: %+v", result)
+	}
+
+	if len(result.EmptiedKeys) != 1 || result.EmptiedKeys[0] != "K2" || h.Keys() != 1 {
+		t.Errorf("Unexpected emptied boat keys: %v", result.EmptiedKeys)
+	}
+
+	keep = false
+	result = h.Publish(snap, testFormat)
+
+	if len(result.Removed) != 1 || !a.Closed || len(dropped) != 2 || dropped[1] || h.Keys() != 0 {
+		t.Errorf("Missing boat key's subscriber not dropped: %+v", result)
+	}
+}
+
+func TestPublishShared(t *testing.T) {
+	h := New(Options[string] {})
+	a, b := &testSub {}, &testSharedSub {}
+
+	h.Subscribe("K1", a)
+	h.Subscribe("K1", b)
+
+	shared := "prepared"
+	h.Publish(&Snapshot[string] { Data: map[string]string { "K1": "one" } }, func (sub Subscriber, boatKey string, data string) *Msg {
+		return &Msg { Data: []byte(data), Shared: &shared }
+	})
+
+	// Subscribers unable to send shared messages are sent the data instead.
+	if len(a.Sent) != 1 || a.Sent[0] != "K1:one" {
+		t.Errorf("Unexpected sent messages: %v", a.Sent)
+	}
+
+	if len(b.Sent) != 0 || len(b.Shared) != 1 || b.Shared[0] != &shared {
+		t.Errorf("Shared message not sent: %v %v", b.Sent, b.Shared)
+	}
+}
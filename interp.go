@@ -0,0 +1,150 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+
+// Interpolation:
+//
+// For smooth map animation, with -interp-rate set, a bdl, bdl_g or bdl_x
+// request may include "interp":true, and its subscription acknowledgement then
+// includes the rate granted ("interp"). Between iterations, the subscription
+// is then also sent live data with interpolated positions, -interp-rate times
+// per poll interval in all, without asking the simulator: each boat is dead
+// reckoned from its latest data along its COG at its SOG, with the COG turning
+// at the rate it turned between the latest two iterations. Interpolated live
+// data is flagged with "interp":true, and has no "ts" (as it didn't arrive
+// from the simulator). Boats without fresh data in the latest iteration (e.g.
+// last known data during a simulator outage) aren't interpolated, and a
+// subscription in high-frequency mode (see hf.go) gets real data instead.
+
+const INTERP_RATE_MAX = 10
+const INTERP_TURN_RATE_MAX = 30.0 // Degrees per second
+
+type InterpSample struct {
+	Data BoatDataLiveRespMsg
+	At time.Time // Tick of the iteration the data is from
+	TurnRate float64 // COG change (degrees per second) since the previous iteration
+}
+
+// Connections wanting interpolated data, and the latest data to interpolate from, by boat key
+var _interpConns = make(map[*WsConn]bool)
+var _interpSamples = make(map[string]*InterpSample)
+
+
+// Returns whether a subscription asked for (and can have) interpolated live data.
+func reqInterp(req *ReqMsg) bool {
+	return req.Interp && _config.InterpRate > 0
+}
+
+// Called (with _lock held) once per iteration to note the data to interpolate from until the next.
+func updateInterp(resps map[string]BoatDataLiveRespMsg, tick time.Time) {
+	if _config.InterpRate == 0 {
+		return
+	}
+
+	_interpConns = make(map[*WsConn]bool)
+	for conn, connCtx := range _conns {
+		if connCtx.Interp {
+			_interpConns[conn] = true
+		}
+	}
+
+	samples := make(map[string]*InterpSample)
+	if len(_interpConns) > 0 {
+		for boatKey, resp := range resps {
+			if resp.LastKnown {
+				continue
+			}
+
+			sample := &InterpSample { Data: resp, At: tick }
+			if prev, exists := _interpSamples[boatKey]; exists {
+				dt := tick.Sub(prev.At).Seconds()
+				if dt > 0.0 && dt <= 2.0 * _config.PollInterval.Seconds() {
+					turn := math.Mod(resp.Cog - prev.Data.Cog + 540.0, 360.0) - 180.0
+					sample.TurnRate = math.Max(-INTERP_TURN_RATE_MAX, math.Min(turn / dt, INTERP_TURN_RATE_MAX))
+				}
+			}
+			samples[boatKey] = sample
+		}
+	}
+	_interpSamples = samples
+}
+
+// Dead reckons a boat's data to the given time.
+func interpolate(sample *InterpSample, at time.Time) BoatDataLiveRespMsg {
+	dt := at.Sub(sample.At).Seconds()
+	resp := sample.Data
+
+	// Sail along the average COG over the time, which is the one halfway through for a steady turn.
+	cog := (sample.Data.Cog + sample.TurnRate * dt / 2.0) * math.Pi / 180.0
+	dist := sample.Data.Sog * dt / 3600.0 / 60.0 // Degrees of latitude
+
+	resp.Lat = math.Max(-90.0, math.Min(sample.Data.Lat + dist * math.Cos(cog), 90.0))
+	resp.Lon = normalizeLon(sample.Data.Lon + dist * math.Sin(cog) / math.Max(math.Cos(resp.Lat * math.Pi / 180.0), 0.01))
+	resp.Cog = normalizeCourse(sample.Data.Cog + sample.TurnRate * dt)
+
+	resp.Interp = true
+	resp.Ts = 0
+	resp.ArrivedAt = time.Time {}
+
+	return resp
+}
+
+// Sends interpolated live data (as of the given time) to the connections wanting it.
+func interpSubTick(at time.Time) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	if len(_interpConns) == 0 {
+		return
+	}
+
+	// The latest iteration's data, with the boats that can be interpolated moved on
+	resps := make(map[string]BoatDataLiveRespMsg, len(_latestResps))
+	for boatKey, resp := range _latestResps {
+		if sample, exists := _interpSamples[boatKey]; exists {
+			resp = interpolate(sample, at)
+		}
+		resps[boatKey] = resp
+	}
+	groupIndexes := newGroupIndexes(resps)
+
+	for conn, _ := range _interpConns {
+		connCtx, exists := _conns[conn]
+		if !exists || _hfConns[conn] {
+			continue // Removed since the latest iteration, or getting real data instead.
+		}
+
+		resp, exists := resps[connCtx.BoatKey]
+		if !exists || !resp.Interp {
+			continue
+		}
+
+		msg := formatLiveMsg(conn, resp, resps, groupIndexes, nil)
+		if msg != nil {
+			conn.SendLiveAt(connCtx.BoatKey, msg.Data, msg.Arrived) // Any failure will be picked up by the next iteration.
+		}
+
+		_countMsgs++
+	}
+}
@@ -0,0 +1,82 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+
+func TestInterpolate(t *testing.T) {
+	tick := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Due east at 36 kn along the equator, so 0.01 NM a second.
+	sample := &InterpSample {
+		Data: BoatDataLiveRespMsg { Lat: 0.0, Lon: 179.9999, Cog: 90.0, Sog: 36.0, Ts: 1, ArrivedAt: tick },
+		At: tick,
+	}
+
+	resp := interpolate(sample, tick.Add(30 * time.Second))
+	if !resp.Interp || resp.Ts != 0 || !resp.ArrivedAt.IsZero() {
+		t.Errorf("Interpolated data not flagged as such: %+v", resp)
+	}
+	if math.Abs(resp.Lat) > 1e-9 || math.Abs(resp.Lon - (-179.9999 + 0.3 / 60.0 - 0.0002)) > 1e-9 || resp.Cog != 90.0 {
+		t.Errorf("Unexpected interpolated position across the antimeridian: %+v", resp)
+	}
+
+	// Turning from north to east over 10 seconds (at 9 degrees a second) ends up heading east, having sailed north-east.
+	sample.Data.Lon = 0.0
+	sample.Data.Cog = 0.0
+	sample.TurnRate = 9.0
+	resp = interpolate(sample, tick.Add(10 * time.Second))
+	if resp.Cog != 90.0 || resp.Lat <= 0.0 || math.Abs(resp.Lat - resp.Lon) > 1e-9 {
+		t.Errorf("Unexpected interpolated position while turning: %+v", resp)
+	}
+}
+
+func TestUpdateInterp(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	interpRate := _config.InterpRate
+	_config.InterpRate = 4
+	defer func() { _config.InterpRate = interpRate }()
+
+	defer updateInterp(nil, time.Time {}) // Once the connection's gone
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	_conns[wc] = ConnCtx { BoatKey: "turning", Interp: true }
+	defer delete(_conns, wc)
+
+	tick := time.Now()
+	updateInterp(map[string]BoatDataLiveRespMsg { "turning": { Cog: 350.0 }, "stale": { LastKnown: true } }, tick)
+	updateInterp(map[string]BoatDataLiveRespMsg { "turning": { Cog: 10.0 }, "stale": { LastKnown: true } }, tick.Add(_config.PollInterval))
+
+	sample := _interpSamples["turning"]
+	if sample == nil || math.Abs(sample.TurnRate - 20.0 / _config.PollInterval.Seconds()) > 1e-9 {
+		t.Errorf("Unexpected sample for turning boat: %+v", sample)
+	}
+	if _, exists := _interpSamples["stale"]; exists {
+		t.Errorf("Last known data to be interpolated!")
+	}
+	if !_interpConns[wc] {
+		t.Errorf("Connection wanting interpolated data not noted!")
+	}
+}
@@ -0,0 +1,70 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+
+// IP allow/deny lists:
+//
+// With -allow-ips, only requests from clients (see client-ip.go, so behind a
+// trusted proxy, the forwarded client address) within the given addresses or
+// CIDR ranges are served by the public listener, and with -deny-ips, requests
+// from within the given ones aren't, regardless of -allow-ips. Rejected
+// requests, including WebSocket upgrade requests, get a 403 response before
+// anything else is done with them. The admin listener isn't affected.
+
+var _countIpRejects int64 = 0
+
+
+// Returns whether a client IP address is allowed by -allow-ips and -deny-ips.
+func ipAllowed(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// Not an IP address (e.g. a Unix socket), so only allowed if no allow list is needed.
+		return len(_config.AllowIps) == 0
+	}
+
+	if ipInRanges(ip, _config.DenyIps) {
+		return false
+	}
+	return len(_config.AllowIps) == 0 || ipInRanges(ip, _config.AllowIps)
+}
+
+// Wraps a handler to reject requests from clients not allowed by -allow-ips and -deny-ips.
+func withIpFilter(handler http.Handler) http.Handler {
+	if len(_config.AllowIps) == 0 && len(_config.DenyIps) == 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		ip := clientIp(r)
+		if !ipAllowed(ip) {
+			log.Println("Rejecting request from disallowed address " + ip + " for " + r.URL.Path)
+			atomic.AddInt64(&_countIpRejects, 1)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
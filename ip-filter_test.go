@@ -0,0 +1,66 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+
+func TestIpFilter(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	savedConfig := *_config
+	defer func() { *_config = savedConfig }()
+
+	var err error
+	_config.AllowIps, err = parseIpRanges("10.0.0.0/8,2001:db8::/32", "allowed client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_config.DenyIps, err = parseIpRanges("10.6.6.6", "denied client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := withIpFilter(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {}))
+
+	for remoteAddr, expected := range map[string]int {
+		"10.1.2.3:1234": http.StatusOK,
+		"[2001:db8::1]:1234": http.StatusOK,
+		"10.6.6.6:1234": http.StatusForbidden,
+		"203.0.113.5:1234": http.StatusForbidden,
+		"@": http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/ws", nil)
+		r.RemoteAddr = remoteAddr
+		handler.ServeHTTP(w, r)
+		if w.Code != expected {
+			t.Errorf("Request from %s got status %d, expected %d", remoteAddr, w.Code, expected)
+		}
+	}
+
+	for _, s := range []string { "10.0.0.0/33", "nonsense" } {
+		if _, err := parseIpRanges(s, "allowed client"); err == nil {
+			t.Errorf("Expected error for invalid range: %s", s)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+
+// Boat key validation:
+//
+// Any well-formed boat key is accepted by the regexp check, so with
+// -validate-keys (the default), a bdl, bdl_g or bdl_x subscription to a boat
+// that isn't already known (i.e. subscribed to, or in the latest iteration's
+// data) is first checked against the simulator, before the client is sent
+// "subscribed". A boat the simulator doesn't know is rejected with an
+// "unknown_boat" error, and the connection is closed (with close code 4001).
+//
+// If the simulator can't be asked (or -validate-keys is off), the first poll
+// decides instead: a "noboat" response for a boat not yet seen since it was
+// subscribed to is reported the same way, rather than as a deleted boat (close
+// code 4004, which is kept for boats that disappear while being streamed).
+
+const ERR_UNKNOWN_BOAT = "unknown_boat"
+
+// Subscribed boat keys not yet seen in the simulator's data (guarded by _lock)
+var _unconfirmedKeys = make(map[string]bool)
+
+
+// Checks a boat key against the simulator, unless it's already known. Returns false (having
+// closed the connection) if the simulator doesn't know it. Must be called without _lock held.
+func validateBoatKey(ctx context.Context, req *ReqMsg, conn *WsConn) bool {
+	if !_config.ValidateKeys || _config.ClusterRole == CLUSTER_ROLE_EDGE {
+		return true
+	}
+
+	_lock.Lock()
+	_, known := _latestResps[req.BoatKey]
+	known = known || _hub.Count(req.BoatKey) > 0
+	_lock.Unlock()
+
+	if known {
+		return true
+	}
+
+	_, noBoats := simClient(req.Sim).GetBoatData(ctx, []SimBoatDataReq { { BoatKey: req.BoatKey } })
+	if noBoats[req.BoatKey] {
+		_lock.Lock()
+		noteNoboat(req.BoatKey, time.Now()) // See deleted-boats.go.
+		_lock.Unlock()
+
+		log.Println("Client (" + conn.RemoteIp + ") sent unknown boat key: " + req.BoatKey)
+		rejectUnknownBoat(conn)
+		return false
+	}
+
+	// Known to the simulator, or it couldn't be asked (in which case the first poll decides).
+	return true
+}
+
+// Called (with _lock held) before a connection subscribes to a boat key, to have the first poll
+// decide whether it's an unknown boat if it hasn't been seen yet.
+func markUnconfirmedKey(boatKey string) {
+	if _, known := _latestResps[boatKey]; !known && _hub.Count(boatKey) == 0 {
+		_unconfirmedKeys[boatKey] = true
+	}
+}
+
+func rejectUnknownBoat(conn *WsConn) {
+	noteInvalidKey(conn.RemoteIp) // See abuse.go.
+	sendErrorMsg(conn, ERR_UNKNOWN_BOAT, "Unknown boat")
+	conn.CloseWithReason(CLOSE_INVALID_KEY, "Unknown boat")
+}
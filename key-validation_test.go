@@ -0,0 +1,84 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestUnknownBoatOnFirstPoll(t *testing.T) {
+	const unconfirmed = "unconfirmed-key"
+	const streamed = "streamed-key"
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	markUnconfirmedKey(unconfirmed)
+	markUnconfirmedKey(streamed)
+	hubPresent(streamed, BoatDataLiveRespMsg {}, nil)
+	defer delete(_unconfirmedKeys, unconfirmed)
+
+	// A boat never seen since being subscribed to is unknown...
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	hubDropped(unconfirmed, wc, true)
+	expectQueued(t, wc, `{"type":"error","error":"unknown_boat","msg":"Unknown boat"}`)
+	if wc.closeCode != CLOSE_INVALID_KEY {
+		t.Errorf("Unexpected close code: %d", wc.closeCode)
+	}
+
+	// ...while one that was streamed has been deleted.
+	wc = testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	hubDropped(streamed, wc, true)
+	expectQueued(t, wc, `{"type":"error","error":"boat_deleted","msg":"Boat deleted"}`)
+	if wc.closeCode != CLOSE_BOAT_DELETED {
+		t.Errorf("Unexpected close code: %d", wc.closeCode)
+	}
+}
+
+func TestNoboatCooldown(t *testing.T) {
+	const boatKey = "noboat-key"
+
+	_lock.Lock()
+	defer _lock.Unlock()
+	defer delete(_noboatKeys, boatKey)
+
+	now := time.Now()
+	cooldown := _config.NoboatCooldown
+
+	// Each further "noboat" doubles the cooldown...
+	updateNoboatKeys(nil, map[string]bool { boatKey: true }, now)
+	updateNoboatKeys(nil, map[string]bool { boatKey: true }, now)
+	if !isNoboatKey(boatKey, now.Add(cooldown)) || isNoboatKey(boatKey, now.Add(2 * cooldown)) {
+		t.Errorf("Unexpected cooldown: %+v", _noboatKeys[boatKey])
+	}
+
+	// ...up to the maximum.
+	for i := 0; i < 100; i++ {
+		noteNoboat(boatKey, now)
+	}
+	if !isNoboatKey(boatKey, now.Add(NOBOAT_COOLDOWN_MAX - time.Second)) || isNoboatKey(boatKey, now.Add(NOBOAT_COOLDOWN_MAX)) {
+		t.Errorf("Unexpected maximum cooldown: %+v", _noboatKeys[boatKey])
+	}
+
+	// Any data for the boat starts over.
+	updateNoboatKeys(map[string]BoatDataLiveRespMsg { boatKey: {} }, nil, now)
+	if isNoboatKey(boatKey, now) {
+		t.Errorf("Boat key still on cooldown after data!")
+	}
+}
@@ -0,0 +1,70 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+
+// Delivery latency:
+//
+// Boat data is timestamped on arrival from the simulator (or, for an edge
+// instance, from the cluster poller), and the time from then until each live
+// data message has been written to its client is recorded in a histogram,
+// which is reported with the other statistics. With -embed-timestamps, the
+// arrival time is also included in each boat's data, as "ts" (Unix time in
+// ms), so that clients can measure it too.
+
+// Upper bounds (in ms) of the histogram buckets, other than the last (unbounded) one
+var _latencyBucketsMs = [...]int64 { 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500 }
+
+const LATENCY_NUM_BUCKETS = len(_latencyBucketsMs) + 1
+
+var _latencyCounts [LATENCY_NUM_BUCKETS]int64
+var _latencySumUs int64 = 0
+
+
+func observeDeliveryLatency(latency time.Duration) {
+	ms := latency.Milliseconds()
+
+	i := 0
+	for i < len(_latencyBucketsMs) && ms > _latencyBucketsMs[i] {
+		i++
+	}
+
+	atomic.AddInt64(&_latencyCounts[i], 1)
+	atomic.AddInt64(&_latencySumUs, latency.Microseconds())
+}
+
+// Names a histogram bucket by its upper bound, e.g. "<=5" (or ">2500" for the last one).
+func latencyBucketName(i int) string {
+	if i < len(_latencyBucketsMs) {
+		return "<=" + strconv.FormatInt(_latencyBucketsMs[i], 10)
+	}
+	return ">" + strconv.FormatInt(_latencyBucketsMs[len(_latencyBucketsMs) - 1], 10)
+}
+
+// Timestamps boat data on arrival.
+func stampArrival(resp *BoatDataLiveRespMsg, arrived time.Time) {
+	resp.ArrivedAt = arrived
+	if _config.EmbedTimestamps {
+		resp.Ts = arrived.UnixMilli()
+	}
+}
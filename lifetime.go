@@ -0,0 +1,120 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"time"
+)
+
+
+// Maximum connection lifetime:
+//
+// With -max-conn-lifetime set, a connection (subscribed or not) that has been
+// open for longer than that is sent a "reauth" message and then closed gracefully, forcing
+// the client to reconnect (with fresh credentials, e.g. after key rotation).
+// Any resumable session on the connection is ended too, since resuming it
+// would otherwise bypass the re-authentication.
+//
+// Similarly, with -max-sub-lifetime set, a subscription that has been going
+// for longer than that is sent a "resubscribe" message and then closed
+// normally, so that forgotten dashboards don't poll the simulator forever.
+// The lifetime counts from the original subscription, through any session
+// resumes and reconnect tokens, so that only a new subscription starts over.
+
+type ReauthMsg struct {
+	Type string `json:"type"`
+	Msg string `json:"msg"`
+}
+
+type ResubscribeMsg struct {
+	Type string `json:"type"`
+	Msg string `json:"msg"`
+}
+
+
+func connLifetimeExpired(conn *WsConn, now time.Time) bool {
+	return _config.MaxConnLifetime > 0 && now.Sub(conn.CreatedAt) > _config.MaxConnLifetime
+}
+
+func subLifetimeExpired(connCtx *ConnCtx, now time.Time) bool {
+	return _config.MaxSubLifetime > 0 && !connCtx.SubscribedAt.IsZero() && now.Sub(connCtx.SubscribedAt) > _config.MaxSubLifetime
+}
+
+func sendReauthMsgAndClose(conn *WsConn) {
+	conn.SendJSON(&ReauthMsg {
+		Type: "reauth",
+		Msg: "Maximum connection lifetime reached; reconnect to continue",
+	})
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Maximum connection lifetime reached")
+}
+
+func sendResubscribeMsgAndClose(conn *WsConn) {
+	conn.SendJSON(&ResubscribeMsg {
+		Type: "resubscribe",
+		Msg: "Maximum subscription lifetime reached; subscribe again to continue",
+	})
+	conn.CloseWithReason(CLOSE_NORMAL, "Maximum subscription lifetime reached")
+}
+
+// Called (with _lock held) once per iteration to close connections (subscribed or not) that have
+// reached their maximum lifetime, and subscribed ones whose subscription has. Subscribed ones are
+// then removed as usual when next sent to.
+func expireConns() {
+	if _config.MaxConnLifetime <= 0 && _config.MaxSubLifetime <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	var expired []*WsConn
+	if _config.MaxConnLifetime > 0 {
+		_wsConnsLock.Lock()
+		for conn := range _wsConns {
+			if !conn.IsClosed() && connLifetimeExpired(conn, now) {
+				expired = append(expired, conn)
+			}
+		}
+		_wsConnsLock.Unlock()
+	}
+
+	for _, conn := range expired {
+		endSession(conn)
+		sendReauthMsgAndClose(conn)
+	}
+
+	for conn, connCtx := range _conns {
+		if conn.IsClosed() || !subLifetimeExpired(&connCtx, now) {
+			continue
+		}
+
+		endSession(conn)
+		sendResubscribeMsgAndClose(conn)
+	}
+}
+
+// Ends any resumable session on a connection about to be closed, so that its boats are
+// untracked along with the connection. Caller must hold _lock.
+func endSession(conn *WsConn) {
+	connCtx, exists := _conns[conn]
+	if !exists || connCtx.Session == nil {
+		return
+	}
+
+	delete(_sessions, connCtx.Session.Token)
+	connCtx.Session = nil
+	_conns[conn] = connCtx
+}
@@ -0,0 +1,106 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestSubLifetimeExpiry(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	maxSubLifetime := _config.MaxSubLifetime
+	_config.MaxSubLifetime = time.Hour
+	defer func() { _config.MaxSubLifetime = maxSubLifetime }()
+
+	old := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	fresh := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	replay := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	_conns[old] = ConnCtx { BoatKey: "old", SubscribedAt: time.Now().Add(-2 * time.Hour) }
+	_conns[fresh] = ConnCtx { BoatKey: "fresh", SubscribedAt: time.Now() }
+	_conns[replay] = ConnCtx { BoatKey: "replay" }
+	defer delete(_conns, old)
+	defer delete(_conns, fresh)
+	defer delete(_conns, replay)
+
+	expireConns()
+
+	expectQueued(t, old, `{"type":"resubscribe","msg":"Maximum subscription lifetime reached; subscribe again to continue"}`)
+	if old.closeCode != CLOSE_NORMAL {
+		t.Errorf("Unexpected close code: %d", old.closeCode)
+	}
+
+	// Newer subscriptions, and those without a maximum lifetime, are left alone.
+	expectQueued(t, fresh)
+	expectQueued(t, replay)
+}
+
+func TestConnLifetimeExpiry(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	maxConnLifetime := _config.MaxConnLifetime
+	_config.MaxConnLifetime = time.Hour
+	defer func() { _config.MaxConnLifetime = maxConnLifetime }()
+
+	idle := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	subscribed := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	fresh := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	idle.CreatedAt = time.Now().Add(-2 * time.Hour)
+	subscribed.CreatedAt = time.Now().Add(-2 * time.Hour)
+	fresh.CreatedAt = time.Now()
+
+	_wsConnsLock.Lock()
+	for _, wc := range []*WsConn { idle, subscribed, fresh } {
+		_wsConns[wc] = true
+	}
+	_wsConnsLock.Unlock()
+	defer func() {
+		_wsConnsLock.Lock()
+		for _, wc := range []*WsConn { idle, subscribed, fresh } {
+			delete(_wsConns, wc)
+		}
+		_wsConnsLock.Unlock()
+	}()
+
+	session := &Session { Token: "lifetime-session", Conn: subscribed }
+	_sessions[session.Token] = session
+	_conns[subscribed] = ConnCtx { BoatKey: "subscribed", Session: session }
+	defer delete(_conns, subscribed)
+	defer delete(_sessions, session.Token)
+
+	expireConns()
+
+	// Connections past their lifetime are closed, whether subscribed or not.
+	for _, wc := range []*WsConn { idle, subscribed } {
+		expectQueued(t, wc, `{"type":"reauth","msg":"Maximum connection lifetime reached; reconnect to continue"}`)
+		if wc.closeCode != CLOSE_POLICY_VIOLATION {
+			t.Errorf("Unexpected close code: %d", wc.closeCode)
+		}
+	}
+	expectQueued(t, fresh)
+
+	// Along with any session, which can't be resumed.
+	if _, exists := _sessions[session.Token]; exists || _conns[subscribed].Session != nil {
+		t.Errorf("Session not ended with its connection")
+	}
+}
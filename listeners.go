@@ -0,0 +1,169 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+
+// Additional listeners:
+//
+// Besides the main listener (listenHostPort, or a socket passed by systemd),
+// the same endpoints can be served on any number of additional listeners given
+// with -listen, e.g. plain HTTP on the LAN, TLS on the WAN, and a Unix socket
+// for a local reverse proxy, all from one process:
+//
+// <host:port>       Plain HTTP (and WebSocket)
+// tls:<host:port>   HTTPS (and secure WebSocket), with -tls-cert and -tls-key
+// h2:<host:port>    As tls:, also offering HTTP/2 and WebSockets over HTTP/2 (see h2-websocket.go)
+// unix:<path>       Plain HTTP on a Unix socket (replacing any stale socket file)
+
+const LISTENER_TCP = "tcp"
+const LISTENER_TLS = "tls"
+const LISTENER_H2 = "h2"
+const LISTENER_UNIX = "unix"
+
+type ListenerSpec struct {
+	Kind string
+	Addr string
+}
+
+
+func (spec ListenerSpec) isTls() bool {
+	return spec.Kind == LISTENER_TLS || spec.Kind == LISTENER_H2
+}
+
+func (spec ListenerSpec) String() string {
+	if spec.Kind == LISTENER_TCP {
+		return spec.Addr
+	}
+	return spec.Kind + ":" + spec.Addr
+}
+
+// Parses additional listeners given as "[tls:|h2:|unix:]<address>[,...]".
+func parseListeners(s string) ([]ListenerSpec, error) {
+	specs := make([]ListenerSpec, 0)
+	if s == "" {
+		return specs, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		spec := ListenerSpec { Kind: LISTENER_TCP, Addr: strings.TrimSpace(item) }
+		for _, kind := range []string { LISTENER_TLS, LISTENER_H2, LISTENER_UNIX } {
+			if strings.HasPrefix(spec.Addr, kind + ":") {
+				spec = ListenerSpec { Kind: kind, Addr: strings.TrimPrefix(spec.Addr, kind + ":") }
+				break
+			}
+		}
+
+		if spec.Addr == "" {
+			return nil, errors.New("Invalid listener: " + item)
+		}
+		if spec.Kind != LISTENER_UNIX {
+			if _, _, err := net.SplitHostPort(spec.Addr); err != nil {
+				return nil, errors.New("Invalid listener: " + item)
+			}
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// Opens the additional listeners, closing any already opened if one fails.
+func openListeners(specs []ListenerSpec) ([]net.Listener, error) {
+	var cert *tls.Certificate = nil
+	listeners := make([]net.Listener, 0, len(specs))
+
+	fail := func (err error) ([]net.Listener, error) {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		var ln net.Listener
+		var err error
+
+		switch spec.Kind {
+		case LISTENER_TCP:
+			ln, err = net.Listen("tcp", spec.Addr)
+
+		case LISTENER_TLS, LISTENER_H2:
+			if cert == nil {
+				c, err := tls.LoadX509KeyPair(_config.TlsCert, _config.TlsKey)
+				if err != nil {
+					return fail(err)
+				}
+				cert = &c
+			}
+
+			tlsConfig := &tls.Config {
+				Certificates: []tls.Certificate { *cert },
+				MinVersion: tls.VersionTLS12,
+			}
+			if spec.Kind == LISTENER_H2 {
+				// Offering h2 is enough for http.Serve to speak HTTP/2 on the connection.
+				tlsConfig.NextProtos = []string { "h2", "http/1.1" }
+				if !h2XconnectEnabled() {
+					log.Println("Warning: GODEBUG=" + H2_XCONNECT_GODEBUG + " isn't set, so WebSockets on " + spec.String() + " will only work over HTTP/1.1")
+				}
+			}
+
+			ln, err = net.Listen("tcp", spec.Addr)
+			if err == nil {
+				ln = tls.NewListener(ln, tlsConfig)
+			}
+
+		case LISTENER_UNIX:
+			if info, statErr := os.Lstat(spec.Addr); statErr == nil && info.Mode() & os.ModeSocket != 0 {
+				os.Remove(spec.Addr) // Left behind by an earlier run.
+			}
+			ln, err = net.Listen("unix", spec.Addr)
+		}
+
+		if err != nil {
+			return fail(err)
+		}
+
+		log.Println("Also listening on " + spec.String() + "...")
+		listeners = append(listeners, ln)
+	}
+
+	return listeners, nil
+}
+
+// Serves the handler on each of the additional listeners.
+func serveListeners(listeners []net.Listener, handler http.Handler) {
+	for _, ln := range listeners {
+		go func (ln net.Listener) {
+			err := http.Serve(ln, handler)
+			if err != nil {
+				log.Println(err)
+			}
+		}(ln)
+	}
+}
@@ -0,0 +1,179 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"os"
+	"testing"
+	"time"
+)
+
+
+func TestParseListeners(t *testing.T) {
+	specs, err := parseListeners(":8080,tls:0.0.0.0:8443,h2:[::]:443, unix:/run/snsw.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ListenerSpec {
+		{ LISTENER_TCP, ":8080" },
+		{ LISTENER_TLS, "0.0.0.0:8443" },
+		{ LISTENER_H2, "[::]:443" },
+		{ LISTENER_UNIX, "/run/snsw.sock" },
+	}
+	if len(specs) != len(expected) {
+		t.Fatalf("Unexpected listeners: %v", specs)
+	}
+	for i := range expected {
+		if specs[i] != expected[i] {
+			t.Errorf("Listener %d: expected %v, but got %v", i, expected[i], specs[i])
+		}
+	}
+
+	for _, s := range []string { "8080", "tls:", "h2:", "unix:", ":8080," } {
+		if _, err := parseListeners(s); err == nil {
+			t.Errorf("Expected invalid listeners: %s", s)
+		}
+	}
+}
+
+func TestServeListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "snsw.sock")
+	listeners, err := openListeners([]ListenerSpec { { LISTENER_TCP, "127.0.0.1:0" }, { LISTENER_UNIX, sock } })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}()
+
+	serveListeners(listeners, http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	get := func (client *http.Client, url string) {
+		t.Helper()
+
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "ok" {
+			t.Errorf("Unexpected response from %s: %s", url, body)
+		}
+	}
+
+	get(http.DefaultClient, "http://" + listeners[0].Addr().String() + "/v1/version")
+	get(&http.Client {
+		Transport: &http.Transport {
+			DialContext: func (ctx context.Context, network string, addr string) (net.Conn, error) {
+				return net.Dial("unix", sock)
+			},
+		},
+	}, "http://unix/v1/version")
+}
+
+func TestH2Listener(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	testWriteCert(t, certFile, keyFile)
+
+	_lock.Lock()
+	savedConfig := *_config
+	_config.TlsCert = certFile
+	_config.TlsKey = keyFile
+	listeners, err := openListeners([]ListenerSpec { { LISTENER_TLS, "127.0.0.1:0" }, { LISTENER_H2, "127.0.0.1:0" } })
+	*_config = savedConfig
+	_lock.Unlock()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}()
+
+	serveListeners(listeners, http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+
+	client := &http.Client {
+		Transport: &http.Transport {
+			TLSClientConfig: &tls.Config { InsecureSkipVerify: true },
+			ForceAttemptHTTP2: true,
+		},
+	}
+
+	for i, expected := range []string { "HTTP/1.1", "HTTP/2.0" } {
+		resp, err := client.Get("https://" + listeners[i].Addr().String() + "/v1/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != expected {
+			t.Errorf("Listener %v: expected %s, but got %s", listeners[i].Addr(), expected, body)
+		}
+	}
+}
+
+func testWriteCert(t *testing.T, certFile string, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate {
+		SerialNumber: big.NewInt(1),
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		DNSNames: []string { "localhost" },
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block { Type: "CERTIFICATE", Bytes: der }), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block { Type: "EC PRIVATE KEY", Bytes: keyDer }), 0600)
+}
@@ -0,0 +1,229 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"sailnavsim-snsw/client"
+)
+
+
+// Load testing ("loadtest" subcommand):
+//
+// Opens a number of WebSocket clients against a running connector, each
+// subscribing with a synthetic boat key (so the connector should be run with
+// -mock-sim), and reports message counts, drop rates and latency percentiles.
+//
+// Latency is measured from the start of the main loop iteration's poll (as given
+// by the most recent "time" message on the connection), so the connector should
+// also be run with "-time-sync-interval 1". Without time messages, only
+// message counts and drop rates (based on the test duration) are reported.
+
+type LoadTestConfig struct {
+	Url string
+	Clients int
+	Keys int
+	Cmd string
+	Duration time.Duration
+	Ramp time.Duration
+}
+
+type LoadTestClientResult struct {
+	Err error
+	Msgs int64
+	Expected int64
+	Latencies []time.Duration
+}
+
+
+func parseLoadTestArgs(args []string) (*LoadTestConfig, error) {
+	cfg := &LoadTestConfig {}
+
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.StringVar(&cfg.Url, "url", "ws://localhost:8080/v1/ws", "WebSocket URL of the connector")
+	fs.IntVar(&cfg.Clients, "clients", 100, "Number of clients")
+	fs.IntVar(&cfg.Keys, "keys", 0, "Number of distinct synthetic boat keys (0 for one per client)")
+	fs.StringVar(&cfg.Cmd, "cmd", "bdl", "Subscription command: \"bdl\" or \"bdl_g\"")
+	fs.DurationVar(&cfg.Duration, "duration", 60 * time.Second, "Test duration (after all clients have connected)")
+	fs.DurationVar(&cfg.Ramp, "ramp", 10 * time.Millisecond, "Delay between connecting clients")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, errors.New("ERROR: " + err.Error())
+	}
+
+	if cfg.Clients < 1 {
+		return nil, errors.New("ERROR: Number of clients must be at least 1")
+	}
+
+	if cfg.Keys < 1 {
+		cfg.Keys = cfg.Clients
+	}
+
+	if cfg.Cmd != "bdl" && cfg.Cmd != "bdl_g" {
+		return nil, errors.New("ERROR: Invalid subscription command: " + cfg.Cmd)
+	}
+
+	return cfg, nil
+}
+
+func loadTestMain(args []string) {
+	cfg, err := parseLoadTestArgs(args)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Printf("Load testing %s with %d clients (%d keys, %s) for %s...\n", cfg.Url, cfg.Clients, cfg.Keys, cfg.Cmd, cfg.Duration)
+
+	// All clients stop at the same time, once the last one has connected and the test duration has passed.
+	stopAt := time.Now().Add(time.Duration(cfg.Clients) * cfg.Ramp + cfg.Duration)
+
+	var wg sync.WaitGroup
+	results := make([]*LoadTestClientResult, cfg.Clients)
+	for i := 0; i < cfg.Clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = loadTestClient(cfg, mockSimKey(fmt.Sprintf("loadtest-%d", i % cfg.Keys)), stopAt)
+		}(i)
+
+		time.Sleep(cfg.Ramp)
+	}
+
+	wg.Wait()
+
+	loadTestReport(results)
+}
+
+func loadTestClient(cfg *LoadTestConfig, boatKey string, stopAt time.Time) *LoadTestClientResult {
+	result := &LoadTestClientResult {}
+
+	c, err := client.Dial(cfg.Url, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer c.Close()
+
+	err = c.SubscribeBoat(boatKey, &client.SubscribeOptions { Group: cfg.Cmd == "bdl_g" })
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	start := time.Now()
+	var poll time.Time
+	var firstIter int64 = -1
+	var lastIter int64 = -1
+	interval := time.Second
+
+	stop := time.After(stopAt.Sub(start))
+
+loop:
+	for {
+		select {
+		case u, ok := <-c.Updates():
+			now := time.Now()
+			if !ok {
+				result.Err = c.Err()
+				break loop
+			}
+
+			switch msg := u.(type) {
+			case *client.BoatDataMsg, *client.GroupMsg:
+				result.Msgs++
+				if !poll.IsZero() {
+					result.Latencies = append(result.Latencies, now.Sub(poll))
+				}
+
+			case *client.SubscribedMsg:
+				if msg.IntervalMs > 0 {
+					interval = time.Duration(msg.IntervalMs) * time.Millisecond
+				}
+
+			case *client.TimeMsg:
+				poll = time.UnixMilli(msg.Poll)
+				if firstIter < 0 {
+					firstIter = msg.Iter
+				}
+				lastIter = msg.Iter
+
+			case *client.ErrorMsg:
+				result.Err = errors.New("Error message received: " + msg.Code + " (" + msg.Msg + ")")
+				return result
+			}
+
+		case <-stop:
+			break loop
+		}
+	}
+
+	if lastIter > firstIter {
+		// One message per iteration, counting from the first iteration with a time message
+		result.Expected = lastIter - firstIter + 1
+	} else {
+		result.Expected = int64(time.Now().Sub(start) / interval)
+	}
+
+	return result
+}
+
+func loadTestReport(results []*LoadTestClientResult) {
+	var failed, msgs, expected int64
+	latencies := make([]time.Duration, 0)
+
+	for _, r := range results {
+		if r.Err != nil {
+			log.Println(r.Err)
+			failed++
+		}
+
+		msgs += r.Msgs
+		expected += r.Expected
+		latencies = append(latencies, r.Latencies...)
+	}
+
+	log.Printf("Clients: %d, failed: %d\n", len(results), failed)
+
+	dropRate := 0.0
+	if expected > 0 && msgs < expected {
+		dropRate = float64(expected - msgs) / float64(expected)
+	}
+	log.Printf("Messages: %d received, ~%d expected (drop rate: %.2f%%)\n", msgs, expected, dropRate * 100.0)
+
+	if len(latencies) == 0 {
+		log.Println("No latencies measured (run the connector with \"-time-sync-interval 1\")")
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p * float64(len(latencies) - 1))]
+	}
+
+	log.Printf("Latency: p50=%s, p90=%s, p99=%s, max=%s\n", percentile(0.5), percentile(0.9), percentile(0.99), latencies[len(latencies) - 1])
+}
@@ -0,0 +1,61 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"regexp"
+)
+
+
+// Log redaction:
+//
+// Boat keys are secrets, so anything that looks like one (32 lowercase hex
+// digits) is replaced in all log output with a short hash ("key:<8 hex
+// digits>"). The same key always hashes the same way, so log lines can still
+// be correlated. This is done on the log output itself, rather than at each
+// log statement, so that keys in error messages (e.g. in track recording file
+// paths) are caught too. It can be disabled with -log-keys, for development.
+
+const LOG_KEY_HASH_LEN = 8 // Hex digits
+
+var _logKeyRegexp *regexp.Regexp = regexp.MustCompile(`\b[0-9a-f]{32}\b`)
+
+type RedactingLogWriter struct {
+	out io.Writer
+}
+
+
+func logInit() {
+	if !_config.LogKeys {
+		log.SetOutput(&RedactingLogWriter { os.Stderr })
+	}
+}
+
+func redactKey(boatKey []byte) []byte {
+	hash := sha256.Sum256(boatKey)
+	return []byte("key:" + hex.EncodeToString(hash[:])[:LOG_KEY_HASH_LEN])
+}
+
+func (w *RedactingLogWriter) Write(p []byte) (int, error) {
+	_, err := w.out.Write(_logKeyRegexp.ReplaceAllFunc(p, redactKey))
+	return len(p), err
+}
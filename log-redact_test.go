@@ -0,0 +1,49 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+
+func TestLogRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	w := &RedactingLogWriter { &buf }
+
+	key := "0123456789abcdef0123456789abcdef"
+	w.Write([]byte("No data for boat key: " + key + "\n"))
+	w.Write([]byte("open /tracks/" + key + "/2026.ndjson: permission denied\n"))
+	w.Write([]byte("Not a key: " + key + "0123\n"))
+
+	lines := strings.Split(buf.String(), "\n")
+	if strings.Contains(lines[0], key) || strings.Contains(lines[1], key) {
+		t.Fatalf("Boat key not redacted: %s", buf.String())
+	}
+
+	// The same key always hashes the same way.
+	redacted := string(redactKey([]byte(key)))
+	if lines[0] != "No data for boat key: " + redacted || lines[1] != "open /tracks/" + redacted + "/2026.ndjson: permission denied" {
+		t.Errorf("Unexpected redaction: %s", buf.String())
+	}
+
+	if lines[2] != "Not a key: " + key + "0123" {
+		t.Errorf("Longer hex string redacted: %s", lines[2])
+	}
+}
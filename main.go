@@ -17,81 +17,310 @@
 package main
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 	"github.com/gorilla/websocket"
 )
 
 func main() {
-	log.Println("SailNavSim WebSocket Connector v1.3.0")
+	log.Println("SailNavSim WebSocket Connector v" + VERSION + " (" + _gitCommit + ", built " + _buildDate + ")")
 
-	listenHostPort, connectHostPort, err := parseArgs(os.Args[1:])
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		loadTestMain(os.Args[2:])
+		return
+	}
+
+	cfg, err := parseArgs(os.Args[1:])
 	if err != nil {
 		log.Println(err)
 		return
 	}
+	_config = cfg
 
-	go boatDataLiveMain(connectHostPort)
+	logInit()
+
+	if cfg.MockSim {
+		cfg.ConnectHostPort = mockSimStart()
+	}
 
-	http.HandleFunc("/v1/ws", wsHandler)
-	http.HandleFunc("/v1/ws/", wsHandler)
+	clusterInit()
+	recorderInit()
+	udpOutputInit()
+	statsFileInit()
+	statsInit()
+	adminInit()
+	systemdInit()
+	shutdownInit()
+	watchListInit()
+	groupFetchInit()
+	tracingInit()
+	memoryInit()
+	reconnectInit()
+	webhooksInit()
+	alertsInit()
+	abuseInit()
 
-	log.Println("About to listen on " + listenHostPort + "...")
+	go boatDataLiveMain(cfg.ConnectHostPort)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/ws", withH2WebSocket(withAbuseCheck(requireAuth(cfg.AuthWs, wsHandler))))
+	mux.HandleFunc("/v1/ws/", withH2WebSocket(withAbuseCheck(requireAuth(cfg.AuthWs, wsHandler))))
+	mux.HandleFunc("/v1/ws/replay", withH2WebSocket(withAbuseCheck(requireAuth(cfg.AuthReplay, wsReplayHandler))))
+	mux.Handle("/v1/version", withCors(http.HandlerFunc(versionHandler)))
+	mux.Handle("/v1/map", withCors(http.HandlerFunc(mapHandler)))
+	mux.HandleFunc("/", statusPageHandler)
+
+	ln := systemdListener()
+	if ln != nil {
+		log.Println("Using listener passed by systemd on " + ln.Addr().String() + "...")
+	} else {
+		log.Println("About to listen on " + cfg.ListenHostPort + "...")
+
+		ln, err = net.Listen("tcp", cfg.ListenHostPort)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
-	err = http.ListenAndServe(listenHostPort, nil)
+	extraListeners, err := openListeners(cfg.Listeners)
 	if err != nil {
 		log.Println(err)
+		return
 	}
-}
 
-func parseArgs(args []string) (string, string, error) {
-	if len(args) != 2 {
-		return "", "", errors.New("ERROR: Program requires two arguments: listenHostPort, connectHostPort")
+	if cfg.Relay != "" {
+		log.Println("Also serving through relay at " + cfg.Relay + "...")
+		extraListeners = append(extraListeners, newRelayListener(cfg.Relay, cfg.RelayToken))
 	}
 
-	listenHostPort := args[0]
-	connectHostPort := args[1]
+	handler := withIpFilter(mux)
+	serveListeners(extraListeners, handler)
+
+	systemdNotify("READY=1")
 
-	return listenHostPort, connectHostPort, nil
+	err = http.Serve(ln, handler)
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 type ReqMsg struct {
 	Cmd string `json:"cmd"`
 	BoatKey string `json:"key"`
+	SpectatorId string `json:"spec"`
+	Session bool `json:"session"`
+	Token string `json:"token"`
+	Admin string `json:"admin"`
+	Text string `json:"text"`
+	Size int `json:"size"`
+	Step float64 `json:"step"`
+	Ais bool `json:"ais"`
+	OthersSog bool `json:"others_sog"`
+	Seq uint64 `json:"seq"`
+	Group ReqGroup `json:"group"`
+	Speed int `json:"speed"`
+	From string `json:"from"`
+	To string `json:"to"`
+	Payload json.RawMessage `json:"payload"`
+	Sim string `json:"sim"`
+	Fields []string `json:"fields"`
+	SmoothCog int `json:"smooth_cog"`
+	Units *Units `json:"units"`
+	Hf bool `json:"hf"`
+	Interp bool `json:"interp"`
+	Bbox []float64 `json:"bbox"`
+	Batch bool `json:"batch"`
+	MsgSeq bool `json:"msg_seq"`
+	Priority string `json:"priority"`
+	Geofences []Geofence `json:"geofences"`
+	Waypoint *Waypoint `json:"waypoint"`
+	Action string `json:"action"`
+	Value json.RawMessage `json:"value"`
+	Id json.RawMessage `json:"id"`
 }
 
-func wsHandler(w http.ResponseWriter, r *http.Request) {
+// Decodes a request message from a client (see req-limits.go).
+func decodeReqMsg(data []byte) (*ReqMsg, error) {
+	var req ReqMsg
+
+	if jsonDepth(data) > REQ_MAX_DEPTH {
+		return nil, errReqTooDeep
+	}
+
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// Reads and decodes the next request message from a client, rejecting it (and closing the connection) if it's invalid.
+func readReqMsg(conn *WsConn) (*ReqMsg, error) {
+	msgType, data, err := conn.Conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	if msgType == websocket.BinaryMessage {
+		// See encoder.go.
+		data, err = conn.Encoder.DecodeReq(data, REQ_MAX_DEPTH)
+		if err != nil {
+			rejectInvalidReq(conn, err)
+			return nil, err
+		}
+	}
+
+	req, err := decodeReqMsg(data)
+	if err != nil {
+		rejectInvalidReq(conn, err)
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// Shared by all connections if -ws-write-buffer-pool is set, so that idle connections don't each hold a write buffer.
+var _wsWriteBufferPool = &sync.Pool {}
+
+func wsUpgrade(w http.ResponseWriter, r *http.Request) *WsConn {
+	_, span := startSpan(context.Background(), "ws.upgrade")
+	span.SetAttr("http.path", r.URL.Path)
+	defer span.Finish()
+
 	var upgrader = websocket.Upgrader {
-		ReadBufferSize: 1024,
-		WriteBufferSize: 4096,
+		ReadBufferSize: _config.WsReadBufferSize,
+		WriteBufferSize: _config.WsWriteBufferSize,
+		EnableCompression: _config.WsCompression,
 		CheckOrigin: func (r *http.Request) bool { return true },
+		Subprotocols: _subprotocols,
+	}
+
+	if _config.WsWriteBufferPool {
+		upgrader.WriteBufferPool = _wsWriteBufferPool
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 
 	if err != nil {
 		log.Println(err)
+		span.SetError(err.Error())
+		return nil
+	}
+
+	return newWsConn(conn, clientIp(r))
+}
+
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	pathSim := simFromPath(r.URL.Path)
+	if pathSim == "" {
+		http.NotFound(w, r)
 		return
 	}
 
-	for {
-		var req ReqMsg
+	conn := wsUpgrade(w, r)
+	if conn == nil {
+		return
+	}
 
-		err := conn.ReadJSON(&req)
+	var replayStop chan int = nil
+	defer func() {
+		if replayStop != nil {
+			close(replayStop)
+		}
+
+		conn.Close()
+
+		// Release the subscription now, rather than once the main loop notices the connection closed.
+		_lock.Lock()
+		releaseConn(conn)
+		_lock.Unlock()
+
+		_mapLock.Lock()
+		delete(_mapConns, conn)
+		_mapLock.Unlock()
+	}()
+	defer recoverConnPanic(conn)
+
+	unknownCmds := 0
+	for {
+		req, err := readReqMsg(conn)
 		if err != nil {
 			log.Println(err)
 			return
 		}
 
+		if req.Cmd == "ping" {
+			// Allowed at any time, even during replay.
+			wsReqPing(req, conn)
+			continue
+		}
+
+		if replayStop != nil {
+			// Nothing else (other than pings) may be requested on a connection once replay has started.
+			log.Println("Command received during replay from " + conn.RemoteIp + ": " + req.Cmd)
+			conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Command not allowed during replay")
+			return
+		}
+
+		if !resolveReqSim(req, conn, pathSim) {
+			return
+		}
+
+		if req.Batch {
+			conn.SetBatch()
+		}
+
+		if req.MsgSeq {
+			conn.SetMsgSeq()
+		}
+
 		switch req.Cmd {
 		case "bdl": // "Boat data live" request
-			wsReqBoatDataLive(&req, conn, false)
+			wsReqBoatDataLive(req, conn, false, false)
 		case "bdl_g": // "Boat data live" request including nearby group members
-			wsReqBoatDataLive(&req, conn, true)
+			wsReqBoatDataLive(req, conn, true, false)
+		case "bdl_x": // "Boat data live" request including extended boat data
+			wsReqBoatDataLive(req, conn, false, true)
+		case "group_all": // All boats in group, at full precision (admin only)
+			wsReqGroupAll(req, conn)
+		case "gdl": // All boats in group, by group ID, at spectator precision
+			wsReqGroupById(req, conn)
+		case "chat": // Chat message to group
+			wsReqChat(req, conn)
+		case "wind_area": // Grid of wind vectors around boat
+			wsReqWindArea(req, conn)
+		case "map": // Public boats in an area, at spectator precision
+			wsReqMap(req, conn)
+		case "resume": // Resume a previous session
+			wsReqResume(req, conn)
+		case "replay": // Replay of a recorded track
+			replayStop = wsReqReplay(req, conn)
+		case "time": // Time sync message
+			wsReqTime(conn)
+		case "version": // Version info
+			wsReqVersion(conn)
+		case "keyframe": // Latest live data again, after a gap in message sequence numbers
+			wsReqKeyframe(conn)
+		case "resync": // Full live data on the next iteration
+			wsReqResync(conn)
+		case "geofences": // Replace the subscription's geofences
+			wsReqGeofences(conn, req)
+		case "waypoint": // Set, move or clear the subscription's waypoint
+			wsReqWaypoint(conn, req)
+		case "action": // Control the subscribed boat
+			wsReqAction(req, conn)
 		default:
-			log.Println("Invalid command: " + req.Cmd)
+			log.Println("Invalid command from " + conn.RemoteIp + ": " + req.Cmd)
+			if !countUnknownCmd(conn, &unknownCmds) {
+				return
+			}
 		}
 	}
 }
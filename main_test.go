@@ -0,0 +1,44 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+
+func FuzzDecodeReqMsg(f *testing.F) {
+	f.Add([]byte(`{"cmd":"bdl","key":"0123456789abcdef0123456789abcdef"}`))
+	f.Add([]byte(`{"cmd":"bdl_g","spec":"abc","session":true,"ais":true}`))
+	f.Add([]byte(`{"cmd":"wind_area","size":5,"step":0.5}`))
+	f.Add([]byte(`{"cmd":"replay","key":"0123456789abcdef0123456789abcdef","group":true,"speed":10,"from":"2026-01-01T00:00:00Z"}`))
+	f.Add([]byte(`{"cmd":"ping","payload":{"a":[1,2,3]}}`))
+	f.Add([]byte(`{"cmd":"resume","token":"x","seq":18446744073709551615}`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, err := decodeReqMsg(data)
+		if (req == nil) == (err == nil) {
+			t.Fatalf("Expected exactly one of request and error: %+v, %v", req, err)
+		}
+
+		if req != nil && req.Sim != "" {
+			// Must not panic, whatever the name.
+			simClient(req.Sim)
+		}
+	})
+}
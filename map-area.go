@@ -0,0 +1,389 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Spectator map:
+//
+// For a live map of all public boats in an area (without subscribing to each
+// of them), a "map" request ({"cmd":"map","bbox":[<lat_min>,<lon_min>,<lat_max>,
+// <lon_max>],"token":"<token>"}) streams their positions, at spectator
+// precision (see spectator.go), every MAP_UPDATE_INTERVAL:
+//
+// {"type":"map","bbox":[...],"boats":[{"spec":"<spectator_id>","name":"...","lat":...,"lon":...,"cog":...,"sog":...},...]}
+//
+// Sending another "map" request (e.g. after the map has been panned) changes
+// the area, and the connection may also be subscribed to a boat as usual. The
+// same data is available once at "GET /v1/map?bbox=<lat_min>,<lon_min>,
+// <lat_max>,<lon_max>[&sim=<name>]" (with the token as a bearer token or a
+// "token" query parameter). The token is -map-token (or the admin token).
+// Responses have an ETag that changes whenever the area's boats are fetched
+// again, so that clients polling with If-None-Match get a 304 response until
+// there's new data.
+//
+// The bounding box is expanded to whole multiples of MAP_GRID_STEP, so that
+// clients looking at about the same area share the simulator query, which is
+// "boatsinarea,<lat_min>,<lon_min>,<lat_max>,<lon_max>", answered with
+// "boatsinarea,<lat_min>,<lon_min>,<lat_max>,<lon_max>,ok", then a line of
+// "<spectator_id>,<lat>,<lon>,<cog>,<sog>,<name>" for each public boat in the
+// area (the simulator decides which boats are public), then a blank line. A
+// bounding box with lon_min > lon_max crosses the antimeridian.
+
+const MAP_GRID_STEP = 1.0 // Degrees
+const MAP_UPDATE_INTERVAL = 10 * time.Second
+const MAP_CACHE_TTL = MAP_UPDATE_INTERVAL / 2
+const MAP_MAX_BOATS = 5000 // Per message, beyond which "truncated" is set
+
+const ERR_MAP_UNAVAILABLE = "map_unavailable"
+
+type MapArea struct {
+	LatMin float64
+	LonMin float64
+	LatMax float64
+	LonMax float64
+}
+
+// A boat in an area, as reported by the simulator
+type SimAreaBoat struct {
+	SpectatorId string
+	Name string
+	Lat float64
+	Lon float64
+	Cog float64
+	Sog float64
+}
+
+type MapBoat struct {
+	SpectatorId string `json:"spec"`
+	Name string `json:"name"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	Cog float64 `json:"cog"`
+	Sog float64 `json:"sog"`
+}
+
+type MapMsg struct {
+	Type string `json:"type,omitempty"` // Only in WebSocket messages
+	Bbox [4]float64 `json:"bbox"`
+	Boats []MapBoat `json:"boats"`
+	Truncated bool `json:"truncated,omitempty"`
+	Seq int64 `json:"-"` // Fetch number, for ETags
+}
+
+type MapCacheEntry struct {
+	Ready chan int // Closed once Msg has been fetched (and is nil on failure)
+	Msg *MapMsg
+	Expires time.Time
+}
+
+type MapView struct {
+	Sim string
+	Area MapArea
+}
+
+var _mapLock sync.Mutex
+var _mapCache = make(map[string]*MapCacheEntry)
+var _mapConns = make(map[*WsConn]*MapView) // Connections streaming map updates (guarded by _mapLock)
+var _mapStreamerStarted = false
+var _mapFetches int64 = 0 // Guarded by _mapLock
+
+
+func parseMapArea(bbox []float64) (MapArea, error) {
+	if len(bbox) != 4 {
+		return MapArea {}, errors.New("Bounding box must be [lat_min, lon_min, lat_max, lon_max]")
+	}
+
+	a := MapArea { bbox[0], bbox[1], bbox[2], bbox[3] }
+	if !(a.LatMin >= -90.0 && a.LatMin < a.LatMax && a.LatMax <= 90.0) ||
+		!(a.LonMin >= -180.0 && a.LonMin <= 180.0 && a.LonMax >= -180.0 && a.LonMax <= 180.0) || a.LonMin == a.LonMax {
+		return MapArea {}, errors.New("Invalid bounding box")
+	}
+
+	// Align outward to the grid.
+	a.LatMin = math.Floor(a.LatMin / MAP_GRID_STEP) * MAP_GRID_STEP
+	a.LonMin = math.Floor(a.LonMin / MAP_GRID_STEP) * MAP_GRID_STEP
+	a.LatMax = math.Ceil(a.LatMax / MAP_GRID_STEP) * MAP_GRID_STEP
+	a.LonMax = math.Ceil(a.LonMax / MAP_GRID_STEP) * MAP_GRID_STEP
+
+	return a, nil
+}
+
+func parseMapBboxParam(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	bbox := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, errors.New("Invalid bounding box")
+		}
+		bbox[i] = v
+	}
+
+	return bbox, nil
+}
+
+func isMapToken(token string) bool {
+	if isAdminToken(token) {
+		return true
+	}
+
+	return _config.MapToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(_config.MapToken)) == 1
+}
+
+func wsReqMap(req *ReqMsg, conn *WsConn) {
+	_mapLock.Lock()
+	_, streaming := _mapConns[conn]
+	_mapLock.Unlock()
+
+	if !streaming && (rejectIfDraining(conn) || rejectIfMemoryPressure(conn)) {
+		return
+	}
+
+	if !isMapToken(req.Token) {
+		log.Println("Client (" + conn.RemoteIp + ") sent map request without valid token")
+
+		sendErrorMsg(conn, ERR_UNAUTHORIZED, "Valid map token required")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Valid map token required")
+		return
+	}
+
+	area, err := parseMapArea(req.Bbox)
+	if err != nil {
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, err.Error())
+		return
+	}
+
+	view := &MapView {
+		Sim: req.Sim,
+		Area: area,
+	}
+
+	_mapLock.Lock()
+	_mapConns[conn] = view
+	if !_mapStreamerStarted {
+		_mapStreamerStarted = true
+		go mapStreamer()
+	}
+	_mapLock.Unlock()
+
+	// The first update is sent right away, rather than at the next interval.
+	if !sendMapUpdate(conn, view) {
+		sendErrorMsg(conn, ERR_MAP_UNAVAILABLE, "Map data unavailable")
+	}
+}
+
+// Sends map updates to every streaming connection, every MAP_UPDATE_INTERVAL.
+func mapStreamer() {
+	for {
+		time.Sleep(MAP_UPDATE_INTERVAL)
+
+		_mapLock.Lock()
+		views := make(map[*WsConn]MapView, len(_mapConns))
+		for conn, view := range _mapConns {
+			if conn.IsClosed() {
+				delete(_mapConns, conn)
+			} else {
+				views[conn] = *view
+			}
+		}
+		_mapLock.Unlock()
+
+		// Failed updates are just skipped, with the next one (hopefully) sent at the next interval.
+		for conn, view := range views {
+			sendMapUpdate(conn, &view)
+		}
+	}
+}
+
+func sendMapUpdate(conn *WsConn, view *MapView) bool {
+	msg := getMapArea(view.Sim, view.Area)
+	if msg == nil {
+		return false
+	}
+
+	wsMsg := *msg
+	wsMsg.Type = "map"
+	conn.SendJSON(&wsMsg)
+	return true
+}
+
+func mapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); len(header) >= 7 && strings.EqualFold(header[:7], "Bearer ") {
+		token = strings.TrimSpace(header[7:])
+	}
+	if !isMapToken(token) {
+		log.Println("Rejecting map request without valid token from " + clientIp(r))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sim := r.URL.Query().Get("sim")
+	if sim == "" {
+		sim = DEFAULT_SIM
+	}
+	if simClient(sim) == nil {
+		http.Error(w, "Unknown simulator", http.StatusNotFound)
+		return
+	}
+
+	bbox, err := parseMapBboxParam(r.URL.Query().Get("bbox"))
+	var area MapArea
+	if err == nil {
+		area, err = parseMapArea(bbox)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg := getMapArea(sim, area)
+	if msg == nil {
+		http.Error(w, "Map data unavailable", http.StatusBadGateway)
+		return
+	}
+
+	// Fetch numbers start over on restart, so the start time is included too.
+	etag := "\"" + strconv.FormatInt(_startTime.Unix(), 36) + "-" + strconv.FormatInt(msg.Seq, 36) + "\""
+
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(msg)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time {}, bytes.NewReader(body.Bytes())) // Answers If-None-Match with 304
+}
+
+// Gets an area's boats from the cache, or else from the simulator (with concurrent identical requests sharing one fetch).
+func getMapArea(sim string, area MapArea) *MapMsg {
+	cacheKey := fmt.Sprintf("%s/%g,%g,%g,%g", sim, area.LatMin, area.LonMin, area.LatMax, area.LonMax)
+	now := time.Now()
+
+	_mapLock.Lock()
+	for k, e := range _mapCache {
+		if now.After(e.Expires) {
+			delete(_mapCache, k)
+		}
+	}
+
+	entry, exists := _mapCache[cacheKey]
+	if !exists {
+		entry = &MapCacheEntry {
+			Ready: make(chan int),
+			Expires: now.Add(MAP_CACHE_TTL),
+		}
+		_mapCache[cacheKey] = entry
+	}
+	_mapLock.Unlock()
+
+	if exists {
+		<-entry.Ready
+		return entry.Msg
+	}
+
+	msg := fetchMapArea(sim, area)
+
+	_mapLock.Lock()
+	if msg != nil {
+		_mapFetches++
+		msg.Seq = _mapFetches
+	} else {
+		// Don't cache failures.
+		delete(_mapCache, cacheKey)
+	}
+	_mapLock.Unlock()
+
+	entry.Msg = msg
+	close(entry.Ready)
+
+	return entry.Msg
+}
+
+func fetchMapArea(sim string, area MapArea) *MapMsg {
+	boats, ok := simClient(sim).GetBoatsInArea(area)
+	if !ok {
+		return nil
+	}
+
+	msg := &MapMsg {
+		Bbox: [4]float64 { area.LatMin, area.LonMin, area.LatMax, area.LonMax },
+		Boats: make([]MapBoat, 0, min(len(boats), MAP_MAX_BOATS)),
+	}
+
+	for _, b := range boats {
+		if len(msg.Boats) == MAP_MAX_BOATS {
+			msg.Truncated = true
+			break
+		}
+
+		msg.Boats = append(msg.Boats, MapBoat {
+			SpectatorId: b.SpectatorId,
+			Name: b.Name,
+			Lat: roundCoord(b.Lat, SPECTATOR_PRECISION_DIST),
+			Lon: roundCoord(normalizeLon(b.Lon), SPECTATOR_PRECISION_DIST),
+			Cog: roundCourse(b.Cog, SPECTATOR_PRECISION_DIST),
+			Sog: roundSpeed(b.Sog, SPECTATOR_PRECISION_DIST),
+		})
+	}
+
+	return msg
+}
+
+// Returns whether a position is within the area (including across the antimeridian).
+func (a MapArea) contains(lat float64, lon float64) bool {
+	if lat < a.LatMin || lat > a.LatMax {
+		return false
+	}
+
+	lon = normalizeLon(lon)
+	if a.LonMin <= a.LonMax {
+		return lon >= a.LonMin && lon <= a.LonMax
+	}
+	return lon >= a.LonMin || lon <= a.LonMax
+}
+
+// Formats the area as the simulator request's arguments.
+func (a MapArea) simArgs() string {
+	coords := []float64 { a.LatMin, a.LonMin, a.LatMax, a.LonMax }
+	s := make([]string, len(coords))
+	for i, v := range coords {
+		s[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,123 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+
+func TestParseMapArea(t *testing.T) {
+	a, err := parseMapArea([]float64 { 45.2, -30.5, 46.7, -29.1 })
+	if err != nil || a != (MapArea { 45.0, -31.0, 47.0, -29.0 }) {
+		t.Errorf("Unexpected map area: %+v, %v", a, err)
+	}
+
+	// Across the antimeridian
+	a, err = parseMapArea([]float64 { -10.0, 170.5, 10.0, -170.5 })
+	if err != nil || !a.contains(0.0, 175.0) || !a.contains(0.0, -175.0) || !a.contains(0.0, 185.0) || a.contains(0.0, 0.0) {
+		t.Errorf("Unexpected map area across antimeridian: %+v, %v", a, err)
+	}
+
+	for _, bbox := range [][]float64 { nil, { 1.0, 2.0, 3.0 }, { 10.0, 0.0, 5.0, 1.0 }, { -91.0, 0.0, 0.0, 1.0 }, { 0.0, 0.0, 1.0, 181.0 }, { 0.0, 5.0, 1.0, 5.0 } } {
+		_, err = parseMapArea(bbox)
+		if err == nil {
+			t.Errorf("Invalid bounding box accepted: %v", bbox)
+		}
+	}
+}
+
+func TestMapArea(t *testing.T) {
+	fake := &FakeSimClient {
+		areaBoats: []SimAreaBoat {
+			{ SpectatorId: "a", Name: "Boat A", Lat: 45.12345, Lon: -30.12345, Cog: 93.0, Sog: 6.3 },
+			{ SpectatorId: "b", Name: "Boat B", Lat: 10.0, Lon: 10.0 },
+		},
+	}
+
+	_lock.Lock()
+	_simClients["fake-map"] = fake
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		delete(_simClients, "fake-map")
+		_lock.Unlock()
+	}()
+
+	prevToken := _config.MapToken
+	defer func() { _config.MapToken = prevToken }()
+	_config.MapToken = "map-secret"
+
+	w := httptest.NewRecorder()
+	mapHandler(w, httptest.NewRequest(http.MethodGet, "/v1/map?sim=fake-map&bbox=45,-31,46,-30", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Map request without token not refused: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mapHandler(w, httptest.NewRequest(http.MethodGet, "/v1/map?sim=fake-map&bbox=45,-31,46,-30&token=map-secret", nil))
+
+	var msg MapMsg
+	err := json.Unmarshal(w.Body.Bytes(), &msg)
+	if err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response: %d %s", w.Code, w.Body.Bytes())
+	}
+	if len(msg.Boats) != 1 || msg.Boats[0] != (MapBoat { SpectatorId: "a", Name: "Boat A", Lat: 45.1235, Lon: -30.1235, Cog: 90.0, Sog: 6.5 }) {
+		t.Errorf("Unexpected map boats: %+v", msg.Boats)
+	}
+
+	// Conditional requests, answered with 304 while the cached data is the same
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("No ETag")
+	}
+	for ifNoneMatch, expected := range map[string]int { etag: http.StatusNotModified, "\"other\"": http.StatusOK } {
+		r := httptest.NewRequest(http.MethodGet, "/v1/map?sim=fake-map&bbox=45,-31,46,-30&token=map-secret", nil)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		w = httptest.NewRecorder()
+		mapHandler(w, r)
+		if w.Code != expected || (expected == http.StatusNotModified && w.Body.Len() > 0) {
+			t.Errorf("If-None-Match %s: expected %d, but got %d %s", ifNoneMatch, expected, w.Code, w.Body.Bytes())
+		}
+	}
+
+	// Streaming, with the same area's boats shared from the cache.
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	wsReqMap(&ReqMsg { Cmd: "map", Sim: "fake-map", Token: "map-secret", Bbox: []float64 { 45.5, -30.5, 45.6, -30.4 } }, wc)
+	expectQueued(t, wc, `{"type":"map","bbox":[45,-31,46,-30],"boats":[{"spec":"a","name":"Boat A","lat":45.1235,"lon":-30.1235,"cog":90,"sog":6.5}]}`)
+	if fake.areaReqs != 1 {
+		t.Errorf("Expected 1 simulator request, but got %d!", fake.areaReqs)
+	}
+
+	_mapLock.Lock()
+	view := _mapConns[wc]
+	delete(_mapConns, wc)
+	_mapLock.Unlock()
+	if view == nil || view.Sim != "fake-map" {
+		t.Errorf("Connection not streaming map updates: %+v", view)
+	}
+
+	wc = testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	wsReqMap(&ReqMsg { Cmd: "map", Token: "wrong", Bbox: []float64 { 45.5, -30.5, 45.6, -30.4 } }, wc)
+	if wc.closeCode != CLOSE_POLICY_VIOLATION {
+		t.Errorf("Map request with invalid token not refused: %d", wc.closeCode)
+	}
+}
@@ -0,0 +1,185 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"container/list"
+	"log"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+
+// Memory guardrails:
+//
+// With a soft memory limit (-memory-limit, or else the GOMEMLIMIT environment
+// variable), the Go runtime collects garbage more aggressively as memory use
+// approaches the limit, and the connector checks its memory use every
+// MEMORY_CHECK_INTERVAL. Once it's over MEMORY_PRESSURE_RATIO of the limit
+// (until it's back under MEMORY_RECOVER_RATIO), the connector sheds load
+// rather than growing until it's OOM-killed (e.g. during big events):
+//
+// - New subscriptions (bdl, bdl_g, bdl_x, group_all, gdl and replay, but
+//   not resumed sessions) are rejected with an error telling the client when
+//   to retry, and closed with 1013 (try again later):
+//
+//   {"type":"error","error":"overloaded","msg":"...","retry_after":<seconds>}
+//
+// - Each connection's live data queue is capped at MEMORY_PRESSURE_QUEUE_SIZE
+//   messages (with its usual overflow policy applying beyond that).
+// - Boats' recent samples (see history.go) stop being kept for boats that
+//   don't already have them.
+//
+// Independently of memory use, -max-tracked-boats caps the number of boats
+// polled from the simulator, with subscriptions to other boats (or groups
+// including other boats) rejected (as above) once it's reached, at most
+// HISTORY_MAX_TOTAL_SAMPLES samples are kept across all boats, and at most
+// -max-sessions resumable sessions (see session.go) are kept, with further
+// subscriptions going without one.
+
+const ERR_OVERLOADED = "overloaded"
+
+const MEMORY_CHECK_INTERVAL = 1 * time.Second
+const MEMORY_PRESSURE_RATIO = 0.9
+const MEMORY_RECOVER_RATIO = 0.8
+const MEMORY_PRESSURE_QUEUE_SIZE = 2
+const MEMORY_RETRY_AFTER = 30 // Seconds
+
+// Current memory use (as counted against the runtime's memory limit) and soft limit, in bytes (0 if no limit)
+var _memoryInUse atomic.Int64
+var _memoryLimit atomic.Int64
+
+var _memoryPressure atomic.Bool
+var _countShedSubscribes int64 = 0
+
+
+func memoryInit() {
+	if _config.MemoryLimit > 0 {
+		debug.SetMemoryLimit(_config.MemoryLimit * 1024 * 1024)
+	}
+
+	// A negative limit only queries the current one (which is math.MaxInt64 if neither -memory-limit nor GOMEMLIMIT is set).
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return
+	}
+	_memoryLimit.Store(limit)
+
+	log.Println("Soft memory limit: " + strconv.FormatInt(limit / 1024 / 1024, 10) + " MiB")
+
+	go func() {
+		for {
+			checkMemory()
+			time.Sleep(MEMORY_CHECK_INTERVAL)
+		}
+	}()
+}
+
+// Reads current memory use, entering or leaving memory pressure as needed.
+func checkMemory() {
+	samples := []metrics.Sample {
+		{ Name: "/memory/classes/total:bytes" },
+		{ Name: "/memory/classes/heap/released:bytes" },
+	}
+	metrics.Read(samples)
+
+	inUse := int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+	_memoryInUse.Store(inUse)
+
+	updateMemoryPressure(inUse, _memoryLimit.Load())
+}
+
+func updateMemoryPressure(inUse int64, limit int64) {
+	if limit <= 0 {
+		return
+	}
+
+	mib := strconv.FormatInt(inUse / 1024 / 1024, 10) + " MiB"
+	if float64(inUse) > float64(limit) * MEMORY_PRESSURE_RATIO {
+		if !_memoryPressure.Swap(true) {
+			log.Println("Memory use high (" + mib + "): rejecting new subscriptions.")
+		}
+	} else if float64(inUse) < float64(limit) * MEMORY_RECOVER_RATIO {
+		if _memoryPressure.Swap(false) {
+			log.Println("Memory use back to normal (" + mib + ").")
+		}
+	}
+}
+
+// Rejects a subscription request (closing the connection) if under memory pressure, returning whether it was rejected.
+func rejectIfMemoryPressure(conn *WsConn) bool {
+	if !_memoryPressure.Load() {
+		return false
+	}
+
+	rejectOverloaded(conn, "Server is overloaded; please retry later")
+	return true
+}
+
+// Returns whether subscribing to the boat would exceed -max-tracked-boats (rejecting the request, if so). Caller must hold _lock.
+func rejectIfTooManyTracked(conn *WsConn, boatKey string) bool {
+	if _config.MaxTrackedBoats <= 0 || _trackedBoats[boatKey] != nil || len(_trackedBoats) < _config.MaxTrackedBoats {
+		return false
+	}
+
+	log.Println("Rejecting subscriber (" + conn.RemoteIp + ") over tracked boats limit for boat key: " + boatKey)
+	rejectOverloaded(conn, "Too many boats being tracked; please retry later")
+	return true
+}
+
+// Returns whether tracking the boats would take the number of tracked boats over -max-tracked-boats. Caller must hold _lock.
+func tooManyTracked(boats *list.List) bool {
+	if _config.MaxTrackedBoats <= 0 {
+		return false
+	}
+
+	added := make(map[string]bool)
+	for boat := boats.Front(); boat != nil; boat = boat.Next() {
+		boatKey := boat.Value.(*BoatInfo).BoatKey
+		if _trackedBoats[boatKey] == nil {
+			added[boatKey] = true
+		}
+	}
+
+	return len(added) > 0 && len(_trackedBoats) + len(added) > _config.MaxTrackedBoats
+}
+
+// Rejects a subscription whose boats couldn't be tracked (see trackConnCtx) as being over -max-tracked-boats.
+func rejectTooManyTracked(conn *WsConn, boatKey string) {
+	log.Println("Rejecting subscriber (" + conn.RemoteIp + ") over tracked boats limit for group of boat key: " + boatKey)
+	rejectOverloaded(conn, "Too many boats being tracked; please retry later")
+}
+
+func rejectOverloaded(conn *WsConn, msg string) {
+	atomic.AddInt64(&_countShedSubscribes, 1)
+
+	sendRetryErrorMsg(conn, ERR_OVERLOADED, msg, MEMORY_RETRY_AFTER)
+	conn.CloseWithReason(CLOSE_TRY_AGAIN_LATER, "Server overloaded")
+}
+
+// Maximum number of live data messages queued on each connection (before its overflow policy applies).
+func liveQueueLimit() int {
+	if _memoryPressure.Load() {
+		return min(_config.QueueSize, MEMORY_PRESSURE_QUEUE_SIZE)
+	}
+	return _config.QueueSize
+}
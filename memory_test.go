@@ -0,0 +1,173 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"container/list"
+	"testing"
+	"time"
+)
+
+
+func TestMemoryPressure(t *testing.T) {
+	defer _memoryPressure.Store(false)
+
+	const limit = 1000
+
+	updateMemoryPressure(850, limit)
+	if _memoryPressure.Load() {
+		t.Errorf("Memory pressure below threshold!")
+	}
+
+	updateMemoryPressure(950, limit)
+	if !_memoryPressure.Load() {
+		t.Errorf("No memory pressure above threshold!")
+	}
+
+	// Memory pressure only ends once well below the threshold.
+	updateMemoryPressure(850, limit)
+	if !_memoryPressure.Load() {
+		t.Errorf("Memory pressure ended too early!")
+	}
+	updateMemoryPressure(750, limit)
+	if _memoryPressure.Load() {
+		t.Errorf("Memory pressure didn't end!")
+	}
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	if rejectIfMemoryPressure(wc) {
+		t.Errorf("Subscription rejected without memory pressure!")
+	}
+
+	_memoryPressure.Store(true)
+
+	wc = testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	if !rejectIfMemoryPressure(wc) {
+		t.Errorf("Subscription not rejected under memory pressure!")
+	}
+	expectQueued(t, wc, `{"type":"error","error":"overloaded","msg":"Server is overloaded; please retry later","retry_after":30}`)
+	if wc.closeCode != CLOSE_TRY_AGAIN_LATER {
+		t.Errorf("Unexpected close code: %d", wc.closeCode)
+	}
+
+	// Live data queues are capped.
+	wc = testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	wc.SendLiveAt("a", []byte("a1"), time.Time {})
+	wc.SendLiveAt("a", []byte("a2"), time.Time {})
+	wc.SendLiveAt("a", []byte("a3"), time.Time {})
+	expectQueued(t, wc, "a2", "a3")
+}
+
+func TestMaxTrackedBoats(t *testing.T) {
+	tracked := testBoatKey(t, 0)
+	other := testBoatKey(t, 1)
+
+	prevMax := _config.MaxTrackedBoats
+	defer func() { _config.MaxTrackedBoats = prevMax }()
+	_config.MaxTrackedBoats = len(_trackedBoats) + 1
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	trackBoat("", tracked)
+	defer untrackBoat(tracked)
+
+	// Already tracked boats can still be subscribed to, but not others.
+	if rejectIfTooManyTracked(testQueuedConn(QUEUE_POLICY_DISCONNECT), tracked) {
+		t.Errorf("Subscription to tracked boat rejected!")
+	}
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	if !rejectIfTooManyTracked(wc, other) {
+		t.Errorf("Subscription over tracked boats limit not rejected!")
+	}
+	if wc.closeCode != CLOSE_TRY_AGAIN_LATER {
+		t.Errorf("Unexpected close code: %d", wc.closeCode)
+	}
+}
+
+func TestMaxTrackedBoatsGroup(t *testing.T) {
+	me := testBoatKey(t, 0)
+	a := testBoatKey(t, 1)
+	b := testBoatKey(t, 2)
+
+	prevMax := _config.MaxTrackedBoats
+	defer func() { _config.MaxTrackedBoats = prevMax }()
+	_config.MaxTrackedBoats = len(_trackedBoats) + 2
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	// A group fetched for a bdl_g subscription counts its newly tracked boats.
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	connCtx := ConnCtx { BoatKey: me, GroupBoats: pendingGroup(me), GroupPending: true }
+	trackConnCtx(&connCtx)
+	_conns[wc] = connCtx
+	defer delete(_conns, wc)
+
+	boats := list.New()
+	boats.PushBack(&BoatInfo { me, "Me" })
+	boats.PushBack(&BoatInfo { a, "A" })
+	boats.PushBack(&BoatInfo { b, "B" })
+	applyGroup(&GroupFetchJob { wc, "", me, connCtx.GroupBoats }, boats)
+
+	if _conns[wc].GroupBoats == boats || wc.closeCode != CLOSE_TRY_AGAIN_LATER {
+		t.Errorf("Group over tracked boats limit not rejected!")
+	}
+	if _trackedBoats[a] != nil || _trackedBoats[b] != nil || _trackedBoats[me].RefCount != 1 {
+		t.Errorf("Group's boats tracked despite rejection!")
+	}
+
+	// One more boat is still within the limit.
+	boats.Remove(boats.Back())
+	if !trackBoats("", boats) {
+		t.Errorf("Group within tracked boats limit rejected!")
+	}
+	untrackBoats(boats)
+	untrackConnCtx(&connCtx)
+}
+
+func TestHistoryUnderMemoryPressure(t *testing.T) {
+	a := testBoatKey(t, 0)
+	b := testBoatKey(t, 1)
+
+	prevSize := _config.HistorySize
+	defer func() { _config.HistorySize = prevSize }()
+	_config.HistorySize = 10
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	trackBoat("", a)
+	trackBoat("", b)
+	defer untrackBoat(a)
+	defer untrackBoat(b)
+	defer delete(_history, a)
+	defer delete(_history, b)
+
+	recordHistory(map[string]BoatDataLiveRespMsg { a: {} })
+
+	// Under memory pressure, boats without samples don't get any, while others' keep rolling.
+	_memoryPressure.Store(true)
+	defer _memoryPressure.Store(false)
+
+	recordHistory(map[string]BoatDataLiveRespMsg { a: { Lat: 1.0 }, b: {} })
+	if len(_history[a]) != 2 || len(_history[b]) != 0 {
+		t.Errorf("Unexpected history under memory pressure: %d, %d", len(_history[a]), len(_history[b]))
+	}
+}
@@ -0,0 +1,130 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+
+// Prometheus metrics:
+//
+// The "prometheus" stats sink serves the latest stats snapshot (in the
+// Prometheus text exposition format) at "/metrics" on the admin listener
+// (see admin.go).
+
+type PrometheusStatsSink struct {
+	lock sync.Mutex
+	latest *StatsSnapshot
+}
+
+
+func newPrometheusStatsSink() *PrometheusStatsSink {
+	sink := &PrometheusStatsSink {
+		latest: &StatsSnapshot {},
+	}
+
+	_adminMux.HandleFunc("/metrics", sink.handler)
+
+	return sink
+}
+
+func (sink *PrometheusStatsSink) Report(s *StatsSnapshot) {
+	sink.lock.Lock()
+	sink.latest = s
+	sink.lock.Unlock()
+}
+
+func (sink *PrometheusStatsSink) handler(w http.ResponseWriter, r *http.Request) {
+	sink.lock.Lock()
+	s := sink.latest
+	sink.lock.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeMetric(w, "snsw_connections", "gauge", "Current subscribed connections", int64(s.Conns))
+	writeMetric(w, "snsw_keys", "gauge", "Current subscribed boat keys", int64(s.Keys))
+	writeMetric(w, "snsw_tracked_boats", "gauge", "Current boats polled from the simulator", int64(s.Tracked))
+	writeMetric(w, "snsw_sessions", "gauge", "Current resumable sessions", int64(s.Sessions))
+	writeMetric(w, "snsw_duplicate_connections", "gauge", "Current connections subscribed to the same boat as another from the same remote IP", int64(s.DuplicateConns))
+	writeMetric(w, "snsw_memory_in_use_bytes", "gauge", "Current memory use, as counted against the soft memory limit", s.MemoryInUse)
+	writeMetric(w, "snsw_memory_limit_bytes", "gauge", "Soft memory limit (0 if none)", s.MemoryLimit)
+	writeMetric(w, "snsw_memory_pressure", "gauge", "Whether new subscriptions are being rejected due to memory use (1) or not (0)", int64(boolToInt(s.MemoryPressure)))
+	writeMetric(w, "snsw_connections_total", "counter", "Subscribed connections", s.CountConns)
+	writeMetric(w, "snsw_messages_total", "counter", "Live data messages sent", s.CountMsgs)
+	writeMetric(w, "snsw_start_time_seconds", "gauge", "Start time of this process (Unix time)", s.StartTime.Unix())
+	writeMetric(w, "snsw_first_start_time_seconds", "gauge", "Start time of the first process sharing the stats file (Unix time; see -stats-file)", s.Lifetime.FirstStart)
+	writeMetric(w, "snsw_restarts", "gauge", "Restarts since the first start (see -stats-file)", s.Lifetime.Restarts)
+	writeMetric(w, "snsw_lifetime_connections_total", "counter", "Subscribed connections, across restarts (see -stats-file)", s.Lifetime.Conns)
+	writeMetric(w, "snsw_lifetime_messages_total", "counter", "Live data messages sent, across restarts (see -stats-file)", s.Lifetime.Msgs)
+	writeMetric(w, "snsw_shared_messages_total", "counter", "Live data messages sent from a shared stream (with -shared-streams)", s.SharedMsgs)
+	writeMetric(w, "snsw_queue_dropped_total", "counter", "Live data messages dropped due to full queues", s.QueueDropped)
+	writeMetric(w, "snsw_queue_coalesced_total", "counter", "Live data messages coalesced due to full queues", s.QueueCoalesced)
+	writeMetric(w, "snsw_queue_conflated_total", "counter", "Queued live data messages replaced by newer ones for the same boat", s.QueueConflated)
+	writeMetric(w, "snsw_queue_disconnects_total", "counter", "Connections closed due to full queues", s.QueueDisconnects)
+	writeMetric(w, "snsw_write_timeouts_total", "counter", "Connections closed due to write timeouts", s.WriteTimeouts)
+
+	fmt.Fprintf(w, "# HELP snsw_sim_results_total Simulator request outcomes\n# TYPE snsw_sim_results_total counter\n")
+	for i := 0; i < SIM_RESULT_COUNT; i++ {
+		fmt.Fprintf(w, "snsw_sim_results_total{result=\"%s\"} %d\n", _simResultNames[i], s.SimResults[i])
+	}
+	fmt.Fprintf(w, "# HELP snsw_sim_rejected_lines_total Malformed simulator response lines rejected, by reason\n# TYPE snsw_sim_rejected_lines_total counter\n")
+	for i := 0; i < SIM_REJECT_COUNT; i++ {
+		fmt.Fprintf(w, "snsw_sim_rejected_lines_total{reason=\"%s\"} %d\n", _simRejectNames[i], s.SimRejected[i])
+	}
+	writeMetric(w, "snsw_sim_retries_total", "counter", "Simulator requests retried after dial failures", s.SimRetries)
+	writeMetric(w, "snsw_noboat_keys", "gauge", "Current boat keys on cooldown after \"noboat\" responses", int64(s.NoboatKeys))
+	writeMetric(w, "snsw_boat_deleted_closes_total", "counter", "Connections closed as their boat disappeared from the simulator", s.BoatDeletedCloses)
+	writeMetric(w, "snsw_noboat_rejects_total", "counter", "Subscriptions rejected as their boat key was on \"noboat\" cooldown", s.NoboatRejects)
+	writeMetric(w, "snsw_degraded_iterations_total", "counter", "Main loop iterations whose boat data needed simulator retries", s.DegradedIters)
+	writeMetric(w, "snsw_iteration_overruns_total", "counter", "Main loop iterations that took longer than the poll interval", s.IterOverruns)
+	writeMetric(w, "snsw_shed_subscriptions_total", "counter", "Subscriptions rejected due to memory pressure or the tracked boats limit", s.ShedSubscribes)
+	writeMetric(w, "snsw_banned", "gauge", "Currently banned client IP addresses and boat keys", int64(s.Banned))
+	writeMetric(w, "snsw_bans_total", "counter", "Bans of client IP addresses and boat keys for abuse", s.Bans)
+	writeMetric(w, "snsw_ban_rejects_total", "counter", "Connections and subscriptions rejected as banned", s.BanRejects)
+	writeMetric(w, "snsw_ip_rejects_total", "counter", "Requests rejected by -allow-ips or -deny-ips", s.IpRejects)
+	writeMetric(w, "snsw_webhooks_sent_total", "counter", "Webhook requests delivered", s.WebhooksSent)
+	writeMetric(w, "snsw_webhook_failures_total", "counter", "Webhook requests failed (after retries) or dropped due to a full queue", s.WebhookFailures)
+	writeMetric(w, "snsw_shed_level", "gauge", "Load shedding level: subscriptions of lower priority class (0 low, 1 normal, 2 high) are degraded (see -shed-policy)", int64(s.ShedLevel))
+	writeMetric(w, "snsw_shed_iterations_total", "counter", "Main loop iterations with load shedding active", s.ShedIters)
+	writeMetric(w, "snsw_shed_messages_total", "counter", "Live data messages skipped due to load shedding", s.ShedMsgs)
+	writeMetric(w, "snsw_skipped_ticks_total", "counter", "Main loop ticks skipped due to overrunning iterations (with -adaptive-tick)", s.SkippedTicks)
+
+	fmt.Fprintf(w, "# HELP snsw_delivery_latency_seconds Time from boat data arrival to live data message written to client\n# TYPE snsw_delivery_latency_seconds histogram\n")
+	var cumulative int64 = 0
+	for i := 0; i < LATENCY_NUM_BUCKETS; i++ {
+		cumulative += s.LatencyCounts[i]
+
+		le := "+Inf"
+		if i < len(_latencyBucketsMs) {
+			le = strconv.FormatFloat(float64(_latencyBucketsMs[i]) / 1000.0, 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "snsw_delivery_latency_seconds_bucket{le=\"%s\"} %d\n", le, cumulative)
+	}
+	fmt.Fprintf(w, "snsw_delivery_latency_seconds_sum %g\nsnsw_delivery_latency_seconds_count %d\n", float64(s.LatencySumUs) / 1000000.0, cumulative)
+
+	writeMetric(w, "snsw_iteration_time_min_us", "gauge", "Minimum main loop iteration time over the last stats interval", s.IterTimeMin)
+	writeMetric(w, "snsw_iteration_time_avg_us", "gauge", "Average main loop iteration time over the last stats interval", s.IterTimeAvg)
+	writeMetric(w, "snsw_iteration_time_max_us", "gauge", "Maximum main loop iteration time over the last stats interval", s.IterTimeMax)
+}
+
+func writeMetric(w http.ResponseWriter, name string, metricType string, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, metricType, name, value)
+}
@@ -0,0 +1,325 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Mock simulator (for development):
+//
+// With -mock-sim, an embedded fake simulator is started on a loopback port and
+// used instead of the real SailNavSim backend. Every valid boat key is a boat,
+// starting at a position derived from its key (all within a few NM of each
+// other), and sailing along a slowly wandering course. All boats seen so far,
+// plus a few extra boats, are in one group. Spectator IDs resolve to a boat
+// key derived from the ID.
+
+const MOCK_SIM_START_LAT = 45.0
+const MOCK_SIM_START_LON = -30.0
+const MOCK_SIM_EXTRA_BOATS = 5
+const MOCK_SIM_WIND_DIR = 225.0
+
+type MockBoat struct {
+	Name string
+	Lat float64
+	Lon float64
+	Ctw float64
+	Stw float64
+	Phase float64 // For varying course and speed
+	Updated time.Time
+}
+
+var _mockSimLock sync.Mutex
+var _mockSimBoats = make(map[string]*MockBoat)
+var _mockSimStart time.Time
+
+
+// Starts the mock simulator, returning the host:port to connect to it at.
+func mockSimStart() string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_mockSimStart = time.Now()
+	for i := 0; i < MOCK_SIM_EXTRA_BOATS; i++ {
+		mockSimBoat(mockSimKey(fmt.Sprintf("mock-boat-%d", i)))
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			go mockSimServe(conn)
+		}
+	}()
+
+	log.Println("Started mock simulator on " + ln.Addr().String())
+	return ln.Addr().String()
+}
+
+func mockSimKey(s string) string {
+	h := md5.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func mockSimServe(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.Trim(line, "\n")
+		if line == "mux," + SIM_MUX_ON {
+			fmt.Fprintf(conn, "%s,ok\n", line)
+			mockSimServeMux(conn, reader)
+			return
+		}
+
+		fmt.Fprint(conn, mockSimRespond(line))
+	}
+}
+
+// Serves a multiplexed connection (see sim-mux.go), answering requests concurrently, and so not necessarily in order.
+func mockSimServeMux(conn net.Conn, reader *bufio.Reader) {
+	var writeLock sync.Mutex
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		id, req, _ := strings.Cut(strings.Trim(line, "\n"), ",")
+		go func() {
+			var b strings.Builder
+			for _, respLine := range strings.Split(strings.TrimSuffix(mockSimRespond(req), "\n"), "\n") {
+				b.WriteString(id + "," + respLine + "\n")
+			}
+
+			writeLock.Lock()
+			defer writeLock.Unlock()
+			fmt.Fprint(conn, b.String())
+		}()
+	}
+}
+
+// Returns the response (including its newlines) to a request line.
+func mockSimRespond(line string) string {
+	s := strings.Split(line, ",")
+	if len(s) < 2 {
+		return "error\n"
+	}
+
+	switch s[0] {
+	case "version":
+		return fmt.Sprintf("version,%s,ok,%d\n", s[1], SIM_PROTO_MAX)
+
+	case "bd_nc", "bdx":
+		if !_boatKeyRegexp.MatchString(s[1]) {
+			return fmt.Sprintf("%s,%s,noboat\n", s[0], s[1])
+		}
+
+		return fmt.Sprintf("%s\n", mockSimBoatData(s[0], s[1]))
+
+	case "boatgroupmembers":
+		return fmt.Sprintf("%s\n", mockSimGroupMembers(s[1]))
+
+	case "spectatorboat":
+		return fmt.Sprintf("spectatorboat,%s,ok,%s\n", s[1], mockSimKey("spectator-" + s[1]))
+
+	case "boatinfo":
+		return fmt.Sprintf("%s\n", mockSimBoatInfo(s[1]))
+
+	case "boatevents":
+		// Mock boats never have any events.
+		return fmt.Sprintf("boatevents,%s,ok,0\n\n", s[1])
+
+	case "boatsinarea":
+		if len(s) < 5 {
+			return "error\n"
+		}
+
+		var area MapArea
+		fmt.Sscanf(strings.Join(s[1:5], " "), "%g %g %g %g", &area.LatMin, &area.LonMin, &area.LatMax, &area.LonMax)
+		return fmt.Sprintf("%s\n", mockSimBoatsInArea(strings.Join(s[1:5], ","), area))
+
+	case "action":
+		// Mock boats accept valid actions, but keep sailing as before.
+		if len(s) < 4 {
+			return "error\n"
+		} else if !_boatKeyRegexp.MatchString(s[1]) {
+			return fmt.Sprintf("action,%s,noboat\n", s[1])
+		} else if s[2] != ACTION_COURSE && s[2] != ACTION_TRIM && s[2] != ACTION_ANCHOR {
+			return fmt.Sprintf("action,%s,rejected,Unknown action\n", s[1])
+		} else {
+			return fmt.Sprintf("action,%s,ok\n", s[1])
+		}
+
+	case "wind":
+		if len(s) < 3 {
+			return "error\n"
+		}
+
+		var lat, lon float64
+		fmt.Sscanf(s[1] + " " + s[2], "%g %g", &lat, &lon)
+		dir, speed := mockSimWind(lat, lon)
+		return fmt.Sprintf("wind,%s,%s,ok,%.1f,%.1f\n", s[1], s[2], dir, speed)
+
+	default:
+		return "error\n"
+	}
+}
+
+// Gets (creating, if needed) and advances a mock boat. Caller must hold _mockSimLock.
+func mockSimBoat(boatKey string) *MockBoat {
+	now := time.Now()
+
+	boat, exists := _mockSimBoats[boatKey]
+	if !exists {
+		seed, _ := hex.DecodeString(boatKey)
+		a := float64(binary.BigEndian.Uint16(seed[0:2])) / 65536.0
+		b := float64(binary.BigEndian.Uint16(seed[2:4])) / 65536.0
+		c := float64(binary.BigEndian.Uint16(seed[4:6])) / 65536.0
+
+		boat = &MockBoat {
+			Name: "Boat " + boatKey[:6],
+			Lat: MOCK_SIM_START_LAT + (a - 0.5) * 0.1,
+			Lon: MOCK_SIM_START_LON + (b - 0.5) * 0.14,
+			Ctw: 90.0 + c * 90.0,
+			Stw: 6.0,
+			Phase: c * 2.0 * math.Pi,
+			Updated: now,
+		}
+		_mockSimBoats[boatKey] = boat
+		return boat
+	}
+
+	dt := now.Sub(boat.Updated).Hours()
+	boat.Updated = now
+
+	t := now.Sub(_mockSimStart).Seconds()
+	boat.Ctw = math.Mod(boat.Ctw + math.Sin(t / 60.0 + boat.Phase) * dt * 600.0 + 360.0, 360.0)
+	boat.Stw = 6.0 + 1.5 * math.Sin(t / 90.0 + boat.Phase)
+
+	dist := boat.Stw * dt / 60.0 // Degrees of latitude
+	boat.Lat += dist * math.Cos(boat.Ctw * math.Pi / 180.0)
+	boat.Lon += dist * math.Sin(boat.Ctw * math.Pi / 180.0) / math.Cos(boat.Lat * math.Pi / 180.0)
+
+	return boat
+}
+
+func mockSimWind(lat float64, lon float64) (float64, float64) {
+	t := time.Now().Sub(_mockSimStart).Seconds()
+	dir := math.Mod(MOCK_SIM_WIND_DIR + 20.0 * math.Sin(t / 300.0 + lat) + 360.0, 360.0)
+	speed := 12.0 + 4.0 * math.Sin(t / 120.0 + lon)
+	return dir, speed
+}
+
+func mockSimBoatData(cmd string, boatKey string) string {
+	_mockSimLock.Lock()
+	defer _mockSimLock.Unlock()
+
+	boat := mockSimBoat(boatKey)
+	windDir, windSpeed := mockSimWind(boat.Lat, boat.Lon)
+
+	ha := math.Mod(windDir - boat.Ctw + 540.0, 360.0) - 180.0
+	cog := math.Mod(boat.Ctw + 2.0, 360.0)
+	sog := boat.Stw + 0.3
+
+	line := fmt.Sprintf("%s,%s,ok,%.6f,%.6f,%.1f,%.2f,%.1f,%.2f,%.1f,%.1f", cmd, boatKey, boat.Lat, boat.Lon, boat.Ctw, boat.Stw, cog, sog, windSpeed, ha)
+
+	if cmd == "bdx" {
+		heel := math.Min(math.Abs(ha) / 180.0 * windSpeed * 1.5, 30.0)
+		line += fmt.Sprintf(",%.1f,%.1f,%.2f,%.1f,%.1f,%.1f,%.2f,up", math.Mod(boat.Ctw - 1.0 + 360.0, 360.0), heel, 0.3, 2.0, -3.0, 180.0, 0.4)
+	}
+
+	return line
+}
+
+// All mock boats are public, with the start of their boat key as their spectator ID.
+func mockSimBoatsInArea(args string, area MapArea) string {
+	_mockSimLock.Lock()
+	defer _mockSimLock.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("boatsinarea," + args + ",ok\n")
+	for k, boat := range _mockSimBoats {
+		mockSimBoat(k)
+		if area.contains(boat.Lat, boat.Lon) {
+			sb.WriteString(fmt.Sprintf("%s,%.6f,%.6f,%.1f,%.2f,%s\n", k[:12], boat.Lat, boat.Lon, math.Mod(boat.Ctw + 2.0, 360.0), boat.Stw + 0.3, boat.Name))
+		}
+	}
+
+	return sb.String() // Ends with a blank line (once the caller's newline is added).
+}
+
+// Mock boats are all French cruisers.
+func mockSimBoatInfo(boatKey string) string {
+	if !_boatKeyRegexp.MatchString(boatKey) {
+		return "boatinfo," + boatKey + ",noboat"
+	}
+
+	_mockSimLock.Lock()
+	defer _mockSimLock.Unlock()
+
+	return "boatinfo," + boatKey + ",ok,FR,Cruiser," + mockSimBoat(boatKey).Name
+}
+
+func mockSimGroupMembers(boatKey string) string {
+	_mockSimLock.Lock()
+	defer _mockSimLock.Unlock()
+
+	if _boatKeyRegexp.MatchString(boatKey) {
+		mockSimBoat(boatKey)
+	}
+
+	keys := make([]string, 0, len(_mockSimBoats))
+	for k, _ := range _mockSimBoats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("boatgroupmembers," + boatKey + ",ok\n")
+	for _, k := range keys {
+		sb.WriteString(k + "," + _mockSimBoats[k].Name + "\n")
+	}
+
+	return sb.String() // Ends with a blank line (once the caller's newline is added).
+}
@@ -0,0 +1,120 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+
+// Message sequence numbers:
+//
+// A client may set "msg_seq" to true in any request to have every message
+// queued for it from then on (live data, replies, status, events, etc.)
+// numbered, with "mseq":<n> added as the message's last field, counting up
+// from 1 for each connection. Numbers are given as messages are queued, so
+// live data later dropped, coalesced or conflated by the connection's queue
+// policy leaves a gap, as does live data skipped by load shedding (see
+// shedding.go), which still uses up a number. On seeing one, a client may send
+// {"cmd":"keyframe"} to be sent the most recent live data message queued for
+// it again (with a new number) right away, rather than waiting for the next
+// iteration.
+//
+// ("seq" is already used by sessions, for live data only and carrying over
+// to the resumed connection, so this is a separate field.)
+
+const ERR_NO_DATA = "no_data"
+
+
+// Enables message sequence numbers for the connection (see above).
+func (wc *WsConn) SetMsgSeq() {
+	wc.lock.Lock()
+	wc.msgSeq = true
+	wc.lock.Unlock()
+}
+
+// Numbers a message about to be queued, if sequence numbers are enabled. Caller must hold wc.lock.
+func (wc *WsConn) numberLocked(msg QueuedMsg) QueuedMsg {
+	if msg.Live && !msg.Chat {
+		wc.lastLive = msg
+	}
+
+	if !wc.msgSeq || msg.Ping {
+		return msg
+	}
+
+	wc.nextMseq++
+	data := addMseq(msg.Data, wc.nextMseq)
+	if data != nil {
+		msg.Data = data
+		msg.Prepared = nil // Now specific to this connection (see shared-streams.go).
+	}
+
+	return msg
+}
+
+// Uses up a number for a live data message skipped for the connection.
+func (wc *WsConn) SkipMseq() {
+	wc.lock.Lock()
+	if wc.msgSeq {
+		wc.nextMseq++
+	}
+	wc.lock.Unlock()
+}
+
+// Adds "mseq" as the last field of a JSON object, returning nil if the message isn't one.
+func addMseq(data []byte, mseq uint64) []byte {
+	data = bytes.TrimRight(data, " \t\r\n")
+	if len(data) < 2 || data[0] != '{' || data[len(data) - 1] != '}' {
+		return nil
+	}
+
+	out := make([]byte, 0, len(data) + 32)
+	out = append(out, data[:len(data) - 1]...)
+	if len(bytes.TrimSpace(data[1:len(data) - 1])) != 0 {
+		out = append(out, ',')
+	}
+	out = append(out, "\"mseq\":"...)
+	out = strconv.AppendUint(out, mseq, 10)
+	out = append(out, '}')
+
+	return out
+}
+
+// Queues the most recent live data message again. Returns false if there's none.
+func (wc *WsConn) SendKeyframe() bool {
+	wc.lock.Lock()
+	msg := wc.lastLive
+	wc.lock.Unlock()
+
+	if msg.Data == nil {
+		return false
+	}
+
+	msg.Arrived = time.Time {} // Not a new delivery, for latency
+	wc.enqueue(msg)
+	return true
+}
+
+func wsReqKeyframe(conn *WsConn) {
+	if !conn.SendKeyframe() {
+		sendErrorMsg(conn, ERR_NO_DATA, "No live data sent yet")
+	}
+}
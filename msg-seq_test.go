@@ -0,0 +1,70 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestAddMseq(t *testing.T) {
+	cases := []struct {
+		data string
+		expected string
+	} {
+		{ `{"lat":1.5}`, `{"lat":1.5,"mseq":7}` },
+		{ "{\"type\":\"time\"}\n", `{"type":"time","mseq":7}` },
+		{ `{}`, `{"mseq":7}` },
+		{ `[1,2]`, "" },
+		{ ``, "" },
+	}
+
+	for _, c := range cases {
+		if out := string(addMseq([]byte(c.data), 7)); out != c.expected {
+			t.Errorf("addMseq(%q): got %q, expected %q", c.data, out, c.expected)
+		}
+	}
+}
+
+func TestMsgSeqGapAndKeyframe(t *testing.T) {
+	wc := testQueuedConn(QUEUE_POLICY_CONFLATE)
+
+	// Not numbered until enabled.
+	wc.Send([]byte(`{"type":"subscribed"}`), false)
+	wc.SetMsgSeq()
+
+	wc.SendLiveAt("a", []byte(`{"lat":1}`), time.Time {})
+	wc.Send([]byte(`{"type":"pong"}`), false)
+	wc.SendLiveAt("a", []byte(`{"lat":2}`), time.Time {}) // Conflates the first one, leaving a gap.
+	wc.SkipMseq()
+	wc.SendLiveAt("b", []byte(`{"lat":3}`), time.Time {})
+
+	expectQueued(t, wc, `{"type":"subscribed"}`, `{"lat":2,"mseq":3}`, `{"type":"pong","mseq":2}`, `{"lat":3,"mseq":5}`)
+
+	if !wc.SendKeyframe() {
+		t.Fatal("No keyframe sent")
+	}
+	if msg := wc.queue[len(wc.queue) - 1]; string(msg.Data) != `{"lat":3,"mseq":6}` {
+		t.Errorf("Unexpected keyframe: %s", msg.Data)
+	}
+
+	if testQueuedConn(QUEUE_POLICY_CONFLATE).SendKeyframe() {
+		t.Error("Keyframe sent without any live data")
+	}
+}
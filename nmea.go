@@ -0,0 +1,134 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+// NMEA 0183 output:
+//
+// On connections with the SUBPROTOCOL_NMEA subprotocol (see wire-format.go),
+// each live data message is sent as a text message of NMEA 0183 sentences
+// (each ended with CR LF), for marine software expecting an instrument feed:
+//
+// $GPRMC  position, SOG and COG (at the time it's sent)
+// $IIVHW  CTW (as the true heading) and STW
+// $IIMWV  true wind angle (from ha) and speed (lws)
+// !AIVDM  other boats (for bdl_g; or every boat, for group_all and gdl), as
+//         AIS type 18 reports (see ais.go)
+//
+// Speeds are taken to be in knots, so units other than the defaults (see
+// units.go) shouldn't be requested. Other messages are sent as JSON, which
+// NMEA parsers skip, as they don't start with "$" or "!".
+
+type NmeaEncoder struct {}
+
+
+func (*NmeaEncoder) Encode(data []byte) (int, [][]byte, error) {
+	msgs, err := splitEncMsgs(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var out [][]byte
+	for _, m := range msgs {
+		live, err := decodeEncLiveMsg(m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if live == nil {
+			out = append(out, m)
+		} else {
+			out = append(out, []byte(strings.Join(createNmeaSentences(live, time.Now()), "\r\n") + "\r\n"))
+		}
+	}
+
+	return websocket.TextMessage, out, nil
+}
+
+func (*NmeaEncoder) DecodeReq(data []byte, maxDepth int) ([]byte, error) {
+	return data, nil
+}
+
+// Creates the NMEA sentences for a live data message.
+func createNmeaSentences(m *EncLiveMsg, now time.Time) []string {
+	if m.Boats != nil {
+		boats := make(map[string][]float64, len(m.Boats))
+		for name, b := range m.Boats {
+			boats[name] = []float64 { b.Lat, b.Lon, b.Cog }
+		}
+		return createAisSentences(boats, now)
+	}
+
+	own := &m.BoatDataLiveRespMsg
+	if m.You != nil {
+		own = m.You
+	}
+
+	sentences := []string {
+		nmeaSentence(fmt.Sprintf("GPRMC,%s,A,%s,%.1f,%.1f,%s,,,A", now.UTC().Format("150405.00"), nmeaLatLon(own.Lat, own.Lon), own.Sog, own.Cog, now.UTC().Format("020106"))),
+		nmeaSentence(fmt.Sprintf("IIVHW,%.1f,T,,M,%.1f,N,%.1f,K", own.Ctw, own.Stw, own.Stw * 1.852)),
+		nmeaSentence(fmt.Sprintf("IIMWV,%.1f,T,%.1f,N,A", normalizeCourse(own.Ha), own.Lws)),
+	}
+
+	if m.Others != nil {
+		sentences = append(sentences, createAisSentences(m.Others, now)...)
+	}
+
+	return sentences
+}
+
+// Formats a position as NMEA's "ddmm.mmmm,N,dddmm.mmmm,E".
+func nmeaLatLon(lat float64, lon float64) string {
+	ns, ew := "N", "E"
+	if lat < 0.0 {
+		ns = "S"
+	}
+	if lon < 0.0 {
+		ew = "W"
+	}
+
+	return nmeaDegMin(math.Abs(lat), 2) + "," + ns + "," + nmeaDegMin(math.Abs(lon), 3) + "," + ew
+}
+
+func nmeaDegMin(v float64, degDigits int) string {
+	minutes := math.Round(v * 60.0 * 10000.0) / 10000.0 // To avoid 60.0000 minutes
+	deg := math.Floor(minutes / 60.0)
+	return fmt.Sprintf("%0*d%07.4f", degDigits, int(deg), minutes - deg * 60.0)
+}
+
+// Adds the "$" and checksum to a sentence.
+func nmeaSentence(sentence string) string {
+	return fmt.Sprintf("$%s*%02X", sentence, nmeaChecksum(sentence))
+}
+
+// Returns the checksum of a sentence (between its "$" or "!" and "*").
+func nmeaChecksum(sentence string) byte {
+	checksum := byte(0)
+	for i := 0; i < len(sentence); i++ {
+		checksum ^= sentence[i]
+	}
+	return checksum
+}
@@ -0,0 +1,109 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"log"
+	"math"
+)
+
+
+// Outgoing data normalization:
+//
+// So that client map libraries never see invalid JSON numbers (NaN and
+// infinities can't be encoded at all) or values outside of their usual
+// ranges, boat data is normalized as each iteration's arrives (from the
+// simulator or the cluster poller), before anything is derived from it:
+//
+// ctw, cog, hdg, cur_set    to [0, 360)
+// ha, lon                   to [-180, 180)
+// lat                       clamped to [-90, 90]
+// stw, sog, lws, cur_drift  clamped to [0, SIM_MAX_SPEED]
+//
+// Other non-finite values are replaced with 0, and boats whose position isn't
+// finite are left out of the iteration (as if missing), since there's nothing
+// sensible to show for them. Values derived later (rounded or smoothed courses,
+// interpolated positions) are kept within the same ranges, as are the boats
+// of spectator maps and wind areas.
+
+
+// Normalizes an iteration's boat data (see above), leaving out boats without a valid position.
+func normalizeResps(resps map[string]BoatDataLiveRespMsg) {
+	for boatKey, data := range resps {
+		if !isFinite(data.Lat) || !isFinite(data.Lon) {
+			log.Println("Leaving out boat with invalid position: " + boatKey)
+			delete(resps, boatKey)
+			continue
+		}
+
+		resps[boatKey] = normalizeBoatData(data)
+	}
+}
+
+func normalizeBoatData(data BoatDataLiveRespMsg) BoatDataLiveRespMsg {
+	data.Lat = clampFinite(data.Lat, -90.0, 90.0)
+	data.Lon = normalizeLon(finite(data.Lon))
+	data.Ctw = normalizeCourse(data.Ctw)
+	data.Stw = clampFinite(data.Stw, 0.0, SIM_MAX_SPEED)
+	data.Cog = normalizeCourse(data.Cog)
+	data.Sog = clampFinite(data.Sog, 0.0, SIM_MAX_SPEED)
+	data.Lws = clampFinite(data.Lws, 0.0, SIM_MAX_SPEED)
+	data.Ha = normalizeLon(finite(data.Ha))
+
+	if data.Ext != nil {
+		ext := *data.Ext
+		ext.Hdg = normalizeCourse(ext.Hdg)
+		ext.Heel = finite(ext.Heel)
+		ext.Heave = finite(ext.Heave)
+		ext.Leeway = finite(ext.Leeway)
+		ext.Rudder = finite(ext.Rudder)
+		ext.CurSet = normalizeCourse(ext.CurSet)
+		ext.CurDrift = clampFinite(ext.CurDrift, 0.0, SIM_MAX_SPEED)
+		data.Ext = &ext
+	}
+
+	return data
+}
+
+// Normalizes a course (or direction) to [0, 360), with non-finite ones as 0.
+func normalizeCourse(course float64) float64 {
+	course = math.Mod(finite(course), 360.0)
+	if course < 0.0 {
+		course += 360.0
+	}
+	if course >= 360.0 {
+		course = 0.0 // A tiny negative course plus 360 may round to 360.
+	}
+	return course
+}
+
+func clampFinite(v float64, min float64, max float64) float64 {
+	return math.Max(min, math.Min(finite(v), max))
+}
+
+// Returns v, or 0 if it isn't finite.
+func finite(v float64) float64 {
+	if !isFinite(v) {
+		return 0.0
+	}
+	return v
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -0,0 +1,62 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+
+func TestNormalizeCourse(t *testing.T) {
+	cases := map[float64]float64 { 0.0: 0.0, 359.5: 359.5, 360.0: 0.0, 725.0: 5.0, -90.0: 270.0, -1e-15: 0.0, math.NaN(): 0.0, math.Inf(-1): 0.0 }
+	for course, expected := range cases {
+		if v := normalizeCourse(course); v != expected {
+			t.Errorf("normalizeCourse(%g): got %g, expected %g", course, v, expected)
+		}
+	}
+}
+
+func TestNormalizeResps(t *testing.T) {
+	resps := map[string]BoatDataLiveRespMsg {
+		"a": { Lat: 95.0, Lon: 190.0, Ctw: -10.0, Stw: -1.0, Cog: 360.0, Sog: math.Inf(1), Lws: 1e9, Ha: 200.0, Ext: &BoatDataExt { Hdg: 370.0, Heel: math.NaN() } },
+		"b": { Lat: math.NaN(), Lon: 10.0 },
+	}
+
+	normalizeResps(resps)
+
+	if _, exists := resps["b"]; exists {
+		t.Errorf("Boat with invalid position not left out")
+	}
+
+	a := resps["a"]
+	expected := BoatDataLiveRespMsg { Lat: 90.0, Lon: -170.0, Ctw: 350.0, Stw: 0.0, Cog: 0.0, Sog: 0.0, Lws: SIM_MAX_SPEED, Ha: -160.0 }
+	if a.Ext == nil || a.Ext.Hdg != 10.0 || a.Ext.Heel != 0.0 {
+		t.Errorf("Unexpected normalized extended data: %+v", a.Ext)
+	}
+	a.Ext = nil
+	if a != expected {
+		t.Errorf("Got %+v, expected %+v", a, expected)
+	}
+}
+
+func TestRoundCourseWraps(t *testing.T) {
+	if v := roundCourse(359.0, 10.0); v != 0.0 {
+		t.Errorf("Course rounded to %g, rather than wrapping to 0", v)
+	}
+}
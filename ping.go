@@ -0,0 +1,49 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+
+// Application-level ping, for clients (e.g. browsers) that can't send
+// WebSocket pings themselves: a "ping" request is answered with a "pong"
+// echoing its (optional) payload, along with the server's time.
+
+const PING_MAX_PAYLOAD_LEN = 256
+
+type PongMsg struct {
+	Type string `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Time int64 `json:"time"` // Unix time in ms
+}
+
+
+func wsReqPing(req *ReqMsg, conn *WsConn) {
+	if len(req.Payload) > PING_MAX_PAYLOAD_LEN {
+		sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, "Ping payload too long", PING_MAX_PAYLOAD_LEN)
+		return
+	}
+
+	conn.SendJSON(&PongMsg {
+		Type: "pong",
+		Payload: req.Payload,
+		Time: time.Now().UnixMilli(),
+	})
+}
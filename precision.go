@@ -0,0 +1,212 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+
+// Precision policy:
+//
+// Other boats' positions and courses (and spectators' speeds) are rounded
+// depending on how far away they're seen from, so as to disguise e.g. the
+// course a boat has set. How coarsely is given by a table for each of
+// position, course and speed, of (distance -> step) rules, the first rule
+// whose distance is reached applying (and full precision if none does). The
+// defaults can be replaced, e.g. for race formats with different rules on
+// what competitors may know of each other, with -precision-policy, a JSON file
+// like:
+//
+// {"coord":[{"dist":5,"step":0.0005},{"dist":1,"step":0.0001},{"dist":0,"step":0.00001}],
+//  "course":[{"dist":6,"step":22.5},{"dist":0,"step":5.625}],
+//  "speed":[{"dist":5,"step":0.5}]}
+//
+// Distances are in NM, and steps in degrees (coord and course) or knots
+// (speed), with 0 for full precision. Tables left out keep their defaults.
+
+// Reduced precision for single-boat streams:
+//
+// Boats in a bdl_g group see each other's positions and courses rounded
+// depending on how far away they are (see roundCoord and roundCourse), and
+// spectators see their boat rounded as if from SPECTATOR_PRECISION_DIST away.
+// With -bdl-precision-dist, bdl and bdl_x streams (e.g. for public embeds
+// served to untrusted consumers) likewise get their boat's position, course
+// and (for bdl_x) heading rounded as if seen from that far away. Other data
+// is left as is, as is the own boat's data in bdl_g streams, and spectators
+// still get their (coarser) spectator precision.
+
+// Maximum -bdl-precision-dist (NM), beyond which rounding gets no coarser
+const BDL_PRECISION_DIST_MAX = 60.0
+
+// From this distance (or more), the rule applies.
+type PrecisionRule struct {
+	Dist float64 `json:"dist"` // NM
+	Step float64 `json:"step"`
+}
+
+// Rules by decreasing distance
+type PrecisionTable []PrecisionRule
+
+type PrecisionPolicy struct {
+	Coord PrecisionTable `json:"coord"`
+	Course PrecisionTable `json:"course"`
+	Speed PrecisionTable `json:"speed"`
+}
+
+
+// Reduces the precision of a boat's data for a single-boat stream (if configured).
+func reduceBoatPrecision(data BoatDataLiveRespMsg) BoatDataLiveRespMsg {
+	dist := _config.BdlPrecisionDist
+	if dist <= 0 {
+		return data
+	}
+
+	data.Lat = roundCoord(data.Lat, dist)
+	data.Lon = roundCoord(data.Lon, dist)
+	data.Ctw = roundCourse(data.Ctw, dist)
+	data.Cog = roundCourse(data.Cog, dist)
+
+	if data.Ext != nil {
+		// Shared with other subscriptions, so copied rather than modified.
+		ext := *data.Ext
+		ext.Hdg = roundCourse(ext.Hdg, dist)
+		data.Ext = &ext
+	}
+
+	return data
+}
+
+func defaultPrecisionPolicy() *PrecisionPolicy {
+	return &PrecisionPolicy {
+		Coord: PrecisionTable {
+			{ 5.0, 0.0005 }, // ~50m (at equator)
+			{ 2.0, 0.0002 }, // ~20m
+			{ 1.0, 0.0001 }, // ~10m
+			{ 0.5, 0.00005 }, // ~5m
+			{ 0.2, 0.00002 }, // ~2m
+			{ 0.1, 0.00001 }, // ~1m
+			{ 0.05, 0.000005 }, // ~0.5m
+			{ 0.02, 0.000002 }, // ~0.2m
+			{ 0.0, 0.000001 }, // ~0.1m
+		},
+		Course: PrecisionTable {
+			{ 6.0, 22.5 }, // 16 points
+			{ 3.0, 11.25 }, // 32 points
+			{ 0.0, 5.625 }, // 64 points
+		},
+		Speed: PrecisionTable {
+			{ SPECTATOR_PRECISION_DIST, 0.5 },
+		},
+	}
+}
+
+// Loads a precision policy file, with any tables left out taken from the defaults.
+func loadPrecisionPolicy(path string) (*PrecisionPolicy, error) {
+	policy := defaultPrecisionPolicy()
+	if path == "" {
+		return policy, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded PrecisionPolicy
+	err = json.Unmarshal(data, &loaded)
+	if err != nil {
+		return nil, errors.New("Invalid precision policy file: " + err.Error())
+	}
+
+	tables := []struct { name string; src PrecisionTable; dst *PrecisionTable } {
+		{ "coord", loaded.Coord, &policy.Coord },
+		{ "course", loaded.Course, &policy.Course },
+		{ "speed", loaded.Speed, &policy.Speed },
+	}
+	for _, t := range tables {
+		if t.src == nil {
+			continue
+		}
+
+		err = t.src.validate()
+		if err != nil {
+			return nil, errors.New("Invalid " + t.name + " precision rules: " + err.Error())
+		}
+		*t.dst = t.src
+	}
+
+	return policy, nil
+}
+
+// Checks the rules, and sorts them by decreasing distance.
+func (t PrecisionTable) validate() error {
+	dists := make(map[float64]bool)
+	for _, rule := range t {
+		if !(rule.Dist >= 0.0) || math.IsInf(rule.Dist, 0) || !(rule.Step >= 0.0) || math.IsInf(rule.Step, 0) {
+			return errors.New("distances and steps must be non-negative")
+		}
+		if dists[rule.Dist] {
+			return errors.New("duplicate distance " + strconv.FormatFloat(rule.Dist, 'g', -1, 64))
+		}
+		dists[rule.Dist] = true
+	}
+
+	sort.Slice(t, func (i int, j int) bool {
+		return t[i].Dist > t[j].Dist
+	})
+	return nil
+}
+
+// Rounds a value as seen from a distance, according to the table's rules.
+func (t PrecisionTable) round(v float64, distance float64) float64 {
+	for _, rule := range t {
+		if distance >= rule.Dist {
+			return roundToStep(v, rule.Step)
+		}
+	}
+	return v
+}
+
+func roundToStep(v float64, step float64) float64 {
+	if step <= 0.0 {
+		return v
+	}
+
+	// Multiplying by the inverse of a fractional step (e.g. 2000 for 0.0005) is exact, whereas dividing by it isn't.
+	if inv := math.Round(1.0 / step); inv >= 1.0 && math.Abs(1.0 / step - inv) < 1e-6 {
+		return math.Round(v * inv) / inv
+	}
+	return math.Round(v / step) * step
+}
+
+func roundCoord(coord float64, distance float64) float64 {
+	return _config.Precision.Coord.round(coord, distance)
+}
+
+func roundCourse(course float64, distance float64) float64 {
+	return normalizeCourse(_config.Precision.Course.round(course, distance)) // 359.9 may round to 360.
+}
+
+func roundSpeed(speed float64, distance float64) float64 {
+	return _config.Precision.Speed.round(speed, distance)
+}
@@ -0,0 +1,76 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+
+func TestLoadPrecisionPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "precision.json")
+	os.WriteFile(path, []byte(`{"course":[{"dist":0,"step":10},{"dist":2,"step":45}],"speed":[]}`), 0600)
+
+	policy, err := loadPrecisionPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rules sorted by distance, and coord rules left at their defaults
+	if len(policy.Course) != 2 || policy.Course[0] != (PrecisionRule { 2.0, 45.0 }) {
+		t.Errorf("Unexpected course rules: %+v", policy.Course)
+	}
+	if len(policy.Coord) != len(defaultPrecisionPolicy().Coord) {
+		t.Errorf("Coord rules not left at their defaults: %+v", policy.Coord)
+	}
+
+	for _, c := range []struct { v float64; dist float64; expected float64 } {
+		{ 93.0, 5.0, 90.0 },
+		{ 93.0, 1.0, 90.0 },
+		{ 96.0, 0.0, 100.0 },
+	} {
+		if r := policy.Course.round(c.v, c.dist); r != c.expected {
+			t.Errorf("Course %f at %f NM rounded to %f, not %f", c.v, c.dist, r, c.expected)
+		}
+	}
+
+	// No speed rules, so full precision
+	if r := policy.Speed.round(5.55, 10.0); r != 5.55 {
+		t.Errorf("Speed rounded to %f", r)
+	}
+
+	for _, invalid := range []string { `{"coord":[{"dist":-1,"step":0.1}]}`, `{"speed":[{"dist":1,"step":0.5},{"dist":1,"step":1}]}`, `{"course":5}` } {
+		os.WriteFile(path, []byte(invalid), 0600)
+		if _, err := loadPrecisionPolicy(path); err == nil {
+			t.Errorf("Invalid precision policy accepted: %s", invalid)
+		}
+	}
+}
+
+func TestRoundToStep(t *testing.T) {
+	if r := roundToStep(123.4567371, 0.00002); r != 123.45674 {
+		t.Errorf("Unexpected rounding: %.10f", r)
+	}
+	if r := roundToStep(100.0, 22.5); r != 90.0 {
+		t.Errorf("Unexpected rounding: %f", r)
+	}
+	if r := roundToStep(1.234, 0.0); r != 1.234 {
+		t.Errorf("Unexpected rounding: %f", r)
+	}
+}
@@ -0,0 +1,76 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+)
+
+
+// Priority classes:
+//
+// Each subscription has a priority class, which decides which subscriptions
+// are degraded first while shedding load (see shedding.go): "high" ones never
+// are, "low" ones are as soon as shedding starts, and "normal" ones only if
+// it goes on. By default, subscriptions by boat key (racers) are "normal",
+// spectator subscriptions (by spectator ID, or gdl) are "low", and group_all
+// subscriptions (which need the admin token) are "high". A request may ask for
+// a lower priority than its default with "priority", e.g. for a secondary
+// display, but only for a higher one with the admin token ("admin").
+
+const PRIORITY_LOW = 0
+const PRIORITY_NORMAL = 1
+const PRIORITY_HIGH = 2
+
+const ERR_INVALID_PRIORITY = "invalid_priority"
+
+var _priorityNames = []string { "low", "normal", "high" }
+
+
+func priorityName(priority int) string {
+	return _priorityNames[priority]
+}
+
+// Returns the priority class for a subscription request with the given default, or false (having rejected
+// the request and closed the connection) if it's invalid or not allowed.
+func reqPriority(req *ReqMsg, conn *WsConn, defaultPriority int) (int, bool) {
+	if req.Priority == "" {
+		return defaultPriority, true
+	}
+
+	for priority, name := range _priorityNames {
+		if name != req.Priority {
+			continue
+		}
+
+		if priority > defaultPriority && !isAdminToken(req.Admin) {
+			log.Println("Client (" + conn.RemoteIp + ") requested " + name + " priority without valid admin token")
+
+			sendErrorMsg(conn, ERR_UNAUTHORIZED, "Valid admin token required for " + name + " priority")
+			conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Valid admin token required")
+			return 0, false
+		}
+
+		return priority, true
+	}
+
+	log.Println("Client (" + conn.RemoteIp + ") requested invalid priority: " + req.Priority)
+
+	sendErrorMsg(conn, ERR_INVALID_PRIORITY, "Priority must be \"low\", \"normal\" or \"high\"")
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid priority")
+	return 0, false
+}
@@ -0,0 +1,65 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+
+func TestIntegrationPriority(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	boatKey := testBoatKey(t, 0)
+	_testSim.setBoat(boatKey, 45.0, -30.0, 90.0)
+
+	for _, c := range []struct { Requested string; Expected string } {
+		{ "", "normal" },
+		{ "low", "low" },
+	} {
+		conn := testDial(t, url)
+		defer conn.Close()
+
+		testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey, "priority": c.Requested })
+		if msg := testReadSubscribed(t, conn); msg.Priority != c.Expected {
+			t.Errorf("Requested priority %q, expected %q, but got %q", c.Requested, c.Expected, msg.Priority)
+		}
+	}
+
+	// Raising priority needs the admin token.
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey, "priority": "high" })
+	var msg ErrorMsg
+	testRead(t, conn, &msg)
+	if msg.Error != ERR_UNAUTHORIZED {
+		t.Errorf("Unexpected response: %+v", msg)
+	}
+	testExpectCloseCode(t, conn, CLOSE_POLICY_VIOLATION)
+
+	conn = testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl", "key": boatKey, "priority": "urgent" })
+	testRead(t, conn, &msg)
+	if msg.Error != ERR_INVALID_PRIORITY {
+		t.Errorf("Unexpected response: %+v", msg)
+	}
+	testExpectCloseCode(t, conn, CLOSE_POLICY_VIOLATION)
+}
@@ -0,0 +1,306 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+
+// Reconnect tokens:
+//
+// With -reconnect-ttl, every bdl, bdl_g and bdl_x subscription (once its group
+// is known, for bdl_g) is sent an opaque token encoding the subscription (its
+// boat key, options, group membership, and any geofences of its own, with
+// their state, and waypoint):
+//
+// {"type":"reconnect","token":"<token>","expires":<unix_time>}
+//
+// On reconnecting, a client can send {"cmd":"resume","token":"<token>"} to
+// restore its subscription with that one message, without its boat key being
+// checked against the simulator or its group being looked up again. Unlike a
+// session (see session.go), nothing is kept on the server: the token itself
+// holds the subscription, encrypted and authenticated (with AES-GCM, since it
+// includes other boats' keys) with a key derived from -reconnect-secret, so
+// that tokens can be used with any instance sharing the secret. Without a
+// secret, a random key is used, and tokens only work with the same instance
+// until it's restarted. A restored subscription is sent "subscribed" (and any
+// history) as for a new one, and a new token.
+//
+// A new token is sent whenever the subscription's geofences or waypoint are
+// replaced, or the boat enters or exits a geofence, so that the latest token
+// always restores them as they are.
+//
+// If a group is too big for the token to stay under RECONNECT_MAX_TOKEN_LEN,
+// its members are left out, and looked up again on resuming. If the token is
+// still too big (with many large geofences), none is sent, since resuming
+// would lose the geofences.
+
+const RECONNECT_TOKEN_VERSION = 1
+const RECONNECT_MAX_TOKEN_LEN = 3072 // Well within the default -max-req-size
+
+type ReconnectMsg struct {
+	Type string `json:"type"`
+	Token string `json:"token"`
+	Expires int64 `json:"expires"` // Unix time (seconds)
+}
+
+// The subscription encoded in a reconnect token
+type ReconnectState struct {
+	Version int `json:"v"`
+	Expires int64 `json:"exp"`
+	BoatKey string `json:"k"`
+	Sim string `json:"sim"`
+	Spectator bool `json:"spec,omitempty"`
+	Extended bool `json:"ext,omitempty"`
+	Ais bool `json:"ais,omitempty"`
+	OthersSog bool `json:"os,omitempty"`
+	Hf bool `json:"hf,omitempty"`
+	Interp bool `json:"ip,omitempty"`
+	Fields []string `json:"f,omitempty"`
+	SmoothCog int `json:"sc,omitempty"`
+	Units *Units `json:"u,omitempty"`
+	Group bool `json:"g,omitempty"`
+	Members [][2]string `json:"m,omitempty"` // [boat key, name], or nil if the group's to be looked up again
+	Subscribed int64 `json:"sub,omitempty"` // Unix time (seconds) of the original subscription (see lifetime.go)
+	Priority *int `json:"pr,omitempty"` // See priority.go (nil for tokens predating priority classes).
+	Geofences []Geofence `json:"gf,omitempty"` // The subscription's own (not the operator's; see geofence.go)
+	GeofencesInside map[string]bool `json:"gfi,omitempty"` // By geofence ID, once checked (including the operator's)
+	Waypoint *Waypoint `json:"wp,omitempty"` // See waypoint.go.
+}
+
+var _reconnectAead cipher.AEAD = nil
+
+
+func reconnectInit() {
+	if _config.ReconnectTtl <= 0 {
+		return
+	}
+
+	var key [32]byte
+	if _config.ReconnectSecret != "" {
+		key = sha256.Sum256([]byte(_config.ReconnectSecret))
+	} else {
+		rand.Read(key[:])
+	}
+
+	block, _ := aes.NewCipher(key[:]) // Can't fail with a 32-byte key.
+	_reconnectAead, _ = cipher.NewGCM(block)
+}
+
+// Sends a subscription's reconnect token, if enabled (and applicable). Caller must hold _lock.
+func sendReconnectToken(conn *WsConn, connCtx *ConnCtx) {
+	if _reconnectAead == nil || connCtx.GroupAll || connCtx.GroupPending {
+		return
+	}
+
+	state := &ReconnectState {
+		Version: RECONNECT_TOKEN_VERSION,
+		Expires: time.Now().Add(_config.ReconnectTtl).Unix(),
+		BoatKey: connCtx.BoatKey,
+		Sim: connCtx.Sim,
+		Spectator: connCtx.Spectator,
+		Extended: connCtx.Extended,
+		Ais: connCtx.Ais,
+		OthersSog: connCtx.OthersSog,
+		Hf: connCtx.Hf != nil,
+		Interp: connCtx.Interp,
+		Fields: listFields(connCtx.Fields),
+		Units: connCtx.Units,
+		Group: connCtx.GroupBoats != nil,
+		Priority: &connCtx.Priority,
+		Waypoint: connCtx.Waypoint,
+	}
+	if !connCtx.SubscribedAt.IsZero() {
+		state.Subscribed = connCtx.SubscribedAt.Unix()
+	}
+	if connCtx.CogSmoother != nil {
+		state.SmoothCog = connCtx.CogSmoother.Iters
+	}
+	if connCtx.Geofences != nil {
+		state.Geofences = connCtx.Geofences.own()
+		state.GeofencesInside = connCtx.Geofences.inside
+	}
+
+	if state.Group {
+		state.Members = make([][2]string, 0, connCtx.GroupBoats.Len())
+		for e := connCtx.GroupBoats.Front(); e != nil; e = e.Next() {
+			boat := e.Value.(*BoatInfo)
+			state.Members = append(state.Members, [2]string { boat.BoatKey, boat.FriendlyName })
+		}
+	}
+
+	token := encodeReconnectToken(state)
+	if len(token) > RECONNECT_MAX_TOKEN_LEN && state.Group {
+		state.Members = nil
+		token = encodeReconnectToken(state)
+	}
+	if token == "" || len(token) > RECONNECT_MAX_TOKEN_LEN {
+		return
+	}
+
+	conn.SendJSON(&ReconnectMsg {
+		Type: "reconnect",
+		Token: token,
+		Expires: state.Expires,
+	})
+}
+
+func encodeReconnectToken(state *ReconnectState) string {
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+
+	nonce := make([]byte, _reconnectAead.NonceSize(), _reconnectAead.NonceSize() + len(data) + _reconnectAead.Overhead())
+	rand.Read(nonce)
+
+	return base64.RawURLEncoding.EncodeToString(_reconnectAead.Seal(nonce, nonce, data, nil))
+}
+
+// Decodes a reconnect token, returning nil if it's invalid or expired.
+func decodeReconnectToken(token string) *ReconnectState {
+	if _reconnectAead == nil || len(token) > RECONNECT_MAX_TOKEN_LEN {
+		return nil
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < _reconnectAead.NonceSize() {
+		return nil
+	}
+
+	nonceSize := _reconnectAead.NonceSize()
+	data, err := _reconnectAead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil
+	}
+
+	var state ReconnectState
+	err = json.Unmarshal(data, &state)
+	if err != nil || state.Version != RECONNECT_TOKEN_VERSION || time.Now().Unix() > state.Expires {
+		return nil
+	}
+
+	return &state
+}
+
+// Restores a subscription from a decoded reconnect token, returning false if it isn't valid. Caller must
+// hold _lock (and have checked that the connection isn't already subscribed, and may subscribe to the boat).
+func resumeFromReconnectToken(state *ReconnectState, conn *WsConn) bool {
+	if simClient(state.Sim) == nil || (state.Extended && _config.ClusterRole == CLUSTER_ROLE_EDGE) {
+		return false
+	}
+
+	fields, ok := parseFields(state.Fields, state.Extended)
+	if !ok {
+		return false
+	}
+
+	// The operator's geofences may have changed since the token was sent (with another instance, say).
+	if checkReqGeofences(state.Geofences) != nil || (state.Waypoint != nil && !validLatLon(state.Waypoint.Lat, state.Waypoint.Lon)) {
+		return false
+	}
+
+	if rejectIfTooManySubscribers(conn, state.BoatKey) || rejectIfTooManyTracked(conn, state.BoatKey) {
+		return true
+	}
+
+	connCtx := ConnCtx {
+		BoatKey: state.BoatKey,
+		Spectator: state.Spectator,
+		Extended: state.Extended && !state.Spectator,
+		Ais: state.Ais,
+		OthersSog: state.OthersSog,
+		Sim: state.Sim,
+		Fields: fields,
+		Units: state.Units,
+		Interp: state.Interp && _config.InterpRate > 0,
+		Waypoint: state.Waypoint,
+	}
+	if !state.Spectator {
+		connCtx.Geofences = newGeofenceState(state.Geofences)
+		connCtx.Geofences.restoreInside(state.GeofencesInside)
+	}
+	if state.Priority != nil {
+		connCtx.Priority = min(max(*state.Priority, PRIORITY_LOW), PRIORITY_HIGH)
+	} else if !state.Spectator {
+		connCtx.Priority = PRIORITY_NORMAL
+	}
+	if state.Subscribed != 0 {
+		// The subscription's lifetime isn't extended by reconnecting.
+		connCtx.SubscribedAt = time.Unix(state.Subscribed, 0)
+		if subLifetimeExpired(&connCtx, time.Now()) {
+			sendResubscribeMsgAndClose(conn)
+			return true
+		}
+	}
+	if state.SmoothCog > 1 {
+		connCtx.CogSmoother = newCogSmoother(state.SmoothCog)
+	}
+	if state.Group {
+		connCtx.Hf = reqHf(&ReqMsg { Hf: state.Hf }, true)
+
+		if state.Members != nil {
+			connCtx.GroupBoats = list.New()
+			for _, m := range state.Members {
+				connCtx.GroupBoats.PushBack(&BoatInfo { BoatKey: m[0], FriendlyName: m[1] })
+			}
+		} else {
+			connCtx.GroupBoats = pendingGroup(state.BoatKey)
+			connCtx.GroupPending = true
+		}
+	}
+
+	if !trackConnCtx(&connCtx) {
+		rejectTooManyTracked(conn, state.BoatKey)
+		return true
+	}
+
+	_conns[conn] = connCtx
+	switch {
+	case connCtx.Spectator:
+		conn.SetType(CONN_TYPE_SPECTATOR)
+	case connCtx.GroupBoats != nil:
+		conn.SetType(CONN_TYPE_BDL_G)
+	default:
+		conn.SetType(CONN_TYPE_BDL)
+	}
+
+	_countConns++
+
+	addConnToKey(state.BoatKey, conn)
+
+	sendSubscribedMsg(conn, &connCtx)
+	sendHistory(conn, &connCtx)
+	sendReconnectToken(conn, &connCtx)
+
+	if connCtx.GroupPending {
+		fetchGroup(conn, &connCtx)
+	}
+
+	return true
+}
@@ -0,0 +1,251 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func testReconnectTokens(t *testing.T) {
+	prevTtl := _config.ReconnectTtl
+
+	_lock.Lock()
+	_config.ReconnectTtl = time.Minute
+	reconnectInit()
+	_lock.Unlock()
+
+	t.Cleanup(func() {
+		_lock.Lock()
+		_config.ReconnectTtl = prevTtl
+		_reconnectAead = nil
+		_lock.Unlock()
+	})
+}
+
+func TestReconnectToken(t *testing.T) {
+	testReconnectTokens(t)
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	state := &ReconnectState {
+		Version: RECONNECT_TOKEN_VERSION,
+		Expires: time.Now().Add(time.Minute).Unix(),
+		BoatKey: testBoatKey(t, 0),
+		Sim: DEFAULT_SIM,
+		Group: true,
+		Members: [][2]string { { testBoatKey(t, 0), "Me" }, { testBoatKey(t, 1), "Other" } },
+	}
+
+	token := encodeReconnectToken(state)
+	if strings.Contains(token, state.BoatKey) {
+		t.Errorf("Boat key visible in token: %s", token)
+	}
+
+	decoded := decodeReconnectToken(token)
+	if decoded == nil || decoded.BoatKey != state.BoatKey || len(decoded.Members) != 2 || decoded.Members[1][1] != "Other" {
+		t.Errorf("Unexpected decoded token: %+v", decoded)
+	}
+
+	// Tampered with
+	b := []byte(token)
+	b[len(b) / 2] ^= 1
+	if decodeReconnectToken(string(b)) != nil {
+		t.Errorf("Tampered token accepted!")
+	}
+
+	// Expired
+	state.Expires = time.Now().Add(-time.Second).Unix()
+	if decodeReconnectToken(encodeReconnectToken(state)) != nil {
+		t.Errorf("Expired token accepted!")
+	}
+}
+
+func TestReconnectTokenGeofencesWaypoint(t *testing.T) {
+	testReconnectTokens(t)
+
+	boatKey := "f4000000000000000000000000000000"
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	geofences := newGeofenceState([]Geofence { { Id: "a", Circle: &GeofenceCircle { Lat: 0.0, Lon: 0.0, Radius: 6.0 } } })
+	geofences.inside["a"] = true
+	connCtx := ConnCtx { BoatKey: boatKey, Sim: DEFAULT_SIM, Priority: PRIORITY_NORMAL, Geofences: geofences, Waypoint: &Waypoint { Lat: 1.0, Lon: 2.0 } }
+
+	_lock.Lock()
+	sendReconnectToken(wc, &connCtx)
+	_lock.Unlock()
+
+	if len(wc.queue) != 1 {
+		t.Fatalf("Expected a reconnect token, but got %d messages!", len(wc.queue))
+	}
+	var reconnect ReconnectMsg
+	if err := json.Unmarshal(wc.queue[0].Data, &reconnect); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resuming restores the geofences (with their state) and the waypoint.
+	resumed := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	_lock.Lock()
+	defer _lock.Unlock()
+	defer releaseConn(resumed)
+
+	if !resumeFromReconnectToken(decodeReconnectToken(reconnect.Token), resumed) {
+		t.Fatalf("Reconnect token not accepted!")
+	}
+
+	restored := _conns[resumed]
+	if restored.Geofences == nil || len(restored.Geofences.own()) != 1 || restored.Geofences.own()[0].Circle.Radius != 6.0 {
+		t.Fatalf("Geofences not restored: %+v", restored.Geofences)
+	}
+	if restored.Waypoint == nil || *restored.Waypoint != *connCtx.Waypoint {
+		t.Errorf("Waypoint not restored: %+v", restored.Waypoint)
+	}
+
+	// Still inside, so there's no alert (rather than another "inside" one).
+	resumed.queue = nil
+	updateGeofences(map[string]BoatDataLiveRespMsg { boatKey: { Lat: 0.0, Lon: 0.0 } })
+	updateGeofences(map[string]BoatDataLiveRespMsg { boatKey: { Lat: 0.0, Lon: 0.5 } })
+	if len(resumed.queue) != 2 || string(resumed.queue[0].Data) != `{"type":"alert","alert":"geofence","id":"a","event":"exit"}` {
+		t.Fatalf("Unexpected messages after resuming: %d", len(resumed.queue))
+	}
+
+	// Which sends a new token, with the new state.
+	if err := json.Unmarshal(resumed.queue[1].Data, &reconnect); err != nil || reconnect.Type != "reconnect" {
+		t.Fatalf("No new reconnect token after geofence alert: %s", resumed.queue[1].Data)
+	}
+	if state := decodeReconnectToken(reconnect.Token); state == nil || state.GeofencesInside["a"] {
+		t.Errorf("Unexpected geofence state in new token: %+v", state)
+	}
+}
+
+func TestIntegrationReconnectToken(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+	testReconnectTokens(t)
+
+	boatKey := testBoatKey(t, 0)
+	nearKey := testBoatKey(t, 1)
+	_testSim.setBoat(boatKey, 45.0, -30.0, 90.0)
+	_testSim.setBoat(nearKey, 45.01, -30.0, 180.0)
+	_testSim.setGroup([]*BoatInfo {
+		&BoatInfo { boatKey, "Me" },
+		&BoatInfo { nearKey, "Near" },
+	})
+
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "bdl_g", "key": boatKey, "fields": []string { "lat", "lon" } })
+	testReadSubscribed(t, conn)
+
+	// The token is sent once the group is known.
+	var reconnect ReconnectMsg
+	for i := 0; i < 5 && reconnect.Type != "reconnect"; i++ {
+		testRead(t, conn, &reconnect)
+	}
+	if reconnect.Type != "reconnect" || reconnect.Token == "" || reconnect.Expires <= time.Now().Unix() {
+		t.Fatalf("Unexpected reconnect message: %+v", reconnect)
+	}
+	conn.Close()
+
+	// Resuming restores the subscription, with its group and fields, without another group lookup.
+	conn = testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "resume", "token": reconnect.Token })
+	ack := testReadSubscribed(t, conn)
+	if ack.Group != 2 || ack.GroupPending || len(ack.Fields) != 2 {
+		t.Errorf("Unexpected restored subscription: %+v", ack)
+	}
+
+	var renewed ReconnectMsg
+	testRead(t, conn, &renewed)
+	if renewed.Type != "reconnect" || renewed.Token == reconnect.Token {
+		t.Errorf("Unexpected reconnect message: %+v", renewed)
+	}
+
+	var msg BoatGroupRespMsg
+	testRead(t, conn, &msg)
+	if msg.ThisBoat.Lat != 45.0 || len(msg.OtherBoats) != 1 {
+		t.Errorf("Unexpected live data: %+v", msg)
+	}
+
+	// An invalid token is rejected as an unknown session would be.
+	conn2 := testDial(t, url)
+	defer conn2.Close()
+
+	testSend(t, conn2, map[string]interface{} { "cmd": "resume", "token": reconnect.Token[1:] })
+	testExpectCloseCode(t, conn2, CLOSE_INVALID_KEY)
+}
+
+func TestReconnectTokenAdmission(t *testing.T) {
+	testReconnectTokens(t)
+
+	boatKey := "f5000000000000000000000000000000"
+	token := encodeReconnectToken(&ReconnectState {
+		Version: RECONNECT_TOKEN_VERSION,
+		Expires: time.Now().Add(time.Minute).Unix(),
+		BoatKey: boatKey,
+		Sim: DEFAULT_SIM,
+	})
+
+	resume := func () *WsConn {
+		wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+		wsReqResume(&ReqMsg { Token: token }, wc)
+
+		_lock.Lock()
+		defer _lock.Unlock()
+		if _, subscribed := _conns[wc]; subscribed {
+			releaseConn(wc)
+			t.Errorf("Resumed despite being refused!")
+		}
+		return wc
+	}
+
+	// Refused under memory pressure, as a new subscription would be.
+	_memoryPressure.Store(true)
+	wc := resume()
+	_memoryPressure.Store(false)
+	if wc.closeCode != CLOSE_TRY_AGAIN_LATER {
+		t.Errorf("Unexpected close code under memory pressure: %d", wc.closeCode)
+	}
+
+	// And for a banned boat key.
+	prevBanConnects := _config.BanConnects
+	_config.BanConnects = 1
+	defer func() {
+		_config.BanConnects = prevBanConnects
+		_abuseLock.Lock()
+		delete(_abuseKeys, boatKey)
+		_abuseLock.Unlock()
+	}()
+
+	_abuseLock.Lock()
+	ban(abuseEntry(_abuseKeys, boatKey, time.Now()), "boat key " + boatKey, time.Now(), "test")
+	_abuseLock.Unlock()
+
+	wc = resume()
+	if wc.closeCode != CLOSE_POLICY_VIOLATION || len(wc.queue) != 1 || !strings.Contains(string(wc.queue[0].Data), ERR_BANNED) {
+		t.Errorf("Banned boat key not refused: %d", wc.closeCode)
+	}
+}
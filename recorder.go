@@ -0,0 +1,346 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+
+// Track recorder:
+//
+// Appends each tracked boat's live data to per-boat files under the record
+// directory, as either newline-delimited GeoJSON point features or GPX track
+// points. Files are laid out as "<dir>/<boat_key>/<start_time>.<ext>", with a
+// new file started for each boat once its current file is older than the
+// rotation period. Files older than the retention period are deleted.
+
+const RECORD_FORMAT_GEOJSON = "geojson"
+const RECORD_FORMAT_GPX = "gpx"
+
+const RECORD_EXT_GEOJSON = ".ndjson"
+const RECORD_EXT_GPX = ".gpx"
+
+const RECORD_FILE_TIME_FORMAT = "20060102T150405Z"
+
+const RECORDER_QUEUE_SIZE = 16
+const RECORDER_IDLE_CLOSE = 60 * time.Second
+const RECORDER_RETENTION_CHECK_INTERVAL = 10 * time.Minute
+
+const GPX_HEADER = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+	"<gpx version=\"1.1\" creator=\"sailnavsim-snsw\" xmlns=\"http://www.topografix.com/GPX/1/1\" xmlns:snsw=\"https://8bitbyte.ca/sailnavsim/gpx/1\">\n" +
+	"<trk><trkseg>\n"
+const GPX_TRAILER = "</trkseg></trk></gpx>\n"
+
+type RecorderBatch struct {
+	Time time.Time
+	Resps map[string]BoatDataLiveRespMsg
+}
+
+type TrackFile struct {
+	File *os.File
+	Opened time.Time
+	LastWrite time.Time
+	Size int64
+}
+
+type GeoJsonPoint struct {
+	Type string `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type GeoJsonTrackProps struct {
+	Time string `json:"time"`
+	Ctw float64 `json:"ctw"`
+	Stw float64 `json:"stw"`
+	Cog float64 `json:"cog"`
+	Sog float64 `json:"sog"`
+	Lws float64 `json:"lws"`
+	Ha float64 `json:"ha"`
+}
+
+type GeoJsonTrackFeature struct {
+	Type string `json:"type"`
+	Geometry GeoJsonPoint `json:"geometry"`
+	Properties GeoJsonTrackProps `json:"properties"`
+}
+
+var _recorderQueue = make(chan *RecorderBatch, RECORDER_QUEUE_SIZE)
+
+
+func recorderEnabled() bool {
+	return _config.RecordDir != ""
+}
+
+func recorderInit() {
+	if !recorderEnabled() {
+		return
+	}
+
+	err := os.MkdirAll(_config.RecordDir, 0755)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Println("Recording tracks (" + _config.RecordFormat + ") to: " + _config.RecordDir)
+	go recorderMain()
+}
+
+// Hands off one iteration's worth of boat data to the recorder, without blocking.
+func recordResps(t time.Time, resps map[string]BoatDataLiveRespMsg) {
+	if !recorderEnabled() || len(resps) == 0 {
+		return
+	}
+
+	select {
+	case _recorderQueue <- &RecorderBatch { t, resps }:
+	default:
+		log.Println("Recorder queue full; dropping data for this iteration.")
+	}
+}
+
+func recordFileExt() string {
+	if _config.RecordFormat == RECORD_FORMAT_GPX {
+		return RECORD_EXT_GPX
+	}
+	return RECORD_EXT_GEOJSON
+}
+
+func recorderMain() {
+	files := make(map[string]*TrackFile)
+
+	retentionTicker := time.NewTicker(RECORDER_RETENTION_CHECK_INTERVAL)
+	defer retentionTicker.Stop()
+
+	for {
+		select {
+		case batch := <-_recorderQueue:
+			for boatKey, resp := range batch.Resps {
+				recordSample(files, boatKey, batch.Time, &resp)
+			}
+
+			// Close files for boats that are no longer being tracked.
+			for boatKey, tf := range files {
+				if batch.Time.Sub(tf.LastWrite) > RECORDER_IDLE_CLOSE {
+					tf.File.Close()
+					delete(files, boatKey)
+				}
+			}
+
+		case <-retentionTicker.C:
+			applyRecordRetention(files)
+		}
+	}
+}
+
+func recordSample(files map[string]*TrackFile, boatKey string, t time.Time, resp *BoatDataLiveRespMsg) {
+	tf, exists := files[boatKey]
+	if exists && _config.RecordRotate > 0 && t.Sub(tf.Opened) >= _config.RecordRotate {
+		tf.File.Close()
+		delete(files, boatKey)
+		exists = false
+	}
+
+	if !exists {
+		tf = openTrackFile(boatKey, t)
+		if tf == nil {
+			return
+		}
+		files[boatKey] = tf
+	}
+
+	var err error
+	if _config.RecordFormat == RECORD_FORMAT_GPX {
+		err = appendGpxTrackPoint(tf, t, resp)
+	} else {
+		err = appendGeoJsonTrackPoint(tf, t, resp)
+	}
+
+	if err != nil {
+		log.Println(err)
+		tf.File.Close()
+		delete(files, boatKey)
+		return
+	}
+
+	tf.LastWrite = t
+}
+
+func openTrackFile(boatKey string, t time.Time) *TrackFile {
+	dir := filepath.Join(_config.RecordDir, boatKey)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	path := filepath.Join(dir, t.UTC().Format(RECORD_FILE_TIME_FORMAT) + recordFileExt())
+	f, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE, 0644)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Println(err)
+		f.Close()
+		return nil
+	}
+
+	tf := &TrackFile {
+		File: f,
+		Opened: t,
+		LastWrite: t,
+		Size: info.Size(),
+	}
+
+	if _config.RecordFormat == RECORD_FORMAT_GPX && tf.Size == 0 {
+		_, err = f.WriteAt([]byte(GPX_HEADER + GPX_TRAILER), 0)
+		if err != nil {
+			log.Println(err)
+			f.Close()
+			return nil
+		}
+		tf.Size = int64(len(GPX_HEADER) + len(GPX_TRAILER))
+	}
+
+	return tf
+}
+
+func appendGeoJsonTrackPoint(tf *TrackFile, t time.Time, resp *BoatDataLiveRespMsg) error {
+	data, err := json.Marshal(&GeoJsonTrackFeature {
+		Type: "Feature",
+		Geometry: GeoJsonPoint {
+			Type: "Point",
+			Coordinates: [2]float64 { resp.Lon, resp.Lat },
+		},
+		Properties: GeoJsonTrackProps {
+			Time: t.UTC().Format(time.RFC3339Nano),
+			Ctw: resp.Ctw,
+			Stw: resp.Stw,
+			Cog: resp.Cog,
+			Sog: resp.Sog,
+			Lws: resp.Lws,
+			Ha: resp.Ha,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+	_, err = tf.File.WriteAt(data, tf.Size)
+	if err != nil {
+		return err
+	}
+
+	tf.Size += int64(len(data))
+	return nil
+}
+
+// Appends a track point to a GPX file, overwriting and then rewriting the closing
+// tags, so that the file is always a complete, valid GPX document.
+func appendGpxTrackPoint(tf *TrackFile, t time.Time, resp *BoatDataLiveRespMsg) error {
+	pt := "<trkpt lat=\"" + formatRecordFloat(resp.Lat) + "\" lon=\"" + formatRecordFloat(resp.Lon) + "\">" +
+		"<time>" + t.UTC().Format(time.RFC3339Nano) + "</time>" +
+		"<extensions>" +
+		"<snsw:ctw>" + formatRecordFloat(resp.Ctw) + "</snsw:ctw>" +
+		"<snsw:stw>" + formatRecordFloat(resp.Stw) + "</snsw:stw>" +
+		"<snsw:cog>" + formatRecordFloat(resp.Cog) + "</snsw:cog>" +
+		"<snsw:sog>" + formatRecordFloat(resp.Sog) + "</snsw:sog>" +
+		"<snsw:lws>" + formatRecordFloat(resp.Lws) + "</snsw:lws>" +
+		"<snsw:ha>" + formatRecordFloat(resp.Ha) + "</snsw:ha>" +
+		"</extensions></trkpt>\n"
+
+	offset := tf.Size - int64(len(GPX_TRAILER))
+	if offset < int64(len(GPX_HEADER)) {
+		offset = tf.Size
+	}
+
+	data := []byte(pt + GPX_TRAILER)
+	_, err := tf.File.WriteAt(data, offset)
+	if err != nil {
+		return err
+	}
+
+	tf.Size = offset + int64(len(data))
+	return nil
+}
+
+func formatRecordFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func applyRecordRetention(openFiles map[string]*TrackFile) {
+	if _config.RecordRetention <= 0 {
+		return
+	}
+
+	open := make(map[string]bool)
+	for _, tf := range openFiles {
+		open[tf.File.Name()] = true
+	}
+
+	cutoff := time.Now().Add(-_config.RecordRetention)
+
+	boatDirs, err := os.ReadDir(_config.RecordDir)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, boatDir := range boatDirs {
+		if !boatDir.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(_config.RecordDir, boatDir.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		remaining := len(entries)
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			info, err := entry.Info()
+			if err != nil || open[path] || !info.ModTime().Before(cutoff) {
+				continue
+			}
+
+			err = os.Remove(path)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			remaining--
+		}
+
+		if remaining == 0 {
+			os.Remove(dir)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+// Minimal Redis client speaking just enough RESP for the cluster fan-out layer
+// (commands with simple/bulk/integer/array replies, and pub/sub).
+type RedisConn struct {
+	conn net.Conn
+	reader *bufio.Reader
+}
+
+func redisDial(hostPort string) (*RedisConn, error) {
+	conn, err := net.DialTimeout("tcp", hostPort, DIAL_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisConn {
+		conn: conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+func (rc *RedisConn) Close() error {
+	return rc.conn.Close()
+}
+
+// Do sends a command and waits for its reply, with the usual read/write timeout.
+func (rc *RedisConn) Do(args ...string) (interface{}, error) {
+	if err := rc.conn.SetDeadline(time.Now().Add(CONN_RW_TIMEOUT)); err != nil {
+		return nil, err
+	}
+
+	if err := rc.Send(args...); err != nil {
+		return nil, err
+	}
+
+	return rc.ReadReply()
+}
+
+// Send writes a command without waiting for a reply (used for pub/sub, where
+// replies arrive asynchronously on the same connection).
+func (rc *RedisConn) Send(args ...string) error {
+	var sb strings.Builder
+
+	sb.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		sb.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+
+	_, err := rc.conn.Write([]byte(sb.String()))
+	return err
+}
+
+// ReadReply reads a single reply, returning one of: string, int64, nil, or []interface{}.
+// Error replies are returned as errors.
+func (rc *RedisConn) ReadReply() (interface{}, error) {
+	line, err := rc.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("Empty reply line from Redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+
+	case '-':
+		return nil, errors.New("Redis error: " + line[1:])
+
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, n + 2)
+		_, err = io.ReadFull(rc.reader, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(buf[:n]), nil
+
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		arr := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			arr[i], err = rc.ReadReply()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return arr, nil
+
+	default:
+		return nil, errors.New("Unexpected reply type from Redis: " + line)
+	}
+}
@@ -0,0 +1,249 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sailnavsim-snsw/internal/hub"
+)
+
+
+func testRedisPipe() (*RedisConn, net.Conn) {
+	client, server := net.Pipe()
+	return &RedisConn { conn: client, reader: bufio.NewReader(client) }, server
+}
+
+// Reads one command (an array of bulk strings) as sent by RedisConn.Send.
+func testRedisReadCmd(t *testing.T, reader *bufio.Reader) []string {
+	rc := &RedisConn { reader: reader }
+	reply, err := rc.ReadReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cmd []string
+	for _, arg := range reply.([]interface{}) {
+		cmd = append(cmd, arg.(string))
+	}
+	return cmd
+}
+
+func TestRedisSend(t *testing.T) {
+	rc, server := testRedisPipe()
+	defer rc.Close()
+	defer server.Close()
+
+	go rc.Send("SET", "key", "", "a\r\nb")
+
+	buf := make([]byte, 256)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "*4\r\n$3\r\nSET\r\n$3\r\nkey\r\n$0\r\n\r\n$4\r\na\r\nb\r\n"
+	if string(buf[:n]) != expected {
+		t.Errorf("Unexpected command encoding: %q", string(buf[:n]))
+	}
+}
+
+func TestRedisReadReply(t *testing.T) {
+	tests := []struct {
+		in string
+		expected interface{}
+	} {
+		{ "+OK\r\n", "OK" },
+		{ ":42\r\n", int64(42) },
+		{ ":-1\r\n", int64(-1) },
+		{ "$5\r\nhello\r\n", "hello" },
+		{ "$0\r\n\r\n", "" },
+		{ "$6\r\na\r\nb\r\n\r\n", "a\r\nb\r\n" },
+		{ "$-1\r\n", nil },
+		{ "*-1\r\n", nil },
+		{ "*0\r\n", []interface{} {} },
+		{ "*3\r\n$7\r\nmessage\r\n$4\r\nchan\r\n:1\r\n", []interface{} { "message", "chan", int64(1) } },
+		{ "*2\r\n*1\r\n+QUEUED\r\n$-1\r\n", []interface{} { []interface{} { "QUEUED" }, nil } },
+	}
+
+	for _, test := range tests {
+		rc := &RedisConn { reader: bufio.NewReader(strings.NewReader(test.in)) }
+
+		reply, err := rc.ReadReply()
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(reply, test.expected) {
+			t.Errorf("%q: got %#v, expected %#v", test.in, reply, test.expected)
+		}
+	}
+}
+
+func TestRedisReadReplyErrors(t *testing.T) {
+	for _, in := range []string {
+		"-ERR unknown command\r\n",
+		"*2\r\n+OK\r\n-WRONGTYPE bad\r\n",
+		"\r\n",
+		"?what\r\n",
+		":notanumber\r\n",
+		"$5\r\nabc",
+		"*2\r\n+OK\r\n",
+		"",
+	} {
+		rc := &RedisConn { reader: bufio.NewReader(strings.NewReader(in)) }
+
+		if reply, err := rc.ReadReply(); err == nil {
+			t.Errorf("%q: expected an error, got %#v", in, reply)
+		}
+	}
+
+	rc := &RedisConn { reader: bufio.NewReader(strings.NewReader("-ERR oops\r\n")) }
+	if _, err := rc.ReadReply(); err == nil || !strings.Contains(err.Error(), "ERR oops") {
+		t.Errorf("Unexpected error for error reply: %v", err)
+	}
+}
+
+func TestRedisDo(t *testing.T) {
+	rc, server := testRedisPipe()
+	defer rc.Close()
+	defer server.Close()
+
+	go func() {
+		reader := bufio.NewReader(server)
+		for _, reply := range []string { ":3\r\n", "-ERR no such key\r\n" } {
+			testRedisReadCmd(t, reader)
+			server.Write([]byte(reply))
+		}
+	}()
+
+	reply, err := rc.Do("HINCRBY", "h", "f", "1")
+	if err != nil || reply != int64(3) {
+		t.Errorf("Unexpected reply: %#v, %v", reply, err)
+	}
+
+	// An error reply doesn't break the connection for later commands' replies, but is returned as an error.
+	if _, err := rc.Do("GET", "nokey"); err == nil {
+		t.Error("Expected an error reply")
+	}
+}
+
+func TestClusterEdgeRegister(t *testing.T) {
+	saved := _config
+	defer func() { _config = saved }()
+	_config = &Config { ClusterPrefix: "test:" }
+	_clusterEdgeId = "e1"
+
+	rc, server := testRedisPipe()
+	defer rc.Close()
+	defer server.Close()
+
+	cmds := make(chan []string, 16)
+	go func() {
+		reader := bufio.NewReader(server)
+		for {
+			cmd := testRedisReadCmd(t, reader)
+			cmds <- cmd
+
+			reply := "+QUEUED\r\n"
+			switch cmd[0] {
+			case "MULTI":
+				reply = "+OK\r\n"
+			case "EXEC":
+				reply = "*3\r\n:1\r\n:1\r\n:1\r\n"
+			}
+			if _, err := server.Write([]byte(reply)); err != nil {
+				return
+			}
+			if cmd[0] == "EXEC" {
+				return
+			}
+		}
+	}()
+
+	err := clusterEdgeRegister(rc, map[string]bool { "k1": true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(cmds)
+
+	var names []string
+	for cmd := range cmds {
+		names = append(names, cmd[0])
+
+		switch cmd[0] {
+		case "SADD":
+			if !reflect.DeepEqual(cmd, []string { "SADD", "test:edge:e1", "k1" }) {
+				t.Errorf("Unexpected SADD: %v", cmd)
+			}
+		case "EXPIRE":
+			if !reflect.DeepEqual(cmd, []string { "EXPIRE", "test:edge:e1", "30" }) {
+				t.Errorf("Unexpected EXPIRE: %v", cmd)
+			}
+		case "ZADD":
+			if len(cmd) != 4 || cmd[1] != "test:edges" || cmd[3] != "e1" {
+				t.Errorf("Unexpected ZADD: %v", cmd)
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(names, []string { "MULTI", "SADD", "EXPIRE", "ZADD", "EXEC" }) {
+		t.Errorf("Unexpected commands: %v", names)
+	}
+}
+
+func TestClusterEdgeStale(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	saved := _config
+	defer func() { _config = saved }()
+	_config = &Config { ClusterRole: CLUSTER_ROLE_EDGE, SimGrace: 1 }
+
+	_clusterLock.Lock()
+	_clusterSnapshots["edge-stale"] = &ClusterSnapshot { Data: BoatDataLiveRespMsg { Lat: 1.0 }, Received: time.Now().Add(-2 * CLUSTER_STALE_TIMEOUT) }
+	_clusterLock.Unlock()
+	defer func() {
+		_clusterLock.Lock()
+		delete(_clusterSnapshots, "edge-stale")
+		_clusterLock.Unlock()
+	}()
+
+	resps, noBoats := clusterEdgeResps()
+	if _, exists := resps["edge-stale"]; exists || noBoats["edge-stale"] {
+		t.Fatalf("Stale snapshot used")
+	}
+
+	// A stale (or missing) snapshot is missed like simulator data, so the connection is closed after -sim-grace iterations.
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	conns := []hub.Subscriber { wc }
+	defer delete(_missedIters, "edge-stale")
+
+	if !hubMissing("edge-stale", conns) {
+		t.Errorf("Connection not kept open within the grace period")
+	}
+	expectQueued(t, wc, `{"type":"status","stale":true,"missed":1}`)
+
+	if hubMissing("edge-stale", conns) {
+		t.Errorf("Connection kept open after the grace period")
+	}
+}
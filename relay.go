@@ -0,0 +1,430 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+// Reverse connection mode:
+//
+// Where the connector can't accept inbound connections (e.g. with a
+// home-hosted simulator behind NAT), -relay has it dial out to a public relay
+// over a persistent WebSocket (redialed with backoff whenever it's lost), and
+// serve the client connections the relay tunnels through it, as if accepted
+// on another listener. The relay authenticates the connector with -relay-token
+// (as a bearer token), if set.
+//
+// Each client connection is a stream of raw bytes (i.e. HTTP, and the
+// WebSocket upgraded from it), carried in binary messages made of a 4-byte
+// stream ID (big-endian, chosen by the relay), a 1-byte message type, and a
+// payload:
+//
+// RELAY_MSG_OPEN   relay -> connector: a new client, with its "<ip>:<port>"
+// RELAY_MSG_DATA   either way: the stream's next bytes
+// RELAY_MSG_CLOSE  either way: the stream is closed (no payload)
+//
+// A client sending more than RELAY_STREAM_BUFFER_MAX bytes that haven't been
+// read yet is disconnected, since there's no flow control.
+
+const RELAY_MSG_OPEN = 1
+const RELAY_MSG_DATA = 2
+const RELAY_MSG_CLOSE = 3
+
+const RELAY_HEADER_SIZE = 5
+const RELAY_DATA_MAX = 32 * 1024 // Bytes per RELAY_MSG_DATA message sent
+const RELAY_STREAM_BUFFER_MAX = 1024 * 1024
+const RELAY_PING_INTERVAL = 30 * time.Second
+const RELAY_DIAL_TIMEOUT = 10 * time.Second
+const RELAY_RETRY_MIN = 1 * time.Second
+const RELAY_RETRY_MAX = 60 * time.Second
+
+// Accepts the client connections tunneled through the relay.
+type RelayListener struct {
+	url string
+	token string
+	accepted chan *RelayStream
+	closed chan int
+	closeOnce sync.Once
+
+	lock sync.Mutex
+	tunnel *RelayTunnel // Current tunnel, or nil while (re)dialing
+}
+
+// A connection to the relay
+type RelayTunnel struct {
+	conn *websocket.Conn
+	writeLock sync.Mutex
+
+	lock sync.Mutex
+	streams map[uint32]*RelayStream
+}
+
+// A client connection through the relay
+type RelayStream struct {
+	tunnel *RelayTunnel
+	id uint32
+	remoteAddr relayAddr
+
+	lock sync.Mutex
+	cond *sync.Cond
+	buf []byte
+	eof bool // Closed by the relay
+	closed bool // Closed here
+	readDeadline time.Time
+	deadlineTimer *time.Timer
+}
+
+type relayAddr string
+
+
+// Starts dialing the relay, returning a listener for the client connections tunneled through it.
+func newRelayListener(url string, token string) *RelayListener {
+	l := &RelayListener {
+		url: url,
+		token: token,
+		accepted: make(chan *RelayStream, 16),
+		closed: make(chan int),
+	}
+
+	go l.run()
+	return l
+}
+
+// Keeps a tunnel to the relay open, until the listener's closed.
+func (l *RelayListener) run() {
+	header := http.Header {}
+	if l.token != "" {
+		header.Set("Authorization", "Bearer " + l.token)
+	}
+	dialer := websocket.Dialer { HandshakeTimeout: RELAY_DIAL_TIMEOUT }
+
+	retry := RELAY_RETRY_MIN
+	for {
+		conn, _, err := dialer.Dial(l.url, header)
+		if err == nil {
+			log.Println("Connected to relay at " + l.url)
+			retry = RELAY_RETRY_MIN
+
+			t := &RelayTunnel { conn: conn, streams: make(map[uint32]*RelayStream) }
+			l.lock.Lock()
+			l.tunnel = t
+			l.lock.Unlock()
+
+			err = t.serve(l)
+
+			l.lock.Lock()
+			l.tunnel = nil
+			l.lock.Unlock()
+		}
+
+		select {
+		case <-l.closed:
+			return
+		default:
+		}
+
+		log.Println("Relay connection failed (retrying in " + retry.String() + "): " + err.Error())
+
+		select {
+		case <-l.closed:
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry * 2, RELAY_RETRY_MAX)
+	}
+}
+
+func (l *RelayListener) Accept() (net.Conn, error) {
+	select {
+	case s := <-l.accepted:
+		return s, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *RelayListener) Close() error {
+	l.closeOnce.Do(func () {
+		close(l.closed)
+
+		l.lock.Lock()
+		if l.tunnel != nil {
+			l.tunnel.conn.Close()
+		}
+		l.lock.Unlock()
+	})
+	return nil
+}
+
+func (l *RelayListener) Addr() net.Addr {
+	return relayAddr(l.url)
+}
+
+// Handles the relay's messages until the tunnel fails, then closes all of its streams.
+func (t *RelayTunnel) serve(l *RelayListener) error {
+	done := make(chan int)
+	defer close(done)
+
+	go func() {
+		// Keeps the tunnel alive through NATs and proxies, and notices if it isn't.
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(RELAY_PING_INTERVAL):
+				if t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(_config.WriteTimeout)) != nil {
+					t.conn.Close()
+					return
+				}
+			}
+		}
+	}()
+	t.conn.SetPongHandler(func (string) error {
+		return t.conn.SetReadDeadline(time.Now().Add(2 * RELAY_PING_INTERVAL))
+	})
+
+	defer func() {
+		t.conn.Close()
+
+		t.lock.Lock()
+		streams := t.streams
+		t.streams = make(map[uint32]*RelayStream)
+		t.lock.Unlock()
+
+		for _, s := range streams {
+			s.remoteClosed()
+		}
+	}()
+
+	for {
+		t.conn.SetReadDeadline(time.Now().Add(2 * RELAY_PING_INTERVAL))
+		msgType, data, err := t.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if msgType != websocket.BinaryMessage || len(data) < RELAY_HEADER_SIZE {
+			continue
+		}
+
+		id := binary.BigEndian.Uint32(data)
+		payload := data[RELAY_HEADER_SIZE:]
+
+		t.lock.Lock()
+		s := t.streams[id]
+		t.lock.Unlock()
+
+		switch data[4] {
+		case RELAY_MSG_OPEN:
+			if s != nil {
+				continue
+			}
+
+			addr := string(payload)
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				addr = net.JoinHostPort(addr, "0")
+			}
+			s = &RelayStream { tunnel: t, id: id, remoteAddr: relayAddr(addr) }
+			s.cond = sync.NewCond(&s.lock)
+
+			t.lock.Lock()
+			t.streams[id] = s
+			t.lock.Unlock()
+
+			select {
+			case l.accepted <- s:
+			case <-l.closed:
+				return net.ErrClosed
+			}
+
+		case RELAY_MSG_DATA:
+			if s != nil && !s.push(payload) {
+				log.Println("Closing relayed connection from " + string(s.remoteAddr) + " sending too much")
+				s.Close()
+			}
+
+		case RELAY_MSG_CLOSE:
+			if s != nil {
+				t.remove(id)
+				s.remoteClosed()
+			}
+		}
+	}
+}
+
+func (t *RelayTunnel) send(id uint32, msgType byte, payload []byte) error {
+	msg := make([]byte, RELAY_HEADER_SIZE + len(payload))
+	binary.BigEndian.PutUint32(msg, id)
+	msg[4] = msgType
+	copy(msg[RELAY_HEADER_SIZE:], payload)
+
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+
+	t.conn.SetWriteDeadline(time.Now().Add(_config.WriteTimeout))
+	err := t.conn.WriteMessage(websocket.BinaryMessage, msg)
+	if err != nil {
+		t.conn.Close() // Ends serve(), and with it all streams.
+	}
+	return err
+}
+
+func (t *RelayTunnel) remove(id uint32) {
+	t.lock.Lock()
+	delete(t.streams, id)
+	t.lock.Unlock()
+}
+
+// Buffers data from the relay, returning false if too much is buffered.
+func (s *RelayStream) push(data []byte) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.buf) + len(data) > RELAY_STREAM_BUFFER_MAX {
+		return false
+	}
+	s.buf = append(s.buf, data...)
+	s.cond.Broadcast()
+	return true
+}
+
+func (s *RelayStream) remoteClosed() {
+	s.lock.Lock()
+	s.eof = true
+	s.cond.Broadcast()
+	s.lock.Unlock()
+}
+
+func (s *RelayStream) Read(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for len(s.buf) == 0 && !s.eof && !s.closed {
+		if !s.readDeadline.IsZero() && !time.Now().Before(s.readDeadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		s.cond.Wait()
+	}
+
+	if s.closed {
+		return 0, net.ErrClosed
+	}
+	if len(s.buf) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	if len(s.buf) == 0 {
+		s.buf = nil // Rather than holding on to the whole buffer
+	}
+	return n, nil
+}
+
+func (s *RelayStream) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	closed := s.closed || s.eof
+	s.lock.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+
+	written := 0
+	for written < len(p) {
+		chunk := p[written:min(len(p), written + RELAY_DATA_MAX)]
+		if err := s.tunnel.send(s.id, RELAY_MSG_DATA, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+func (s *RelayStream) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	eof := s.eof
+	if s.deadlineTimer != nil {
+		s.deadlineTimer.Stop()
+	}
+	s.cond.Broadcast()
+	s.lock.Unlock()
+
+	s.tunnel.remove(s.id)
+	if !eof {
+		s.tunnel.send(s.id, RELAY_MSG_CLOSE, nil)
+	}
+	return nil
+}
+
+func (s *RelayStream) LocalAddr() net.Addr {
+	return relayAddr("relay")
+}
+
+func (s *RelayStream) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+func (s *RelayStream) SetDeadline(t time.Time) error {
+	return s.SetReadDeadline(t)
+}
+
+func (s *RelayStream) SetReadDeadline(t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.readDeadline = t
+	if s.deadlineTimer != nil {
+		s.deadlineTimer.Stop()
+		s.deadlineTimer = nil
+	}
+	if !t.IsZero() {
+		s.deadlineTimer = time.AfterFunc(time.Until(t), func () {
+			s.lock.Lock()
+			s.cond.Broadcast()
+			s.lock.Unlock()
+		})
+	}
+	s.cond.Broadcast()
+	return nil
+}
+
+// Writes are bounded by the tunnel's own write timeout instead.
+func (s *RelayStream) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (a relayAddr) Network() string {
+	return "tcp"
+}
+
+func (a relayAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,126 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+func testRelayMsg(id uint32, msgType byte, payload string) []byte {
+	msg := make([]byte, RELAY_HEADER_SIZE + len(payload))
+	binary.BigEndian.PutUint32(msg, id)
+	msg[4] = msgType
+	copy(msg[RELAY_HEADER_SIZE:], payload)
+	return msg
+}
+
+func TestRelay(t *testing.T) {
+	tunnels := make(chan *websocket.Conn, 1)
+	relay := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer relay-secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		upgrader := websocket.Upgrader {}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		tunnels <- conn
+	}))
+	defer relay.Close()
+
+	l := newRelayListener("ws" + strings.TrimPrefix(relay.URL, "http"), "relay-secret")
+	defer l.Close()
+
+	serveListeners([]net.Listener { l }, http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "Hello " + clientIp(r))
+	}))
+
+	var tunnel *websocket.Conn
+	select {
+	case tunnel = <-tunnels:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Relay not dialed")
+	}
+	defer tunnel.Close()
+
+	// A client, sending its request in two parts
+	tunnel.WriteMessage(websocket.BinaryMessage, testRelayMsg(7, RELAY_MSG_OPEN, "203.0.113.9:5555"))
+	tunnel.WriteMessage(websocket.BinaryMessage, testRelayMsg(7, RELAY_MSG_DATA, "GET /v1/version HTTP/1.1\r\nHost: relay\r\n"))
+	tunnel.WriteMessage(websocket.BinaryMessage, testRelayMsg(7, RELAY_MSG_DATA, "Connection: close\r\n\r\n"))
+
+	var resp strings.Builder
+	for {
+		tunnel.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, msg, err := tunnel.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if binary.BigEndian.Uint32(msg) != 7 {
+			t.Fatalf("Unexpected stream ID: %d", binary.BigEndian.Uint32(msg))
+		}
+
+		if msg[4] == RELAY_MSG_CLOSE {
+			break
+		}
+		if msg[4] == RELAY_MSG_DATA {
+			resp.Write(msg[RELAY_HEADER_SIZE:])
+		}
+	}
+
+	if !strings.HasPrefix(resp.String(), "HTTP/1.1 200 OK") || !strings.HasSuffix(resp.String(), "Hello 203.0.113.9") {
+		t.Errorf("Unexpected response: %q", resp.String())
+	}
+}
+
+func TestRelayStreamReadDeadline(t *testing.T) {
+	s := &RelayStream { id: 1 }
+	s.cond = sync.NewCond(&s.lock)
+
+	s.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := s.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, but got %v", err)
+	}
+
+	s.SetReadDeadline(time.Time {})
+	s.push([]byte("abc"))
+	s.remoteClosed()
+
+	data, err := io.ReadAll(s)
+	if err != nil || string(data) != "abc" {
+		t.Errorf("Unexpected data: %q, %v", data, err)
+	}
+
+	if s.push(make([]byte, RELAY_STREAM_BUFFER_MAX + 1)) {
+		t.Errorf("Too much data buffered")
+	}
+}
@@ -0,0 +1,451 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+
+// Replay of recorded tracks (see recorder.go):
+//
+// A "replay" request streams a boat's recorded track (and, optionally, the
+// recorded tracks of the other boats in its group) back to the client, once
+// per second, using the same message formats as "bdl" and "bdl_g". Each
+// second of wall time advances the replay by "speed" seconds of recorded time.
+//
+// Recorded tracks are loaded (by the replay's own goroutine, rather than the
+// connection's reader) before the replay starts, reading each file as a
+// stream, and keeping only the samples within the requested time range. So
+// that a long track can't use unbounded memory, a replay (of all its tracks
+// together) is limited to REPLAY_MAX_SAMPLES samples, and is rejected if its
+// time range holds more than that.
+
+const MAX_REPLAY_SPEED = 60
+
+// About 28 hours of a boat's track at one sample per second, or roughly 15 MiB
+const REPLAY_MAX_SAMPLES = 100000
+
+const ERR_INVALID_REQUEST = "invalid_request"
+const ERR_REPLAY_UNAVAILABLE = "replay_unavailable"
+const ERR_NO_RECORDING = "no_recording"
+
+type ReplayEndMsg struct {
+	Type string `json:"type"`
+}
+
+type TrackSample struct {
+	Time time.Time
+	Data BoatDataLiveRespMsg
+}
+
+type GpxTrackPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+	Time string `xml:"time"`
+	Ctw float64 `xml:"extensions>ctw"`
+	Stw float64 `xml:"extensions>stw"`
+	Cog float64 `xml:"extensions>cog"`
+	Sog float64 `xml:"extensions>sog"`
+	Lws float64 `xml:"extensions>lws"`
+	Ha float64 `xml:"extensions>ha"`
+}
+
+type ReplayTrack struct {
+	BoatKey string
+	Samples []TrackSample
+	Next int
+}
+
+
+func wsReplayHandler(w http.ResponseWriter, r *http.Request) {
+	conn := wsUpgrade(w, r)
+	if conn == nil {
+		return
+	}
+
+	var replayStop chan int = nil
+	defer func() {
+		if replayStop != nil {
+			close(replayStop)
+		}
+		conn.Close()
+	}()
+	defer recoverConnPanic(conn)
+
+	for {
+		req, err := readReqMsg(conn)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		if req.Cmd == "ping" {
+			wsReqPing(req, conn)
+			continue
+		}
+
+		if req.Cmd != "replay" || replayStop != nil {
+			log.Println("Invalid command on replay connection from " + conn.RemoteIp + ": " + req.Cmd)
+			conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid command")
+			return
+		}
+
+		if !resolveReqSim(req, conn, DEFAULT_SIM) {
+			return
+		}
+
+		replayStop = wsReqReplay(req, conn)
+	}
+}
+
+// Starts a replay for the connection, returning a channel to be closed to stop
+// it (or nil, if the replay couldn't be started, in which case the connection
+// has been closed).
+func wsReqReplay(req *ReqMsg, conn *WsConn) chan int {
+	if rejectIfDraining(conn) || rejectIfMemoryPressure(conn) {
+		return nil
+	}
+
+	if !_boatKeyRegexp.MatchString(req.BoatKey) {
+		log.Println("Client (" + conn.RemoteIp + ") sent invalid boat key!")
+		noteInvalidKey(conn.RemoteIp) // See abuse.go.
+		conn.CloseWithReason(CLOSE_INVALID_KEY, "Invalid boat key")
+		return nil
+	}
+
+	_lock.Lock()
+	_, exists := _conns[conn]
+	_lock.Unlock()
+	if exists {
+		// Live and replayed data can't be mixed on one connection.
+		conn.CloseWithReason(CLOSE_DUPLICATE_SUBSCRIBE, "Already subscribed")
+		return nil
+	}
+
+	if !recorderEnabled() {
+		replayFail(conn, ERR_REPLAY_UNAVAILABLE, "Track recording is not enabled")
+		return nil
+	}
+
+	speed := req.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	if speed < 1 || speed > MAX_REPLAY_SPEED {
+		replayFail(conn, ERR_INVALID_REQUEST, "Invalid replay speed")
+		return nil
+	}
+
+	var from, to time.Time
+	var err error
+	if req.From != "" {
+		from, err = time.Parse(time.RFC3339, req.From)
+		if err != nil {
+			replayFail(conn, ERR_INVALID_REQUEST, "Invalid replay start time")
+			return nil
+		}
+	}
+	if req.To != "" {
+		to, err = time.Parse(time.RFC3339, req.To)
+		if err != nil {
+			replayFail(conn, ERR_INVALID_REQUEST, "Invalid replay end time")
+			return nil
+		}
+	}
+
+	connCtx := ConnCtx {
+		BoatKey: req.BoatKey,
+	}
+
+	boatKeys := []string { req.BoatKey }
+	if req.Group.Flag {
+		connCtx.GroupBoats = getBoatsInGroup(context.Background(), req.Sim, req.BoatKey)
+		if connCtx.GroupBoats == nil {
+			conn.CloseWithReason(CLOSE_BACKEND_UNREACHABLE, "Group lookup failed")
+			return nil
+		}
+
+		for e := connCtx.GroupBoats.Front(); e != nil; e = e.Next() {
+			boatKey := e.Value.(*BoatInfo).BoatKey
+			if boatKey != req.BoatKey {
+				boatKeys = append(boatKeys, boatKey)
+			}
+		}
+	}
+
+	conn.SetType(CONN_TYPE_REPLAY)
+
+	stop := make(chan int)
+	go replayMain(conn, &connCtx, boatKeys, from, to, speed, stop)
+
+	return stop
+}
+
+// Loads the recorded tracks for a replay (the first being for the boat being replayed), returning nil
+// (having closed the connection) if there's no track for that boat, or the tracks are too long.
+func loadReplayTracks(conn *WsConn, boatKeys []string, from time.Time, to time.Time) []*ReplayTrack {
+	budget := REPLAY_MAX_SAMPLES
+
+	tracks := make([]*ReplayTrack, 0, len(boatKeys))
+	for i, boatKey := range boatKeys {
+		samples, ok := loadRecordedTrack(boatKey, from, to, budget)
+		if !ok {
+			log.Println("Recorded tracks too long for replay requested by client (" + conn.RemoteIp + ")")
+
+			sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, "Recorded track too long; please request a shorter time range", REPLAY_MAX_SAMPLES)
+			conn.Close()
+			return nil
+		}
+
+		if len(samples) > 0 {
+			tracks = append(tracks, &ReplayTrack { boatKey, samples, 0 })
+			budget -= len(samples)
+		} else if i == 0 {
+			replayFail(conn, ERR_NO_RECORDING, "No recorded track for boat")
+			return nil
+		}
+	}
+
+	return tracks
+}
+
+func replayFail(conn *WsConn, errCode string, msg string) {
+	sendErrorMsg(conn, errCode, msg)
+	conn.Close()
+}
+
+func replayMain(conn *WsConn, connCtx *ConnCtx, boatKeys []string, from time.Time, to time.Time, speed int, stop chan int) {
+	defer recoverConnPanic(conn)
+
+	tracks := loadReplayTracks(conn, boatKeys, from, to)
+	if tracks == nil {
+		return
+	}
+
+	// The first track is always for the boat being replayed.
+	replayTime := tracks[0].Samples[0].Time
+	endTime := tracks[0].Samples[len(tracks[0].Samples) - 1].Time
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		resps := make(map[string]BoatDataLiveRespMsg)
+		for _, track := range tracks {
+			// Advance to the latest sample at (or before) the current replay time.
+			for track.Next < len(track.Samples) && !track.Samples[track.Next].Time.After(replayTime) {
+				track.Next++
+			}
+
+			if track.Next > 0 {
+				resps[track.BoatKey] = track.Samples[track.Next - 1].Data
+			}
+		}
+
+		if !conn.SendLive(createRespMsg(connCtx, resps[connCtx.BoatKey], resps, nil)) {
+			return
+		}
+
+		if connLifetimeExpired(conn, time.Now()) {
+			sendReauthMsgAndClose(conn)
+			return
+		}
+
+		if !replayTime.Before(endTime) {
+			conn.SendJSON(&ReplayEndMsg { Type: "replay_end" })
+			conn.Close()
+			return
+		}
+
+		select {
+		case <-ticker.C:
+			replayTime = replayTime.Add(time.Duration(speed) * time.Second)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Samples loaded from recorded track files, within a time range (with zero times meaning unbounded), up to a maximum number
+type TrackLoader struct {
+	From time.Time
+	To time.Time
+	Max int
+	Samples []TrackSample
+	Full bool // More samples in range than Max
+}
+
+// Adds a sample, if it's within the time range, returning false once there are too many.
+func (l *TrackLoader) add(sample TrackSample) bool {
+	if (!l.From.IsZero() && sample.Time.Before(l.From)) || (!l.To.IsZero() && sample.Time.After(l.To)) {
+		return true
+	}
+
+	if len(l.Samples) >= l.Max {
+		l.Full = true
+		return false
+	}
+
+	l.Samples = append(l.Samples, sample)
+	return true
+}
+
+// Loads all recorded samples (in either recording format) for a boat, within the given time range (with zero
+// times meaning unbounded), sorted by time, returning false if there are more than max of them.
+func loadRecordedTrack(boatKey string, from time.Time, to time.Time, max int) ([]TrackSample, bool) {
+	dir := filepath.Join(_config.RecordDir, boatKey)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return nil, true
+	}
+
+	loader := &TrackLoader { From: from, To: to, Max: max }
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+
+		// Skip files started after the end of the requested range.
+		started, err := time.Parse(RECORD_FILE_TIME_FORMAT, strings.TrimSuffix(name, ext))
+		if err == nil && !to.IsZero() && started.After(to) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		switch ext {
+		case RECORD_EXT_GEOJSON:
+			loadGeoJsonTrackFile(path, loader)
+		case RECORD_EXT_GPX:
+			loadGpxTrackFile(path, loader)
+		}
+
+		if loader.Full {
+			return nil, false
+		}
+	}
+
+	samples := loader.Samples
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+
+	return samples, true
+}
+
+func loadGeoJsonTrackFile(path string, loader *TrackLoader) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var feature GeoJsonTrackFeature
+		if json.Unmarshal(scanner.Bytes(), &feature) != nil {
+			continue // Likely a partially written line
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, feature.Properties.Time)
+		if err != nil {
+			continue
+		}
+
+		sample := TrackSample {
+			Time: t,
+			Data: BoatDataLiveRespMsg {
+				Lat: feature.Geometry.Coordinates[1],
+				Lon: feature.Geometry.Coordinates[0],
+				Ctw: feature.Properties.Ctw,
+				Stw: feature.Properties.Stw,
+				Cog: feature.Properties.Cog,
+				Sog: feature.Properties.Sog,
+				Lws: feature.Properties.Lws,
+				Ha: feature.Properties.Ha,
+			},
+		}
+		if !loader.add(sample) {
+			return
+		}
+	}
+}
+
+// Reads a GPX track file as a stream, a track point at a time.
+func loadGpxTrackFile(path string, loader *TrackLoader) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(bufio.NewReader(f))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				log.Println(err) // Possibly a partially written file, so keep what's been read.
+			}
+			return
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "trkpt" {
+			continue
+		}
+
+		var pt GpxTrackPoint
+		if decoder.DecodeElement(&pt, &start) != nil {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, pt.Time)
+		if err != nil {
+			continue
+		}
+
+		sample := TrackSample {
+			Time: t,
+			Data: BoatDataLiveRespMsg {
+				Lat: pt.Lat,
+				Lon: pt.Lon,
+				Ctw: pt.Ctw,
+				Stw: pt.Stw,
+				Cog: pt.Cog,
+				Sog: pt.Sog,
+				Lws: pt.Lws,
+				Ha: pt.Ha,
+			},
+		}
+		if !loader.add(sample) {
+			return
+		}
+	}
+}
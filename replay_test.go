@@ -0,0 +1,86 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestLoadRecordedTrack(t *testing.T) {
+	prevDir := _config.RecordDir
+	_config.RecordDir = t.TempDir()
+	defer func() { _config.RecordDir = prevDir }()
+
+	boatKey := "f7000000000000000000000000000000"
+	dir := filepath.Join(_config.RecordDir, boatKey)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Ten samples a second apart in each format, the GPX ones following the GeoJSON ones.
+	var ndjson strings.Builder
+	gpx := GPX_HEADER
+	for i := 0; i < 10; i++ {
+		ts := start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano)
+		line, _ := json.Marshal(&GeoJsonTrackFeature {
+			Type: "Feature",
+			Geometry: GeoJsonPoint { Type: "Point", Coordinates: [2]float64 { -30.0, float64(i) } },
+			Properties: GeoJsonTrackProps { Time: ts },
+		})
+		ndjson.Write(line)
+		ndjson.WriteString("\n")
+
+		ts = start.Add(time.Duration(10 + i) * time.Second).Format(time.RFC3339Nano)
+		gpx += "<trkpt lat=\"" + strconv.Itoa(10 + i) + "\" lon=\"-30\"><time>" + ts + "</time><extensions><snsw:sog>5</snsw:sog></extensions></trkpt>\n"
+	}
+	gpx += GPX_TRAILER
+
+	os.WriteFile(filepath.Join(dir, start.Format(RECORD_FILE_TIME_FORMAT) + RECORD_EXT_GEOJSON), []byte(ndjson.String()), 0644)
+	os.WriteFile(filepath.Join(dir, start.Add(10 * time.Second).Format(RECORD_FILE_TIME_FORMAT) + RECORD_EXT_GPX), []byte(gpx), 0644)
+
+	samples, ok := loadRecordedTrack(boatKey, time.Time {}, time.Time {}, 100)
+	if !ok || len(samples) != 20 {
+		t.Fatalf("Unexpected samples loaded: %d", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.Data.Lat != float64(i) {
+			t.Errorf("Sample %d out of order: %+v", i, sample)
+		}
+	}
+	if samples[15].Data.Sog != 5.0 {
+		t.Errorf("GPX extensions not loaded: %+v", samples[15])
+	}
+
+	// Only samples within the time range count towards the maximum.
+	samples, ok = loadRecordedTrack(boatKey, start.Add(5 * time.Second), start.Add(14 * time.Second), 10)
+	if !ok || len(samples) != 10 || samples[0].Data.Lat != 5.0 {
+		t.Errorf("Unexpected samples loaded for time range: %d", len(samples))
+	}
+
+	if _, ok := loadRecordedTrack(boatKey, time.Time {}, time.Time {}, 19); ok {
+		t.Errorf("Too many samples loaded!")
+	}
+}
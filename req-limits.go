@@ -0,0 +1,100 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+
+// Request limits:
+//
+// Requests from clients are small, so each connection's read limit
+// (-max-req-size) stops a client from sending multi-megabyte frames: as soon
+// as a larger frame arrives, the connection is closed (with close code 1009).
+// A request that isn't valid JSON, or is nested more deeply than
+// REQ_MAX_DEPTH (checked before it's decoded), is rejected with an
+// "invalid_request" error, and the connection is closed.
+//
+// Unknown commands are just ignored (so that clients written against newer
+// servers still work), but only up to -max-unknown-cmds per connection, after
+// which the client is assumed to be misbehaving, and is disconnected.
+
+// Default maximum size of a request message from a client (bytes)
+const REQ_MAX_SIZE = 4096
+
+// Maximum nesting depth of objects and arrays in a request message (including the request object itself)
+const REQ_MAX_DEPTH = 8
+
+var errReqTooDeep = errors.New("Request nested too deeply")
+
+
+// Returns the maximum nesting depth of objects and arrays in JSON data (which needn't be valid).
+func jsonDepth(data []byte) int {
+	depth := 0
+	maxDepth := 0
+	inString := false
+	escaped := false
+
+	for _, b := range data {
+		if inString {
+			if escaped {
+				escaped = false
+			} else if b == '\\' {
+				escaped = true
+			} else if b == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+
+	return maxDepth
+}
+
+// Rejects a request that couldn't be decoded, and closes the connection.
+func rejectInvalidReq(conn *WsConn, err error) {
+	sendErrorMsg(conn, ERR_INVALID_REQUEST, "Invalid request")
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid request")
+}
+
+// Counts an unknown command on a connection, closing it if it's sent too many. Returns whether it's still open.
+func countUnknownCmd(conn *WsConn, count *int) bool {
+	*count++
+	if _config.MaxUnknownCmds == 0 || *count < _config.MaxUnknownCmds {
+		return true
+	}
+
+	log.Println("Closing connection from " + conn.RemoteIp + " after too many unknown commands")
+
+	sendLimitErrorMsg(conn, ERR_INVALID_REQUEST, "Too many unknown commands", _config.MaxUnknownCmds)
+	conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Too many unknown commands")
+	return false
+}
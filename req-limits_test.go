@@ -0,0 +1,83 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"github.com/gorilla/websocket"
+)
+
+
+func TestJsonDepth(t *testing.T) {
+	cases := map[string]int {
+		`{"cmd":"bdl"}`: 1,
+		`{"cmd":"bdl","units":{"speed":"kn"}}`: 2,
+		`{"cmd":"ping","payload":[[{"a":[1]}]]}`: 5,
+		`{"cmd":"ping","payload":"[[[[{{{{"}`: 1,
+		`{"cmd":"ping","payload":"\"[[[["}`: 1,
+		`null`: 0,
+	}
+
+	for data, expected := range cases {
+		if depth := jsonDepth([]byte(data)); depth != expected {
+			t.Errorf("Expected depth %d, but got %d: %s", expected, depth, data)
+		}
+	}
+
+	_, err := decodeReqMsg([]byte(`{"cmd":"ping","payload":` + strings.Repeat("[", REQ_MAX_DEPTH) + strings.Repeat("]", REQ_MAX_DEPTH) + `}`))
+	if err != errReqTooDeep {
+		t.Errorf("Deeply nested request not rejected: %v", err)
+	}
+}
+
+func TestIntegrationReqLimits(t *testing.T) {
+	t.Parallel()
+	url := testServer(t)
+
+	// Oversized frame
+	conn := testDial(t, url)
+	defer conn.Close()
+
+	testSend(t, conn, map[string]interface{} { "cmd": "ping", "payload": strings.Repeat("x", REQ_MAX_SIZE) })
+	testExpectCloseCode(t, conn, websocket.CloseMessageTooBig)
+
+	// Invalid JSON
+	conn = testDial(t, url)
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"cmd":`))
+	var msg ErrorMsg
+	testRead(t, conn, &msg)
+	if msg.Error != ERR_INVALID_REQUEST {
+		t.Errorf("Unexpected error message: %+v", msg)
+	}
+	testExpectCloseCode(t, conn, CLOSE_POLICY_VIOLATION)
+
+	// Too many unknown commands
+	conn = testDial(t, url)
+	defer conn.Close()
+
+	for i := 0; i < _config.MaxUnknownCmds; i++ {
+		testSend(t, conn, map[string]interface{} { "cmd": "nonsense" })
+	}
+	testRead(t, conn, &msg)
+	if msg.Error != ERR_INVALID_REQUEST || msg.Limit != _config.MaxUnknownCmds {
+		t.Errorf("Unexpected error message: %+v", msg)
+	}
+	testExpectCloseCode(t, conn, CLOSE_POLICY_VIOLATION)
+}
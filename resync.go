@@ -0,0 +1,61 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"sailnavsim-snsw/internal/hub"
+)
+
+
+// Resync:
+//
+// A subscribed client may send {"cmd":"resync"} (e.g. after a gap in message
+// sequence numbers, see msg-seq.go) to have its next iteration's live data
+// message be a full one: with every field, whatever its field selection, and
+// for bdl_g, the whole group snapshot, even while load shedding degrades
+// groups. That message is never skipped by load shedding, nor shared with
+// other connections (see shared-streams.go). Later messages are as before.
+//
+// Unlike a keyframe, which is the most recent message sent again right away,
+// a resync message is built afresh on the next iteration.
+
+
+func wsReqResync(conn *WsConn) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	connCtx, exists := _conns[conn]
+	if !exists {
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Not subscribed")
+		return
+	}
+
+	connCtx.Resync = true
+	_conns[conn] = connCtx
+}
+
+// Called (with _lock held) to format a full live data message for a connection that's asked to resync.
+func formatResyncMsg(conn *WsConn, resp BoatDataLiveRespMsg, liveResps map[string]BoatDataLiveRespMsg, groupIndexes *GroupIndexes) *hub.Msg {
+	connCtx := _conns[conn]
+	connCtx.Resync = false
+	_conns[conn] = connCtx
+
+	connCtx.Resync = true // For this message only (see shedding.go).
+	connCtx.Fields = nil
+	return formatConnCtxMsg(&connCtx, resp, liveResps, groupIndexes, nil)
+}
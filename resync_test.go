@@ -0,0 +1,65 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+
+func TestResync(t *testing.T) {
+	boatKey := "f2000000000000000000000000000000"
+	resp := BoatDataLiveRespMsg { Lat: 45.5, Lon: -30.25, Ctw: 90.0 }
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+
+	wsReqResync(wc)
+	expectQueued(t, wc, `{"type":"error","error":"invalid_request","msg":"Not subscribed"}`)
+
+	_lock.Lock()
+	_conns[wc] = ConnCtx { BoatKey: boatKey, Fields: map[string]bool { "lat": true } }
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		delete(_conns, wc)
+		_lock.Unlock()
+	}()
+
+	wsReqResync(wc)
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	if !_conns[wc].Resync {
+		t.Fatal("Resync not requested")
+	}
+
+	msg := formatResyncMsg(wc, resp, nil, nil)
+	if !strings.Contains(string(msg.Data), `"lon":-30.25`) {
+		t.Errorf("Resync message not full: %s", msg.Data)
+	}
+
+	if _conns[wc].Resync || _conns[wc].Fields == nil {
+		t.Errorf("Subscription not restored after resync: %+v", _conns[wc])
+	}
+
+	msg = formatLiveMsg(wc, resp, nil, nil, nil)
+	if string(msg.Data) != `{"lat":45.5}` {
+		t.Errorf("Unexpected message after resync: %s", msg.Data)
+	}
+}
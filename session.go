@@ -0,0 +1,290 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+
+// Resumable sessions:
+//
+// A client may ask for a session when subscribing (by setting "session" to
+// true in its subscription request). The server then replies with a session
+// token, and includes a "seq" sequence number in every live data message.
+//
+// If the connection drops, the session (and its boat tracking) is kept alive
+// for a grace period, during which messages continue to be buffered. A client
+// reconnecting within the grace period sends a "resume" request with its
+// token and the last sequence number it received, and is sent any buffered
+// messages it missed before live streaming continues.
+
+const SESSION_BUFFER_SIZE = 120
+
+type SessionMsg struct {
+	Type string `json:"type"`
+	Token string `json:"token"`
+	GraceSecs int64 `json:"grace"`
+}
+
+type BufferedMsg struct {
+	Seq uint64
+	Data []byte
+}
+
+type Session struct {
+	Token string
+	Sub ConnCtx // The subscription (with no Session of its own)
+
+	NextSeq uint64
+	Buffer []BufferedMsg
+
+	Conn *WsConn // nil while detached
+	DetachedAt time.Time
+}
+
+type SeqBoatDataLiveRespMsg struct {
+	BoatDataLiveRespMsg
+	Seq uint64 `json:"seq"`
+}
+
+type SeqBoatGroupRespMsg struct {
+	*BoatGroupRespMsg
+	Seq uint64 `json:"seq"`
+}
+
+// Map of session tokens to sessions
+var _sessions = make(map[string]*Session)
+
+
+func newSessionToken() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Creates a new session for a connection that has just subscribed, and sends its token to the client
+// (or returns nil if there are already -max-sessions sessions, each holding a buffer). Caller must hold _lock.
+func startSession(conn *WsConn, connCtx *ConnCtx) *Session {
+	if _config.MaxSessions > 0 && len(_sessions) >= _config.MaxSessions {
+		log.Println("Too many sessions; subscribing without one for client (" + conn.RemoteIp + ")")
+		return nil
+	}
+
+	token := newSessionToken()
+	if token == "" {
+		return nil
+	}
+
+	sub := *connCtx
+	sub.Session = nil
+
+	session := &Session {
+		Token: token,
+		Sub: sub,
+		NextSeq: 1,
+		Buffer: make([]BufferedMsg, 0, SESSION_BUFFER_SIZE),
+		Conn: conn,
+	}
+	_sessions[token] = session
+
+	conn.SendJSON(&SessionMsg {
+		Type: "session",
+		Token: token,
+		GraceSecs: int64(_config.SessionGrace / time.Second),
+	})
+
+	return session
+}
+
+// Assigns the next sequence number to a live data message, and marshals and buffers it.
+func (s *Session) bufferMsg(msg interface{}) []byte {
+	seq := s.NextSeq
+	s.NextSeq++
+
+	var data []byte
+	var err error
+
+	switch m := msg.(type) {
+	case BoatDataLiveRespMsg:
+		data, err = json.Marshal(&SeqBoatDataLiveRespMsg { m, seq })
+	case *BoatGroupRespMsg:
+		data, err = json.Marshal(&SeqBoatGroupRespMsg { m, seq })
+	case *BoatDataExtRespMsg:
+		m.Seq = seq
+		data, err = json.Marshal(m)
+	case *GroupAllRespMsg:
+		m.Seq = seq
+		data, err = json.Marshal(m)
+	case FieldsMsg:
+		m["seq"] = seq
+		data, err = json.Marshal(m)
+	default:
+		data, err = json.Marshal(msg)
+	}
+
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	if len(s.Buffer) == SESSION_BUFFER_SIZE {
+		copy(s.Buffer, s.Buffer[1:])
+		s.Buffer = s.Buffer[:SESSION_BUFFER_SIZE - 1]
+	}
+	s.Buffer = append(s.Buffer, BufferedMsg { seq, data })
+
+	return data
+}
+
+// Called (with _lock held) when a connection with a session is removed. The session keeps
+// its boats tracked until it's either resumed or expires.
+func (s *Session) detach(conn *WsConn) {
+	if s.Conn != conn {
+		return // Already taken over by another connection.
+	}
+
+	s.Conn = nil
+	s.DetachedAt = time.Now()
+}
+
+// Called (with _lock held) once per iteration to buffer messages for detached sessions and expire old ones.
+func processDetachedSessions(resps map[string]BoatDataLiveRespMsg, groupIndexes *GroupIndexes) {
+	now := time.Now()
+
+	for token, session := range _sessions {
+		if session.Conn != nil {
+			continue
+		}
+
+		if now.Sub(session.DetachedAt) > _config.SessionGrace {
+			untrackConnCtx(&session.Sub)
+
+			delete(_sessions, token)
+			continue
+		}
+
+		resp, exists := resps[session.Sub.BoatKey]
+		if !exists {
+			continue
+		}
+
+		session.bufferMsg(createRespMsg(&session.Sub, resp, resps, groupIndexes))
+	}
+}
+
+func wsReqResume(req *ReqMsg, conn *WsConn) {
+	if rejectIfDraining(conn) {
+		return
+	}
+
+	_lock.Lock()
+	if rejectIfSubscribed(conn) {
+		_lock.Unlock()
+		return
+	}
+
+	session, exists := _sessions[req.Token]
+	if exists {
+		resumeSession(conn, session, req.Seq)
+		_lock.Unlock()
+		return
+	}
+	_lock.Unlock()
+
+	// Not a session, but possibly a reconnect token (see reconnect.go), restoring a subscription as a
+	// new one would be, and so subject to the same checks.
+	state := decodeReconnectToken(req.Token)
+	if state == nil {
+		rejectInvalidSession(conn)
+		return
+	}
+
+	if rejectIfMemoryPressure(conn) || rejectIfRefusedKey(conn, state.BoatKey) {
+		return
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	if !rejectIfSubscribed(conn) && !resumeFromReconnectToken(state, conn) {
+		rejectInvalidSession(conn)
+	}
+}
+
+// Returns whether the connection is already subscribed (closing it, if so). Caller must hold _lock.
+func rejectIfSubscribed(conn *WsConn) bool {
+	if _, exists := _conns[conn]; !exists {
+		return false
+	}
+
+	conn.CloseWithReason(CLOSE_DUPLICATE_SUBSCRIBE, "Already subscribed")
+	return true
+}
+
+func rejectInvalidSession(conn *WsConn) {
+	sendErrorMsg(conn, ERR_INVALID_SESSION, "Unknown or expired session token")
+	conn.CloseWithReason(CLOSE_INVALID_KEY, "Unknown or expired session token")
+}
+
+// Resumes a session on a new connection, replaying any messages after seq. Caller must hold _lock.
+func resumeSession(conn *WsConn, session *Session, seq uint64) {
+	if session.Conn != nil {
+		// The previous connection hasn't been noticed as closed yet, so take over from it.
+		oldConn := session.Conn
+		delete(_conns, oldConn)
+		removeConnFromKey(session.Sub.BoatKey, oldConn)
+		oldConn.CloseWithReason(CLOSE_DUPLICATE_SUBSCRIBE, "Session resumed on another connection")
+	}
+
+	session.Conn = conn
+	connCtx := session.Sub
+	connCtx.Session = session
+	_conns[conn] = connCtx
+	if session.Sub.GroupAll {
+		conn.SetType(CONN_TYPE_GROUP_ALL)
+	} else if session.Sub.Spectator {
+		conn.SetType(CONN_TYPE_SPECTATOR)
+	} else if session.Sub.GroupBoats != nil {
+		conn.SetType(CONN_TYPE_BDL_G)
+	} else {
+		conn.SetType(CONN_TYPE_BDL)
+	}
+	addConnToKey(session.Sub.BoatKey, conn)
+
+	_countConns++
+
+	// Replay any messages missed by the client.
+	for _, msg := range session.Buffer {
+		if msg.Seq <= seq {
+			continue
+		}
+
+		if !conn.Send(msg.Data, false) {
+			break // Failure will be picked up when next sending to this connection.
+		}
+	}
+}
@@ -0,0 +1,180 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"testing"
+	"time"
+)
+
+
+// Subscribes a connection to a boat with a session (as wsReqBoatDataLive would). Caller must hold _lock.
+func testSessionConn(connCtx ConnCtx) (*WsConn, *Session) {
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	trackConnCtx(&connCtx)
+	session := startSession(wc, &connCtx)
+	connCtx.Session = session
+	_conns[wc] = connCtx
+	addConnToKey(connCtx.BoatKey, wc)
+
+	wc.queue = nil // Discard the session message.
+	return wc, session
+}
+
+func TestSessionDetachReattach(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	wc, session := testSessionConn(ConnCtx { BoatKey: "session-me" })
+	defer delete(_sessions, session.Token)
+
+	releaseConn(wc)
+	if session.Conn != nil || session.DetachedAt.IsZero() {
+		t.Fatalf("Session not detached: %+v", session)
+	}
+	if _, exists := _trackedBoats["session-me"]; !exists {
+		t.Fatalf("Detached session's boat no longer tracked")
+	}
+
+	// Messages continue to be buffered while detached.
+	resps := map[string]BoatDataLiveRespMsg { "session-me": BoatDataLiveRespMsg { Lat: 1.0, Lon: 2.0 } }
+	processDetachedSessions(resps, nil)
+	processDetachedSessions(resps, nil)
+	if len(session.Buffer) != 2 || session.Buffer[0].Seq != 1 || session.Buffer[1].Seq != 2 {
+		t.Fatalf("Expected 2 buffered messages, but got %+v", session.Buffer)
+	}
+
+	// Resuming after the first replays only the second.
+	wc2 := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	resumeSession(wc2, session, 1)
+
+	if session.Conn != wc2 || _conns[wc2].Session != session || _conns[wc2].BoatKey != "session-me" {
+		t.Errorf("Session not reattached: %+v", _conns[wc2])
+	}
+	expectQueued(t, wc2, string(session.Buffer[1].Data))
+
+	// A detach by the old connection no longer affects the session.
+	session.detach(wc)
+	if session.Conn != wc2 {
+		t.Errorf("Reattached session detached by its previous connection")
+	}
+
+	// Attached sessions aren't buffered for here (but when sending to their connections).
+	processDetachedSessions(resps, nil)
+	if len(session.Buffer) != 2 {
+		t.Errorf("Attached session buffered: %+v", session.Buffer)
+	}
+
+	releaseConn(wc2)
+	untrackConnCtx(&session.Sub)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	grace := _config.SessionGrace
+	_config.SessionGrace = time.Minute
+	defer func() { _config.SessionGrace = grace }()
+
+	wc, session := testSessionConn(ConnCtx { BoatKey: "session-expire" })
+	releaseConn(wc)
+
+	// Still within the grace period.
+	resps := make(map[string]BoatDataLiveRespMsg)
+	processDetachedSessions(resps, nil)
+	if _sessions[session.Token] != session {
+		t.Fatalf("Session expired within its grace period")
+	}
+
+	session.DetachedAt = time.Now().Add(-2 * time.Minute)
+	processDetachedSessions(resps, nil)
+	if _, exists := _sessions[session.Token]; exists {
+		t.Errorf("Session not expired after its grace period")
+	}
+	if _, exists := _trackedBoats["session-expire"]; exists {
+		t.Errorf("Expired session's boat still tracked")
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	prevMax := _config.MaxSessions
+	defer func() { _config.MaxSessions = prevMax }()
+	_config.MaxSessions = len(_sessions) + 1
+
+	wc, session := testSessionConn(ConnCtx { BoatKey: "session-max" })
+	if session == nil {
+		t.Fatalf("Session not started within the limit")
+	}
+	defer delete(_sessions, session.Token)
+
+	// Beyond the limit, a subscription goes without a session (and isn't told of one).
+	wc2 := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	if startSession(wc2, &ConnCtx { BoatKey: "session-max" }) != nil {
+		t.Errorf("Session started over the limit")
+	}
+	expectQueued(t, wc2)
+
+	releaseConn(wc)
+	untrackConnCtx(&session.Sub)
+}
+
+func TestApplyGroupDetachedSession(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	wc, session := testSessionConn(ConnCtx { BoatKey: "session-group", GroupBoats: pendingGroup("session-group"), GroupPending: true })
+	defer delete(_sessions, session.Token)
+	job := &GroupFetchJob { wc, "", "session-group", session.Sub.GroupBoats }
+
+	releaseConn(wc)
+
+	// A failed lookup leaves the detached session with its placeholder group.
+	applyGroup(job, nil)
+	if session.Sub.GroupBoats != job.Pending || !session.Sub.GroupPending {
+		t.Fatalf("Placeholder group replaced: %+v", session.Sub)
+	}
+
+	boats := list.New()
+	boats.PushBack(&BoatInfo { "session-group", "Me" })
+	boats.PushBack(&BoatInfo { "session-group-other", "Other" })
+	applyGroup(job, boats)
+
+	if session.Sub.GroupBoats != boats || session.Sub.GroupPending {
+		t.Errorf("Group not applied to detached session: %+v", session.Sub)
+	}
+	expectQueued(t, wc)
+
+	if _trackedBoats["session-group"].RefCount != 1 || _trackedBoats["session-group-other"].RefCount != 1 {
+		t.Errorf("Group's boats not tracked once each")
+	}
+
+	// The session resumes with the applied group.
+	wc2 := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	resumeSession(wc2, session, 0)
+	if _conns[wc2].GroupBoats != boats || _conns[wc2].GroupPending {
+		t.Errorf("Session resumed without its group: %+v", _conns[wc2])
+	}
+
+	delete(_conns, wc2)
+	removeConnFromKey("session-group", wc2)
+	untrackConnCtx(&session.Sub)
+}
@@ -0,0 +1,152 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"github.com/gorilla/websocket"
+	"sailnavsim-snsw/internal/hub"
+)
+
+
+// Shared streams:
+//
+// A user opening the same boat in several browser tabs has several
+// connections subscribed to the same boat with the same options, each sent
+// identical live data. With -shared-streams, each iteration's live data
+// message for such a stream is marshalled just once, and for the second and
+// further connections, prepared just once as a WebSocket message, so that it's
+// also compressed (with -ws-compression) just once, however many connections
+// it's sent to. Subscriptions with a session or with COG smoothing have state
+// of their own, and so never share a stream.
+//
+// Duplicate subscriptions (connections from the same remote IP subscribed to
+// the same boat) are counted in the stats (as "dup_conns"). With
+// -coalesce-duplicates, they're also switched to the "conflate" queue policy,
+// so that a congested client (whose tabs all share its bandwidth) is only
+// ever sent the latest data for its boat on each of them, rather than falling
+// further behind on all of them.
+
+// A live data message shared between connections
+type SharedLiveMsg struct {
+	Data []byte
+	Prepared *websocket.PreparedMessage // Once there's more than one connection
+}
+
+// One iteration's shared live data messages, by stream
+type SharedStreams struct {
+	Msgs map[string]*SharedLiveMsg
+}
+
+// Live data messages sent from a shared stream (rather than marshalled for their connection alone)
+var _countSharedMsgs int64 = 0
+
+
+func newSharedStreams() *SharedStreams {
+	if !_config.SharedStreams {
+		return nil
+	}
+
+	return &SharedStreams {
+		Msgs: make(map[string]*SharedLiveMsg),
+	}
+}
+
+// Returns the key of the stream a subscription's live data belongs to, or "" if it can't be shared.
+func sharedStreamKey(connCtx *ConnCtx) string {
+	if connCtx.Session != nil || connCtx.CogSmoother != nil || connCtx.Waypoint != nil {
+		return ""
+	}
+
+	// Whether a group subscription is degraded while shedding load depends on its priority (see shedding.go).
+	return fmt.Sprintf("%s|%s|%p|%t|%t|%t|%t|%t|%t|%v|%+v", connCtx.BoatKey, connCtx.Sim, connCtx.GroupBoats, connCtx.Spectator, connCtx.Extended, connCtx.GroupAll, connCtx.Ais, connCtx.OthersSog, shedGroups(connCtx), listFields(connCtx.Fields), connCtx.Units)
+}
+
+// Returns the shared live data message for a subscription if it's already been marshalled this iteration
+// (preparing it, if it hasn't been yet), or else marshals it with marshal and remembers it. Returns nil if
+// marshalling fails.
+func (ss *SharedStreams) get(connCtx *ConnCtx, marshal func () []byte) *hub.Msg {
+	if ss == nil {
+		return &hub.Msg { Data: marshal() }
+	}
+
+	key := sharedStreamKey(connCtx)
+	if key == "" {
+		return &hub.Msg { Data: marshal() }
+	}
+
+	sm, exists := ss.Msgs[key]
+	if !exists {
+		data := marshal()
+		if data != nil {
+			ss.Msgs[key] = &SharedLiveMsg { Data: data }
+		}
+		return &hub.Msg { Data: data }
+	}
+
+	if sm.Prepared == nil {
+		pm, err := websocket.NewPreparedMessage(websocket.TextMessage, sm.Data)
+		if err != nil {
+			log.Println(err)
+			return &hub.Msg { Data: sm.Data }
+		}
+		sm.Prepared = pm
+	}
+
+	_countSharedMsgs++
+	return &hub.Msg { Data: sm.Data, Shared: sm }
+}
+
+// Called (with _lock held) when a connection has subscribed to a boat key, to coalesce its writes with
+// any other connections from the same remote IP subscribed to it (with -coalesce-duplicates).
+func coalesceDuplicates(boatKey string, conn *WsConn) {
+	if !_config.CoalesceDuplicates {
+		return
+	}
+
+	dup := false
+	for _, sub := range _hub.Subscribers(boatKey) {
+		other := sub.(*WsConn)
+		if other != conn && other.RemoteIp == conn.RemoteIp {
+			other.setPolicy(QUEUE_POLICY_CONFLATE)
+			dup = true
+		}
+	}
+
+	if dup {
+		conn.setPolicy(QUEUE_POLICY_CONFLATE)
+	}
+}
+
+// Returns (with _lock held) the number of connections subscribed to a boat key that another
+// connection from the same remote IP is also subscribed to.
+func countDuplicateConns() int {
+	counts := make(map[string]int)
+	for conn, connCtx := range _conns {
+		counts[conn.RemoteIp + "|" + connCtx.BoatKey]++
+	}
+
+	dups := 0
+	for _, n := range counts {
+		if n > 1 {
+			dups += n
+		}
+	}
+
+	return dups
+}
@@ -0,0 +1,107 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"testing"
+)
+
+
+func TestSharedStreams(t *testing.T) {
+	prevShared := _config.SharedStreams
+	_config.SharedStreams = true
+	defer func() { _config.SharedStreams = prevShared }()
+
+	ss := newSharedStreams()
+	marshals := 0
+	marshal := func () []byte {
+		marshals++
+		return []byte("{}")
+	}
+
+	a := &ConnCtx { BoatKey: "K1" }
+	b := &ConnCtx { BoatKey: "K1" }
+	x := &ConnCtx { BoatKey: "K1", Extended: true }
+	s := &ConnCtx { BoatKey: "K1", Session: &Session {} }
+
+	// The first subscription of a stream marshals its message, which is then shared (and prepared) for the next.
+	if msg := ss.get(a, marshal); msg.Shared != nil || marshals != 1 {
+		t.Errorf("First message unexpectedly shared!")
+	}
+
+	msg := ss.get(b, marshal)
+	if msg.Shared == nil || msg.Shared.(*SharedLiveMsg).Prepared == nil || marshals != 1 {
+		t.Errorf("Second message not shared!")
+	}
+
+	// Different options, or a session, mean a different (or no) stream.
+	ss.get(x, marshal)
+	ss.get(s, marshal)
+	ss.get(s, marshal)
+	if marshals != 4 {
+		t.Errorf("Unexpected marshal count: %d", marshals)
+	}
+
+	// Not sharing at all without -shared-streams.
+	_config.SharedStreams = false
+	if newSharedStreams().get(b, marshal).Shared != nil {
+		t.Errorf("Message shared without -shared-streams!")
+	}
+}
+
+func TestSharedStreamsShedGroups(t *testing.T) {
+	prevShared := _config.SharedStreams
+	prevPolicy := _config.ShedPolicy
+	prevLevel := _shedLevel
+	_config.SharedStreams = true
+	_config.ShedPolicy = SHED_POLICY_DEGRADE_GROUPS
+	_shedLevel = PRIORITY_NORMAL
+	defer func() {
+		_config.SharedStreams = prevShared
+		_config.ShedPolicy = prevPolicy
+		_shedLevel = prevLevel
+	}()
+
+	group := list.New()
+	low := &ConnCtx { BoatKey: "K1", GroupBoats: group, Priority: PRIORITY_LOW }
+	normal := &ConnCtx { BoatKey: "K1", GroupBoats: group, Priority: PRIORITY_NORMAL }
+
+	// Only the low priority subscription is degraded to its own boat's data, so the two mustn't share a stream.
+	ss := newSharedStreams()
+	ss.get(low, func () []byte { return []byte("own boat") })
+	msg := ss.get(normal, func () []byte { return []byte("whole group") })
+	if msg.Shared != nil || string(msg.Data) != "whole group" {
+		t.Errorf("Normal priority subscription got a low priority subscription's message: %s", msg.Data)
+	}
+
+	// And the reverse.
+	ss = newSharedStreams()
+	ss.get(normal, func () []byte { return []byte("whole group") })
+	msg = ss.get(low, func () []byte { return []byte("own boat") })
+	if msg.Shared != nil || string(msg.Data) != "own boat" {
+		t.Errorf("Low priority subscription got a normal priority subscription's message: %s", msg.Data)
+	}
+
+	// Once no longer shedding load, they share a stream again.
+	_shedLevel = PRIORITY_LOW
+	ss = newSharedStreams()
+	ss.get(low, func () []byte { return []byte("whole group") })
+	if ss.get(normal, func () []byte { return []byte("whole group") }).Shared == nil {
+		t.Errorf("Message not shared when not shedding load!")
+	}
+}
@@ -0,0 +1,137 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+
+// Load shedding:
+//
+// When iterations keep taking longer than their budget (-shed-budget, as a
+// fraction of the poll interval), e.g. because fan-out to many connections
+// can't finish within the tick, the main loop starts shedding load according
+// to -shed-policy, rather than silently drifting behind. Only subscriptions of
+// a lower priority class than the shedding level (see priority.go) are
+// degraded: "low" ones as soon as shedding starts, and "normal" ones too once
+// SHED_ESCALATE_ITERS more iterations have been over budget. "high" ones never
+// are.
+//
+// degrade-groups     bdl_g and gdl subscriptions are sent only their own boat's
+//                    data ("others" is empty), skipping the group lookups.
+// slow-low-priority  Low priority subscriptions are sent data only every other
+//                    iteration (never escalating to normal priority ones).
+// skip-alternate     Subscriptions are sent data only every other iteration
+//                    (boats are still polled, and history recorded, on each).
+//
+// Shedding starts after SHED_ENTER_ITERS consecutive iterations over budget,
+// and stops after SHED_EXIT_ITERS consecutive iterations under
+// SHED_EXIT_FRACTION of it. The shedding level, the iterations shedding has
+// been active for, and the live data messages it's skipped are included in
+// the stats.
+
+const SHED_POLICY_NONE = "none"
+const SHED_POLICY_DEGRADE_GROUPS = "degrade-groups"
+const SHED_POLICY_SLOW_LOW_PRIORITY = "slow-low-priority"
+const SHED_POLICY_SKIP_ALTERNATE = "skip-alternate"
+
+const SHED_ENTER_ITERS = 3
+const SHED_ESCALATE_ITERS = 5
+const SHED_EXIT_ITERS = 10
+const SHED_EXIT_FRACTION = 0.7
+
+// Shedding state (only accessed from the main loop goroutine, with _lock held)
+var _shedLevel int = PRIORITY_LOW // Subscriptions of lower priority than this are degraded
+var _shedOverIters int = 0
+var _shedUnderIters int = 0
+var _shedIter int64 = 0 // Iterations since shedding started
+
+var _countShedIters int64 = 0 // Guarded by _lock
+var _countShedMsgs int64 = 0 // Guarded by _lock
+
+
+func isValidShedPolicy(policy string) bool {
+	switch policy {
+	case SHED_POLICY_NONE, SHED_POLICY_DEGRADE_GROUPS, SHED_POLICY_SLOW_LOW_PRIORITY, SHED_POLICY_SKIP_ALTERNATE:
+		return true
+	}
+	return false
+}
+
+// Called (with _lock held) at the end of each iteration, with how long it took, to start, escalate or stop shedding.
+func updateShedding(iterTime time.Duration) {
+	if _config.ShedPolicy == SHED_POLICY_NONE {
+		return
+	}
+
+	budget := time.Duration(float64(_config.PollInterval) * _config.ShedBudget)
+
+	if iterTime > budget {
+		_shedOverIters++
+		_shedUnderIters = 0
+	} else if iterTime < time.Duration(float64(budget) * SHED_EXIT_FRACTION) {
+		_shedUnderIters++
+		_shedOverIters = 0
+	} else {
+		_shedOverIters = 0
+		_shedUnderIters = 0
+	}
+
+	switch {
+	case _shedLevel == PRIORITY_LOW && _shedOverIters >= SHED_ENTER_ITERS:
+		log.Println("Iterations over budget (" + budget.String() + "); shedding load (" + _config.ShedPolicy + ") for low priority subscriptions")
+		_shedLevel = PRIORITY_NORMAL
+		_shedIter = 0
+
+	case _shedLevel == PRIORITY_NORMAL && _shedOverIters >= SHED_ENTER_ITERS + SHED_ESCALATE_ITERS && _config.ShedPolicy != SHED_POLICY_SLOW_LOW_PRIORITY:
+		log.Println("Iterations still over budget; shedding load for normal priority subscriptions too")
+		_shedLevel = PRIORITY_HIGH
+
+	case _shedLevel > PRIORITY_LOW && _shedUnderIters >= SHED_EXIT_ITERS:
+		log.Println("Iterations back within budget; no longer shedding load after " + strconv.FormatInt(_shedIter, 10) + " iteration(s)")
+		_shedLevel = PRIORITY_LOW
+	}
+
+	if _shedLevel > PRIORITY_LOW {
+		_shedIter++
+		_countShedIters++
+	}
+}
+
+// Returns whether a group subscription should only get its own boat's data (with _lock held).
+func shedGroups(connCtx *ConnCtx) bool {
+	return connCtx.Priority < _shedLevel && _config.ShedPolicy == SHED_POLICY_DEGRADE_GROUPS && !connCtx.Resync
+}
+
+// Returns whether a connection should be skipped this iteration (with _lock held), counting it if so.
+func shedSkip(connCtx *ConnCtx) bool {
+	if connCtx.Priority >= _shedLevel || _shedIter % 2 == 0 {
+		return false
+	}
+
+	switch _config.ShedPolicy {
+	case SHED_POLICY_SKIP_ALTERNATE, SHED_POLICY_SLOW_LOW_PRIORITY:
+	default:
+		return false
+	}
+
+	_countShedMsgs++
+	return true
+}
@@ -0,0 +1,93 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestUpdateShedding(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	savedConfig := *_config
+	defer func() {
+		*_config = savedConfig
+		_shedLevel = PRIORITY_LOW
+		_shedOverIters = 0
+		_shedUnderIters = 0
+	}()
+	_config.PollInterval = time.Second
+	_config.ShedBudget = 0.5
+	_config.ShedPolicy = SHED_POLICY_SKIP_ALTERNATE
+
+	spectator := &ConnCtx { Priority: PRIORITY_LOW }
+	racer := &ConnCtx { Priority: PRIORITY_NORMAL }
+	admin := &ConnCtx { Priority: PRIORITY_HIGH }
+
+	skipped := func (connCtx *ConnCtx) int {
+		n := 0
+		for i := 0; i < 4; i++ {
+			if shedSkip(connCtx) {
+				n++
+			}
+			updateShedding(400 * time.Millisecond) // Within budget, but not enough to stop shedding
+		}
+		return n
+	}
+
+	for i := 0; i < SHED_ENTER_ITERS; i++ {
+		if _shedLevel != PRIORITY_LOW {
+			t.Fatalf("Shedding after only %d iterations over budget", i)
+		}
+		updateShedding(600 * time.Millisecond)
+	}
+	if _shedLevel != PRIORITY_NORMAL {
+		t.Fatalf("Not shedding after %d iterations over budget", SHED_ENTER_ITERS)
+	}
+
+	// Low priority subscriptions are skipped every other iteration, while others keep full rate.
+	if n := skipped(spectator); n != 2 {
+		t.Errorf("Expected 2 skipped iterations for low priority, but got %d", n)
+	}
+	if n := skipped(racer); n != 0 {
+		t.Errorf("Normal priority skipped %d times before escalating", n)
+	}
+
+	// Still over budget, so normal priority subscriptions are degraded too, but never high priority ones.
+	for i := 0; i < SHED_ENTER_ITERS + SHED_ESCALATE_ITERS; i++ {
+		updateShedding(600 * time.Millisecond)
+	}
+	if _shedLevel != PRIORITY_HIGH {
+		t.Fatalf("Shedding not escalated")
+	}
+	if n := skipped(racer); n != 2 {
+		t.Errorf("Expected 2 skipped iterations for normal priority, but got %d", n)
+	}
+	if n := skipped(admin); n != 0 {
+		t.Errorf("High priority skipped %d times", n)
+	}
+
+	for i := 0; i < SHED_EXIT_ITERS; i++ {
+		updateShedding(100 * time.Millisecond)
+	}
+	if _shedLevel != PRIORITY_LOW || shedSkip(spectator) {
+		t.Errorf("Still shedding after %d iterations well within budget", SHED_EXIT_ITERS)
+	}
+}
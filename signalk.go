@@ -0,0 +1,199 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+// Signal K output:
+//
+// On connections with the SUBPROTOCOL_SIGNALK subprotocol (see
+// wire-format.go), each live data message is sent as Signal K delta messages
+// (one per vessel), in SI units (speeds in m/s, angles in radians), for
+// marine software and displays built on Signal K:
+//
+// - The subscribed boat (bdl, bdl_x, bdl_g) is "vessels.self".
+// - Other boats (bdl_g; or every boat, for group_all and gdl) are
+//   "vessels.urn:mrn:imo:mmsi:<n>", with the same pseudo MMSI as in AIS
+//   output (see ais.go).
+//
+// Speeds are taken to be in knots, so units other than the defaults (see
+// units.go) shouldn't be requested. Other messages are sent as JSON.
+
+const SIGNALK_SELF = "vessels.self"
+const SIGNALK_SOURCE = "sailnavsim"
+
+const KNOTS_TO_MS = 1852.0 / 3600.0
+
+
+type SignalKEncoder struct {}
+
+type SignalKDelta struct {
+	Context string `json:"context"`
+	Updates []SignalKUpdate `json:"updates"`
+}
+
+type SignalKUpdate struct {
+	Source SignalKSource `json:"source"`
+	Timestamp string `json:"timestamp"`
+	Values []SignalKValue `json:"values"`
+}
+
+type SignalKSource struct {
+	Label string `json:"label"`
+}
+
+type SignalKValue struct {
+	Path string `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+type SignalKPosition struct {
+	Latitude float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+
+func (*SignalKEncoder) Encode(data []byte) (int, [][]byte, error) {
+	msgs, err := splitEncMsgs(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var out [][]byte
+	for _, m := range msgs {
+		live, err := decodeEncLiveMsg(m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if live == nil {
+			out = append(out, m)
+			continue
+		}
+
+		for _, delta := range createSignalKDeltas(live, time.Now()) {
+			d, err := json.Marshal(delta)
+			if err != nil {
+				return 0, nil, err
+			}
+			out = append(out, d)
+		}
+	}
+
+	return websocket.TextMessage, out, nil
+}
+
+func (*SignalKEncoder) DecodeReq(data []byte, maxDepth int) ([]byte, error) {
+	return data, nil
+}
+
+// Creates the Signal K deltas for a live data message (the subscribed boat's first, then others by name).
+func createSignalKDeltas(m *EncLiveMsg, now time.Time) []*SignalKDelta {
+	ts := now.UTC().Format(time.RFC3339Nano)
+
+	if m.Boats != nil {
+		names := make([]string, 0, len(m.Boats))
+		for name := range m.Boats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		deltas := make([]*SignalKDelta, 0, len(names))
+		for _, name := range names {
+			b := m.Boats[name]
+			deltas = append(deltas, signalKDelta(signalKContext(name), ts, signalKBoatValues(&b)))
+		}
+		return deltas
+	}
+
+	own := &m.BoatDataLiveRespMsg
+	if m.You != nil {
+		own = m.You
+	}
+
+	deltas := []*SignalKDelta { signalKDelta(SIGNALK_SELF, ts, signalKBoatValues(own)) }
+
+	names := make([]string, 0, len(m.Others))
+	for name := range m.Others {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		other := m.Others[name]
+		if len(other) < 3 {
+			continue
+		}
+
+		values := []SignalKValue {
+			{ Path: "navigation.position", Value: SignalKPosition { Latitude: other[0], Longitude: other[1] } },
+			{ Path: "navigation.headingTrue", Value: degToRad(other[2]) },
+		}
+		if len(other) >= 4 {
+			values = append(values, SignalKValue { Path: "navigation.speedOverGround", Value: other[3] * KNOTS_TO_MS })
+		}
+
+		deltas = append(deltas, signalKDelta(signalKContext(name), ts, values))
+	}
+
+	return deltas
+}
+
+func signalKDelta(context string, ts string, values []SignalKValue) *SignalKDelta {
+	return &SignalKDelta {
+		Context: context,
+		Updates: []SignalKUpdate { { Source: SignalKSource { Label: SIGNALK_SOURCE }, Timestamp: ts, Values: values } },
+	}
+}
+
+func signalKContext(name string) string {
+	return fmt.Sprintf("vessels.urn:mrn:imo:mmsi:%09d", aisPseudoMmsi(name))
+}
+
+func signalKBoatValues(b *BoatDataLiveRespMsg) []SignalKValue {
+	return []SignalKValue {
+		{ Path: "navigation.position", Value: SignalKPosition { Latitude: b.Lat, Longitude: b.Lon } },
+		{ Path: "navigation.courseOverGroundTrue", Value: degToRad(b.Cog) },
+		{ Path: "navigation.speedOverGround", Value: b.Sog * KNOTS_TO_MS },
+		{ Path: "navigation.headingTrue", Value: degToRad(b.Ctw) },
+		{ Path: "navigation.speedThroughWater", Value: b.Stw * KNOTS_TO_MS },
+		{ Path: "environment.wind.speedTrue", Value: b.Lws * KNOTS_TO_MS },
+		{ Path: "environment.wind.angleTrueWater", Value: degToRad(normalizeAngle(b.Ha)) },
+	}
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180.0
+}
+
+// Normalizes an angle to [-180, 180), as Signal K expects for angles relative to the bow.
+func normalizeAngle(deg float64) float64 {
+	deg = normalizeCourse(deg)
+	if deg >= 180.0 {
+		deg -= 360.0
+	}
+	return deg
+}
@@ -0,0 +1,644 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+// TCP simulator backend:
+//
+// sailnavsim-core's line protocol, over a new TCP connection per request (or
+// batch of requests), or for boat data and group lookups, over a persistent
+// multiplexed connection if the simulator supports it (see sim-mux.go). See
+// sim-decoder.go for the response line formats.
+//
+// A failed dial (e.g. a refused connection while the simulator restarts) is
+// retried a few times with jittered exponential backoff, as long as the retry
+// would still start within SIM_RETRY_BUDGET, so that a transient failure
+// doesn't lose a whole iteration of boat data. A boat data poll that needed
+// retries marks its iteration as degraded (see sim-stats.go).
+//
+// Each connection starts with a protocol version handshake, when the
+// simulator's version isn't already known (see sim-version.go).
+
+const SIM_MAX_RETRIES = 3
+const SIM_RETRY_BACKOFF = 50 * time.Millisecond // Before the first retry, doubling after each
+const SIM_RETRY_BUDGET = 500 * time.Millisecond // From the first attempt
+
+type TcpSimClient struct {
+	HostPort string
+}
+
+
+// Connects to the simulator (retrying on failure), returning nil on failure, and whether any retries were needed.
+func (c *TcpSimClient) dial(ctx context.Context) (net.Conn, bool) {
+	_, span := startSpan(ctx, "sim.dial")
+	defer span.Finish()
+
+	start := time.Now()
+	backoff := SIM_RETRY_BACKOFF
+
+	for retries := 0; ; retries++ {
+		conn, err := net.DialTimeout("tcp", c.HostPort, DIAL_TIMEOUT)
+		if err == nil {
+			span.SetAttr("retries", retries)
+			alertSimReachable(c.HostPort, true)
+			noteSimReachable(c.HostPort, true)
+
+			err = conn.SetDeadline(time.Now().Add(CONN_RW_TIMEOUT))
+			if err != nil {
+				log.Println(err)
+				span.SetError(err.Error())
+				conn.Close()
+				return nil, retries > 0
+			}
+
+			if !c.handshake(conn) {
+				span.SetError("Protocol version handshake failed")
+				conn.Close()
+				return nil, retries > 0
+			}
+			span.SetAttr("proto", simProtoVersion(c.HostPort))
+
+			return conn, retries > 0
+		}
+
+		log.Println(err)
+
+		// Wait for between half and one and a half times the backoff.
+		wait := backoff / 2 + time.Duration(rand.Int63n(int64(backoff)))
+		if retries == SIM_MAX_RETRIES || time.Now().Add(wait).Sub(start) > SIM_RETRY_BUDGET {
+			countSimResult(SIM_RESULT_DIAL_FAILURE)
+			forgetSimProtoVersion(c.HostPort)
+			alertSimReachable(c.HostPort, false)
+			noteSimReachable(c.HostPort, false)
+			span.SetAttr("retries", retries)
+			span.SetError(err.Error())
+			return nil, retries > 0
+		}
+
+		time.Sleep(wait)
+		backoff *= 2
+		countSimRetry()
+	}
+}
+
+// Returns the command for a boat data request ("bdx" for extended boat data, if the simulator supports it).
+func boatDataCmd(req SimBoatDataReq, extended bool) string {
+	if req.Extended && extended {
+		return "bdx"
+	}
+	return "bd_nc"
+}
+
+func (c *TcpSimClient) GetBoatData(ctx context.Context, reqs []SimBoatDataReq) (map[string]BoatDataLiveRespMsg, map[string]bool) {
+	resps := make(map[string]BoatDataLiveRespMsg)
+	noBoats := make(map[string]bool)
+
+	if m, retried, use := c.mux(ctx); use {
+		if retried {
+			markIterDegraded()
+		}
+		if m != nil {
+			defer m.release()
+			c.getBoatDataMux(ctx, m, reqs, resps, noBoats)
+		}
+		return resps, noBoats
+	}
+
+	conn, retried := c.dial(ctx)
+	if retried {
+		markIterDegraded()
+	}
+	if conn == nil {
+		return resps, noBoats
+	}
+	defer conn.Close()
+
+	// Simulators without extended boat data are just asked for the usual data.
+	extended := simProtoSupports(simProtoVersion(c.HostPort), "bdx")
+
+	requestWriterDone := make(chan int)
+	go func() {
+		for _, req := range reqs {
+			fmt.Fprintf(conn, boatDataCmd(req, extended) + "," + req.BoatKey + "\n")
+		}
+
+		requestWriterDone <- 0
+	}()
+
+	responseReader := bufio.NewReader(conn)
+	readBoatData(reqs, extended, false, func (i int) (string, error) {
+		return responseReader.ReadString('\n')
+	}, resps, noBoats)
+
+	// Ensure that our request writer goroutine has finished before continuing.
+	<-requestWriterDone
+
+	return resps, noBoats
+}
+
+// Gets boat data over a multiplexed connection (see sim-mux.go).
+func (c *TcpSimClient) getBoatDataMux(ctx context.Context, m *SimMux, reqs []SimBoatDataReq, resps map[string]BoatDataLiveRespMsg, noBoats map[string]bool) {
+	// Extended boat data came before multiplexing, so is always supported here.
+	lines := make([]string, len(reqs))
+	for i, req := range reqs {
+		lines[i] = boatDataCmd(req, true) + "," + req.BoatKey
+	}
+
+	sent, err := m.send(lines, simMuxSingleLine)
+	if err != nil {
+		countSimIoError(err)
+		return
+	}
+	defer m.abandon(sent)
+
+	ctx, cancel := context.WithTimeout(ctx, CONN_RW_TIMEOUT)
+	defer cancel()
+
+	// Responses are handled in the order requested, however they arrive, and each stands on its own.
+	readBoatData(reqs, true, true, func (i int) (string, error) {
+		lines, err := m.wait(ctx, sent[i])
+		if err != nil {
+			return "", err
+		}
+		return lines[0], nil
+	}, resps, noBoats)
+}
+
+// Handles the responses to boat data requests, reading the response to each request in turn. Unless the
+// responses are independent of each other (as on a multiplexed connection, where each is matched to its
+// request by ID), an error response or one out of step with the requests ends the reading of responses.
+func readBoatData(reqs []SimBoatDataReq, extended bool, independent bool, readLine func (i int) (string, error), resps map[string]BoatDataLiveRespMsg, noBoats map[string]bool) {
+	// For each boat requested, process its data from the simulator.
+	for i := 0; i < len(reqs); i++ {
+		line, err := readLine(i)
+
+		if err != nil {
+			log.Println(err)
+			countSimIoError(err)
+			break
+		}
+
+		line = strings.Trim(line, "\n")
+		if line == "error" {
+			log.Println("Error returned from simulator when trying to get live data for boat num: " + strconv.Itoa(i))
+			countSimResult(SIM_RESULT_ERROR)
+			if independent {
+				continue
+			}
+			break
+		}
+
+		r, err := decodeBoatDataLine(line)
+		if err != nil {
+			log.Println(err)
+			countSimResult(SIM_RESULT_PARSE_ERROR)
+			continue
+		}
+
+		if r.BoatKey != reqs[i].BoatKey || r.Cmd != boatDataCmd(reqs[i], extended) {
+			// Out of step with our requests, so none of the remaining responses can be trusted either
+			// (unless matched to their requests by ID).
+			log.Println(newSimDecodeError(line, 0, SIM_REJECT_FRAMING, "not a response to request " + strconv.Itoa(i)))
+			countSimResult(SIM_RESULT_PARSE_ERROR)
+			if independent {
+				continue
+			}
+			break
+		}
+
+		switch r.Status {
+		case SIM_STATUS_OK:
+			resp := r.Data
+			stampArrival(&resp, time.Now())
+
+			resps[r.BoatKey] = resp
+			countSimResult(SIM_RESULT_OK)
+
+		case SIM_STATUS_NOBOAT:
+			log.Println("No boat for key: " + r.BoatKey)
+			noBoats[r.BoatKey] = true
+			countSimResult(SIM_RESULT_NOBOAT)
+
+		default:
+			log.Println("Unexpected response from simulator: " + r.Status)
+			countSimResult(SIM_RESULT_ERROR)
+		}
+	}
+}
+
+func (c *TcpSimClient) GetGroupMembers(ctx context.Context, boatKey string) *list.List {
+	if m, _, use := c.mux(ctx); use {
+		if m == nil {
+			return nil
+		}
+		defer m.release()
+		return c.getGroupMembersMux(ctx, m, boatKey)
+	}
+
+	conn, _ := c.dial(ctx)
+	if conn == nil {
+		return nil
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "boatgroupmembers," + boatKey + "\n")
+
+	reader := bufio.NewReader(conn)
+	return readGroupMembers(boatKey, func () (string, error) {
+		return reader.ReadString('\n')
+	})
+}
+
+// Gets a boat's group members over a multiplexed connection (see sim-mux.go).
+func (c *TcpSimClient) getGroupMembersMux(ctx context.Context, m *SimMux, boatKey string) *list.List {
+	sent, err := m.send([]string { "boatgroupmembers," + boatKey }, simMuxGroupMembersComplete)
+	if err != nil {
+		countSimIoError(err)
+		return nil
+	}
+	defer m.abandon(sent)
+
+	ctx, cancel := context.WithTimeout(ctx, CONN_RW_TIMEOUT)
+	defer cancel()
+
+	lines, err := m.wait(ctx, sent[0])
+	return readGroupMembers(boatKey, func () (string, error) {
+		if err != nil {
+			return "", err
+		}
+		if len(lines) == 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	})
+}
+
+// Handles the response to a "boatgroupmembers" request, read line by line.
+func readGroupMembers(boatKey string, readLine func () (string, error)) *list.List {
+	groupKeys := list.New()
+
+	start := true
+	for {
+		line, err := readLine()
+		if err != nil {
+			log.Println(err)
+			countSimIoError(err)
+			return nil
+		}
+
+		line = strings.Trim(line, "\n")
+
+		if start {
+			if line == "error" {
+				log.Println("Error returned from simulator when trying to get boat group membership for boat key: " + boatKey)
+				countSimResult(SIM_RESULT_ERROR)
+				return nil
+			}
+
+			status, err := decodeGroupHeaderLine(line)
+			if err != nil {
+				log.Println(err)
+				countSimResult(SIM_RESULT_PARSE_ERROR)
+				return nil
+			}
+
+			switch status {
+			case SIM_STATUS_OK:
+				start = false
+				continue
+
+			default:
+				log.Println("Unexpected code (\"" + status + "\") returned from simulator when trying to get boat group membership for boat key: " + boatKey)
+				countSimResult(SIM_RESULT_ERROR)
+				return nil
+			}
+		} else if line == "" {
+			countSimResult(SIM_RESULT_OK)
+			return groupKeys
+		} else {
+			boat, err := decodeGroupMemberLine(line)
+			if err != nil {
+				// Just leave out the member.
+				log.Println(err)
+				countSimResult(SIM_RESULT_PARSE_ERROR)
+			} else if boat != nil {
+				groupKeys.PushBack(boat)
+			}
+		}
+	}
+}
+
+func (c *TcpSimClient) GetSpectatorBoat(spectatorId string) string {
+	conn, _ := c.dial(context.Background())
+	if conn == nil {
+		return ""
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "spectatorboat," + spectatorId + "\n")
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		log.Println(err)
+		countSimIoError(err)
+		return ""
+	}
+
+	line = strings.Trim(line, "\n")
+	if line == "error" {
+		log.Println("Error returned from simulator when trying to resolve spectator ID: " + spectatorId)
+		countSimResult(SIM_RESULT_ERROR)
+		return ""
+	}
+
+	status, boatKey, err := decodeSpectatorLine(line)
+	if err != nil {
+		log.Println(err)
+		countSimResult(SIM_RESULT_PARSE_ERROR)
+		return ""
+	}
+
+	if status != SIM_STATUS_OK {
+		countSimResult(SIM_RESULT_ERROR)
+		return ""
+	}
+
+	countSimResult(SIM_RESULT_OK)
+
+	return boatKey
+}
+
+func (c *TcpSimClient) GetBoatInfo(ctx context.Context, boatKey string) *SimBoatInfo {
+	conn := c.dialFor("boatinfo")
+	if conn == nil {
+		return nil
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "boatinfo," + boatKey + "\n")
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		log.Println(err)
+		countSimIoError(err)
+		return nil
+	}
+
+	line = strings.Trim(line, "\n")
+	if line == "error" {
+		countSimResult(SIM_RESULT_ERROR)
+		return nil
+	}
+
+	status, info, err := decodeBoatInfoLine(line)
+	if err != nil {
+		log.Println(err)
+		countSimResult(SIM_RESULT_PARSE_ERROR)
+		return nil
+	}
+
+	if status == SIM_STATUS_NOBOAT {
+		countSimResult(SIM_RESULT_NOBOAT)
+		return nil
+	}
+	if status != SIM_STATUS_OK {
+		log.Println("Unexpected code (\"" + status + "\") returned from simulator when trying to get boat info")
+		countSimResult(SIM_RESULT_ERROR)
+		return nil
+	}
+
+	countSimResult(SIM_RESULT_OK)
+
+	return info
+}
+
+func (c *TcpSimClient) SendAction(boatKey string, action string, value string) (string, string, bool) {
+	conn := c.dialFor("action")
+	if conn == nil {
+		return "", "", false
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "action," + boatKey + "," + action + "," + value + "\n")
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		log.Println(err)
+		countSimIoError(err)
+		return "", "", false
+	}
+
+	line = strings.Trim(line, "\n")
+	if line == "error" {
+		countSimResult(SIM_RESULT_ERROR)
+		return "", "", false
+	}
+
+	status, reason, err := decodeActionLine(line, boatKey)
+	if err != nil {
+		log.Println(err)
+		countSimResult(SIM_RESULT_PARSE_ERROR)
+		return "", "", false
+	}
+
+	if status == SIM_STATUS_NOBOAT {
+		countSimResult(SIM_RESULT_NOBOAT)
+	} else {
+		countSimResult(SIM_RESULT_OK)
+	}
+
+	return status, reason, true
+}
+
+func (c *TcpSimClient) GetWindArea(lat0 float64, lon0 float64, step float64, size int) [][2]float64 {
+	conn, _ := c.dial(context.Background())
+	if conn == nil {
+		return nil
+	}
+	defer conn.Close()
+
+	requestWriterDone := make(chan int)
+	go func() {
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				fmt.Fprintf(conn, "wind," + formatWindAreaCoord(lat0 + float64(i) * step) + "," + formatWindAreaCoord(normalizeLon(lon0 + float64(j) * step)) + "\n")
+			}
+		}
+
+		requestWriterDone <- 0
+	}()
+	defer func() { <-requestWriterDone }()
+
+	wind := make([][2]float64, 0, size * size)
+
+	responseReader := bufio.NewReader(conn)
+	for n := 0; n < size * size; n++ {
+		line, err := responseReader.ReadString('\n')
+		if err != nil {
+			log.Println(err)
+			countSimIoError(err)
+			return nil
+		}
+
+		dir, speed, err := decodeWindLine(strings.Trim(line, "\n"))
+		if err != nil {
+			log.Println(err)
+			countSimResult(SIM_RESULT_PARSE_ERROR)
+			return nil
+		}
+
+		wind = append(wind, [2]float64 { dir, speed })
+	}
+
+	countSimResult(SIM_RESULT_OK)
+	return wind
+}
+
+func (c *TcpSimClient) GetBoatEvents(since int64) ([]SimBoatEvent, int64, bool) {
+	conn := c.dialFor("boatevents")
+	if conn == nil {
+		return nil, 0, false
+	}
+	defer conn.Close()
+
+	var events []SimBoatEvent
+	var latest int64 = 0
+
+	fmt.Fprintf(conn, "boatevents," + strconv.FormatInt(since, 10) + "\n")
+	start := true
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			log.Println(err)
+			countSimIoError(err)
+			return nil, 0, false
+		}
+
+		line = strings.Trim(line, "\n")
+
+		if start {
+			if line == "error" {
+				countSimResult(SIM_RESULT_ERROR)
+				return nil, 0, false
+			}
+
+			status, latestId, err := decodeBoatEventsHeaderLine(line)
+			if err != nil {
+				log.Println(err)
+				countSimResult(SIM_RESULT_PARSE_ERROR)
+				return nil, 0, false
+			}
+
+			if status != SIM_STATUS_OK {
+				log.Println("Unexpected code (\"" + status + "\") returned from simulator when trying to get boat events")
+				countSimResult(SIM_RESULT_ERROR)
+				return nil, 0, false
+			}
+
+			latest = latestId
+			start = false
+		} else if line == "" {
+			countSimResult(SIM_RESULT_OK)
+			return events, latest, true
+		} else {
+			ev, err := decodeBoatEventLine(line)
+			if err != nil {
+				// Just leave out the event.
+				log.Println(err)
+				countSimResult(SIM_RESULT_PARSE_ERROR)
+			} else {
+				events = append(events, *ev)
+			}
+		}
+	}
+}
+
+func (c *TcpSimClient) GetBoatsInArea(area MapArea) ([]SimAreaBoat, bool) {
+	conn := c.dialFor("boatsinarea")
+	if conn == nil {
+		return nil, false
+	}
+	defer conn.Close()
+
+	var boats []SimAreaBoat
+
+	fmt.Fprintf(conn, "boatsinarea," + area.simArgs() + "\n")
+	start := true
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			log.Println(err)
+			countSimIoError(err)
+			return nil, false
+		}
+
+		line = strings.Trim(line, "\n")
+
+		if start {
+			if line == "error" {
+				countSimResult(SIM_RESULT_ERROR)
+				return nil, false
+			}
+
+			status, err := decodeAreaHeaderLine(line)
+			if err != nil {
+				log.Println(err)
+				countSimResult(SIM_RESULT_PARSE_ERROR)
+				return nil, false
+			}
+
+			if status != SIM_STATUS_OK {
+				log.Println("Unexpected code (\"" + status + "\") returned from simulator when trying to get boats in area")
+				countSimResult(SIM_RESULT_ERROR)
+				return nil, false
+			}
+
+			start = false
+		} else if line == "" {
+			countSimResult(SIM_RESULT_OK)
+			return boats, true
+		} else {
+			boat, err := decodeAreaBoatLine(line)
+			if err != nil {
+				// Just leave out the boat.
+				log.Println(err)
+				countSimResult(SIM_RESULT_PARSE_ERROR)
+			} else {
+				boats = append(boats, *boat)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"context"
+)
+
+
+// Simulator backends:
+//
+// All communication with a simulator goes through a SimClient, so that the
+// rest of the connector (and the main loop in particular) doesn't depend on
+// how a simulator is reached. TcpSimClient implements sailnavsim-core's TCP
+// line protocol; other backends (e.g. an HTTP API, or an in-memory fake for
+// tests) only need to implement the same interface, and be registered in
+// _simClients under the simulator's name.
+//
+// Implementations count their outcomes with countSimResult (see sim-stats.go),
+// and must be safe for concurrent use.
+
+type SimClient interface {
+	// Gets the live data for the given boats, and which of them the simulator doesn't know about.
+	GetBoatData(ctx context.Context, reqs []SimBoatDataReq) (map[string]BoatDataLiveRespMsg, map[string]bool)
+
+	// Gets the boats in a boat's group (including itself), or nil on failure.
+	GetGroupMembers(ctx context.Context, boatKey string) *list.List
+
+	// Resolves a spectator ID to a boat key, or "" if it can't be resolved.
+	GetSpectatorBoat(spectatorId string) string
+
+	// Gets a boat's info (see boat-info.go), or nil if it's unknown or on failure.
+	GetBoatInfo(ctx context.Context, boatKey string) *SimBoatInfo
+
+	// Gets the wind ([dir, speed]) on a grid of size x size points, row by row from (lat0, lon0), or nil on failure.
+	GetWindArea(lat0 float64, lon0 float64, step float64, size int) [][2]float64
+
+	// Gets the boat events after the given event ID (none if 0), and the latest event ID, or false on failure (see boat-events.go).
+	GetBoatEvents(since int64) ([]SimBoatEvent, int64, bool)
+
+	// Gets the public boats in an area, or false on failure (see map-area.go).
+	GetBoatsInArea(area MapArea) ([]SimAreaBoat, bool)
+
+	// Forwards an action for a boat, returning the simulator's status (and reason, if rejected), or false on failure (see boat-actions.go).
+	SendAction(boatKey string, action string, value string) (string, string, bool)
+}
+
+type SimBoatDataReq struct {
+	BoatKey string
+	Extended bool // Whether extended data (see boat-data-ext.go) is wanted
+}
+
+// Simulator backends by name, overriding the TCP ones that would otherwise be used for the
+// default simulator and those configured with -sims. Only modified before the main loop starts.
+var _simClients = make(map[string]SimClient)
+
+
+// Returns the backend for a simulator, or nil if there's no such simulator.
+func simClient(sim string) SimClient {
+	if client, exists := _simClients[sim]; exists {
+		return client
+	}
+
+	if sim == DEFAULT_SIM {
+		return &TcpSimClient { HostPort: _connectHostPort }
+	}
+
+	if hostPort, exists := _config.Sims[sim]; exists {
+		return &TcpSimClient { HostPort: hostPort }
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+
+// In-memory simulator backend, recording the boat data requests it's asked.
+type FakeSimClient struct {
+	lock sync.Mutex
+	boats map[string]BoatDataLiveRespMsg
+	reqs []SimBoatDataReq
+	events []SimBoatEvent
+	areaBoats []SimAreaBoat
+	areaReqs int
+	boatInfo map[string]*SimBoatInfo
+	actions []string // As "<action>,<value>"
+}
+
+func (c *FakeSimClient) GetBoatData(ctx context.Context, reqs []SimBoatDataReq) (map[string]BoatDataLiveRespMsg, map[string]bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	resps := make(map[string]BoatDataLiveRespMsg)
+	noBoats := make(map[string]bool)
+
+	for _, req := range reqs {
+		c.reqs = append(c.reqs, req)
+
+		data, exists := c.boats[req.BoatKey]
+		if exists {
+			resps[req.BoatKey] = data
+		} else {
+			noBoats[req.BoatKey] = true
+		}
+	}
+
+	return resps, noBoats
+}
+
+func (c *FakeSimClient) GetGroupMembers(ctx context.Context, boatKey string) *list.List {
+	return nil
+}
+
+func (c *FakeSimClient) GetSpectatorBoat(spectatorId string) string {
+	return ""
+}
+
+func (c *FakeSimClient) GetBoatInfo(ctx context.Context, boatKey string) *SimBoatInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.boatInfo[boatKey]
+}
+
+func (c *FakeSimClient) GetWindArea(lat0 float64, lon0 float64, step float64, size int) [][2]float64 {
+	wind := make([][2]float64, size * size)
+	for i := range wind {
+		wind[i] = [2]float64 { 270.0, float64(i) }
+	}
+	return wind
+}
+
+func (c *FakeSimClient) GetBoatEvents(since int64) ([]SimBoatEvent, int64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var events []SimBoatEvent
+	var latest int64 = 0
+	for _, ev := range c.events {
+		if since > 0 && ev.Id > since {
+			events = append(events, ev)
+		}
+		latest = ev.Id
+	}
+
+	return events, latest, true
+}
+
+func (c *FakeSimClient) SendAction(boatKey string, action string, value string) (string, string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.actions = append(c.actions, action + "," + value)
+
+	if _, exists := c.boats[boatKey]; !exists {
+		return SIM_STATUS_NOBOAT, "", true
+	}
+	if action == ACTION_ANCHOR {
+		return SIM_ACTION_REJECTED, "No anchor, in water this deep", true
+	}
+	return SIM_STATUS_OK, "", true
+}
+
+func (c *FakeSimClient) GetBoatsInArea(area MapArea) ([]SimAreaBoat, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.areaReqs++
+
+	var boats []SimAreaBoat
+	for _, b := range c.areaBoats {
+		if area.contains(b.Lat, b.Lon) {
+			boats = append(boats, b)
+		}
+	}
+	return boats, true
+}
+
+func TestGetBoatDataLiveRespsWithSimClient(t *testing.T) {
+	known := testBoatKey(t, 0)
+	extended := testBoatKey(t, 1)
+	unknown := testBoatKey(t, 2)
+
+	fake := &FakeSimClient {
+		boats: map[string]BoatDataLiveRespMsg {
+			known: { Lat: 10.0, Lon: 20.0 },
+			extended: { Lat: -10.0, Lon: -20.0 },
+		},
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	_simClients["fake"] = fake
+	defer delete(_simClients, "fake")
+
+	trackBoat("fake", known)
+	trackBoat("fake", extended)
+	trackBoatExt(extended)
+	trackBoat("fake", unknown)
+	defer untrackBoat(known)
+	defer untrackBoat(extended)
+
+	resps, noBoats := getBoatDataLiveResps(context.Background(), nil)
+
+	if resps[known].Lat != 10.0 || resps[extended].Lon != -20.0 {
+		t.Errorf("Boat data from simulator backend missing!")
+	}
+	if !noBoats[unknown] || noBoats[known] {
+		t.Errorf("Unexpected \"noboat\" boats: %v", noBoats)
+	}
+	if _, exists := _trackedBoats[unknown]; exists {
+		t.Errorf("\"noboat\" boat still tracked!")
+	}
+
+	if len(fake.reqs) != 3 {
+		t.Fatalf("Expected 3 boat data requests, but got %d!", len(fake.reqs))
+	}
+	for _, req := range fake.reqs {
+		if req.Extended != (req.BoatKey == extended) {
+			t.Errorf("Unexpected extended data request: %+v", req)
+		}
+	}
+
+	msg := fetchWindArea("fake", 45.0, 190.0, 0.5, 3)
+	if msg == nil || msg.Lon0 != -170.0 || len(msg.Wind) != 9 || msg.Wind[8][1] != 8.0 {
+		t.Errorf("Unexpected wind area from simulator backend: %+v", msg)
+	}
+}
+
+func TestTcpSimClientDialRetry(t *testing.T) {
+	boatKey := testBoatKey(t, 0)
+
+	sim := &TestSim {
+		boats: make(map[string]string),
+		groups: make(map[string][]*BoatInfo),
+	}
+	sim.setBoat(boatKey, 10.0, 20.0, 90.0)
+
+	// Find a free port, and only start listening on it (as if the simulator were restarting) after the first dial has failed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostPort := ln.Addr().String()
+	ln.Close()
+
+	lnReady := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(SIM_RETRY_BACKOFF / 4)
+
+		ln, err := net.Listen("tcp", hostPort)
+		if err != nil {
+			lnReady <- nil
+			return // Port taken in the meantime, so the request will just fail.
+		}
+		lnReady <- ln
+
+		sim.serve(ln)
+	}()
+	defer func() {
+		if ln := <-lnReady; ln != nil {
+			ln.Close()
+		}
+	}()
+
+	retriesBefore := atomic.LoadInt64(&_countSimRetries)
+
+	client := &TcpSimClient { HostPort: hostPort }
+	resps, _ := client.GetBoatData(context.Background(), []SimBoatDataReq { { boatKey, false } })
+
+	if resps[boatKey].Lat != 10.0 {
+		t.Errorf("Boat data not received after retrying!")
+	}
+	if atomic.LoadInt64(&_countSimRetries) == retriesBefore {
+		t.Errorf("No retries counted!")
+	}
+	if !_iterDegraded.Load() {
+		t.Errorf("Iteration not marked as degraded!")
+	}
+	_iterDegraded.Store(false)
+}
+
+func TestTcpSimClientFraming(t *testing.T) {
+	keys := []string { testBoatKey(t, 0), testBoatKey(t, 1), testBoatKey(t, 2) }
+
+	sim := &TestSim {
+		boats: make(map[string]string),
+		groups: make(map[string][]*BoatInfo),
+	}
+	for _, boatKey := range keys {
+		sim.setBoat(boatKey, 10.0, 20.0, 90.0)
+	}
+
+	// A stray line after the first response puts the rest out of step with the requests.
+	sim.boats[keys[0]] += "\nbd_nc," + keys[0] + ",noboat"
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go sim.serve(ln)
+
+	client := &TcpSimClient { HostPort: ln.Addr().String() }
+	defer forgetSimProtoVersion(client.HostPort)
+
+	before := atomic.LoadInt64(&_simRejectCounts[SIM_REJECT_FRAMING])
+
+	resps, noBoats := client.GetBoatData(context.Background(), []SimBoatDataReq { { keys[0], false }, { keys[1], false }, { keys[2], false } })
+	if len(resps) != 1 || resps[keys[0]].Lat != 10.0 || len(noBoats) != 0 {
+		t.Errorf("Unexpected responses after stray line: %v, %v", resps, noBoats)
+	}
+	if atomic.LoadInt64(&_simRejectCounts[SIM_REJECT_FRAMING]) == before {
+		t.Errorf("Framing error not counted!")
+	}
+}
+
+func TestTcpSimClientProtoVersion(t *testing.T) {
+	boatKey := testBoatKey(t, 0)
+
+	// The test simulator predates the handshake, and knows nothing of "bdx" or "boatevents".
+	sim := &TestSim {
+		boats: make(map[string]string),
+		groups: make(map[string][]*BoatInfo),
+	}
+	sim.setBoat(boatKey, 10.0, 20.0, 90.0)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go sim.serve(ln)
+
+	legacy := &TcpSimClient { HostPort: ln.Addr().String() }
+	defer forgetSimProtoVersion(legacy.HostPort)
+
+	resps, _ := legacy.GetBoatData(context.Background(), []SimBoatDataReq { { boatKey, true } })
+	if resps[boatKey].Lat != 10.0 {
+		t.Errorf("Boat data not received from legacy simulator!")
+	}
+	if v := simProtoVersion(legacy.HostPort); v != SIM_PROTO_LEGACY {
+		t.Errorf("Unexpected protocol version for legacy simulator: %d", v)
+	}
+	if _, _, ok := legacy.GetBoatEvents(0); ok {
+		t.Errorf("Boat events from legacy simulator!")
+	}
+
+	current := &TcpSimClient { HostPort: mockSimStart() }
+	defer forgetSimProtoVersion(current.HostPort)
+
+	resps, _ = current.GetBoatData(context.Background(), []SimBoatDataReq { { boatKey, true } })
+	if resps[boatKey].Ext == nil {
+		t.Errorf("Extended boat data not received from current simulator!")
+	}
+	if v := simProtoVersion(current.HostPort); v != SIM_PROTO_MAX {
+		t.Errorf("Unexpected protocol version for current simulator: %d", v)
+	}
+	if _, _, ok := current.GetBoatEvents(0); !ok {
+		t.Errorf("No boat events from current simulator!")
+	}
+}
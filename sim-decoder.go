@@ -0,0 +1,508 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+
+// Simulator response decoding:
+//
+// Responses from the simulator are comma-separated lines. They're decoded
+// here, with the number of fields, the format of keys and IDs, and the ranges
+// of values checked, so that a malformed line (e.g. one truncated mid-stream,
+// with fields missing or run together with the next line's) results in a
+// *SimDecodeError (counted as a parse error, and as a rejected line by reason)
+// rather than a panic or nonsense being sent to clients. Numeric ranges are
+// generous, and only meant to reject values that can't be right (including
+// NaN and infinities, which can't be encoded as JSON). Lines with a fixed
+// number of fields must have exactly that many, and boat data responses must
+// also be for the boat (and of the type) requested at that point of the
+// stream, as otherwise the responses after them can't be trusted either.
+
+const SIM_STATUS_OK = "ok"
+const SIM_STATUS_NOBOAT = "noboat"
+
+const SIM_MAX_SPEED = 1000.0 // Knots, for boat, wind and current speeds
+
+// Number of fields in a "bd_nc" (or "bdx", without extended data) response line with status "ok", and with "noboat"
+const SIM_BOAT_DATA_FIELDS = 11
+const SIM_STATUS_FIELDS = 3
+
+// Reasons for rejecting a line (see above)
+const SIM_REJECT_FIELDS = 0 // Missing or extra fields
+const SIM_REJECT_FORMAT = 1 // Malformed boat key, spectator ID, etc.
+const SIM_REJECT_NUMBER = 2 // Not a number
+const SIM_REJECT_RANGE = 3 // Number out of range
+const SIM_REJECT_TYPE = 4 // Unexpected response type or status
+const SIM_REJECT_FRAMING = 5 // Response not for what was requested at that point
+const SIM_REJECT_COUNT = 6
+
+var _simRejectNames = [SIM_REJECT_COUNT]string { "fields", "format", "number", "range", "type", "framing" }
+
+var _simRejectCounts [SIM_REJECT_COUNT]int64
+
+type SimDecodeError struct {
+	Line string
+	Field int // Index of the offending field
+	Kind int // SIM_REJECT_*
+	Reason string
+}
+
+// Decodes the fields of one line, remembering the first error encountered.
+type SimLineDecoder struct {
+	line string
+	fields []string
+	err *SimDecodeError
+}
+
+// A decoded "bd_nc" or "bdx" response line
+type SimBoatDataLine struct {
+	Cmd string
+	BoatKey string
+	Status string
+	Data BoatDataLiveRespMsg // Only for SIM_STATUS_OK
+}
+
+
+func (e *SimDecodeError) Error() string {
+	return "Invalid response from simulator (field " + strconv.Itoa(e.Field) + ": " + e.Reason + "): " + e.Line
+}
+
+func newSimLineDecoder(line string) *SimLineDecoder {
+	return &SimLineDecoder {
+		line: line,
+		fields: strings.Split(line, ","),
+	}
+}
+
+func newSimDecodeError(line string, i int, kind int, reason string) *SimDecodeError {
+	atomic.AddInt64(&_simRejectCounts[kind], 1)
+	return &SimDecodeError { line, i, kind, reason }
+}
+
+func (d *SimLineDecoder) fail(i int, kind int, reason string) {
+	if d.err == nil {
+		d.err = newSimDecodeError(d.line, i, kind, reason)
+	}
+}
+
+// Checks that the line has one of the given numbers of fields.
+func (d *SimLineDecoder) Count(counts ...int) {
+	for _, n := range counts {
+		if len(d.fields) == n {
+			return
+		}
+	}
+
+	if len(d.fields) > counts[len(counts) - 1] {
+		d.fail(len(d.fields) - 1, SIM_REJECT_FIELDS, "unexpected field")
+	} else {
+		d.fail(len(d.fields), SIM_REJECT_FIELDS, "missing")
+	}
+}
+
+// Returns the first error, if any (and a nil error, rather than a nil *SimDecodeError, if none).
+func (d *SimLineDecoder) Err() error {
+	if d.err == nil {
+		return nil
+	}
+	return d.err
+}
+
+func (d *SimLineDecoder) NumFields() int {
+	return len(d.fields)
+}
+
+func (d *SimLineDecoder) String(i int) string {
+	if i >= len(d.fields) {
+		d.fail(i, SIM_REJECT_FIELDS, "missing")
+		return ""
+	}
+	return d.fields[i]
+}
+
+func (d *SimLineDecoder) BoatKey(i int) string {
+	s := d.String(i)
+	if d.err == nil && !_boatKeyRegexp.MatchString(s) {
+		d.fail(i, SIM_REJECT_FORMAT, "invalid boat key")
+	}
+	return s
+}
+
+func (d *SimLineDecoder) Float(i int, min float64, max float64) float64 {
+	s := d.String(i)
+	if d.err != nil {
+		return 0.0
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		d.fail(i, SIM_REJECT_NUMBER, "not a number")
+		return 0.0
+	}
+
+	if v < min || v > max {
+		d.fail(i, SIM_REJECT_RANGE, "out of range")
+		return 0.0
+	}
+
+	return v
+}
+
+func (d *SimLineDecoder) Int(i int, min int64, max int64) int64 {
+	s := d.String(i)
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		d.fail(i, SIM_REJECT_NUMBER, "not an integer")
+		return 0
+	}
+
+	if v < min || v > max {
+		d.fail(i, SIM_REJECT_RANGE, "out of range")
+		return 0
+	}
+
+	return v
+}
+
+
+// Decodes a response line to a "bd_nc" or "bdx" request.
+func decodeBoatDataLine(line string) (*SimBoatDataLine, error) {
+	d := newSimLineDecoder(line)
+
+	r := &SimBoatDataLine {
+		Cmd: d.String(0),
+		BoatKey: d.BoatKey(1),
+		Status: d.String(2),
+	}
+
+	if d.err == nil && r.Cmd != "bd_nc" && r.Cmd != "bdx" {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+
+	if d.err == nil && r.Status == SIM_STATUS_OK {
+		r.Data = BoatDataLiveRespMsg {
+			Lat: d.Float(3, -90.0, 90.0),
+			Lon: d.Float(4, -360.0, 360.0),
+			Ctw: d.Float(5, -360.0, 360.0),
+			Stw: d.Float(6, 0.0, SIM_MAX_SPEED),
+			Cog: d.Float(7, -360.0, 360.0),
+			Sog: d.Float(8, 0.0, SIM_MAX_SPEED),
+			Lws: d.Float(9, 0.0, SIM_MAX_SPEED),
+			Ha: d.Float(10, -360.0, 360.0),
+		}
+
+		if r.Cmd == "bdx" {
+			r.Data.Ext = decodeBoatDataExt(d) // See boat-data-ext.go.
+		} else {
+			d.Count(SIM_BOAT_DATA_FIELDS)
+		}
+	} else if d.err == nil && r.Status == SIM_STATUS_NOBOAT {
+		d.Count(SIM_STATUS_FIELDS)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return r, nil
+}
+
+// Decodes the response line to an "action" request for a boat, returning its status, and the reason if rejected.
+func decodeActionLine(line string, boatKey string) (string, string, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "action" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	if d.BoatKey(1) != boatKey && d.err == nil {
+		d.fail(1, SIM_REJECT_FRAMING, "response for another boat")
+	}
+	status := d.String(2)
+
+	reason := ""
+	switch status {
+	case SIM_STATUS_OK, SIM_STATUS_NOBOAT:
+		d.Count(SIM_STATUS_FIELDS)
+	case SIM_ACTION_REJECTED:
+		// The reason is everything after the status, and so may contain commas.
+		d.String(3)
+		if d.err == nil {
+			reason = strings.SplitN(line, ",", 4)[3]
+		}
+	default:
+		if d.err == nil {
+			d.fail(2, SIM_REJECT_FORMAT, "unknown status")
+		}
+	}
+
+	if d.err != nil {
+		return "", "", d.err
+	}
+	return status, reason, nil
+}
+
+// Decodes the first response line to a "boatgroupmembers" request, returning its status.
+func decodeGroupHeaderLine(line string) (string, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "boatgroupmembers" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	d.BoatKey(1)
+	status := d.String(2)
+	if status == SIM_STATUS_OK {
+		d.Count(SIM_STATUS_FIELDS)
+	}
+
+	return status, d.Err()
+}
+
+// Decodes a group member line ("<boat_key>,<name>") of a "boatgroupmembers" response.
+// Boats with a name of "!" aren't to be shown to others, and are returned as nil.
+func decodeGroupMemberLine(line string) (*BoatInfo, error) {
+	d := newSimLineDecoder(line)
+
+	boatKey := d.BoatKey(0)
+
+	// The name is everything after the boat key, and so may contain commas.
+	name := ""
+	if d.err == nil && d.NumFields() > 1 {
+		name = line[len(boatKey) + 1:]
+	}
+	if name == "" {
+		d.fail(1, SIM_REJECT_FIELDS, "missing")
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if name == "!" {
+		return nil, nil
+	}
+
+	return &BoatInfo { BoatKey: boatKey, FriendlyName: name }, nil
+}
+
+// Decodes a response line to a "spectatorboat" request, returning its status and (if "ok") the boat key.
+func decodeSpectatorLine(line string) (string, string, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "spectatorboat" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	status := d.String(2)
+
+	boatKey := ""
+	if status == SIM_STATUS_OK {
+		boatKey = d.BoatKey(3)
+		d.Count(4)
+	}
+
+	return status, boatKey, d.Err()
+}
+
+// Decodes a response line to a "version" request, returning its status and (if "ok") the simulator's protocol version.
+func decodeVersionLine(line string) (string, int, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "version" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	status := d.String(2)
+
+	version := 0
+	if status == SIM_STATUS_OK {
+		version = int(d.Int(3, SIM_PROTO_LEGACY, math.MaxInt32))
+		d.Count(4)
+	}
+
+	return status, version, d.Err()
+}
+
+// Decodes the response to a "mux,on" request (see sim-mux.go), returning its status.
+func decodeMuxOnLine(line string) (string, error) {
+	d := newSimLineDecoder(line)
+
+	if (d.String(0) != "mux" || d.String(1) != SIM_MUX_ON) && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	status := d.String(2)
+	d.Count(SIM_STATUS_FIELDS)
+
+	return status, d.Err()
+}
+
+// Splits a line from a multiplexed connection ("#<id>,<line>") into its request ID and the line itself.
+func decodeMuxLine(line string) (uint64, string, error) {
+	prefix, rest, found := strings.Cut(line, ",")
+	if !found || !strings.HasPrefix(prefix, "#") {
+		return 0, "", newSimDecodeError(line, 0, SIM_REJECT_FRAMING, "not a multiplexed response")
+	}
+
+	id, err := strconv.ParseUint(prefix[1:], 10, 64)
+	if err != nil {
+		return 0, "", newSimDecodeError(line, 0, SIM_REJECT_NUMBER, "invalid request ID")
+	}
+
+	return id, rest, nil
+}
+
+// Decodes the first response line to a "boatevents" request, returning its status and (if "ok") the latest event ID.
+func decodeBoatEventsHeaderLine(line string) (string, int64, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "boatevents" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	status := d.String(2)
+
+	var latest int64 = 0
+	if status == SIM_STATUS_OK {
+		latest = d.Int(3, 0, math.MaxInt64)
+		d.Count(4)
+	}
+
+	return status, latest, d.Err()
+}
+
+// Decodes an event line ("<id>,<boat_key>,<event>,<time>[,<detail>]") of a "boatevents" response,
+// where <time> is a Unix time (seconds), and <detail> (optional) may contain commas.
+func decodeBoatEventLine(line string) (*SimBoatEvent, error) {
+	d := newSimLineDecoder(line)
+
+	ev := &SimBoatEvent {
+		Id: d.Int(0, 1, math.MaxInt64),
+		BoatKey: d.BoatKey(1),
+		Event: d.String(2),
+		Time: d.Int(3, 0, math.MaxInt64 / 1000),
+	}
+
+	if d.err == nil && !_boatEventRegexp.MatchString(ev.Event) {
+		d.fail(2, SIM_REJECT_FORMAT, "invalid event type")
+	}
+
+	if d.err == nil && d.NumFields() > 4 {
+		ev.Detail = strings.SplitN(line, ",", 5)[4]
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return ev, nil
+}
+
+// Decodes the first response line to a "boatsinarea" request, returning its status.
+func decodeAreaHeaderLine(line string) (string, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "boatsinarea" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	status := d.String(5)
+	if status == SIM_STATUS_OK {
+		d.Count(6)
+	}
+
+	return status, d.Err()
+}
+
+// Decodes a boat line ("<spectator_id>,<lat>,<lon>,<cog>,<sog>,<name>") of a "boatsinarea" response,
+// where <name> may contain commas.
+func decodeAreaBoatLine(line string) (*SimAreaBoat, error) {
+	d := newSimLineDecoder(line)
+
+	b := &SimAreaBoat {
+		SpectatorId: d.String(0),
+		Lat: d.Float(1, -90.0, 90.0),
+		Lon: d.Float(2, -360.0, 360.0),
+		Cog: d.Float(3, -360.0, 360.0),
+		Sog: d.Float(4, 0.0, SIM_MAX_SPEED),
+	}
+
+	if d.err == nil && !_spectatorIdRegexp.MatchString(b.SpectatorId) {
+		d.fail(0, SIM_REJECT_FORMAT, "invalid spectator ID")
+	}
+
+	if d.err == nil && d.NumFields() > 5 {
+		b.Name = strings.SplitN(line, ",", 6)[5]
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return b, nil
+}
+
+// Decodes a response line to a "boatinfo" request ("boatinfo,<boat_key>,ok,<flag>,<type>,<name>",
+// where <flag> and <type> may be empty, and <name> may contain commas), returning its status and (if "ok") the boat's info.
+func decodeBoatInfoLine(line string) (string, *SimBoatInfo, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "boatinfo" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	d.BoatKey(1)
+	status := d.String(2)
+
+	var info *SimBoatInfo = nil
+	if status == SIM_STATUS_OK {
+		info = &SimBoatInfo {
+			Flag: d.String(3),
+			Type: d.String(4),
+		}
+		d.String(5)
+		if d.err == nil {
+			info.Name = strings.SplitN(line, ",", 6)[5]
+		}
+		if d.err == nil && info.Flag != "" && !_boatFlagRegexp.MatchString(info.Flag) {
+			d.fail(3, SIM_REJECT_FORMAT, "invalid flag")
+		}
+	}
+
+	if d.err != nil {
+		return "", nil, d.err
+	}
+	return status, info, nil
+}
+
+// Decodes an "ok" response line to a "wind" request, returning the wind direction and speed.
+func decodeWindLine(line string) (float64, float64, error) {
+	d := newSimLineDecoder(line)
+
+	if d.String(0) != "wind" && d.err == nil {
+		d.fail(0, SIM_REJECT_TYPE, "unexpected response type")
+	}
+	if d.String(3) != SIM_STATUS_OK && d.err == nil {
+		d.fail(3, SIM_REJECT_TYPE, "unexpected status")
+	}
+
+	dir := d.Float(4, -360.0, 360.0)
+	speed := d.Float(5, 0.0, SIM_MAX_SPEED)
+	d.Count(6)
+
+	return dir, speed, d.Err()
+}
@@ -0,0 +1,338 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sync/atomic"
+	"testing"
+)
+
+
+const TEST_SIM_KEY = "0123456789abcdef0123456789abcdef"
+
+func TestDecodeBoatDataLine(t *testing.T) {
+	r, err := decodeBoatDataLine("bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := BoatDataLiveRespMsg { Lat: 45.5, Lon: -30.25, Ctw: 123.0, Stw: 5.5, Cog: 125.0, Sog: 6.0, Lws: 12.5, Ha: 45.0 }
+	if r.BoatKey != TEST_SIM_KEY || r.Status != SIM_STATUS_OK || r.Data != expected {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+
+	r, err = decodeBoatDataLine("bdx," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,88,15.5,0.4,3,-5,180,0.7,up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Data.Ext == nil || r.Data.Ext.Heel != 15.5 || r.Data.Ext.Sail != "up" {
+		t.Errorf("Unexpected extended data: %+v", r.Data.Ext)
+	}
+
+	// Invalid extended data is left out, rather than failing the whole line.
+	r, err = decodeBoatDataLine("bdx," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,88,NaN,0.4,3,-5,180,0.7,up")
+	if err != nil || r.Data.Ext != nil {
+		t.Errorf("Unexpected result for invalid extended data: %+v, %v", r, err)
+	}
+
+	r, err = decodeBoatDataLine("bd_nc," + TEST_SIM_KEY + ",noboat")
+	if err != nil || r.Status != SIM_STATUS_NOBOAT {
+		t.Errorf("Unexpected result for noboat: %+v, %v", r, err)
+	}
+
+	invalid := []string {
+		"",
+		"bd_nc",
+		"bd_nc," + TEST_SIM_KEY,
+		"bd_nc,not-a-key,ok,45.5,-30.25,123,5.5,125,6,12.5,45",
+		"bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5",
+		"bd_nc," + TEST_SIM_KEY + ",ok,95.0,-30.25,123,5.5,125,6,12.5,45",
+		"bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,NaN,125,6,12.5,45",
+		"bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,+Inf,12.5,45",
+		"bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,abc,45",
+		"wind," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45",
+		"bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,7",
+		"bd_nc," + TEST_SIM_KEY + ",noboat,45.5",
+		"bdx," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,88,15.5,0.4",
+		"bdx," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,88,15.5,0.4,3,-5,180,0.7,up,x",
+	}
+	for _, line := range invalid {
+		_, err := decodeBoatDataLine(line)
+		if err == nil {
+			t.Errorf("Expected error for line: %q", line)
+		}
+	}
+}
+
+func TestDecodeGroupLines(t *testing.T) {
+	status, err := decodeGroupHeaderLine("boatgroupmembers," + TEST_SIM_KEY + ",ok")
+	if err != nil || status != SIM_STATUS_OK {
+		t.Errorf("Unexpected result for group header: %s, %v", status, err)
+	}
+
+	_, err = decodeGroupHeaderLine("boatgroupmembers," + TEST_SIM_KEY)
+	if err == nil {
+		t.Error("Expected error for short group header")
+	}
+
+	boat, err := decodeGroupMemberLine(TEST_SIM_KEY + ",Sea, Breeze")
+	if err != nil || boat == nil || boat.BoatKey != TEST_SIM_KEY || boat.FriendlyName != "Sea, Breeze" {
+		t.Errorf("Unexpected result for group member: %+v, %v", boat, err)
+	}
+
+	boat, err = decodeGroupMemberLine(TEST_SIM_KEY + ",!")
+	if err != nil || boat != nil {
+		t.Errorf("Unexpected result for hidden group member: %+v, %v", boat, err)
+	}
+
+	for _, line := range []string { TEST_SIM_KEY, TEST_SIM_KEY + ",", "not-a-key,Name" } {
+		_, err = decodeGroupMemberLine(line)
+		if err == nil {
+			t.Errorf("Expected error for group member line: %q", line)
+		}
+	}
+}
+
+func TestDecodeWindAndSpectatorLines(t *testing.T) {
+	dir, speed, err := decodeWindLine("wind,45.0000,-30.0000,ok,270,15.5")
+	if err != nil || dir != 270.0 || speed != 15.5 {
+		t.Errorf("Unexpected result for wind: %v, %v, %v", dir, speed, err)
+	}
+
+	for _, line := range []string { "wind,45.0000,-30.0000,ok,270", "wind,45.0000,-30.0000,error,270,15.5", "wind,45.0000,-30.0000,ok,270,-1" } {
+		_, _, err = decodeWindLine(line)
+		if err == nil {
+			t.Errorf("Expected error for wind line: %q", line)
+		}
+	}
+
+	status, boatKey, err := decodeSpectatorLine("spectatorboat,abc,ok," + TEST_SIM_KEY)
+	if err != nil || status != SIM_STATUS_OK || boatKey != TEST_SIM_KEY {
+		t.Errorf("Unexpected result for spectator: %s, %s, %v", status, boatKey, err)
+	}
+
+	_, _, err = decodeSpectatorLine("spectatorboat,abc,ok")
+	if err == nil {
+		t.Error("Expected error for spectator line without boat key")
+	}
+}
+
+func TestDecodeBoatEventLines(t *testing.T) {
+	status, latest, err := decodeBoatEventsHeaderLine("boatevents,0,ok,42")
+	if err != nil || status != SIM_STATUS_OK || latest != 42 {
+		t.Errorf("Unexpected result for boat events header: %s, %d, %v", status, latest, err)
+	}
+
+	_, _, err = decodeBoatEventsHeaderLine("boatevents,0,ok,-1")
+	if err == nil {
+		t.Error("Expected error for boat events header with negative ID")
+	}
+
+	ev, err := decodeBoatEventLine("43," + TEST_SIM_KEY + ",damage,1767225600,Spinnaker, torn")
+	if err != nil || ev.Id != 43 || ev.BoatKey != TEST_SIM_KEY || ev.Event != BOAT_EVENT_DAMAGE || ev.Time != 1767225600 || ev.Detail != "Spinnaker, torn" {
+		t.Errorf("Unexpected result for boat event: %+v, %v", ev, err)
+	}
+
+	ev, err = decodeBoatEventLine("44," + TEST_SIM_KEY + ",finished,1767225601")
+	if err != nil || ev.Event != BOAT_EVENT_FINISHED || ev.Detail != "" {
+		t.Errorf("Unexpected result for boat event without detail: %+v, %v", ev, err)
+	}
+
+	for _, line := range []string { "0," + TEST_SIM_KEY + ",aground,1", "45,not-a-key,aground,1", "46," + TEST_SIM_KEY + ",Aground!,1", "47," + TEST_SIM_KEY + ",aground" } {
+		_, err = decodeBoatEventLine(line)
+		if err == nil {
+			t.Errorf("Expected error for boat event line: %q", line)
+		}
+	}
+}
+
+func TestDecodeAreaLines(t *testing.T) {
+	status, err := decodeAreaHeaderLine("boatsinarea,40,-40,50,-30,ok")
+	if err != nil || status != SIM_STATUS_OK {
+		t.Errorf("Unexpected result for area header: %s, %v", status, err)
+	}
+
+	b, err := decodeAreaBoatLine("spec-1,45.5,-35.25,123,5.5,Boaty, the boat")
+	if err != nil || b.SpectatorId != "spec-1" || b.Lat != 45.5 || b.Lon != -35.25 || b.Cog != 123.0 || b.Sog != 5.5 || b.Name != "Boaty, the boat" {
+		t.Errorf("Unexpected result for area boat: %+v, %v", b, err)
+	}
+
+	for _, line := range []string { "spec 1,45.5,-35.25,123,5.5,Boat", "spec-1,95,-35.25,123,5.5,Boat", "spec-1,45.5,-35.25,123,-1,Boat", "spec-1,45.5,-35.25" } {
+		_, err = decodeAreaBoatLine(line)
+		if err == nil {
+			t.Errorf("Expected error for area boat line: %q", line)
+		}
+	}
+}
+
+func TestDecodeBoatInfoLine(t *testing.T) {
+	status, info, err := decodeBoatInfoLine("boatinfo," + TEST_SIM_KEY + ",ok,FR,Class 40,Boat, with comma")
+	if err != nil || status != SIM_STATUS_OK || *info != (SimBoatInfo { Name: "Boat, with comma", Flag: "FR", Type: "Class 40" }) {
+		t.Errorf("Unexpected result for boat info: %s, %+v, %v", status, info, err)
+	}
+
+	status, info, err = decodeBoatInfoLine("boatinfo," + TEST_SIM_KEY + ",ok,,,Boat")
+	if err != nil || *info != (SimBoatInfo { Name: "Boat" }) {
+		t.Errorf("Unexpected result for boat info without flag or type: %+v, %v", info, err)
+	}
+
+	status, info, err = decodeBoatInfoLine("boatinfo," + TEST_SIM_KEY + ",noboat")
+	if err != nil || status != SIM_STATUS_NOBOAT || info != nil {
+		t.Errorf("Unexpected result for noboat: %s, %+v, %v", status, info, err)
+	}
+
+	for _, line := range []string { "boatinfo," + TEST_SIM_KEY + ",ok,FR,Cruiser", "boatinfo," + TEST_SIM_KEY + ",ok,France,,Boat", "boatinfo,nope,ok,FR,,Boat", "bd_nc," + TEST_SIM_KEY + ",ok,FR,,Boat" } {
+		_, _, err = decodeBoatInfoLine(line)
+		if err == nil {
+			t.Errorf("Expected error for boat info line: %q", line)
+		}
+	}
+}
+
+func TestDecodeMuxLine(t *testing.T) {
+	id, line, err := decodeMuxLine("#42,bd_nc," + TEST_SIM_KEY + ",noboat")
+	if err != nil || id != 42 || line != "bd_nc," + TEST_SIM_KEY + ",noboat" {
+		t.Errorf("Unexpected result for multiplexed line: %d, %q, %v", id, line, err)
+	}
+
+	id, line, err = decodeMuxLine("#7,")
+	if err != nil || id != 7 || line != "" {
+		t.Errorf("Unexpected result for multiplexed empty line: %d, %q, %v", id, line, err)
+	}
+
+	for _, line := range []string { "bd_nc," + TEST_SIM_KEY + ",noboat", "#x,error", "#-1,error", "#42" } {
+		if _, _, err := decodeMuxLine(line); err == nil {
+			t.Errorf("Expected error for multiplexed line: %q", line)
+		}
+	}
+
+	if status, err := decodeMuxOnLine("mux,on,ok"); err != nil || status != SIM_STATUS_OK {
+		t.Errorf("Unexpected result for mux response: %s, %v", status, err)
+	}
+	if _, err := decodeMuxOnLine("version,5,ok,5"); err == nil {
+		t.Errorf("Expected error for unexpected mux response")
+	}
+}
+
+func TestDecodeActionLine(t *testing.T) {
+	status, reason, err := decodeActionLine("action," + TEST_SIM_KEY + ",ok", TEST_SIM_KEY)
+	if err != nil || status != SIM_STATUS_OK || reason != "" {
+		t.Errorf("Unexpected result for action: %s, %q, %v", status, reason, err)
+	}
+
+	status, reason, err = decodeActionLine("action," + TEST_SIM_KEY + ",rejected,Aground, can't steer", TEST_SIM_KEY)
+	if err != nil || status != SIM_ACTION_REJECTED || reason != "Aground, can't steer" {
+		t.Errorf("Unexpected result for rejected action: %s, %q, %v", status, reason, err)
+	}
+
+	status, _, err = decodeActionLine("action," + TEST_SIM_KEY + ",noboat", TEST_SIM_KEY)
+	if err != nil || status != SIM_STATUS_NOBOAT {
+		t.Errorf("Unexpected result for noboat: %s, %v", status, err)
+	}
+
+	otherKey := "fedcba9876543210fedcba9876543210"
+	for _, line := range []string { "action," + TEST_SIM_KEY + ",ok,extra", "action," + TEST_SIM_KEY + ",rejected", "action," + TEST_SIM_KEY + ",maybe", "action," + otherKey + ",ok", "boatinfo," + TEST_SIM_KEY + ",ok" } {
+		_, _, err = decodeActionLine(line, TEST_SIM_KEY)
+		if err == nil {
+			t.Errorf("Expected error for action line: %q", line)
+		}
+	}
+}
+
+func TestDecodeVersionLine(t *testing.T) {
+	status, version, err := decodeVersionLine("version,2,ok,3")
+	if err != nil || status != SIM_STATUS_OK || version != 3 {
+		t.Errorf("Unexpected result for version: %s, %d, %v", status, version, err)
+	}
+
+	for _, line := range []string { "version,2,ok", "version,2,ok,0", "bd_nc,2,ok,2" } {
+		_, _, err = decodeVersionLine(line)
+		if err == nil {
+			t.Errorf("Expected error for version line: %q", line)
+		}
+	}
+}
+
+func FuzzDecodeBoatDataLine(f *testing.F) {
+	f.Add("bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45")
+	f.Add("bdx," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,88,15.5,0.4,3,-5,180,0.7,up")
+	f.Add("bd_nc," + TEST_SIM_KEY + ",noboat")
+	f.Add("bd_nc,,ok,,,")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		r, err := decodeBoatDataLine(line)
+		if (r == nil) == (err == nil) {
+			t.Fatalf("Expected exactly one of result and error: %+v, %v", r, err)
+		}
+
+		if r != nil && r.Status == SIM_STATUS_OK {
+			// Anything decoded must be sendable to clients.
+			_, err = json.Marshal(createBoatDataExtRespMsg(r.Data))
+			if err != nil {
+				t.Fatalf("Decoded data can't be encoded: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzDecodeGroupMemberLine(f *testing.F) {
+	f.Add(TEST_SIM_KEY + ",Sea Breeze")
+	f.Add(TEST_SIM_KEY + ",!")
+	f.Add(TEST_SIM_KEY)
+
+	f.Fuzz(func(t *testing.T, line string) {
+		boat, err := decodeGroupMemberLine(line)
+		if boat != nil && (err != nil || !_boatKeyRegexp.MatchString(boat.BoatKey) || boat.FriendlyName == "") {
+			t.Fatalf("Invalid group member decoded: %+v, %v", boat, err)
+		}
+	})
+}
+
+func FuzzDecodeWindLine(f *testing.F) {
+	f.Add("wind,45.0000,-30.0000,ok,270,15.5")
+	f.Add("wind,45.0000,-30.0000,ok,270")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		dir, speed, err := decodeWindLine(line)
+		if err == nil && (math.IsNaN(dir) || math.IsInf(dir, 0) || speed < 0.0 || speed > SIM_MAX_SPEED) {
+			t.Fatalf("Invalid wind decoded: %v, %v", dir, speed)
+		}
+	})
+}
+
+func TestSimRejectCounts(t *testing.T) {
+	lines := map[int]string {
+		SIM_REJECT_FIELDS: "bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,45,bd_nc",
+		SIM_REJECT_FORMAT: "bd_nc,not-a-key,noboat",
+		SIM_REJECT_NUMBER: "bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,12bd_nc,5.5,125,6,12.5,45",
+		SIM_REJECT_RANGE: "bd_nc," + TEST_SIM_KEY + ",ok,45.5,-30.25,123,5.5,125,6,12.5,4500",
+		SIM_REJECT_TYPE: "bd_xx," + TEST_SIM_KEY + ",noboat",
+	}
+
+	for kind, line := range lines {
+		before := atomic.LoadInt64(&_simRejectCounts[kind])
+		_, err := decodeBoatDataLine(line)
+		if e, ok := err.(*SimDecodeError); !ok || e.Kind != kind {
+			t.Errorf("Unexpected error for line %q: %v", line, err)
+		}
+		if atomic.LoadInt64(&_simRejectCounts[kind]) == before {
+			t.Errorf("Rejection not counted as %s", _simRejectNames[kind])
+		}
+	}
+}
@@ -0,0 +1,336 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Multiplexed simulator connections:
+//
+// A simulator with protocol version 5 or later (see sim-version.go) may be
+// asked to switch a connection to multiplexed mode with "mux,on" (answered
+// with "mux,on,ok"). Every request line is then sent as "#<id>,<request>",
+// and every response line (including the empty line ending a multi-line
+// response) comes back as "#<id>,<line>", with the ID chosen here, so that
+// the simulator may answer requests in any order, and even interleave the
+// lines of its responses.
+//
+// Boat data polls and group lookups then share one persistent connection per
+// simulator, rather than each dialing their own: all of an iteration's boat
+// data requests are written at once, and each response is matched to its
+// request by ID as it arrives. Other (less frequent) requests still use a
+// connection each. Each request waits at most CONN_RW_TIMEOUT for its
+// response, and later lines for it are dropped. A multiplexed connection
+// that fails, or that outlives the check of the simulator's protocol version
+// (every SIM_PROTO_RECHECK), is replaced by a new one, with the old one only
+// closed once it's no longer in use.
+
+const SIM_MUX_ON = "on"
+
+type SimMux struct {
+	HostPort string
+	conn net.Conn
+	writeLock sync.Mutex // Serializes writes of batches of requests
+
+	lock sync.Mutex // Guards the fields below
+	pending map[uint64]*SimMuxReq // By request ID
+	nextId uint64
+	users int // Callers of mux() yet to release() the connection
+	retired bool // Replaced by a newer connection
+	closed bool
+	err error // Why the connection failed, if it did
+}
+
+// A request on a multiplexed connection, and the lines of its response so far
+type SimMuxReq struct {
+	id uint64
+	complete func(lines []string) bool // Whether the response is complete with the given lines
+	lines []string
+	done chan struct{} // Closed once the response is complete, or the connection fails
+	ok bool
+}
+
+type SimMuxSlot struct {
+	lock sync.Mutex // Held while connecting
+	mux *SimMux
+}
+
+var _simMuxLock sync.Mutex
+var _simMuxes = make(map[string]*SimMuxSlot) // By host:port
+
+
+// Returns the simulator's multiplexed connection (connecting, if needed), whether any retries were needed to
+// connect, and whether it's to be used at all: not until the simulator is known to support it (as the usual
+// connection per request also checks its protocol version). The connection is nil if it's to be used, but
+// couldn't be established. Callers must release() it when done.
+func (c *TcpSimClient) mux(ctx context.Context) (*SimMux, bool, bool) {
+	version := simProtoVersion(c.HostPort)
+
+	_simMuxLock.Lock()
+	slot, exists := _simMuxes[c.HostPort]
+	if !exists {
+		slot = &SimMuxSlot {}
+		_simMuxes[c.HostPort] = slot
+	}
+	_simMuxLock.Unlock()
+
+	slot.lock.Lock()
+	defer slot.lock.Unlock()
+
+	// Checked before taking the connection into use, as it's then not used (nor released) at all.
+	if version == 0 || !simProtoSupports(version, "mux") {
+		if slot.mux != nil {
+			slot.mux.retire()
+			slot.mux = nil
+		}
+		return nil, false, false
+	}
+	if slot.mux != nil && !slot.mux.acquire() {
+		slot.mux.retire()
+		slot.mux = nil
+	}
+	if slot.mux != nil {
+		return slot.mux, false, true
+	}
+
+	conn, retried := c.dial(ctx)
+	if conn == nil {
+		return nil, retried, true
+	}
+
+	m := c.startMux(conn)
+	if m != nil {
+		m.acquire()
+		slot.mux = m
+	}
+	return m, retried, true
+}
+
+// Switches a new connection to multiplexed mode, returning nil (having closed the connection) on failure.
+func (c *TcpSimClient) startMux(conn net.Conn) *SimMux {
+	fmt.Fprintf(conn, "mux," + SIM_MUX_ON + "\n")
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		log.Println(err)
+		countSimIoError(err)
+		conn.Close()
+		return nil
+	}
+
+	status, err := decodeMuxOnLine(strings.Trim(line, "\n"))
+	if err != nil {
+		log.Println(err)
+		countSimResult(SIM_RESULT_PARSE_ERROR)
+		conn.Close()
+		return nil
+	}
+
+	if status != SIM_STATUS_OK {
+		log.Println("Unexpected code (\"" + status + "\") returned from simulator when asked to multiplex requests")
+		countSimResult(SIM_RESULT_ERROR)
+		conn.Close()
+		return nil
+	}
+
+	// Requests have their own timeouts from now on, as the connection may be idle between them.
+	conn.SetDeadline(time.Time {})
+
+	m := &SimMux {
+		HostPort: c.HostPort,
+		conn: conn,
+		pending: make(map[uint64]*SimMuxReq),
+	}
+	go m.read(reader)
+
+	return m
+}
+
+// Takes the connection into use, unless it's no longer usable.
+func (m *SimMux) acquire() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.closed || m.retired {
+		return false
+	}
+	m.users++
+	return true
+}
+
+func (m *SimMux) release() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.users--
+	m.closeIfRetired()
+}
+
+func (m *SimMux) retire() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.retired = true
+	m.closeIfRetired()
+}
+
+// Closes a replaced connection once it's no longer in use. Caller must hold m.lock.
+func (m *SimMux) closeIfRetired() {
+	if m.retired && m.users == 0 && !m.closed {
+		m.closed = true
+		m.conn.Close()
+	}
+}
+
+// Fails all pending requests, and closes the connection (unless already closed here, as it's then no failure).
+func (m *SimMux) fail(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.closed {
+		log.Println(err)
+		m.err = err
+		m.closed = true
+		m.conn.Close()
+
+		// The simulator may be restarting, possibly with another release.
+		forgetSimProtoVersion(m.HostPort)
+	}
+
+	for id, r := range m.pending {
+		delete(m.pending, id)
+		close(r.done)
+	}
+}
+
+// Reads responses, handing each line to the request it's for, until the connection fails or is closed.
+func (m *SimMux) read(reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			m.fail(err)
+			return
+		}
+
+		id, resp, err := decodeMuxLine(strings.Trim(line, "\n"))
+		if err != nil {
+			log.Println(err)
+			countSimResult(SIM_RESULT_PARSE_ERROR)
+			continue
+		}
+
+		m.lock.Lock()
+		if r, exists := m.pending[id]; exists {
+			r.lines = append(r.lines, resp)
+			if r.complete(r.lines) {
+				delete(m.pending, id)
+				r.ok = true
+				close(r.done)
+			}
+		} else {
+			log.Println("Response from simulator at " + m.HostPort + " for a request no longer waited on (ID " + strconv.FormatUint(id, 10) + ")")
+		}
+		m.lock.Unlock()
+	}
+}
+
+// Sends requests (without their newlines), each with the given check of whether its response is complete.
+func (m *SimMux) send(lines []string, complete func(lines []string) bool) ([]*SimMuxReq, error) {
+	var b strings.Builder
+	reqs := make([]*SimMuxReq, len(lines))
+
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil, net.ErrClosed
+	}
+	for i, line := range lines {
+		m.nextId++
+		reqs[i] = &SimMuxReq { id: m.nextId, complete: complete, done: make(chan struct{}) }
+		m.pending[m.nextId] = reqs[i]
+		b.WriteString("#" + strconv.FormatUint(m.nextId, 10) + "," + line + "\n")
+	}
+	m.lock.Unlock()
+
+	m.writeLock.Lock()
+	defer m.writeLock.Unlock()
+
+	// Responses are read concurrently, so even a large batch can't fill up the simulator's buffers.
+	m.conn.SetWriteDeadline(time.Now().Add(CONN_RW_TIMEOUT))
+	if _, err := m.conn.Write([]byte(b.String())); err != nil {
+		m.fail(err)
+		return nil, err
+	}
+
+	return reqs, nil
+}
+
+// Waits for the response to a request, returning its lines.
+func (m *SimMux) wait(ctx context.Context, r *SimMuxReq) ([]string, error) {
+	select {
+	case <-r.done:
+		if !r.ok {
+			m.lock.Lock()
+			defer m.lock.Unlock()
+			if m.err == nil {
+				return nil, net.ErrClosed
+			}
+			return nil, m.err
+		}
+		return r.lines, nil
+
+	case <-ctx.Done():
+		return nil, os.ErrDeadlineExceeded
+	}
+}
+
+// Stops waiting for the responses to requests (any not received yet being dropped when they arrive).
+func (m *SimMux) abandon(reqs []*SimMuxReq) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, r := range reqs {
+		delete(m.pending, r.id)
+	}
+}
+
+// Whether a single line response is complete.
+func simMuxSingleLine(lines []string) bool {
+	return true
+}
+
+// Whether a "boatgroupmembers" response is complete: after the empty line ending its members, if its status is "ok".
+func simMuxGroupMembersComplete(lines []string) bool {
+	if len(lines) == 1 {
+		fields := strings.Split(lines[0], ",")
+		return len(fields) < 3 || fields[0] != "boatgroupmembers" || fields[2] != SIM_STATUS_OK
+	}
+	return lines[len(lines) - 1] == ""
+}
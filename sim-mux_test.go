@@ -0,0 +1,244 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+// Serves one multiplexed connection, answering the given number of requests only once all have arrived, in reverse order.
+func testSimMuxServe(t *testing.T, ln net.Listener, n int) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "mux,on\n" {
+		t.Errorf("Unexpected request to switch to multiplexed mode: %q", line)
+		return
+	}
+	fmt.Fprintf(conn, "mux,on,ok\n")
+
+	var reqs []string
+	for len(reqs) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		reqs = append(reqs, strings.Trim(line, "\n"))
+	}
+
+	// A response for a request nobody is waiting for is just dropped.
+	fmt.Fprintf(conn, "#999,bd_nc,%s,noboat\n", testBoatKey(t, 0))
+
+	for i := len(reqs) - 1; i >= 0; i-- {
+		id, req, _ := strings.Cut(reqs[i], ",")
+		for _, respLine := range strings.Split(strings.TrimSuffix(mockSimRespond(req), "\n"), "\n") {
+			fmt.Fprintf(conn, "%s,%s\n", id, respLine)
+		}
+	}
+
+	// Keep the connection open until the client is done with it.
+	reader.ReadString('\n')
+}
+
+func TestTcpSimClientMux(t *testing.T) {
+	keys := []string { testBoatKey(t, 0), testBoatKey(t, 1), testBoatKey(t, 2) }
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go testSimMuxServe(t, ln, len(keys))
+
+	client := &TcpSimClient { HostPort: ln.Addr().String() }
+	setSimProtoVersion(client.HostPort, SIM_PROTO_MAX)
+	defer forgetSimProtoVersion(client.HostPort)
+
+	resps, noBoats := client.GetBoatData(context.Background(), []SimBoatDataReq { { keys[0], false }, { keys[1], true }, { keys[2], false } })
+	if len(resps) != len(keys) || len(noBoats) != 0 {
+		t.Fatalf("Unexpected responses over multiplexed connection: %v, %v", resps, noBoats)
+	}
+	if resps[keys[1]].Ext == nil || resps[keys[0]].Ext != nil {
+		t.Errorf("Responses not matched to requests by ID!")
+	}
+
+	_simMuxLock.Lock()
+	m := _simMuxes[client.HostPort].mux
+	_simMuxLock.Unlock()
+	if m == nil {
+		t.Fatalf("Multiplexed connection not kept!")
+	}
+
+	m.lock.Lock()
+	if m.users != 0 || len(m.pending) != 0 {
+		t.Errorf("Multiplexed connection left in use: %d users, %d pending", m.users, len(m.pending))
+	}
+	m.lock.Unlock()
+
+	// Once the simulator closes the connection, the version is asked again (on a connection of its own).
+	m.conn.Write([]byte("\n"))
+	for i := 0; i < 100; i++ {
+		m.lock.Lock()
+		closed := m.closed
+		m.lock.Unlock()
+		if closed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := simProtoVersion(client.HostPort); v != 0 {
+		t.Errorf("Protocol version kept after multiplexed connection failed: %d", v)
+	}
+
+	ln.Close()
+	if members := client.GetGroupMembers(context.Background(), keys[0]); members != nil {
+		t.Errorf("Group members from closed simulator: %v", members)
+	}
+}
+
+func TestTcpSimClientMuxGroupMembers(t *testing.T) {
+	boatKey := testBoatKey(t, 0)
+	client := &TcpSimClient { HostPort: mockSimStart() }
+	defer forgetSimProtoVersion(client.HostPort)
+
+	// The first request (without the protocol version known) uses a connection of its own.
+	expected := client.GetGroupMembers(context.Background(), boatKey)
+	if expected == nil || expected.Len() == 0 {
+		t.Fatalf("No group members from mock simulator!")
+	}
+
+	members := client.GetGroupMembers(context.Background(), boatKey)
+	if members == nil || members.Len() != expected.Len() {
+		t.Fatalf("Unexpected group members over multiplexed connection: %v", members)
+	}
+
+	_simMuxLock.Lock()
+	defer _simMuxLock.Unlock()
+	if _simMuxes[client.HostPort].mux == nil {
+		t.Errorf("No multiplexed connection used!")
+	}
+}
+
+func TestSimMuxGroupMembersComplete(t *testing.T) {
+	boatKey := testBoatKey(t, 0)
+
+	if simMuxGroupMembersComplete([]string { "boatgroupmembers," + boatKey + ",ok" }) {
+		t.Errorf("Group members response complete after header!")
+	}
+	if simMuxGroupMembersComplete([]string { "boatgroupmembers," + boatKey + ",ok", boatKey + ",Boat" }) {
+		t.Errorf("Group members response complete before empty line!")
+	}
+	if !simMuxGroupMembersComplete([]string { "boatgroupmembers," + boatKey + ",ok", boatKey + ",Boat", "" }) {
+		t.Errorf("Group members response not complete after empty line!")
+	}
+	if !simMuxGroupMembersComplete([]string { "boatgroupmembers," + boatKey + ",noboat" }) || !simMuxGroupMembersComplete([]string { "error" }) {
+		t.Errorf("Group members response without members not complete!")
+	}
+}
+
+func TestTcpSimClientMuxErrorResponse(t *testing.T) {
+	keys := []string { testBoatKey(t, 0), testBoatKey(t, 1), testBoatKey(t, 2) }
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Answers the first request with an error, and the second with a response for another boat.
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reader.ReadString('\n')
+		fmt.Fprintf(conn, "mux,on,ok\n")
+
+		for i := 0; i < len(keys); i++ {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			id, req, _ := strings.Cut(strings.Trim(line, "\n"), ",")
+
+			switch i {
+			case 0:
+				fmt.Fprintf(conn, "%s,error\n", id)
+			case 1:
+				fmt.Fprintf(conn, "%s,%s", id, mockSimRespond("bd_nc," + keys[0]))
+			default:
+				fmt.Fprintf(conn, "%s,%s", id, mockSimRespond(req))
+			}
+		}
+
+		reader.ReadString('\n')
+	}()
+
+	client := &TcpSimClient { HostPort: ln.Addr().String() }
+	setSimProtoVersion(client.HostPort, SIM_PROTO_MAX)
+	defer forgetSimProtoVersion(client.HostPort)
+
+	reqs := []SimBoatDataReq { { keys[0], false }, { keys[1], false }, { keys[2], false } }
+	resps, _ := client.GetBoatData(context.Background(), reqs)
+	if len(resps) != 1 {
+		t.Fatalf("Unexpected responses over multiplexed connection: %v", resps)
+	}
+	if _, exists := resps[keys[2]]; !exists {
+		t.Errorf("Independent response dropped after other requests' failures!")
+	}
+}
+
+func TestTcpSimClientMuxUnsupported(t *testing.T) {
+	client := &TcpSimClient { HostPort: "127.0.0.1:1" }
+	setSimProtoVersion(client.HostPort, 1)
+	defer forgetSimProtoVersion(client.HostPort)
+
+	conn, other := net.Pipe()
+	defer other.Close()
+	m := &SimMux { HostPort: client.HostPort, conn: conn, pending: make(map[uint64]*SimMuxReq) }
+
+	_simMuxLock.Lock()
+	_simMuxes[client.HostPort] = &SimMuxSlot { mux: m }
+	_simMuxLock.Unlock()
+
+	// A simulator no longer supporting multiplexing has its connection retired, without taking it into use.
+	if _, _, use := client.mux(context.Background()); use {
+		t.Fatalf("Multiplexed connection used with unsupported protocol version!")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.users != 0 || !m.closed {
+		t.Errorf("Multiplexed connection left in use: %d users, closed: %t", m.users, m.closed)
+	}
+}
@@ -0,0 +1,135 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+	"sailnavsim-snsw/internal/hub"
+)
+
+
+// Simulator outages:
+//
+// If there's no data for a boat key because the simulator couldn't be reached
+// or returned an error (e.g. while it's briefly restarting), rather than an
+// explicit "noboat", its connections are kept open for up to -sim-grace
+// consecutive iterations. They're told that their data is stale with a status
+// message ({"type":"status","stale":true,"missed":<n>}) on each such
+// iteration, and with {"type":"status","stale":false} once data is back.
+//
+// With -max-staleness, each boat's most recent data is also kept, and sent in
+// place of missing data (with an "age" field, in seconds) until it's older than
+// -max-staleness, so that clients' maps don't blank out during longer outages.
+// Connections are only closed once there's neither data nor recent enough last
+// known data, and -sim-grace iterations have passed.
+
+type StatusMsg struct {
+	Type string `json:"type"`
+	Stale bool `json:"stale"`
+	Missed int `json:"missed,omitempty"` // Consecutive iterations without data
+}
+
+// Consecutive iterations without data, by boat key (guarded by _lock)
+var _missedIters = make(map[string]int)
+
+// Most recent data for each tracked boat, if -max-staleness is set (only accessed from the main loop goroutine)
+var _lastKnownResps = make(map[string]BoatDataLiveRespMsg)
+
+
+// Called (with _lock held) after polling, to remember this iteration's data and fill in
+// any missing data with recent enough last known data. Returns the data to send.
+func withLastKnownResps(resps map[string]BoatDataLiveRespMsg, noBoats map[string]bool) map[string]BoatDataLiveRespMsg {
+	if _config.MaxStaleness <= 0 {
+		return resps
+	}
+
+	now := time.Now()
+	var filled map[string]BoatDataLiveRespMsg = nil
+
+	for boatKey, resp := range _lastKnownResps {
+		if _, exists := resps[boatKey]; exists {
+			continue
+		}
+
+		age := now.Sub(resp.ArrivedAt)
+		if _trackedBoats[boatKey] == nil || noBoats[boatKey] || age > _config.MaxStaleness {
+			delete(_lastKnownResps, boatKey)
+			continue
+		}
+
+		if filled == nil {
+			// Leave the caller's map alone, since it's also recorded and published as this iteration's data.
+			filled = make(map[string]BoatDataLiveRespMsg, len(resps) + len(_lastKnownResps))
+			for k, v := range resps {
+				filled[k] = v
+			}
+		}
+
+		resp.LastKnown = true
+		resp.Age = int64(age / time.Second)
+		if resp.Age < 1 {
+			resp.Age = 1
+		}
+		filled[boatKey] = resp
+	}
+
+	for boatKey, resp := range resps {
+		_lastKnownResps[boatKey] = resp
+	}
+
+	if filled == nil {
+		return resps
+	}
+	return filled
+}
+
+// Called (with _lock held) when there's no data for a subscribed boat key this iteration
+// (other than "noboat"), or only last known data. Returns whether its connections should
+// be kept open for now.
+func simMissed(boatKey string, conns []hub.Subscriber, lastKnown bool) bool {
+	missed := _missedIters[boatKey] + 1
+	if missed > _config.SimGrace && !lastKnown {
+		delete(_missedIters, boatKey)
+		return false
+	}
+
+	if missed == 1 {
+		log.Println("No data for boat key (allowing " + strconv.Itoa(_config.SimGrace) + " iterations): " + boatKey)
+	}
+	_missedIters[boatKey] = missed
+
+	sendStatusMsg(conns, &StatusMsg { Type: "status", Stale: true, Missed: missed })
+	return true
+}
+
+// Called (with _lock held) when there's data for a subscribed boat key this iteration.
+func simRecovered(boatKey string, conns []hub.Subscriber) {
+	if _, exists := _missedIters[boatKey]; !exists {
+		return
+	}
+
+	delete(_missedIters, boatKey)
+	sendStatusMsg(conns, &StatusMsg { Type: "status", Stale: false })
+}
+
+func sendStatusMsg(conns []hub.Subscriber, msg *StatusMsg) {
+	for _, conn := range conns {
+		conn.(*WsConn).SendJSON(msg) // Any failure will be picked up when next sending live data.
+	}
+}
@@ -0,0 +1,75 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+
+// Counts of simulator request outcomes, by category, to tell backend problems apart from client ones.
+// Boat data requests count one outcome per boat, while dial failures and timeouts count once per connection.
+
+const SIM_RESULT_OK = 0
+const SIM_RESULT_NOBOAT = 1
+const SIM_RESULT_PARSE_ERROR = 2
+const SIM_RESULT_TIMEOUT = 3
+const SIM_RESULT_DIAL_FAILURE = 4
+const SIM_RESULT_ERROR = 5 // "error" or unexpected response, or other I/O error
+const SIM_RESULT_COUNT = 6
+
+var _simResultNames = [SIM_RESULT_COUNT]string { "ok", "noboat", "parse_error", "timeout", "dial_failure", "error" }
+
+var _simResultCounts [SIM_RESULT_COUNT]int64
+
+// Retried simulator requests (see sim-client-tcp.go), and iterations whose boat data needed retries
+var _countSimRetries int64 = 0
+var _countDegradedIters int64 = 0
+
+// Whether the current iteration's boat data poll needed retries
+var _iterDegraded atomic.Bool
+
+
+func countSimResult(result int) {
+	atomic.AddInt64(&_simResultCounts[result], 1)
+}
+
+func countSimRetry() {
+	atomic.AddInt64(&_countSimRetries, 1)
+}
+
+// Called by a simulator backend when it needed retries to get boat data for the current iteration.
+func markIterDegraded() {
+	_iterDegraded.Store(true)
+}
+
+// Called by the main loop after polling for boat data, counting the iteration if it was degraded.
+func countIterDegraded() {
+	if _iterDegraded.Swap(false) {
+		atomic.AddInt64(&_countDegradedIters, 1)
+	}
+}
+
+// Counts an I/O error on a simulator connection, as either a timeout or other error.
+func countSimIoError(err error) {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		countSimResult(SIM_RESULT_TIMEOUT)
+	} else {
+		countSimResult(SIM_RESULT_ERROR)
+	}
+}
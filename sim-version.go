@@ -0,0 +1,157 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Simulator protocol versions:
+//
+// The first connection to a simulator starts with a "version,<max>" request,
+// where <max> is the latest protocol version supported here, expecting a
+// "version,<max>,ok,<version>" response with the simulator's own. Releases
+// predating the handshake answer "error", and are taken to use protocol 1.
+// Requests added in later versions (see _simProtoFeatures) are then left out,
+// or replaced by older ones, so that old and new releases can be used side by
+// side (e.g. with -sims). The version is asked again every SIM_PROTO_RECHECK,
+// and after a failed connection (as the simulator may be restarting with
+// another release).
+
+const SIM_PROTO_LEGACY = 1 // Releases without the handshake
+const SIM_PROTO_MAX = 5
+const SIM_PROTO_RECHECK = 1 * time.Minute
+
+type SimProto struct {
+	Version int
+	Checked time.Time
+}
+
+// The first protocol version supporting each optional request
+var _simProtoFeatures = map[string]int {
+	"bdx": 2,
+	"boatevents": 2,
+	"boatsinarea": 2,
+	"boatinfo": 3,
+	"action": 4,
+	"mux": 5,
+}
+
+var _simProtoLock sync.Mutex
+var _simProtos = make(map[string]SimProto) // By host:port
+
+
+// Returns a simulator's protocol version, or 0 if not (recently) known.
+func simProtoVersion(hostPort string) int {
+	_simProtoLock.Lock()
+	defer _simProtoLock.Unlock()
+
+	p, exists := _simProtos[hostPort]
+	if !exists || time.Since(p.Checked) > SIM_PROTO_RECHECK {
+		return 0
+	}
+	return p.Version
+}
+
+func setSimProtoVersion(hostPort string, version int) {
+	_simProtoLock.Lock()
+	defer _simProtoLock.Unlock()
+
+	if p, exists := _simProtos[hostPort]; !exists || p.Version != version {
+		log.Println("Simulator at " + hostPort + " uses protocol version " + strconv.Itoa(version))
+	}
+
+	_simProtos[hostPort] = SimProto { version, time.Now() }
+}
+
+// Forgets a simulator's protocol version, so that it's asked again on the next connection.
+func forgetSimProtoVersion(hostPort string) {
+	_simProtoLock.Lock()
+	defer _simProtoLock.Unlock()
+
+	delete(_simProtos, hostPort)
+}
+
+// Whether a simulator with the given protocol version supports a request.
+func simProtoSupports(version int, req string) bool {
+	first, exists := _simProtoFeatures[req]
+	return !exists || version >= first
+}
+
+// Asks the simulator for its protocol version on a new connection (unless already known), returning false on failure.
+func (c *TcpSimClient) handshake(conn net.Conn) bool {
+	if simProtoVersion(c.HostPort) != 0 {
+		return true
+	}
+
+	fmt.Fprintf(conn, "version," + strconv.Itoa(SIM_PROTO_MAX) + "\n")
+
+	// Nothing else is sent before the next request, so nothing is lost with this reader.
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		log.Println(err)
+		countSimIoError(err)
+		return false
+	}
+
+	line = strings.Trim(line, "\n")
+	if line == "error" {
+		setSimProtoVersion(c.HostPort, SIM_PROTO_LEGACY)
+		return true
+	}
+
+	status, version, err := decodeVersionLine(line)
+	if err != nil {
+		log.Println(err)
+		countSimResult(SIM_RESULT_PARSE_ERROR)
+		return false
+	}
+
+	if status != SIM_STATUS_OK {
+		log.Println("Unexpected code (\"" + status + "\") returned from simulator when asked for its protocol version")
+		countSimResult(SIM_RESULT_ERROR)
+		return false
+	}
+
+	setSimProtoVersion(c.HostPort, min(version, SIM_PROTO_MAX))
+	return true
+}
+
+// Connects to the simulator for an optional request, returning nil (without connecting, if its protocol version is already known) if it's not supported.
+func (c *TcpSimClient) dialFor(req string) net.Conn {
+	if version := simProtoVersion(c.HostPort); version != 0 && !simProtoSupports(version, req) {
+		return nil
+	}
+
+	conn, _ := c.dial(context.Background())
+	if conn != nil && !simProtoSupports(simProtoVersion(c.HostPort), req) {
+		conn.Close()
+		return nil
+	}
+
+	return conn
+}
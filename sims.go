@@ -0,0 +1,103 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+)
+
+
+// Multiple simulators:
+//
+// Besides the default simulator (given on the command line), any number of
+// named simulators may be configured with -sims, e.g. for a practice server
+// and a race server. Each request is directed to one simulator, chosen by its
+// "sim" field, or else by the endpoint's path ("/v1/ws/<sim>"), or else the
+// default one.
+//
+// Boat keys are random, so are assumed to be unique across simulators. Boats
+// are still tracked by boat key alone, each remembering the simulator it's
+// polled from (that of the first subscription to it).
+
+const DEFAULT_SIM = "default"
+
+const ERR_UNKNOWN_SIM = "unknown_sim"
+
+var _simNameRegexp *regexp.Regexp = regexp.MustCompile("^[0-9a-z_-]{1,32}$")
+
+
+// Parses simulators given as "<name>=<host:port>[,...]".
+func parseSims(s string) (map[string]string, error) {
+	sims := make(map[string]string)
+	if s == "" {
+		return sims, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[1] == "" || !_simNameRegexp.MatchString(kv[0]) {
+			return nil, errors.New("Invalid simulator: " + item)
+		}
+
+		if kv[0] == DEFAULT_SIM || kv[0] == "replay" {
+			return nil, errors.New("Reserved simulator name: " + kv[0])
+		}
+
+		if _, exists := sims[kv[0]]; exists {
+			return nil, errors.New("Duplicate simulator name: " + kv[0])
+		}
+
+		sims[kv[0]] = kv[1]
+	}
+
+	return sims, nil
+}
+
+// Returns the simulator selected by an endpoint's path, or "" if there's no such simulator.
+func simFromPath(path string) string {
+	sim := strings.Trim(strings.TrimPrefix(path, "/v1/ws"), "/")
+	if sim == "" {
+		return DEFAULT_SIM
+	}
+
+	if simClient(sim) == nil {
+		return ""
+	}
+	return sim
+}
+
+// Sets the simulator for a request (from its "sim" field, or else the endpoint's), returning false
+// (having closed the connection) if there's no such simulator.
+func resolveReqSim(req *ReqMsg, conn *WsConn, pathSim string) bool {
+	if req.Sim == "" {
+		req.Sim = pathSim
+		return true
+	}
+
+	if simClient(req.Sim) == nil {
+		log.Println("Client (" + conn.RemoteIp + ") requested unknown simulator: " + req.Sim)
+
+		sendErrorMsg(conn, ERR_UNKNOWN_SIM, "Unknown simulator")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Unknown simulator")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,149 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Spectator access:
+//
+// Instead of a (secret) boat key, a client may subscribe using a public
+// spectator ID, which is resolved to a boat key either via a mapping file
+// (lines of "<spectator_id>,<boat_key>") or by asking the simulator. Spectator
+// subscriptions are view-only, and receive data at reduced precision.
+
+// Boat data for spectators is rounded as if it were for another boat this far away (NM).
+const SPECTATOR_PRECISION_DIST = 5.0
+
+const SPECTATOR_MAP_CHECK_INTERVAL = 10 * time.Second
+
+const ERR_UNKNOWN_SPECTATOR_ID = "unknown_spectator_id"
+
+var _spectatorIdRegexp *regexp.Regexp = regexp.MustCompile("^[0-9A-Za-z_-]{1,64}$")
+
+var _spectatorLock sync.Mutex
+var _spectatorMap = make(map[string]string)
+var _spectatorMapModTime time.Time
+var _spectatorMapChecked time.Time
+
+
+// Resolves a spectator ID to a boat key, returning "" if it can't be resolved.
+func resolveSpectatorId(sim string, spectatorId string) string {
+	if !_spectatorIdRegexp.MatchString(spectatorId) {
+		return ""
+	}
+
+	if _config.SpectatorMapFile != "" {
+		boatKey := lookupSpectatorMap(spectatorId)
+		if boatKey != "" {
+			return boatKey
+		}
+	}
+
+	if _config.SpectatorSimLookup {
+		return simClient(sim).GetSpectatorBoat(spectatorId)
+	}
+
+	return ""
+}
+
+func lookupSpectatorMap(spectatorId string) string {
+	_spectatorLock.Lock()
+	defer _spectatorLock.Unlock()
+
+	// (Re)load the mapping file if it's changed since it was last loaded.
+	now := time.Now()
+	if now.Sub(_spectatorMapChecked) >= SPECTATOR_MAP_CHECK_INTERVAL {
+		_spectatorMapChecked = now
+
+		info, err := os.Stat(_config.SpectatorMapFile)
+		if err != nil {
+			log.Println(err)
+		} else if !info.ModTime().Equal(_spectatorMapModTime) {
+			m := loadSpectatorMap(_config.SpectatorMapFile)
+			if m != nil {
+				_spectatorMap = m
+				_spectatorMapModTime = info.ModTime()
+			}
+		}
+	}
+
+	return _spectatorMap[spectatorId]
+}
+
+func loadSpectatorMap(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer f.Close()
+
+	m := make(map[string]string)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s := strings.Split(line, ",")
+		if len(s) != 2 || !_spectatorIdRegexp.MatchString(s[0]) || !_boatKeyRegexp.MatchString(s[1]) {
+			log.Println("Ignoring invalid line in spectator map file: " + line)
+			continue
+		}
+
+		m[s[0]] = s[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	log.Printf("Loaded %d spectator IDs from %s\n", len(m), path)
+	return m
+}
+
+
+// Reduces the precision of a boat's data for spectators.
+func coarsenBoatData(data BoatDataLiveRespMsg) BoatDataLiveRespMsg {
+	return BoatDataLiveRespMsg {
+		Lat: roundCoord(data.Lat, SPECTATOR_PRECISION_DIST),
+		Lon: roundCoord(data.Lon, SPECTATOR_PRECISION_DIST),
+		Ctw: roundCourse(data.Ctw, SPECTATOR_PRECISION_DIST),
+		Stw: roundSpeed(data.Stw, SPECTATOR_PRECISION_DIST),
+		Cog: roundCourse(data.Cog, SPECTATOR_PRECISION_DIST),
+		Sog: roundSpeed(data.Sog, SPECTATOR_PRECISION_DIST),
+		Lws: math.Round(data.Lws),
+		Ha: math.Round(data.Ha),
+		Ts: data.Ts,
+		ArrivedAt: data.ArrivedAt,
+		Age: data.Age,
+		LastKnown: data.LastKnown,
+	}
+}
@@ -0,0 +1,131 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+
+// Cumulative statistics persistence:
+//
+// The cumulative counts of stats snapshots (see stats.go) start from zero
+// with each process, as Prometheus counters are expected to. For reporting
+// long-term usage, -stats-file keeps lifetime totals of connections and
+// messages (along with when the connector was first started, and how many
+// times it's been restarted since) in a small JSON file, which is loaded on
+// startup, and saved with every stats report and on shutdown. Lifetime
+// totals are reported alongside the usual counts (as the process's own
+// counts, if there's no stats file).
+
+const STATS_FILE_VERSION = 1
+
+type StatsTotals struct {
+	Version int `json:"version"`
+	FirstStart int64 `json:"first_start"` // Unix time
+	Restarts int64 `json:"restarts"`
+	Conns int64 `json:"conns"`
+	Msgs int64 `json:"msgs"`
+}
+
+// Totals as of this process's start (read-only once loaded)
+var _statsBase = StatsTotals { Version: STATS_FILE_VERSION, FirstStart: _startTime.Unix() }
+
+// Serializes saving (from the main loop, and on shutdown).
+var _statsFileLock sync.Mutex
+
+
+// Loads the lifetime totals (if enabled and saved). Called before the main loop starts.
+func statsFileInit() {
+	if _config.StatsFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(_config.StatsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return
+	}
+
+	var totals StatsTotals
+	err = json.Unmarshal(data, &totals)
+	if err != nil || totals.Version != STATS_FILE_VERSION || totals.FirstStart <= 0 || totals.Conns < 0 || totals.Msgs < 0 {
+		log.Println("Ignoring invalid stats file: " + _config.StatsFile)
+		return
+	}
+
+	totals.Restarts++
+	_statsBase = totals
+	log.Println("Loaded lifetime statistics, since " + time.Unix(totals.FirstStart, 0).UTC().Format(time.RFC3339) + ".")
+}
+
+// Adds this process's counts to the lifetime totals.
+func statsTotals(countConns int64, countMsgs int64) StatsTotals {
+	totals := _statsBase
+	totals.Conns += countConns
+	totals.Msgs += countMsgs
+	return totals
+}
+
+// Saves the lifetime totals of a snapshot, if enabled.
+func saveStatsFile(s *StatsSnapshot) {
+	if _config.StatsFile == "" {
+		return
+	}
+
+	data, err := json.Marshal(&s.Lifetime)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	_statsFileLock.Lock()
+	defer _statsFileLock.Unlock()
+
+	// Write to a temporary file, then rename it into place, so that a crash never leaves a partial file.
+	tmpPath := _config.StatsFile + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = os.Rename(tmpPath, _config.StatsFile)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Saves the lifetime totals on shutdown, if enabled.
+func saveStatsFileNow() {
+	if _config.StatsFile == "" {
+		return
+	}
+
+	_lock.Lock()
+	s := &StatsSnapshot { Lifetime: statsTotals(_countConns, _countMsgs) }
+	_lock.Unlock()
+
+	saveStatsFile(s)
+}
@@ -0,0 +1,66 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+
+func TestStatsFile(t *testing.T) {
+	prevFile := _config.StatsFile
+	_config.StatsFile = filepath.Join(t.TempDir(), "stats.json")
+	prevBase := _statsBase
+	defer func() {
+		_config.StatsFile = prevFile
+		_statsBase = prevBase
+	}()
+
+	// No file yet: totals are this process's counts.
+	statsFileInit()
+	if _statsBase.Restarts != 0 || _statsBase.Conns != 0 || _statsBase.FirstStart != _startTime.Unix() {
+		t.Fatalf("Unexpected base: %+v", _statsBase)
+	}
+
+	saveStatsFile(&StatsSnapshot { Lifetime: statsTotals(5, 100) })
+
+	// After a restart, counts continue from the saved totals.
+	_statsBase = StatsTotals {}
+	statsFileInit()
+	if _statsBase.Restarts != 1 || _statsBase.Conns != 5 || _statsBase.Msgs != 100 || _statsBase.FirstStart != _startTime.Unix() {
+		t.Fatalf("Unexpected base: %+v", _statsBase)
+	}
+
+	totals := statsTotals(2, 20)
+	if totals.Conns != 7 || totals.Msgs != 120 || totals.Restarts != 1 {
+		t.Fatalf("Unexpected totals: %+v", totals)
+	}
+
+	// Invalid files are ignored.
+	err := os.WriteFile(_config.StatsFile, []byte(`{"version":1,"first_start":1,"conns":-1}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_statsBase = prevBase
+	statsFileInit()
+	if _statsBase != prevBase {
+		t.Fatalf("Invalid stats file loaded: %+v", _statsBase)
+	}
+}
@@ -0,0 +1,354 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+
+// Statistics:
+//
+// Every -stats-interval iterations, the main loop takes a snapshot of its
+// statistics, which is then reported to each of the configured stats sinks
+// (the log, statsd, and/or Prometheus; see metrics.go).
+
+const STATS_SINK_LOG = "log"
+const STATS_SINK_STATSD = "statsd"
+const STATS_SINK_PROMETHEUS = "prometheus"
+
+type StatsSnapshot struct {
+	// Current values
+	Conns int
+	Keys int
+	Tracked int
+	Sessions int
+	DuplicateConns int
+	MemoryInUse int64 // Bytes (see memory.go)
+	MemoryLimit int64 // Bytes (0 if no soft limit)
+	MemoryPressure bool
+	NoboatKeys int // Boat keys on "noboat" cooldown (see deleted-boats.go)
+
+	// Cumulative counts
+	CountConns int64
+	CountMsgs int64
+	SharedMsgs int64
+	QueueDropped int64
+	QueueCoalesced int64
+	QueueConflated int64
+	QueueDisconnects int64
+	WriteTimeouts int64
+	SimResults [SIM_RESULT_COUNT]int64
+	SimRejected [SIM_REJECT_COUNT]int64 // Malformed response lines, by reason (see sim-decoder.go)
+	SimRetries int64
+	DegradedIters int64
+	IterOverruns int64
+	SkippedTicks int64
+	ShedLevel int // Subscriptions of lower priority than this are being degraded (see shedding.go)
+	ShedIters int64
+	ShedMsgs int64
+	ShedSubscribes int64
+	BoatDeletedCloses int64
+	NoboatRejects int64
+	IpRejects int64
+	Banned int
+	Bans int64
+	BanRejects int64
+	WebhooksSent int64
+	WebhookFailures int64
+	LatencyCounts [LATENCY_NUM_BUCKETS]int64 // Delivery latency histogram (see latency.go)
+	LatencySumUs int64
+
+	// Process start time, and lifetime totals across restarts (see stats-file.go)
+	StartTime time.Time
+	Lifetime StatsTotals
+
+	// Iteration times (in microseconds) over the stats interval
+	IterTimeMin int64
+	IterTimeAvg int64
+	IterTimeMax int64
+}
+
+type StatsSink interface {
+	Report(s *StatsSnapshot)
+}
+
+type LogStatsSink struct {
+}
+
+// Metrics are sent in as many UDP packets of up to this size as it takes, so that none exceeds a typical MTU.
+const STATSD_MAX_PACKET_SIZE = 1400
+
+type StatsdStatsSink struct {
+	conn net.Conn
+	prev *StatsSnapshot // For reporting cumulative counts as deltas
+}
+
+var _statsSinks []StatsSink = nil
+
+
+// Parses the comma-separated list of stats sink names.
+func parseStatsSinks(s string) ([]string, error) {
+	sinks := make([]string, 0)
+	if s == "" {
+		return sinks, nil
+	}
+
+	for _, name := range strings.Split(s, ",") {
+		switch name {
+		case STATS_SINK_LOG, STATS_SINK_STATSD, STATS_SINK_PROMETHEUS:
+			sinks = append(sinks, name)
+		default:
+			return nil, errors.New("Invalid stats sink: " + name)
+		}
+	}
+
+	return sinks, nil
+}
+
+func statsInit() {
+	for _, name := range _config.StatsSinks {
+		switch name {
+		case STATS_SINK_LOG:
+			_statsSinks = append(_statsSinks, &LogStatsSink {})
+
+		case STATS_SINK_STATSD:
+			conn, err := net.Dial("udp", _config.StatsdHostPort)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			_statsSinks = append(_statsSinks, &StatsdStatsSink { conn: conn })
+
+		case STATS_SINK_PROMETHEUS:
+			_statsSinks = append(_statsSinks, newPrometheusStatsSink())
+		}
+	}
+}
+
+// Takes a snapshot of the current statistics. Caller must hold _lock.
+func collectStats(iterTimeMin int64, iterTimeAvg int64, iterTimeMax int64) *StatsSnapshot {
+	s := &StatsSnapshot {
+		Conns: len(_conns),
+		Keys: _hub.Keys(),
+		Tracked: len(_trackedBoats),
+		Sessions: len(_sessions),
+		DuplicateConns: countDuplicateConns(),
+		MemoryInUse: _memoryInUse.Load(),
+		MemoryLimit: _memoryLimit.Load(),
+		MemoryPressure: _memoryPressure.Load(),
+		NoboatKeys: countNoboatKeys(time.Now()),
+		CountConns: _countConns,
+		CountMsgs: _countMsgs,
+		SharedMsgs: _countSharedMsgs,
+		QueueDropped: atomic.LoadInt64(&_countQueueDropped),
+		QueueCoalesced: atomic.LoadInt64(&_countQueueCoalesced),
+		QueueConflated: atomic.LoadInt64(&_countQueueConflated),
+		QueueDisconnects: atomic.LoadInt64(&_countQueueDisconnects),
+		WriteTimeouts: atomic.LoadInt64(&_countWriteTimeouts),
+		SimRetries: atomic.LoadInt64(&_countSimRetries),
+		DegradedIters: atomic.LoadInt64(&_countDegradedIters),
+		IterOverruns: atomic.LoadInt64(&_countIterOverruns),
+		SkippedTicks: atomic.LoadInt64(&_countSkippedTicks),
+		ShedLevel: _shedLevel,
+		ShedIters: _countShedIters,
+		ShedMsgs: _countShedMsgs,
+		ShedSubscribes: atomic.LoadInt64(&_countShedSubscribes),
+		BoatDeletedCloses: _countBoatDeletedCloses,
+		NoboatRejects: _countNoboatRejects,
+		IpRejects: atomic.LoadInt64(&_countIpRejects),
+		Banned: countBanned(),
+		Bans: atomic.LoadInt64(&_countBans),
+		BanRejects: atomic.LoadInt64(&_countBanRejects),
+		WebhooksSent: atomic.LoadInt64(&_countWebhooksSent),
+		WebhookFailures: atomic.LoadInt64(&_countWebhookFailures),
+		IterTimeMin: iterTimeMin,
+		IterTimeAvg: iterTimeAvg,
+		IterTimeMax: iterTimeMax,
+		StartTime: _startTime,
+		Lifetime: statsTotals(_countConns, _countMsgs),
+	}
+
+	for i := 0; i < SIM_RESULT_COUNT; i++ {
+		s.SimResults[i] = atomic.LoadInt64(&_simResultCounts[i])
+	}
+
+	for i := 0; i < SIM_REJECT_COUNT; i++ {
+		s.SimRejected[i] = atomic.LoadInt64(&_simRejectCounts[i])
+	}
+
+	for i := 0; i < LATENCY_NUM_BUCKETS; i++ {
+		s.LatencyCounts[i] = atomic.LoadInt64(&_latencyCounts[i])
+	}
+	s.LatencySumUs = atomic.LoadInt64(&_latencySumUs)
+
+	return s
+}
+
+func reportStats(s *StatsSnapshot) {
+	for _, sink := range _statsSinks {
+		sink.Report(s)
+	}
+	saveStatsFile(s)
+}
+
+func (sink *LogStatsSink) Report(s *StatsSnapshot) {
+	log.Println("Now:        conns=" + strconv.Itoa(s.Conns) + ", keys=" + strconv.Itoa(s.Keys) + ", tracked=" + strconv.Itoa(s.Tracked) + ", sessions=" + strconv.Itoa(s.Sessions) + ", dup_conns=" + strconv.Itoa(s.DuplicateConns))
+	if s.MemoryLimit > 0 {
+		log.Println("Memory:     in_use=" + strconv.FormatInt(s.MemoryInUse / 1024 / 1024, 10) + "MiB, limit=" + strconv.FormatInt(s.MemoryLimit / 1024 / 1024, 10) + "MiB, pressure=" + strconv.FormatBool(s.MemoryPressure) + ", shed_subscribes=" + strconv.FormatInt(s.ShedSubscribes, 10))
+	}
+	log.Println("Uptime:     " + formatUptime(time.Since(s.StartTime)) + " (since " + s.StartTime.UTC().Format(time.RFC3339) + ")" +
+		", lifetime: conns=" + strconv.FormatInt(s.Lifetime.Conns, 10) + ", msgs=" + strconv.FormatInt(s.Lifetime.Msgs, 10) +
+		" (since " + time.Unix(s.Lifetime.FirstStart, 0).UTC().Format(time.RFC3339) + ", restarts=" + strconv.FormatInt(s.Lifetime.Restarts, 10) + ")")
+	log.Println("Cumulative: conns=" + strconv.FormatInt(s.CountConns, 10) + ", msgs=" + strconv.FormatInt(s.CountMsgs, 10) +
+		", shared=" + strconv.FormatInt(s.SharedMsgs, 10) +
+		", dropped=" + strconv.FormatInt(s.QueueDropped, 10) +
+		", coalesced=" + strconv.FormatInt(s.QueueCoalesced, 10) +
+		", conflated=" + strconv.FormatInt(s.QueueConflated, 10) +
+		", overflowed=" + strconv.FormatInt(s.QueueDisconnects, 10) +
+		", write_timeouts=" + strconv.FormatInt(s.WriteTimeouts, 10) +
+		", ip_rejects=" + strconv.FormatInt(s.IpRejects, 10) +
+		", bans=" + strconv.FormatInt(s.Bans, 10) + " (now " + strconv.Itoa(s.Banned) + ")" +
+		", ban_rejects=" + strconv.FormatInt(s.BanRejects, 10))
+
+	sim := ""
+	for i := 0; i < SIM_RESULT_COUNT; i++ {
+		if i > 0 {
+			sim += ", "
+		}
+		sim += _simResultNames[i] + "=" + strconv.FormatInt(s.SimResults[i], 10)
+	}
+	sim += ", retries=" + strconv.FormatInt(s.SimRetries, 10) + ", degraded_iters=" + strconv.FormatInt(s.DegradedIters, 10)
+	sim += ", rejected=("
+	for i := 0; i < SIM_REJECT_COUNT; i++ {
+		if i > 0 {
+			sim += ", "
+		}
+		sim += _simRejectNames[i] + "=" + strconv.FormatInt(s.SimRejected[i], 10)
+	}
+	sim += ")"
+	sim += ", noboat_keys=" + strconv.Itoa(s.NoboatKeys) + ", deleted_closes=" + strconv.FormatInt(s.BoatDeletedCloses, 10) + ", noboat_rejects=" + strconv.FormatInt(s.NoboatRejects, 10)
+	log.Println("Simulator:  " + sim)
+
+	if _config.WebhooksFile != "" {
+		log.Println("Webhooks:   sent=" + strconv.FormatInt(s.WebhooksSent, 10) + ", failed=" + strconv.FormatInt(s.WebhookFailures, 10))
+	}
+
+	latency := ""
+	for i := 0; i < LATENCY_NUM_BUCKETS; i++ {
+		if i > 0 {
+			latency += ", "
+		}
+		latency += latencyBucketName(i) + "=" + strconv.FormatInt(s.LatencyCounts[i], 10)
+	}
+	log.Println("Delivery latency (ms): " + latency)
+
+	log.Println("Iteration times (min/avg/max us): " +
+		strconv.FormatInt(s.IterTimeMin, 10) + "/" +
+		strconv.FormatInt(s.IterTimeAvg, 10) + "/" +
+		strconv.FormatInt(s.IterTimeMax, 10) +
+		", overruns=" + strconv.FormatInt(s.IterOverruns, 10) +
+		", skipped_ticks=" + strconv.FormatInt(s.SkippedTicks, 10))
+	if _config.ShedPolicy != SHED_POLICY_NONE {
+		log.Println("Shedding:   level=" + strconv.Itoa(s.ShedLevel) + ", iters=" + strconv.FormatInt(s.ShedIters, 10) + ", skipped_msgs=" + strconv.FormatInt(s.ShedMsgs, 10))
+	}
+}
+
+// Sends gauges for current values and iteration times, and counters (deltas) for cumulative counts.
+func (sink *StatsdStatsSink) Report(s *StatsSnapshot) {
+	prev := sink.prev
+	if prev == nil {
+		prev = &StatsSnapshot {}
+	}
+	sink.prev = s
+
+	var buf bytes.Buffer
+	p := _config.StatsdPrefix
+
+	fmt.Fprintf(&buf, "%sconns:%d|g\n%skeys:%d|g\n%stracked:%d|g\n%ssessions:%d|g\n", p, s.Conns, p, s.Keys, p, s.Tracked, p, s.Sessions)
+	fmt.Fprintf(&buf, "%sconns_total:%d|c\n%smsgs:%d|c\n", p, s.CountConns - prev.CountConns, p, s.CountMsgs - prev.CountMsgs)
+	fmt.Fprintf(&buf, "%suptime_s:%d|g\n%slifetime.conns:%d|g\n%slifetime.msgs:%d|g\n", p, int64(time.Since(s.StartTime).Seconds()), p, s.Lifetime.Conns, p, s.Lifetime.Msgs)
+	fmt.Fprintf(&buf, "%sdup_conns:%d|g\n%sshared_msgs:%d|c\n", p, s.DuplicateConns, p, s.SharedMsgs - prev.SharedMsgs)
+	fmt.Fprintf(&buf, "%squeue.dropped:%d|c\n%squeue.coalesced:%d|c\n%squeue.disconnects:%d|c\n", p, s.QueueDropped - prev.QueueDropped, p, s.QueueCoalesced - prev.QueueCoalesced, p, s.QueueDisconnects - prev.QueueDisconnects)
+	fmt.Fprintf(&buf, "%squeue.conflated:%d|c\n", p, s.QueueConflated - prev.QueueConflated)
+	fmt.Fprintf(&buf, "%swrite_timeouts:%d|c\n%sip_rejects:%d|c\n", p, s.WriteTimeouts - prev.WriteTimeouts, p, s.IpRejects - prev.IpRejects)
+	fmt.Fprintf(&buf, "%sbanned:%d|g\n%sbans:%d|c\n%sban_rejects:%d|c\n", p, s.Banned, p, s.Bans - prev.Bans, p, s.BanRejects - prev.BanRejects)
+	for i := 0; i < SIM_RESULT_COUNT; i++ {
+		fmt.Fprintf(&buf, "%ssim.%s:%d|c\n", p, _simResultNames[i], s.SimResults[i] - prev.SimResults[i])
+	}
+	for i := 0; i < SIM_REJECT_COUNT; i++ {
+		fmt.Fprintf(&buf, "%ssim.rejected.%s:%d|c\n", p, _simRejectNames[i], s.SimRejected[i] - prev.SimRejected[i])
+	}
+	fmt.Fprintf(&buf, "%ssim.retries:%d|c\n%sdegraded_iters:%d|c\n", p, s.SimRetries - prev.SimRetries, p, s.DegradedIters - prev.DegradedIters)
+	fmt.Fprintf(&buf, "%snoboat.keys:%d|g\n%snoboat.deleted_closes:%d|c\n%snoboat.rejects:%d|c\n", p, s.NoboatKeys, p, s.BoatDeletedCloses - prev.BoatDeletedCloses, p, s.NoboatRejects - prev.NoboatRejects)
+	for i := 0; i < LATENCY_NUM_BUCKETS; i++ {
+		name := strings.Replace(strings.Replace(latencyBucketName(i), "<=", "le_", 1), ">", "gt_", 1)
+		fmt.Fprintf(&buf, "%slatency_ms.%s:%d|c\n", p, name, s.LatencyCounts[i] - prev.LatencyCounts[i])
+	}
+	fmt.Fprintf(&buf, "%smemory.in_use:%d|g\n%smemory.pressure:%d|g\n%sshed_subscribes:%d|c\n", p, s.MemoryInUse, p, boolToInt(s.MemoryPressure), p, s.ShedSubscribes - prev.ShedSubscribes)
+	fmt.Fprintf(&buf, "%swebhooks.sent:%d|c\n%swebhooks.failed:%d|c\n", p, s.WebhooksSent - prev.WebhooksSent, p, s.WebhookFailures - prev.WebhookFailures)
+	fmt.Fprintf(&buf, "%siter_overruns:%d|c\n%sskipped_ticks:%d|c\n", p, s.IterOverruns - prev.IterOverruns, p, s.SkippedTicks - prev.SkippedTicks)
+	fmt.Fprintf(&buf, "%sshed.level:%d|g\n%sshed.iters:%d|c\n%sshed.msgs:%d|c\n", p, s.ShedLevel, p, s.ShedIters - prev.ShedIters, p, s.ShedMsgs - prev.ShedMsgs)
+	fmt.Fprintf(&buf, "%siter_us.min:%d|g\n%siter_us.avg:%d|g\n%siter_us.max:%d|g", p, s.IterTimeMin, p, s.IterTimeAvg, p, s.IterTimeMax)
+
+	for _, packet := range statsdPackets(buf.Bytes(), STATSD_MAX_PACKET_SIZE) {
+		_, err := sink.conn.Write(packet)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
+
+// Splits newline-separated metrics into packets of at most max bytes (unless a single metric is longer), on line boundaries.
+func statsdPackets(data []byte, max int) [][]byte {
+	packets := make([][]byte, 0)
+
+	var packet []byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(packet) > 0 && len(packet) + 1 + len(line) > max {
+			packets = append(packets, packet)
+			packet = nil
+		}
+
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+
+	return packets
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+
+func TestStatsdPackets(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, "snsw.metric." + strings.Repeat("x", i % 20) + ":1|c")
+	}
+	data := []byte(strings.Join(lines, "\n"))
+
+	packets := statsdPackets(data, 100)
+	if len(packets) < 2 {
+		t.Fatalf("Metrics not split into packets: %d", len(packets))
+	}
+
+	for _, packet := range packets {
+		if len(packet) > 100 {
+			t.Errorf("Packet too big: %d bytes", len(packet))
+		}
+		if bytes.HasPrefix(packet, []byte("\n")) || bytes.HasSuffix(packet, []byte("\n")) {
+			t.Errorf("Packet not split on a line boundary: %q", packet)
+		}
+	}
+
+	// Nothing's lost or split mid-line.
+	if joined := bytes.Join(packets, []byte("\n")); !bytes.Equal(joined, data) {
+		t.Errorf("Packets don't add up to the metrics sent")
+	}
+
+	// A metric longer than a packet still gets sent, on its own.
+	long := strings.Repeat("y", 150)
+	packets = statsdPackets([]byte("a:1|c\n" + long + "\nb:1|c"), 100)
+	if len(packets) != 3 || string(packets[1]) != long {
+		t.Errorf("Unexpected packets with a long metric: %q", packets)
+	}
+}
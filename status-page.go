@@ -0,0 +1,188 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+
+// Status page:
+//
+// A small status page is served at / on the public listener, so that
+// operators and curious users can sanity-check the service with a browser. It
+// shows only aggregate figures (connections, boats, message counts, iteration
+// times), uptime and version, and whether each simulator is reachable (by
+// name, without its address), so nothing on it is sensitive.
+
+type SimHealth struct {
+	Reachable bool
+	Since time.Time // When it became (un)reachable
+}
+
+type StatusPageSim struct {
+	Name string
+	Status string
+	Since string
+}
+
+type StatusPageData struct {
+	Version string
+	Uptime string
+	Conns int
+	Tracked int
+	Sessions int
+	CountConns int64
+	CountMsgs int64
+	IterTimeMs string
+	Sims []StatusPageSim
+}
+
+var _startTime = time.Now()
+
+// Duration of the main loop's most recent iteration (guarded by _lock)
+var _lastIterTimeUs int64 = 0
+
+// Simulator reachability, by host:port, as of the most recent dial attempt
+var _simHealth = make(map[string]SimHealth)
+var _simHealthLock sync.Mutex
+
+var _statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="30">
+<title>SailNavSim WebSocket Connector</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 1em 0.2em 0; text-align: left; }
+.ok { color: #080; }
+.down { color: #c00; }
+</style>
+</head>
+<body>
+<h1>SailNavSim WebSocket Connector</h1>
+<table>
+<tr><th>Version</th><td>{{.Version}}</td></tr>
+<tr><th>Uptime</th><td>{{.Uptime}}</td></tr>
+<tr><th>Connections</th><td>{{.Conns}}</td></tr>
+<tr><th>Boats tracked</th><td>{{.Tracked}}</td></tr>
+<tr><th>Sessions</th><td>{{.Sessions}}</td></tr>
+<tr><th>Connections served</th><td>{{.CountConns}}</td></tr>
+<tr><th>Messages sent</th><td>{{.CountMsgs}}</td></tr>
+<tr><th>Last iteration time</th><td>{{.IterTimeMs}} ms</td></tr>
+</table>
+<h2>Simulators</h2>
+<table>
+{{range .Sims}}<tr><th>{{.Name}}</th><td class="{{.Status}}">{{.Status}}</td><td>{{.Since}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+
+// Called after each attempt to dial a simulator (including retries), with whether it succeeded.
+func noteSimReachable(hostPort string, reachable bool) {
+	_simHealthLock.Lock()
+	defer _simHealthLock.Unlock()
+
+	if health, exists := _simHealth[hostPort]; !exists || health.Reachable != reachable {
+		_simHealth[hostPort] = SimHealth { Reachable: reachable, Since: time.Now() }
+	}
+}
+
+// Returns each simulator's status ("ok", "down", or "unknown" if it hasn't been dialed yet), by name.
+func statusPageSims(now time.Time) []StatusPageSim {
+	hostPorts := map[string]string { DEFAULT_SIM: _config.ConnectHostPort }
+	for name, hostPort := range _config.Sims {
+		hostPorts[name] = hostPort
+	}
+
+	_simHealthLock.Lock()
+	defer _simHealthLock.Unlock()
+
+	sims := make([]StatusPageSim, 0, len(hostPorts))
+	for name, hostPort := range hostPorts {
+		sim := StatusPageSim { Name: name, Status: "unknown" }
+		if health, exists := _simHealth[hostPort]; exists {
+			sim.Status = "down"
+			if health.Reachable {
+				sim.Status = "ok"
+			}
+			sim.Since = "for " + formatUptime(now.Sub(health.Since))
+		}
+		sims = append(sims, sim)
+	}
+
+	sort.Slice(sims, func (i int, j int) bool {
+		return sims[i].Name < sims[j].Name
+	})
+	return sims
+}
+
+// Formats a duration in whole seconds, e.g. "3d 4h5m6s" or "6s".
+func formatUptime(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	if days > 0 {
+		return strconv.FormatInt(int64(days), 10) + "d " + d.String()
+	}
+	return d.String()
+}
+
+func statusPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	data := StatusPageData {
+		Version: VERSION,
+		Uptime: formatUptime(now.Sub(_startTime)),
+		Sims: statusPageSims(now),
+	}
+
+	_lock.Lock()
+	data.Conns = len(_conns)
+	data.Tracked = len(_trackedBoats)
+	data.Sessions = len(_sessions)
+	data.CountConns = _countConns
+	data.CountMsgs = _countMsgs
+	data.IterTimeMs = strconv.FormatFloat(float64(_lastIterTimeUs) / 1000.0, 'f', 1, 64)
+	_lock.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	err := _statusPageTemplate.Execute(w, data)
+	if err != nil {
+		log.Println(err)
+	}
+}
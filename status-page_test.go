@@ -0,0 +1,71 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestFormatUptime(t *testing.T) {
+	cases := map[time.Duration]string {
+		1500 * time.Millisecond: "1s",
+		2 * time.Hour + 3 * time.Minute: "2h3m0s",
+		(3 * 24 + 4) * time.Hour + 5 * time.Second: "3d 4h0m5s",
+	}
+	for d, expected := range cases {
+		if s := formatUptime(d); s != expected {
+			t.Errorf("%v: expected %s, but got %s", d, expected, s)
+		}
+	}
+}
+
+func TestStatusPageHandler(t *testing.T) {
+	noteSimReachable(_config.ConnectHostPort, true)
+
+	w := httptest.NewRecorder()
+	statusPageHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Unexpected response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.String()
+	for _, s := range []string { VERSION, "<th>" + DEFAULT_SIM + "</th><td class=\"ok\">ok</td>" } {
+		if !strings.Contains(body, s) {
+			t.Errorf("Status page doesn't contain %s: %s", s, body)
+		}
+	}
+	if _config.ConnectHostPort != "" && strings.Contains(body, _config.ConnectHostPort) {
+		t.Errorf("Status page shows simulator address: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	statusPageHandler(w, httptest.NewRequest(http.MethodGet, "/nothing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for other paths, but got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	statusPageHandler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST not refused: %d", w.Code)
+	}
+}
@@ -0,0 +1,179 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+const STRESS_NUM_BOATS = 8
+const STRESS_NUM_CLIENTS = 300
+const STRESS_SPREAD = 2500 * time.Millisecond // Clients start over a few main loop iterations
+
+
+// Hundreds of clients subscribing, streaming and disconnecting (cleanly, abruptly, or before their
+// subscription is even acknowledged) while the main loop polls, meant to be run with the race detector
+// (go test -race -run Stress). Once they're all gone, nothing they subscribed to may be left behind.
+func TestIntegrationStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping stress test in short mode")
+	}
+
+	t.Parallel()
+	url := testServer(t)
+
+	members := make([]*BoatInfo, 0, STRESS_NUM_BOATS)
+	for i := 0; i < STRESS_NUM_BOATS; i++ {
+		boatKey := testBoatKey(t, i)
+		_testSim.setBoat(boatKey, 45.0 + float64(i) * 0.01, -30.0, 90.0)
+		members = append(members, &BoatInfo { boatKey, fmt.Sprintf("Stress %d", i) })
+	}
+	_testSim.setGroup(members)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, STRESS_NUM_CLIENTS)
+
+	for i := 0; i < STRESS_NUM_CLIENTS; i++ {
+		wg.Add(1)
+		go func(i int, delay time.Duration) {
+			defer wg.Done()
+			time.Sleep(delay)
+
+			err := stressClient(url, i, members[i % STRESS_NUM_BOATS].BoatKey)
+			if err != nil {
+				errs <- fmt.Errorf("client %d: %w", i, err)
+			}
+		}(i, time.Duration(rand.Int63n(int64(STRESS_SPREAD))))
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	testWaitFor(t, "all stress clients to be released", func() bool {
+		for _, m := range members {
+			if _, tracked := _trackedBoats[m.BoatKey]; tracked || _hub.Count(m.BoatKey) != 0 {
+				return false
+			}
+		}
+
+		for _, connCtx := range _conns {
+			for _, m := range members {
+				if connCtx.BoatKey == m.BoatKey {
+					return false
+				}
+			}
+		}
+
+		return true
+	})
+}
+
+// Runs one stress client, its behaviour depending on its number.
+func stressClient(url string, i int, boatKey string) error {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	cmd := "bdl"
+	if i % 2 == 1 {
+		cmd = "bdl_g"
+	}
+
+	err = conn.WriteJSON(map[string]interface{} { "cmd": cmd, "key": boatKey })
+	if err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(TEST_READ_TIMEOUT))
+
+	switch i % 6 {
+	case 0, 1:
+		// Streams for a few iterations, then closes cleanly.
+		for n := 0; n < 2 + i % 3; n++ {
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				return err
+			}
+		}
+
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+
+	case 2:
+		// Drops the connection before the subscription is acknowledged.
+		conn.NetConn().Close()
+
+	case 3:
+		// Drops the connection while streaming.
+		_, _, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		conn.NetConn().Close()
+
+	case 4:
+		// Sends other requests while streaming.
+		for n := 0; n < 3; n++ {
+			err := conn.WriteJSON(map[string]interface{} { "cmd": "ping", "payload": n })
+			if err == nil {
+				err = conn.WriteJSON(map[string]interface{} { "cmd": "time" })
+			}
+			if err == nil {
+				_, _, err = conn.ReadMessage()
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+	case 5:
+		// Subscribes again, which closes the connection.
+		_, _, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		err = conn.WriteJSON(map[string]interface{} { "cmd": cmd, "key": boatKey })
+		if err != nil {
+			return err
+		}
+
+		for {
+			_, _, err := conn.ReadMessage()
+			if _, closed := err.(*websocket.CloseError); closed {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
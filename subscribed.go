@@ -0,0 +1,112 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"time"
+)
+
+
+// Subscription acknowledgements:
+//
+// After a successful bdl, bdl_g, bdl_x, group_all or gdl request, the client is sent the
+// parameters of its stream, so that it doesn't have to infer them:
+//
+// {"type":"subscribed","version":<n>,"interval":<seconds>,"interval_ms":<ms>,"radius":<nm>,"group":<n>}
+//
+// "interval" is the poll interval (see tick.go) rounded to whole seconds (but
+// at least 1), for older clients, while "interval_ms" is exact.
+//
+// "radius" (the distance within which other boats are included) and "group"
+// (the number of boats in the group, including this one) are only present for
+// bdl_g subscriptions, other than "group" also being present for group_all
+// and gdl (but for bdl_g, "group_pending" is present instead until the group has been
+// fetched; see group-fetch.go). If the
+// request selected fields (see fields.go), they're listed in "fields", and if
+// it asked for COG smoothing (see cog-smoothing.go), "smooth_cog" is present,
+// as is "units" if it asked for other than the default units (see units.go),
+// "hf" and "hf_dist" if it was granted high-frequency mode (see hf.go), and
+// "interp" if it was granted interpolated data (see interp.go). "priority" is
+// the subscription's priority class (see priority.go), and "boat" the boat's
+// name, etc. (see boat-info.go).
+
+// Version of the WebSocket protocol, incremented on incompatible changes
+const PROTOCOL_VERSION = 1
+
+type SubscribedMsg struct {
+	Type string `json:"type"`
+	Version int `json:"version"`
+	Interval int64 `json:"interval"` // Seconds between live data messages (rounded, but at least 1)
+	IntervalMs int64 `json:"interval_ms"` // Milliseconds between live data messages
+	Radius float64 `json:"radius,omitempty"` // Group visibility radius (NM)
+	Group int `json:"group,omitempty"` // Number of boats in the group
+	GroupPending bool `json:"group_pending,omitempty"` // Group still being fetched, and sent later (see group-fetch.go)
+	Fields []string `json:"fields,omitempty"` // Selected fields, if not all
+	SmoothCog int `json:"smooth_cog,omitempty"` // Iterations COG is smoothed over, if requested
+	Units *Units `json:"units,omitempty"` // Units, if not the defaults
+	Hf int `json:"hf,omitempty"` // Live data messages per poll interval in high-frequency mode, if granted
+	HfDist float64 `json:"hf_dist,omitempty"` // Distance (NM) to other boats within which high-frequency mode is on
+	Interp int `json:"interp,omitempty"` // Live data messages per poll interval with interpolated data, if granted
+	Priority string `json:"priority"` // Priority class (see priority.go)
+	Boat *SimBoatInfo `json:"boat,omitempty"` // The boat's name, etc., if known (see boat-info.go)
+}
+
+
+// Called (with _lock held) once the connection has been subscribed.
+func sendSubscribedMsg(conn *WsConn, connCtx *ConnCtx) {
+	msg := &SubscribedMsg {
+		Type: "subscribed",
+		Version: PROTOCOL_VERSION,
+		Interval: max(int64(_config.PollInterval.Round(time.Second) / time.Second), 1),
+		IntervalMs: _config.PollInterval.Milliseconds(),
+		Priority: priorityName(connCtx.Priority),
+	}
+
+	if connCtx.GroupBoats != nil {
+		if !connCtx.GroupAll {
+			msg.Radius = GROUP_VISIBILITY_DIST
+		}
+		if connCtx.GroupPending {
+			msg.GroupPending = true
+		} else {
+			msg.Group = connCtx.GroupBoats.Len()
+		}
+	}
+
+	msg.Fields = listFields(connCtx.Fields)
+
+	if connCtx.CogSmoother != nil {
+		msg.SmoothCog = connCtx.CogSmoother.Iters
+	}
+
+	msg.Units = connCtx.Units
+
+	if connCtx.Hf != nil {
+		msg.Hf = _config.HfRate
+		msg.HfDist = _config.HfDist
+	}
+
+	if connCtx.Interp {
+		msg.Interp = _config.InterpRate
+	}
+
+	if !connCtx.GroupAll {
+		msg.Boat = cachedBoatInfo(connCtx.BoatKey)
+	}
+
+	conn.SendJSON(msg)
+}
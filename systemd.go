@@ -0,0 +1,132 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+
+// systemd integration:
+//
+// When started by systemd with socket activation (LISTEN_PID/LISTEN_FDS), the
+// public WebSocket listener is taken from the passed socket instead of being
+// bound here, so that connections queue up in the kernel across restarts.
+//
+// When started as a Type=notify service (NOTIFY_SOCKET), systemd is told
+// "READY=1" once the listener is ready, and "STOPPING=1" on SIGTERM/SIGINT
+// (see close-codes.go).
+// If the service has a watchdog (WATCHDOG_USEC), "WATCHDOG=1" is sent from
+// the main loop, so that the service is restarted if the loop gets stuck.
+//
+// None of this has any effect unless those environment variables are set.
+
+// First file descriptor passed by socket activation
+const SD_LISTEN_FDS_START = 3
+
+var _watchdogInterval time.Duration
+var _watchdogLast time.Time
+
+
+func systemdInit() {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err == nil && usec > 0 && watchdogForUs() {
+		// Ping at half the watchdog timeout, as recommended.
+		_watchdogInterval = time.Duration(usec) * time.Microsecond / 2
+		log.Println("systemd watchdog enabled, pinging every " + _watchdogInterval.String())
+	}
+}
+
+// Whether the watchdog (if set) is meant for this process, rather than e.g. a parent shell script.
+func watchdogForUs() bool {
+	pid := os.Getenv("WATCHDOG_PID")
+	return pid == "" || pid == strconv.Itoa(os.Getpid())
+}
+
+// Returns the listener passed by systemd socket activation, or nil if there isn't one.
+func systemdListener() net.Listener {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if n > 1 {
+		log.Printf("Using only the first of %d sockets passed by systemd\n", n)
+	}
+
+	f := os.NewFile(uintptr(SD_LISTEN_FDS_START), "systemd-socket")
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return ln
+}
+
+// Sends a state notification (e.g. "READY=1") to systemd, if running as a notify service.
+func systemdNotify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:] // Abstract socket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr { Name: addr, Net: "unixgram" })
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Called by the main loop once per iteration, to ping the systemd watchdog when due.
+func systemdWatchdog(now time.Time) {
+	if _watchdogInterval == 0 || now.Sub(_watchdogLast) < _watchdogInterval {
+		return
+	}
+
+	_watchdogLast = now
+	systemdNotify("WATCHDOG=1")
+}
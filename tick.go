@@ -0,0 +1,159 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+
+// Poll interval:
+//
+// The main loop polls the simulator, and sends live data, once every poll
+// interval (-poll-interval, one second by default). The interval is measured
+// from the start of one iteration to the start of the next, so time spent
+// after releasing the lock (reporting stats, recording tracks, etc.) doesn't
+// make the ticks drift.
+//
+// An iteration taking longer than the interval is counted as an overrun, and
+// the next one is started straight away. With -adaptive-tick, iterations are
+// instead kept aligned to multiples of the interval (e.g. to whole seconds),
+// and any ticks missed by an overrunning iteration are skipped, so that under
+// sustained load the data polled by the next iteration covers (merges) them.
+
+const POLL_INTERVAL_MIN = 100 * time.Millisecond
+const POLL_INTERVAL_MAX = 60 * time.Second
+
+// Main loop iterations that took longer than the poll interval, and ticks skipped as a result (with -adaptive-tick)
+var _countIterOverruns int64 = 0
+var _countSkippedTicks int64 = 0
+
+// Consecutive overrunning iterations (so far)
+var _overrunIters int64 = 0
+
+// A point between iterations at which to send high-frequency mode (see hf.go) and/or interpolated (see interp.go) data
+type SubTick struct {
+	At time.Time
+	Hf bool
+	Interp bool
+}
+
+
+// Returns when the first iteration should start.
+func firstTick(now time.Time, interval time.Duration, adaptive bool) time.Time {
+	if !adaptive {
+		return now
+	}
+
+	return now.Truncate(interval)
+}
+
+// Given when the current iteration's tick was and the time now (after it's finished), returns when
+// the next iteration should start, whether the current one overran, and how many ticks it skipped.
+func nextTick(tick time.Time, now time.Time, interval time.Duration, adaptive bool) (time.Time, bool, int64) {
+	next := tick.Add(interval)
+	if now.Before(next) {
+		return next, false, 0
+	}
+
+	if !adaptive {
+		return now, true, 0
+	}
+
+	// Skip to the next tick still to come.
+	skipped := int64(now.Sub(next) / interval) + 1
+	return next.Add(time.Duration(skipped) * interval), true, skipped
+}
+
+// Returns the sub-ticks (in order) between an iteration's tick and the next, at each of the given
+// rates (per interval), merging any that coincide.
+func subTicks(tick time.Time, next time.Time, interval time.Duration, hfRate int, interpRate int) []SubTick {
+	var ticks []SubTick
+
+	add := func (rate int, hf bool) {
+		for i := 1; i < rate; i++ {
+			at := tick.Add(interval * time.Duration(i) / time.Duration(rate))
+			if !at.Before(next) {
+				break
+			}
+
+			j := sort.Search(len(ticks), func (j int) bool { return !ticks[j].At.Before(at) })
+			if j == len(ticks) || !ticks[j].At.Equal(at) {
+				ticks = append(ticks, SubTick {})
+				copy(ticks[j + 1:], ticks[j:])
+				ticks[j] = SubTick { At: at }
+			}
+
+			if hf {
+				ticks[j].Hf = true
+			} else {
+				ticks[j].Interp = true
+			}
+		}
+	}
+	add(hfRate, true)
+	add(interpRate, false)
+
+	return ticks
+}
+
+// Called by the main loop (without _lock held) after an iteration, to run any sub-ticks due before the next iteration's tick.
+func runSubTicks(tick time.Time, next time.Time) {
+	for _, st := range subTicks(tick, next, _config.PollInterval, _config.HfRate, _config.InterpRate) {
+		wait := time.Until(st.At)
+		if wait < 0 {
+			continue // Missed due to the iteration running long.
+		}
+
+		time.Sleep(wait)
+		if st.Hf {
+			hfSubTick()
+		}
+		if st.Interp {
+			interpSubTick(st.At)
+		}
+	}
+}
+
+// Called by the main loop once an iteration has finished, sleeping until the next one should start
+// (running any sub-ticks in the meantime), and returning when that is.
+func waitForNextTick(tick time.Time) time.Time {
+	now := time.Now()
+	next, overrun, skipped := nextTick(tick, now, _config.PollInterval, _config.AdaptiveTick)
+
+	if overrun {
+		atomic.AddInt64(&_countIterOverruns, 1)
+		atomic.AddInt64(&_countSkippedTicks, skipped)
+		_overrunIters++
+
+		if _overrunIters == 1 {
+			log.Println("Main loop iteration overran poll interval (took " + now.Sub(tick).String() + ")")
+		}
+	} else if _overrunIters > 0 {
+		log.Println("Main loop iterations back within poll interval after " + strconv.FormatInt(_overrunIters, 10) + " overrunning iteration(s)")
+		_overrunIters = 0
+	}
+
+	runSubTicks(tick, next)
+
+	time.Sleep(time.Until(next))
+	return next
+}
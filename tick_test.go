@@ -0,0 +1,91 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestNextTick(t *testing.T) {
+	interval := time.Second
+	tick := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	next, overrun, skipped := nextTick(tick, tick.Add(300 * time.Millisecond), interval, false)
+	if !next.Equal(tick.Add(interval)) || overrun || skipped != 0 {
+		t.Errorf("Unexpected next tick: %v %v %d", next, overrun, skipped)
+	}
+
+	// Overrunning iterations are followed straight away by the next one.
+	now := tick.Add(2500 * time.Millisecond)
+	next, overrun, skipped = nextTick(tick, now, interval, false)
+	if !next.Equal(now) || !overrun || skipped != 0 {
+		t.Errorf("Unexpected next tick after overrun: %v %v %d", next, overrun, skipped)
+	}
+
+	// ...unless adaptive, when missed ticks are skipped.
+	next, overrun, skipped = nextTick(tick, now, interval, true)
+	if !next.Equal(tick.Add(3 * interval)) || !overrun || skipped != 2 {
+		t.Errorf("Unexpected next adaptive tick after overrun: %v %v %d", next, overrun, skipped)
+	}
+
+	next, overrun, skipped = nextTick(tick, tick.Add(interval), interval, true)
+	if !next.Equal(tick.Add(2 * interval)) || !overrun || skipped != 1 {
+		t.Errorf("Unexpected next adaptive tick after exact overrun: %v %v %d", next, overrun, skipped)
+	}
+}
+
+func TestFirstTick(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 700 * int(time.Millisecond), time.UTC)
+
+	if !firstTick(now, time.Second, false).Equal(now) {
+		t.Errorf("First tick not now!")
+	}
+
+	if !firstTick(now, 500 * time.Millisecond, true).Equal(now.Add(-200 * time.Millisecond)) {
+		t.Errorf("First adaptive tick not aligned to the interval!")
+	}
+}
+
+func TestSubTicks(t *testing.T) {
+	interval := time.Second
+	tick := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ms := func (n int) time.Time { return tick.Add(time.Duration(n) * time.Millisecond) }
+
+	// Coinciding sub-ticks are merged.
+	ticks := subTicks(tick, tick.Add(interval), interval, 2, 4)
+	expected := []SubTick { { ms(250), false, true }, { ms(500), true, true }, { ms(750), false, true } }
+	if len(ticks) != len(expected) {
+		t.Fatalf("Unexpected sub-ticks: %v", ticks)
+	}
+	for i := range ticks {
+		if !ticks[i].At.Equal(expected[i].At) || ticks[i].Hf != expected[i].Hf || ticks[i].Interp != expected[i].Interp {
+			t.Errorf("Unexpected sub-tick %d: %+v", i, ticks[i])
+		}
+	}
+
+	// None after the next iteration's tick (e.g. when it's straight after an overrunning one).
+	ticks = subTicks(tick, ms(400), interval, 0, 5)
+	if len(ticks) != 1 || !ticks[0].At.Equal(ms(200)) {
+		t.Errorf("Unexpected sub-ticks before early next tick: %v", ticks)
+	}
+
+	if len(subTicks(tick, tick.Add(interval), interval, 0, 0)) != 0 {
+		t.Errorf("Sub-ticks without high-frequency mode or interpolation!")
+	}
+}
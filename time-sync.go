@@ -0,0 +1,78 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"time"
+)
+
+
+// Time sync:
+//
+// A "time" message tells clients when the current main loop iteration started
+// polling the simulator ("poll", Unix time in ms), the server's current time
+// ("utc", Unix time in ms), and the iteration counter ("iter"), so that they
+// can align their clocks and animations with the iterations live data is sent
+// on. Both times are by the connector's clock: the simulator doesn't report
+// its own time, so "poll" is as close to the simulator's tick as the connector
+// can tell. It's sent on request (with a "time" command), and (if enabled)
+// periodically on every connection.
+
+type TimeMsg struct {
+	Type string `json:"type"`
+	Poll int64 `json:"poll"`
+	Utc int64 `json:"utc"`
+	Iter int64 `json:"iter"`
+}
+
+// State of the current main loop iteration (guarded by _lock)
+var _timeSyncIter int64 = 0
+var _timeSyncPoll time.Time
+
+
+// Creates a time message for the current iteration. Caller must hold _lock.
+func createTimeMsg() *TimeMsg {
+	return &TimeMsg {
+		Type: "time",
+		Poll: _timeSyncPoll.UnixMilli(),
+		Utc: time.Now().UnixMilli(),
+		Iter: _timeSyncIter,
+	}
+}
+
+// Called (with _lock held) at the start of each main loop iteration, after polling. Also
+// sends a time message on every connection every -time-sync-interval iterations.
+func updateTimeSync(iterCount int64, iterStartTime time.Time) {
+	_timeSyncIter = iterCount
+	_timeSyncPoll = iterStartTime
+
+	if _config.TimeSyncInterval <= 0 || iterCount % int64(_config.TimeSyncInterval) != 0 {
+		return
+	}
+
+	msg := createTimeMsg()
+	for conn := range _conns {
+		conn.SendJSON(msg) // Any failure will be picked up when next sending live data.
+	}
+}
+
+func wsReqTime(conn *WsConn) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	conn.SendJSON(createTimeMsg())
+}
@@ -0,0 +1,279 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+
+// Tracing:
+//
+// With -otlp-endpoint, spans are recorded for WebSocket upgrades,
+// subscriptions, group fetches, and each main loop iteration (with its
+// simulator polls, and the fan-out of live data to connections), and exported
+// in batches to an OpenTelemetry collector with OTLP/HTTP (JSON encoding), so
+// that it can be seen where time goes when iteration latency spikes. The
+// context of a span is passed to the simulator client, whose spans (e.g. for
+// dialling the simulator) are children of the span they were made for.
+//
+// -trace-sample sets the fraction of traces (i.e. iterations, upgrades,
+// subscriptions and group fetches) recorded. Without -otlp-endpoint, no spans
+// are recorded at all, and all of the functions below are no-ops (*Span
+// methods may be called on nil).
+
+const TRACE_SERVICE_NAME = "sailnavsim-snsw"
+
+const TRACE_QUEUE_SIZE = 4096
+const TRACE_BATCH_SIZE = 512
+const TRACE_EXPORT_INTERVAL = 5 * time.Second
+const TRACE_EXPORT_TIMEOUT = 10 * time.Second
+
+// OTLP span status code for errors
+const TRACE_STATUS_ERROR = 2
+
+type Span struct {
+	TraceId [16]byte
+	SpanId [8]byte
+	ParentId [8]byte // Zero for a root span
+	Name string
+	Start time.Time
+	End time.Time
+	Attrs map[string]interface{}
+	Err string
+}
+
+type spanCtxKey struct {}
+
+// Marks a trace that isn't sampled (in place of a span in the context), so its spans aren't recorded.
+var _unsampledSpan = &Span {}
+
+var _traceSpans chan *Span = nil
+var _countDroppedSpans int64 = 0
+
+
+func tracingInit() {
+	if _config.TraceEndpoint == "" {
+		return
+	}
+
+	_traceSpans = make(chan *Span, TRACE_QUEUE_SIZE)
+	go traceExporter()
+
+	log.Println("Exporting traces to " + _config.TraceEndpoint + "...")
+}
+
+// Starts a span, as a child of the span in ctx (if any), returning a context holding the new span
+// (to be passed on to calls whose spans should be its children), and the span (nil if not recorded).
+func startSpan(ctx context.Context, name string) (context.Context, *Span) {
+	if _traceSpans == nil {
+		return ctx, nil
+	}
+
+	parent, _ := ctx.Value(spanCtxKey {}).(*Span)
+	if parent == _unsampledSpan {
+		return ctx, nil
+	}
+
+	span := &Span {
+		Name: name,
+		Start: time.Now(),
+	}
+	rand.Read(span.SpanId[:])
+
+	if parent != nil {
+		span.TraceId = parent.TraceId
+		span.ParentId = parent.SpanId
+	} else if mrand.Float64() < _config.TraceSampleRatio {
+		rand.Read(span.TraceId[:])
+	} else {
+		return context.WithValue(ctx, spanCtxKey {}, _unsampledSpan), nil
+	}
+
+	return context.WithValue(ctx, spanCtxKey {}, span), span
+}
+
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	if s.Attrs == nil {
+		s.Attrs = make(map[string]interface{})
+	}
+	s.Attrs[key] = value
+}
+
+func (s *Span) SetError(err string) {
+	if s == nil {
+		return
+	}
+
+	s.Err = err
+}
+
+// Ends the span, queueing it for export (or dropping it, if the queue is full).
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.End = time.Now()
+
+	select {
+	case _traceSpans <- s:
+	default:
+		atomic.AddInt64(&_countDroppedSpans, 1)
+	}
+}
+
+func traceExporter() {
+	ticker := time.NewTicker(TRACE_EXPORT_INTERVAL)
+	client := &http.Client { Timeout: TRACE_EXPORT_TIMEOUT }
+	batch := make([]*Span, 0, TRACE_BATCH_SIZE)
+	failing := false
+
+	for {
+		select {
+		case span := <-_traceSpans:
+			batch = append(batch, span)
+			if len(batch) < TRACE_BATCH_SIZE {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if dropped := atomic.SwapInt64(&_countDroppedSpans, 0); dropped > 0 {
+			log.Println("Trace queue full; dropped " + strconv.FormatInt(dropped, 10) + " spans.")
+		}
+
+		err := exportSpans(client, batch)
+		if err != nil && !failing {
+			log.Println("Failed to export traces: " + err.Error())
+		} else if err == nil && failing {
+			log.Println("Exporting traces again.")
+		}
+		failing = err != nil
+
+		batch = batch[:0]
+	}
+}
+
+func exportSpans(client *http.Client, spans []*Span) error {
+	resp, err := client.Post(_config.TraceEndpoint, "application/json", bytes.NewReader(otlpTraceData(spans)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode / 100 != 2 {
+		return &traceExportError { resp.StatusCode }
+	}
+	return nil
+}
+
+type traceExportError struct {
+	StatusCode int
+}
+
+func (e *traceExportError) Error() string {
+	return "Collector returned HTTP status " + strconv.Itoa(e.StatusCode)
+}
+
+// Encodes spans as an OTLP/HTTP JSON export request.
+func otlpTraceData(spans []*Span) []byte {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]interface{} {
+			"traceId": hex.EncodeToString(s.TraceId[:]),
+			"spanId": hex.EncodeToString(s.SpanId[:]),
+			"name": s.Name,
+			"kind": 1, // Internal
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano": strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes": otlpAttrs(s.Attrs),
+		}
+		if s.ParentId != [8]byte {} {
+			span["parentSpanId"] = hex.EncodeToString(s.ParentId[:])
+		}
+		if s.Err != "" {
+			span["status"] = map[string]interface{} { "code": TRACE_STATUS_ERROR, "message": s.Err }
+		}
+
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	data, _ := json.Marshal(map[string]interface{} {
+		"resourceSpans": []interface{} {
+			map[string]interface{} {
+				"resource": map[string]interface{} {
+					"attributes": otlpAttrs(map[string]interface{} { "service.name": TRACE_SERVICE_NAME, "service.version": VERSION }),
+				},
+				"scopeSpans": []interface{} {
+					map[string]interface{} {
+						"scope": map[string]interface{} { "name": TRACE_SERVICE_NAME },
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	})
+
+	return data
+}
+
+func otlpAttrs(attrs map[string]interface{}) []map[string]interface{} {
+	kvs := make([]map[string]interface{}, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch v := v.(type) {
+		case string:
+			value = map[string]interface{} { "stringValue": v }
+		case bool:
+			value = map[string]interface{} { "boolValue": v }
+		case int:
+			value = map[string]interface{} { "intValue": strconv.Itoa(v) }
+		case int64:
+			value = map[string]interface{} { "intValue": strconv.FormatInt(v, 10) }
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			value = map[string]interface{} { "doubleValue": v }
+		default:
+			continue
+		}
+
+		kvs = append(kvs, map[string]interface{} { "key": k, "value": value })
+	}
+
+	return kvs
+}
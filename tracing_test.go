@@ -0,0 +1,130 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+
+func TestStartSpan(t *testing.T) {
+	_, span := startSpan(context.Background(), "disabled")
+	if span != nil {
+		t.Fatalf("Span recorded with tracing disabled!")
+	}
+	span.SetAttr("x", 1)
+	span.Finish()
+
+	prevSpans, prevRatio := _traceSpans, _config.TraceSampleRatio
+	defer func() { _traceSpans, _config.TraceSampleRatio = prevSpans, prevRatio }()
+	_traceSpans = make(chan *Span, 4)
+	_config.TraceSampleRatio = 1.0
+
+	ctx, root := startSpan(context.Background(), "root")
+	_, child := startSpan(ctx, "child")
+	if root == nil || child == nil {
+		t.Fatalf("Spans not recorded!")
+	}
+	if child.TraceId != root.TraceId || child.ParentId != root.SpanId || root.ParentId != [8]byte {} {
+		t.Errorf("Unexpected span relationship: %+v %+v", root, child)
+	}
+
+	child.SetAttr("boats", 3)
+	child.SetError("failed")
+	child.Finish()
+	root.Finish()
+	if len(_traceSpans) != 2 {
+		t.Errorf("Expected 2 queued spans, but got %d!", len(_traceSpans))
+	}
+
+	// Neither an unsampled root span nor its children are recorded.
+	_config.TraceSampleRatio = 0.0
+	ctx, root = startSpan(context.Background(), "root")
+	_, child = startSpan(ctx, "child")
+	if root != nil || child != nil {
+		t.Errorf("Unsampled spans recorded!")
+	}
+}
+
+func TestExportSpans(t *testing.T) {
+	var body []byte
+	collector := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	prevEndpoint := _config.TraceEndpoint
+	defer func() { _config.TraceEndpoint = prevEndpoint }()
+	_config.TraceEndpoint = collector.URL
+
+	root := &Span { TraceId: [16]byte { 1 }, SpanId: [8]byte { 2 }, Name: "iteration" }
+	child := &Span { TraceId: root.TraceId, SpanId: [8]byte { 3 }, ParentId: root.SpanId, Name: "sim.poll", Err: "failed" }
+	child.SetAttr("boats", 3)
+
+	err := exportSpans(collector.Client(), []*Span { root, child })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceId string
+					SpanId string
+					ParentSpanId string
+					Name string
+					Attributes []struct {
+						Key string
+						Value map[string]interface{}
+					}
+					Status *struct {
+						Code int
+					}
+				}
+			}
+		}
+	}
+	err = json.Unmarshal(body, &req)
+	if err != nil || len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Unexpected export request: %s", body)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].ParentSpanId != "" || spans[1].ParentSpanId != spans[0].SpanId || spans[1].TraceId != spans[0].TraceId {
+		t.Fatalf("Unexpected spans: %s", body)
+	}
+	if spans[0].Status != nil || spans[1].Status == nil || spans[1].Status.Code != TRACE_STATUS_ERROR {
+		t.Errorf("Unexpected span status: %s", body)
+	}
+	if len(spans[1].Attributes) != 1 || spans[1].Attributes[0].Key != "boats" || spans[1].Attributes[0].Value["intValue"] != "3" {
+		t.Errorf("Unexpected span attributes: %s", body)
+	}
+
+	collector.Config.Handler = http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if exportSpans(collector.Client(), []*Span { root }) == nil {
+		t.Errorf("Collector error not returned!")
+	}
+}
@@ -0,0 +1,157 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"time"
+)
+
+
+// UDP output:
+//
+// Optionally (with -udp-output), each tracked boat's live data is also sent
+// as UDP datagrams to a fixed address every iteration, regardless of which
+// (if any) WebSocket clients are connected, e.g. to feed a chart plotter
+// such as OpenCPN, or a telemetry logger, on the same LAN. There's one
+// datagram per boat, in one of two formats (-udp-output-format):
+//
+// nmea  An AIS type 18 position report (see ais.go), ended with CR LF, with
+//       SOG, COG and heading (CTW), so that all boats show up as AIS
+//       targets.
+// json  {"mmsi":<n>,"name":"...","lat":...,"lon":...,"ctw":...,"stw":...,
+//       "cog":...,"sog":...,"lws":...,"ha":...,"ts":<ms>}
+//
+// Boats are identified by the same pseudo MMSI as in AIS output, from their
+// name if it's cached (see boat-info.go), or their boat key otherwise, and
+// by their name (if known) in JSON. Boat keys are never sent.
+//
+// Datagrams are sent by a goroutine of their own, from a bounded queue, so a
+// slow network never holds up the main loop: iterations are dropped if the
+// queue is full.
+
+const UDP_OUTPUT_FORMAT_NMEA = "nmea"
+const UDP_OUTPUT_FORMAT_JSON = "json"
+
+const UDP_OUTPUT_QUEUE_SIZE = 16
+
+
+type UdpOutputBatch struct {
+	Time time.Time
+	Resps map[string]BoatDataLiveRespMsg
+}
+
+type UdpOutputMsg struct {
+	Mmsi uint32 `json:"mmsi"`
+	Name string `json:"name,omitempty"`
+	BoatDataLiveRespMsg
+}
+
+var _udpOutputQueue = make(chan *UdpOutputBatch, UDP_OUTPUT_QUEUE_SIZE)
+
+
+func udpOutputEnabled() bool {
+	return _config.UdpOutput != ""
+}
+
+func udpOutputInit() {
+	if !udpOutputEnabled() {
+		return
+	}
+
+	conn, err := net.Dial("udp", _config.UdpOutput)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Println("Sending live data (" + _config.UdpOutputFormat + ") over UDP to: " + _config.UdpOutput)
+	go udpOutputMain(conn)
+}
+
+// Hands off one iteration's worth of boat data for UDP output, without blocking.
+func udpOutputResps(t time.Time, resps map[string]BoatDataLiveRespMsg) {
+	if !udpOutputEnabled() || len(resps) == 0 {
+		return
+	}
+
+	select {
+	case _udpOutputQueue <- &UdpOutputBatch { t, resps }:
+	default:
+		log.Println("UDP output queue full; dropping data for this iteration.")
+	}
+}
+
+func udpOutputMain(conn net.Conn) {
+	for batch := range _udpOutputQueue {
+		for _, d := range udpOutputDatagrams(batch, _config.UdpOutputFormat) {
+			_, err := conn.Write(d)
+			if err != nil {
+				log.Println("UDP output: " + err.Error())
+				break // Likely the same for the rest of this iteration.
+			}
+		}
+	}
+}
+
+// Creates the datagrams for one iteration's worth of boat data.
+func udpOutputDatagrams(batch *UdpOutputBatch, format string) [][]byte {
+	datagrams := make([][]byte, 0, len(batch.Resps))
+
+	for boatKey, resp := range batch.Resps {
+		name := ""
+		if info := cachedBoatInfo(boatKey); info != nil {
+			name = info.Name
+		}
+
+		mmsi := aisPseudoMmsi(boatKey)
+		if name != "" {
+			mmsi = aisPseudoMmsi(name)
+		}
+
+		if format == UDP_OUTPUT_FORMAT_JSON {
+			resp.Ts = batch.Time.UnixMilli()
+			resp.Age = 0
+			resp.Interp = false
+			d, err := json.Marshal(&UdpOutputMsg { Mmsi: mmsi, Name: name, BoatDataLiveRespMsg: resp })
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			datagrams = append(datagrams, d)
+		} else {
+			datagrams = append(datagrams, []byte(encodeAisType18(&AisType18 {
+				Mmsi: mmsi,
+				Sog: resp.Sog,
+				Lat: resp.Lat,
+				Lon: resp.Lon,
+				Cog: resp.Cog,
+				Heading: int(math.Round(resp.Ctw)) % 360,
+				Second: batch.Time.UTC().Second(),
+				Flags: AIS_TYPE_18_FLAGS,
+				Radio: 0,
+				Channel: "B",
+			}) + "\r\n"))
+		}
+	}
+
+	return datagrams
+}
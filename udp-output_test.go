@@ -0,0 +1,91 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestUdpOutputDatagrams(t *testing.T) {
+	_boatInfoLock.Lock()
+	_boatInfoCache["udp-named"] = BoatInfoCacheEntry { &SimBoatInfo { Name: "Named" }, time.Now().Add(time.Minute) }
+	_boatInfoLock.Unlock()
+
+	now := time.UnixMilli(1700000000123)
+	batch := &UdpOutputBatch { now, map[string]BoatDataLiveRespMsg {
+		"udp-named": { Lat: 1.5, Lon: -2.5, Ctw: 359.7, Sog: 6.0, Age: 5 },
+	} }
+
+	d := udpOutputDatagrams(batch, UDP_OUTPUT_FORMAT_JSON)
+	if len(d) != 1 {
+		t.Fatalf("Unexpected datagrams: %q", d)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(d[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg["mmsi"] != float64(aisPseudoMmsi("Named")) || msg["name"] != "Named" || msg["lat"] != 1.5 || msg["ts"] != 1700000000123.0 || msg["age"] != nil {
+		t.Fatalf("Unexpected message: %s", d[0])
+	}
+	if strings.Contains(string(d[0]), "udp-named") {
+		t.Fatalf("Boat key sent: %s", d[0])
+	}
+
+	d = udpOutputDatagrams(batch, UDP_OUTPUT_FORMAT_NMEA)
+	if len(d) != 1 || !strings.HasPrefix(string(d[0]), "!AIVDM,1,1,,B,") || !strings.HasSuffix(string(d[0]), "\r\n") {
+		t.Fatalf("Unexpected datagrams: %q", d)
+	}
+
+	// Unnamed boats are identified by their boat key.
+	batch.Resps = map[string]BoatDataLiveRespMsg { "udp-unnamed": { Lat: 1.0 } }
+	d = udpOutputDatagrams(batch, UDP_OUTPUT_FORMAT_JSON)
+	if len(d) != 1 || string(d[0])[:len(`{"mmsi":`)] != `{"mmsi":` || strings.Contains(string(d[0]), "name") {
+		t.Fatalf("Unexpected datagrams: %q", d)
+	}
+}
+
+func TestUdpOutputSend(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go udpOutputMain(conn)
+	_udpOutputQueue <- &UdpOutputBatch { time.Now(), map[string]BoatDataLiveRespMsg { "udp-send": { Lat: 1.0, Lon: 2.0 } } }
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf[:n]), "!AIVDM,") {
+		t.Fatalf("Unexpected datagram: %q", buf[:n])
+	}
+}
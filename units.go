@@ -0,0 +1,140 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"log"
+	"math"
+)
+
+
+// Units:
+//
+// A subscription request (bdl, bdl_g, bdl_x, group_all or gdl) may include
+// "units":{"speed":"<unit>","heading":"<reference>"}, so that thin clients
+// don't need conversion logic. Speeds (stw, sog, lws and, for bdl_x,
+// cur_drift, as well as wind speeds in wind areas) are sent in knots ("kn",
+// the default), kilometres per hour ("kmh") or metres per second ("ms").
+// Headings and courses are always relative to true north ("true"), since the
+// simulator doesn't provide magnetic variation data, so "magnetic" is rejected.
+// "others" (which only include positions and courses) are unaffected.
+
+const UNITS_SPEED_KN = "kn"
+const UNITS_SPEED_KMH = "kmh"
+const UNITS_SPEED_MS = "ms"
+
+const UNITS_HEADING_TRUE = "true"
+const UNITS_HEADING_MAGNETIC = "magnetic"
+
+const ERR_UNITS_UNAVAILABLE = "units_unavailable"
+
+type Units struct {
+	Speed string `json:"speed"`
+	Heading string `json:"heading"`
+}
+
+// Conversion factors from knots
+var _speedFactors = map[string]float64 {
+	UNITS_SPEED_KN: 1.0,
+	UNITS_SPEED_KMH: 1.852,
+	UNITS_SPEED_MS: 1852.0 / 3600.0,
+}
+
+
+// Checks the units requested by a client, returning them (nil if the defaults are wanted) and whether they're valid.
+func reqUnits(req *ReqMsg, conn *WsConn) (*Units, bool) {
+	if req.Units == nil {
+		return nil, true
+	}
+
+	units := &Units {
+		Speed: req.Units.Speed,
+		Heading: req.Units.Heading,
+	}
+	if units.Speed == "" {
+		units.Speed = UNITS_SPEED_KN
+	}
+	if units.Heading == "" {
+		units.Heading = UNITS_HEADING_TRUE
+	}
+
+	if units.Heading == UNITS_HEADING_MAGNETIC {
+		log.Println("Client (" + conn.RemoteIp + ") requested magnetic headings")
+
+		sendErrorMsg(conn, ERR_UNITS_UNAVAILABLE, "Magnetic variation data unavailable")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Magnetic headings unavailable")
+		return nil, false
+	}
+
+	if _, exists := _speedFactors[units.Speed]; !exists || units.Heading != UNITS_HEADING_TRUE {
+		log.Println("Client (" + conn.RemoteIp + ") requested invalid units")
+
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Invalid units")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid units")
+		return nil, false
+	}
+
+	if units.Speed == UNITS_SPEED_KN {
+		return nil, true // Nothing to convert
+	}
+
+	return units, true
+}
+
+func (u *Units) speed(kn float64) float64 {
+	return math.Round(kn * _speedFactors[u.Speed] * 100.0) / 100.0 // To nearest 0.01
+}
+
+// Returns a boat's data converted to the requested units, or unchanged if the defaults were requested (u is nil).
+func (u *Units) convert(data BoatDataLiveRespMsg) BoatDataLiveRespMsg {
+	if u == nil {
+		return data
+	}
+
+	data.Stw = u.speed(data.Stw)
+	data.Sog = u.speed(data.Sog)
+	data.Lws = u.speed(data.Lws)
+
+	if data.Wpt != nil {
+		wpt := *data.Wpt
+		wpt.Vmg = u.speed(wpt.Vmg)
+		data.Wpt = &wpt
+	}
+
+	if data.Ext != nil {
+		ext := *data.Ext // Since the original is shared with other connections
+		ext.CurDrift = u.speed(ext.CurDrift)
+		data.Ext = &ext
+	}
+
+	return data
+}
+
+// Returns a wind area converted to the requested units, or unchanged if the defaults were requested (u is nil).
+func (u *Units) convertWindArea(msg *WindAreaMsg) *WindAreaMsg {
+	if u == nil {
+		return msg
+	}
+
+	converted := *msg // Since the original is cached
+	converted.Wind = make([][2]float64, len(msg.Wind))
+	for i, w := range msg.Wind {
+		converted.Wind[i] = [2]float64 { w[0], u.speed(w[1]) }
+	}
+
+	return &converted
+}
@@ -0,0 +1,68 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+
+func TestReqUnits(t *testing.T) {
+	units, ok := reqUnits(&ReqMsg {}, nil)
+	if !ok || units != nil {
+		t.Errorf("Unexpected units for none requested: %+v", units)
+	}
+
+	units, ok = reqUnits(&ReqMsg { Units: &Units { Speed: UNITS_SPEED_KN, Heading: UNITS_HEADING_TRUE } }, nil)
+	if !ok || units != nil {
+		t.Errorf("Unexpected units for defaults requested: %+v", units)
+	}
+
+	units, ok = reqUnits(&ReqMsg { Units: &Units { Speed: UNITS_SPEED_KMH } }, nil)
+	if !ok || units == nil || units.Speed != UNITS_SPEED_KMH || units.Heading != UNITS_HEADING_TRUE {
+		t.Errorf("Unexpected units for km/h requested: %+v", units)
+	}
+}
+
+func TestUnitsConvert(t *testing.T) {
+	var none *Units = nil
+	if data := none.convert(BoatDataLiveRespMsg { Sog: 5.5 }); data.Sog != 5.5 {
+		t.Errorf("Speed changed without units: %g", data.Sog)
+	}
+
+	ext := &BoatDataExt { CurDrift: 2.0 }
+	data := BoatDataLiveRespMsg { Cog: 90.0, Stw: 10.0, Sog: 1.0, Lws: 20.0, Ext: ext }
+
+	kmh := (&Units { Speed: UNITS_SPEED_KMH, Heading: UNITS_HEADING_TRUE }).convert(data)
+	if kmh.Stw != 18.52 || kmh.Sog != 1.85 || kmh.Lws != 37.04 || kmh.Cog != 90.0 || kmh.Ext.CurDrift != 3.7 {
+		t.Errorf("Unexpected data in km/h: %+v (%+v)", kmh, kmh.Ext)
+	}
+	if ext.CurDrift != 2.0 {
+		t.Errorf("Shared extended data modified!")
+	}
+
+	ms := (&Units { Speed: UNITS_SPEED_MS, Heading: UNITS_HEADING_TRUE }).convert(data)
+	if ms.Stw != 5.14 || ms.Lws != 10.29 {
+		t.Errorf("Unexpected data in m/s: %+v", ms)
+	}
+
+	wind := &WindAreaMsg { Wind: [][2]float64 { { 270.0, 10.0 } } }
+	converted := (&Units { Speed: UNITS_SPEED_MS, Heading: UNITS_HEADING_TRUE }).convertWindArea(wind)
+	if converted.Wind[0] != [2]float64 { 270.0, 5.14 } || wind.Wind[0][1] != 10.0 {
+		t.Errorf("Unexpected wind area conversion: %v (from %v)", converted.Wind, wind.Wind)
+	}
+}
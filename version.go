@@ -0,0 +1,121 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+
+// Version info:
+//
+// The connector's version, the git commit and date it was built from, the
+// WebSocket protocol version, and the optional protocol features enabled on
+// this instance are available at /v1/version (for operators verifying
+// deployments, and for browser apps, subject to -cors-origins), and with a
+// "version" command (so that clients can adapt to the server they're
+// connected to):
+//
+// {"type":"version","version":"<semver>","commit":"<hash>","build_date":"<date>","protocol":<n>,"features":[...]}
+//
+// The commit and build date are set at build time, e.g.:
+//
+// go build -ldflags "-X main._gitCommit=$(git rev-parse HEAD) -X main._buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+const VERSION = "1.3.0"
+
+// Set with -ldflags -X (see above)
+var _gitCommit = "unknown"
+var _buildDate = "unknown"
+
+type VersionMsg struct {
+	Type string `json:"type,omitempty"` // Only in WebSocket messages
+	Version string `json:"version"`
+	Commit string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	Protocol int `json:"protocol"`
+	Features []string `json:"features"`
+}
+
+
+// Returns the optional protocol features enabled on this instance.
+func versionFeatures() []string {
+	features := []string { "msgpack", "sessions", "fields", "units", "smooth_cog", "wind_area", "chat", "batch" }
+
+	if _config.HistorySize > 0 {
+		features = append(features, "history")
+	}
+	if _config.HfRate > 0 {
+		features = append(features, "hf")
+	}
+	if _config.InterpRate > 0 {
+		features = append(features, "interp")
+	}
+	if _config.Events {
+		features = append(features, "events")
+	}
+	if _config.RecordDir != "" {
+		features = append(features, "replay")
+	}
+	if _config.GroupMapFile != "" {
+		features = append(features, "gdl")
+	}
+	if _config.AdminToken != "" {
+		features = append(features, "group_all")
+	}
+	if _config.MapToken != "" || _config.AdminToken != "" {
+		features = append(features, "map")
+	}
+	if _config.ReconnectTtl > 0 {
+		features = append(features, "reconnect")
+	}
+	if len(_config.Sims) > 0 {
+		features = append(features, "sims")
+	}
+	if _config.Actions != nil {
+		features = append(features, "actions")
+	}
+
+	return features
+}
+
+func createVersionMsg() *VersionMsg {
+	return &VersionMsg {
+		Version: VERSION,
+		Commit: _gitCommit,
+		BuildDate: _buildDate,
+		Protocol: PROTOCOL_VERSION,
+		Features: versionFeatures(),
+	}
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(createVersionMsg())
+}
+
+func wsReqVersion(conn *WsConn) {
+	msg := createVersionMsg()
+	msg.Type = "version"
+	conn.SendJSON(msg)
+}
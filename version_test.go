@@ -0,0 +1,46 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+
+func TestVersionHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	versionHandler(w, httptest.NewRequest(http.MethodGet, "/v1/version", nil))
+
+	var msg VersionMsg
+	err := json.Unmarshal(w.Body.Bytes(), &msg)
+	if err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response: %d %s", w.Code, w.Body.Bytes())
+	}
+
+	if msg.Type != "" || msg.Version != VERSION || msg.Commit == "" || msg.Protocol != PROTOCOL_VERSION || len(msg.Features) == 0 {
+		t.Errorf("Unexpected version info: %+v", msg)
+	}
+
+	w = httptest.NewRecorder()
+	versionHandler(w, httptest.NewRequest(http.MethodPost, "/v1/version", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST not refused: %d", w.Code)
+	}
+}
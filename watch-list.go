@@ -0,0 +1,229 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+
+// Watch list persistence:
+//
+// With -watch-list, the set of tracked boats (and the group memberships of
+// bdl_g subscriptions) is periodically saved to a file. On startup, the saved
+// boats are tracked straight away, and the saved groups put into the group
+// cache, so that clients reconnecting after a restart don't all have to wait
+// for their boats' first poll, or ask the simulator for their groups at once.
+//
+// Boats tracked only because of the watch list are untracked again after
+// WATCH_LIST_WARM_PERIOD (unless clients have subscribed to them by then),
+// which is also how long the saved groups stay cached.
+//
+// Since the file contains boat keys, it's only readable by its owner.
+
+const WATCH_LIST_VERSION = 1
+const WATCH_LIST_SAVE_INTERVAL = 30 // Iterations
+const WATCH_LIST_WARM_PERIOD = 60 * time.Second
+
+type WatchList struct {
+	Version int `json:"version"`
+	Boats []WatchListBoat `json:"boats"`
+	Groups []WatchListGroup `json:"groups"`
+}
+
+type WatchListBoat struct {
+	BoatKey string `json:"key"`
+	Sim string `json:"sim"`
+}
+
+type WatchListGroup struct {
+	Sim string `json:"sim"`
+	Boats []WatchListMember `json:"boats"`
+}
+
+type WatchListMember struct {
+	BoatKey string `json:"key"`
+	FriendlyName string `json:"name"`
+}
+
+// When boats tracked from the watch list are to be untracked (zero if there are none)
+var _watchListWarmUntil time.Time
+
+// Serializes saving, so that a slow write can't be overtaken by the next one.
+var _watchListSaving = make(chan int, 1)
+
+
+// Loads the watch list (if enabled and saved) and pre-warms the tracker and group cache with it. Called before the main loop starts.
+func watchListInit() {
+	if _config.WatchListFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(_config.WatchListFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return
+	}
+
+	var wl WatchList
+	err = json.Unmarshal(data, &wl)
+	if err != nil || wl.Version != WATCH_LIST_VERSION {
+		log.Println("Ignoring invalid watch list file: " + _config.WatchListFile)
+		return
+	}
+
+	now := time.Now()
+
+	_lock.Lock()
+	numBoats := 0
+	for _, boat := range wl.Boats {
+		if !_boatKeyRegexp.MatchString(boat.BoatKey) || simClient(boat.Sim) == nil {
+			continue
+		}
+
+		if entry, exists := _trackedBoats[boat.BoatKey]; exists && entry.Warm {
+			continue // Duplicate
+		}
+
+		trackBoat(boat.Sim, boat.BoatKey)
+		_trackedBoats[boat.BoatKey].Warm = true
+		numBoats++
+	}
+	_watchListWarmUntil = now.Add(WATCH_LIST_WARM_PERIOD)
+	_lock.Unlock()
+
+	numGroups := 0
+	for _, group := range wl.Groups {
+		if simClient(group.Sim) == nil {
+			continue
+		}
+
+		boats := list.New()
+		for _, m := range group.Boats {
+			if _boatKeyRegexp.MatchString(m.BoatKey) {
+				boats.PushBack(&BoatInfo { m.BoatKey, m.FriendlyName })
+			}
+		}
+
+		if cacheGroup(group.Sim, boats, now.Add(WATCH_LIST_WARM_PERIOD)) {
+			numGroups++
+		}
+	}
+
+	log.Println("Pre-warmed " + strconv.Itoa(numBoats) + " boats and " + strconv.Itoa(numGroups) + " groups from watch list: " + _config.WatchListFile)
+}
+
+// Called (with _lock held) once per iteration, to untrack boats tracked from the watch list once they've had time to be resubscribed to,
+// and to save the watch list every WATCH_LIST_SAVE_INTERVAL iterations.
+func updateWatchList(iterCount int64, now time.Time) {
+	if _config.WatchListFile == "" {
+		return
+	}
+
+	if !_watchListWarmUntil.IsZero() && now.After(_watchListWarmUntil) {
+		_watchListWarmUntil = time.Time {}
+
+		for boatKey, entry := range _trackedBoats {
+			if entry.Warm {
+				entry.Warm = false
+				untrackBoat(boatKey)
+			}
+		}
+	}
+
+	if iterCount % WATCH_LIST_SAVE_INTERVAL != 0 {
+		return
+	}
+
+	wl := collectWatchList()
+
+	select {
+	case _watchListSaving <- 0:
+		go func() {
+			saveWatchList(wl)
+			<-_watchListSaving
+		}()
+	default:
+		log.Println("Still saving previous watch list; skipping.")
+	}
+}
+
+// Collects the tracked boats and subscribed groups. Caller must hold _lock.
+func collectWatchList() *WatchList {
+	wl := &WatchList {
+		Version: WATCH_LIST_VERSION,
+		Boats: make([]WatchListBoat, 0, len(_trackedBoats)),
+		Groups: make([]WatchListGroup, 0),
+	}
+
+	for boatKey, entry := range _trackedBoats {
+		wl.Boats = append(wl.Boats, WatchListBoat { boatKey, entry.Sim })
+	}
+
+	// Group lists are shared between subscriptions (see group-cache.go), so each is only saved once.
+	seen := make(map[*list.List]bool)
+	addGroup := func (connCtx *ConnCtx) {
+		if connCtx.GroupBoats == nil || seen[connCtx.GroupBoats] {
+			return
+		}
+		seen[connCtx.GroupBoats] = true
+
+		group := WatchListGroup { Sim: connCtx.Sim }
+		for e := connCtx.GroupBoats.Front(); e != nil; e = e.Next() {
+			boat := e.Value.(*BoatInfo)
+			group.Boats = append(group.Boats, WatchListMember { boat.BoatKey, boat.FriendlyName })
+		}
+		wl.Groups = append(wl.Groups, group)
+	}
+
+	for _, connCtx := range _conns {
+		addGroup(&connCtx)
+	}
+	for _, session := range _sessions {
+		addGroup(&session.Sub)
+	}
+
+	return wl
+}
+
+// Writes the watch list to a temporary file, then renames it into place, so that a crash never leaves a partial file.
+func saveWatchList(wl *WatchList) {
+	data, err := json.Marshal(wl)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	tmpPath := _config.WatchListFile + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = os.Rename(tmpPath, _config.WatchListFile)
+	if err != nil {
+		log.Println(err)
+	}
+}
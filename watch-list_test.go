@@ -0,0 +1,83 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+
+func TestWatchListPreWarm(t *testing.T) {
+	tracked := testBoatKey(t, 0)
+	member := testBoatKey(t, 1)
+
+	prevFile := _config.WatchListFile
+	_config.WatchListFile = filepath.Join(t.TempDir(), "watch-list.json")
+	defer func() { _config.WatchListFile = prevFile }()
+
+	saveWatchList(&WatchList {
+		Version: WATCH_LIST_VERSION,
+		Boats: []WatchListBoat { { tracked, DEFAULT_SIM }, { tracked, DEFAULT_SIM }, { "invalid", DEFAULT_SIM } },
+		Groups: []WatchListGroup { { DEFAULT_SIM, []WatchListMember { { tracked, "Tracked" }, { member, "Member" } } } },
+	})
+
+	watchListInit()
+	defer func() {
+		_groupCacheLock.Lock()
+		delete(_groupCache, DEFAULT_SIM + "/" + tracked)
+		delete(_groupCache, DEFAULT_SIM + "/" + member)
+		_groupCacheLock.Unlock()
+	}()
+
+	_lock.Lock()
+	entry, exists := _trackedBoats[tracked]
+	if !exists || !entry.Warm || entry.RefCount != 1 {
+		t.Errorf("Boat from watch list not tracked (once)!")
+	}
+
+	// A subscription to the boat in the meantime keeps it tracked.
+	trackBoat(DEFAULT_SIM, tracked)
+	_lock.Unlock()
+
+	// Answered from the cache, without asking the simulator.
+	boats := getBoatsInGroup(context.Background(), DEFAULT_SIM, member)
+	if boats == nil || boats.Len() != 2 || boats.Back().Value.(*BoatInfo).FriendlyName != "Member" {
+		t.Errorf("Group from watch list not cached!")
+	}
+
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	updateWatchList(1, time.Now().Add(2 * WATCH_LIST_WARM_PERIOD))
+
+	entry, exists = _trackedBoats[tracked]
+	if !exists || entry.Warm || entry.RefCount != 1 {
+		t.Errorf("Watch list reference not released after warm period!")
+	}
+
+	untrackBoat(tracked)
+
+	wl := collectWatchList()
+	for _, boat := range wl.Boats {
+		if boat.BoatKey == tracked {
+			t.Errorf("Untracked boat still in watch list!")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"log"
+	"math"
+)
+
+
+// Waypoint navigation:
+//
+// A bdl, bdl_g or bdl_x request may include "waypoint":{"lat":<deg>,"lon":<deg>}
+// (or set, move or clear it later with {"cmd":"waypoint","waypoint":{...}},
+// or null), so that thin clients don't need their own navigation math. The
+// subscribed boat's live data (for bdl_g, in "you") then includes
+// "wpt":{"brg":<deg>,"dist":<nm>,"vmg":<speed>,"eta":<s>}: the initial
+// great-circle bearing and distance to the waypoint, the boat's velocity made
+// good toward it (from its COG and SOG, in the subscription's speed unit; see
+// units.go), and the time to reach it at that VMG, in seconds (left out unless
+// the boat is making way toward it). These are worked out from the position
+// as sent (so at the subscription's precision), and "wpt" is always sent,
+// even if not in the selected fields (see fields.go).
+
+const WAYPOINT_ETA_MAX = 100 * 24 * 3600 // Seconds (left out if further away in time than this)
+
+type Waypoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type WaypointInfo struct {
+	Brg float64 `json:"brg"` // Degrees true
+	Dist float64 `json:"dist"` // nm
+	Vmg float64 `json:"vmg"` // Knots (or the requested speed unit)
+	Eta int64 `json:"eta,omitempty"` // Seconds
+}
+
+
+// Checks the waypoint requested by a client, returning it (nil if none) and whether it's valid.
+func reqWaypoint(req *ReqMsg, conn *WsConn) (*Waypoint, bool) {
+	if req.Waypoint == nil {
+		return nil, true
+	}
+
+	if !validLatLon(req.Waypoint.Lat, req.Waypoint.Lon) {
+		log.Println("Client (" + conn.RemoteIp + ") requested invalid waypoint")
+
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Invalid waypoint")
+		conn.CloseWithReason(CLOSE_POLICY_VIOLATION, "Invalid waypoint")
+		return nil, false
+	}
+
+	return &Waypoint { Lat: req.Waypoint.Lat, Lon: req.Waypoint.Lon }, true
+}
+
+// Sets, moves or clears a subscription's waypoint.
+func wsReqWaypoint(conn *WsConn, req *ReqMsg) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	connCtx, exists := _conns[conn]
+	if !exists || connCtx.GroupAll {
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Not subscribed by boat key")
+		return
+	}
+
+	if req.Waypoint != nil && !validLatLon(req.Waypoint.Lat, req.Waypoint.Lon) {
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Invalid waypoint")
+		return
+	}
+
+	connCtx.Waypoint = req.Waypoint
+	_conns[conn] = connCtx
+
+	sendReconnectToken(conn, &connCtx)
+}
+
+// Returns the bearing, distance, VMG and ETA to the waypoint from a boat's data, or nil if there's no waypoint (w is nil).
+func (w *Waypoint) info(data *BoatDataLiveRespMsg) *WaypointInfo {
+	if w == nil {
+		return nil
+	}
+
+	brg := initialBearing(data.Lat, data.Lon, w.Lat, w.Lon)
+	dist := greatCircleDistance(data.Lat, data.Lon, w.Lat, w.Lon) // See geofence.go.
+	vmg := data.Sog * math.Cos((data.Cog - brg) * math.Pi / 180.0)
+
+	info := &WaypointInfo {
+		Brg: normalizeCourse(math.Round(brg * 10.0) / 10.0),
+		Dist: math.Round(dist * 100.0) / 100.0,
+		Vmg: math.Round(vmg * 100.0) / 100.0,
+	}
+	if vmg > 0.0 {
+		if eta := dist / vmg * 3600.0; eta <= WAYPOINT_ETA_MAX {
+			info.Eta = int64(math.Round(eta))
+		}
+	}
+
+	return info
+}
+
+// Returns the initial great-circle bearing (in degrees true) from one position to another.
+func initialBearing(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	p1 := lat1 * math.Pi / 180.0
+	p2 := lat2 * math.Pi / 180.0
+	dl := (lon2 - lon1) * math.Pi / 180.0
+
+	y := math.Sin(dl) * math.Cos(p2)
+	x := math.Cos(p1) * math.Sin(p2) - math.Sin(p1) * math.Cos(p2) * math.Cos(dl)
+	return normalizeCourse(math.Atan2(y, x) * 180.0 / math.Pi)
+}
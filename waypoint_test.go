@@ -0,0 +1,94 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+
+func TestWaypointInfo(t *testing.T) {
+	var w *Waypoint
+	if w.info(&BoatDataLiveRespMsg {}) != nil {
+		t.Fatal("Waypoint info without waypoint")
+	}
+
+	// One degree of latitude due north, sailing north-east at 10 knots.
+	w = &Waypoint { Lat: 46.0, Lon: -30.0 }
+	info := w.info(&BoatDataLiveRespMsg { Lat: 45.0, Lon: -30.0, Cog: 45.0, Sog: 10.0 })
+	if info.Brg != 0.0 || math.Abs(info.Dist - 60.04) > 0.01 || info.Vmg != 7.07 || math.Abs(float64(info.Eta) - 60.04 / 7.0711 * 3600.0) > 10.0 {
+		t.Errorf("Unexpected waypoint info: %+v", info)
+	}
+
+	// Sailing away: no ETA.
+	info = w.info(&BoatDataLiveRespMsg { Lat: 45.0, Lon: -30.0, Cog: 180.0, Sog: 5.0 })
+	if info.Vmg != -5.0 || info.Eta != 0 {
+		t.Errorf("Unexpected waypoint info: %+v", info)
+	}
+
+	// West, across the antimeridian.
+	w = &Waypoint { Lat: 0.0, Lon: 179.5 }
+	info = w.info(&BoatDataLiveRespMsg { Lat: 0.0, Lon: -179.5 })
+	if info.Brg != 270.0 || math.Abs(info.Dist - 60.04) > 0.01 {
+		t.Errorf("Unexpected waypoint info: %+v", info)
+	}
+}
+
+func TestWaypointRespMsg(t *testing.T) {
+	connCtx := &ConnCtx {
+		BoatKey: "f4000000000000000000000000000000",
+		Waypoint: &Waypoint { Lat: 46.0, Lon: -30.0 },
+		Units: &Units { Speed: UNITS_SPEED_KMH, Heading: UNITS_HEADING_TRUE },
+		Fields: map[string]bool { "lat": true },
+	}
+	resp := BoatDataLiveRespMsg { Lat: 45.0, Lon: -30.0, Cog: 0.0, Sog: 10.0 }
+
+	data, err := json.Marshal(createRespMsg(connCtx, resp, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"lat":45,"wpt":{"brg":0,"dist":60.04,"vmg":18.52,"eta":21615}}` {
+		t.Errorf("Unexpected message: %s", data)
+	}
+
+	wc := testQueuedConn(QUEUE_POLICY_DISCONNECT)
+	wsReqWaypoint(wc, &ReqMsg {})
+	expectQueued(t, wc, `{"type":"error","error":"invalid_request","msg":"Not subscribed by boat key"}`)
+	wc.queue = nil
+
+	_lock.Lock()
+	_conns[wc] = *connCtx
+	_lock.Unlock()
+	defer func() {
+		_lock.Lock()
+		delete(_conns, wc)
+		_lock.Unlock()
+	}()
+
+	wsReqWaypoint(wc, &ReqMsg { Waypoint: &Waypoint { Lat: 95.0 } })
+	expectQueued(t, wc, `{"type":"error","error":"invalid_request","msg":"Invalid waypoint"}`)
+
+	wsReqWaypoint(wc, &ReqMsg {})
+	_lock.Lock()
+	defer _lock.Unlock()
+	if _conns[wc].Waypoint != nil {
+		t.Error("Waypoint not cleared")
+	}
+}
@@ -0,0 +1,442 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+
+// Webhooks:
+//
+// With -webhooks, selected events are POSTed as JSON to operator-defined URLs
+// (e.g. of a Discord bot or a scoring system), so that external services can
+// react to them without consuming the WebSocket stream. The file has one
+// webhook per line (blank lines and lines starting with "#" are ignored), and
+// is reloaded automatically when it changes:
+//
+// boat_finished,<url>
+//     A boat finished (with -events, for boats on simulators with tracked boats).
+// boat_entered_area,<url>,<lat_min>,<lon_min>,<lat_max>,<lon_max>,<name>
+//     A tracked boat entered the area (having been seen outside it).
+// connection_surge,<url>,<n>
+//     At least <n> new subscriptions within a minute (once per minute).
+//
+// Deliveries are queued, and made by a couple of workers, with a few retries
+// on failure. With -webhook-secret, each request has an "X-Snsw-Signature:
+// sha256=<hex>" header, the HMAC-SHA256 of its body, so that receivers can
+// check that it's genuine.
+
+const WEBHOOK_BOAT_FINISHED = "boat_finished"
+const WEBHOOK_BOAT_ENTERED_AREA = "boat_entered_area"
+const WEBHOOK_CONNECTION_SURGE = "connection_surge"
+
+const WEBHOOK_CHECK_INTERVAL = 10 * time.Second
+const WEBHOOK_QUEUE_SIZE = 256
+const WEBHOOK_WORKERS = 2
+const WEBHOOK_TIMEOUT = 5 * time.Second
+const WEBHOOK_MAX_ATTEMPTS = 3
+const WEBHOOK_RETRY_BACKOFF = 1 * time.Second // Before the first retry, doubling after each
+const WEBHOOK_SURGE_WINDOW = 1 * time.Minute
+
+type Webhook struct {
+	Event string
+	Url string
+
+	// For WEBHOOK_BOAT_ENTERED_AREA
+	Area MapArea
+	AreaName string
+	inArea map[string]bool // Whether each boat was last seen in the area (guarded by _lock)
+
+	// For WEBHOOK_CONNECTION_SURGE
+	Surge int64
+	surgeFired bool // Within the current window (guarded by _lock)
+}
+
+type WebhookDelivery struct {
+	Url string
+	Body []byte
+}
+
+type WebhookBoatFinishedMsg struct {
+	Event string `json:"event"`
+	Time int64 `json:"time"` // Unix time (ms)
+	Sim string `json:"sim"`
+	BoatKey string `json:"key"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type WebhookBoatEnteredAreaMsg struct {
+	Event string `json:"event"`
+	Time int64 `json:"time"` // Unix time (ms)
+	Sim string `json:"sim"`
+	BoatKey string `json:"key"`
+	Area string `json:"area"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type WebhookConnectionSurgeMsg struct {
+	Event string `json:"event"`
+	Time int64 `json:"time"` // Unix time (ms)
+	Conns int `json:"conns"` // Current subscribed connections
+	New int64 `json:"new"` // New subscriptions within the window
+	Window int64 `json:"window"` // Seconds
+}
+
+var _webhooksLock sync.Mutex
+var _webhooks []*Webhook = nil
+var _webhooksModTime time.Time
+
+var _webhookQueue chan *WebhookDelivery = nil
+
+// Current connection surge window (guarded by _lock)
+var _surgeWindowStart time.Time
+var _surgeWindowBase int64 = 0
+
+var _countWebhooksSent int64 = 0
+var _countWebhookFailures int64 = 0 // Including deliveries dropped due to a full queue
+
+
+func webhooksInit() {
+	if _config.WebhooksFile == "" {
+		return
+	}
+
+	reloadWebhooks()
+
+	_webhookQueue = make(chan *WebhookDelivery, WEBHOOK_QUEUE_SIZE)
+	for i := 0; i < WEBHOOK_WORKERS; i++ {
+		go webhookWorker()
+	}
+
+	go func() {
+		for range time.Tick(WEBHOOK_CHECK_INTERVAL) {
+			reloadWebhooks()
+		}
+	}()
+}
+
+// (Re)loads the webhooks file if it's changed since it was last loaded.
+func reloadWebhooks() {
+	info, err := os.Stat(_config.WebhooksFile)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	_webhooksLock.Lock()
+	unchanged := info.ModTime().Equal(_webhooksModTime)
+	_webhooksLock.Unlock()
+	if unchanged {
+		return
+	}
+
+	hooks := loadWebhooks(_config.WebhooksFile)
+	if hooks == nil {
+		return
+	}
+
+	_webhooksLock.Lock()
+	_webhooks = hooks
+	_webhooksModTime = info.ModTime()
+	_webhooksLock.Unlock()
+}
+
+func loadWebhooks(path string) []*Webhook {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer f.Close()
+
+	hooks := make([]*Webhook, 0)
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hook := parseWebhook(strings.Split(line, ","))
+		if hook == nil {
+			log.Println("Ignoring invalid line " + strconv.Itoa(n) + " in webhooks file") // (Not the line itself, whose URL may have a secret.)
+			continue
+		}
+
+		if hook.Event == WEBHOOK_BOAT_FINISHED && !_config.Events {
+			log.Println("Webhooks for " + WEBHOOK_BOAT_FINISHED + " need -events")
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	log.Printf("Loaded %d webhooks from %s\n", len(hooks), path)
+	return hooks
+}
+
+// Parses the fields of a webhooks file line, returning nil if invalid.
+func parseWebhook(s []string) *Webhook {
+	if len(s) < 2 {
+		return nil
+	}
+
+	u, err := url.Parse(s[1])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil
+	}
+
+	hook := &Webhook { Event: s[0], Url: s[1] }
+
+	switch hook.Event {
+	case WEBHOOK_BOAT_FINISHED:
+		if len(s) != 2 {
+			return nil
+		}
+
+	case WEBHOOK_BOAT_ENTERED_AREA:
+		if len(s) != 7 || s[6] == "" {
+			return nil
+		}
+
+		var coords [4]float64
+		for i := range coords {
+			coords[i], err = strconv.ParseFloat(s[2 + i], 64)
+			if err != nil {
+				return nil
+			}
+		}
+
+		hook.Area = MapArea { LatMin: coords[0], LonMin: normalizeLon(coords[1]), LatMax: coords[2], LonMax: normalizeLon(coords[3]) }
+		if hook.Area.LatMin < -90.0 || hook.Area.LatMax > 90.0 || hook.Area.LatMin >= hook.Area.LatMax {
+			return nil
+		}
+		hook.AreaName = s[6]
+		hook.inArea = make(map[string]bool)
+
+	case WEBHOOK_CONNECTION_SURGE:
+		if len(s) != 3 {
+			return nil
+		}
+
+		hook.Surge, err = strconv.ParseInt(s[2], 10, 64)
+		if err != nil || hook.Surge <= 0 {
+			return nil
+		}
+
+	default:
+		return nil
+	}
+
+	return hook
+}
+
+// Returns the current webhooks for an event.
+func webhooksFor(event string) []*Webhook {
+	_webhooksLock.Lock()
+	defer _webhooksLock.Unlock()
+
+	var hooks []*Webhook
+	for _, hook := range _webhooks {
+		if hook.Event == event {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// Queues a webhook delivery, dropping it if the queue is full.
+func sendWebhook(hook *Webhook, msg interface{}) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	select {
+	case _webhookQueue <- &WebhookDelivery { Url: hook.Url, Body: body }:
+	default:
+		atomic.AddInt64(&_countWebhookFailures, 1)
+	}
+}
+
+func webhookWorker() {
+	client := &http.Client { Timeout: WEBHOOK_TIMEOUT }
+
+	for d := range _webhookQueue {
+		if err := deliverWebhookRetrying(client, d); err != nil {
+			log.Println("Failed to deliver webhook: " + err.Error())
+			atomic.AddInt64(&_countWebhookFailures, 1)
+		} else {
+			atomic.AddInt64(&_countWebhooksSent, 1)
+		}
+	}
+}
+
+// Delivers a webhook, retrying (with backoff) up to WEBHOOK_MAX_ATTEMPTS times, and returning the last error.
+func deliverWebhookRetrying(client *http.Client, d *WebhookDelivery) error {
+	backoff := WEBHOOK_RETRY_BACKOFF
+	for attempt := 1; ; attempt++ {
+		err := deliverWebhook(client, d)
+		if err == nil || attempt == WEBHOOK_MAX_ATTEMPTS {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func deliverWebhook(client *http.Client, d *WebhookDelivery) error {
+	req, err := http.NewRequest("POST", d.Url, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if _config.WebhookSecret != "" {
+		req.Header.Set("X-Snsw-Signature", "sha256=" + webhookSignature(d.Body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode / 100 != 2 {
+		return &webhookError { resp.StatusCode }
+	}
+	return nil
+}
+
+type webhookError struct {
+	StatusCode int
+}
+
+func (e *webhookError) Error() string {
+	return "Webhook receiver returned HTTP status " + strconv.Itoa(e.StatusCode)
+}
+
+func webhookSignature(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(_config.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Called (with _lock held) for each boat event fetched from a simulator, whether or not the boat is tracked.
+func webhookBoatEvent(sim string, ev SimBoatEvent) {
+	if _webhookQueue == nil || ev.Event != BOAT_EVENT_FINISHED {
+		return
+	}
+
+	for _, hook := range webhooksFor(WEBHOOK_BOAT_FINISHED) {
+		sendWebhook(hook, &WebhookBoatFinishedMsg {
+			Event: WEBHOOK_BOAT_FINISHED,
+			Time: ev.Time * 1000,
+			Sim: sim,
+			BoatKey: ev.BoatKey,
+			Detail: ev.Detail,
+		})
+	}
+}
+
+// Called (with _lock held) once per iteration to check for boats entering areas, and connection surges.
+func updateWebhooks(resps map[string]BoatDataLiveRespMsg, now time.Time) {
+	if _webhookQueue == nil {
+		return
+	}
+
+	for _, hook := range webhooksFor(WEBHOOK_BOAT_ENTERED_AREA) {
+		inArea := make(map[string]bool, len(hook.inArea))
+		for boatKey, resp := range resps {
+			if resp.LastKnown {
+				if was, exists := hook.inArea[boatKey]; exists {
+					inArea[boatKey] = was // Not moving on without fresh data.
+				}
+				continue
+			}
+
+			in := hook.Area.contains(resp.Lat, resp.Lon)
+			if was, exists := hook.inArea[boatKey]; exists && in && !was {
+				sim := DEFAULT_SIM
+				if entry, tracked := _trackedBoats[boatKey]; tracked {
+					sim = entry.Sim
+				}
+
+				sendWebhook(hook, &WebhookBoatEnteredAreaMsg {
+					Event: WEBHOOK_BOAT_ENTERED_AREA,
+					Time: now.UnixMilli(),
+					Sim: sim,
+					BoatKey: boatKey,
+					Area: hook.AreaName,
+					Lat: resp.Lat,
+					Lon: resp.Lon,
+				})
+			}
+			inArea[boatKey] = in
+		}
+		hook.inArea = inArea
+	}
+
+	surgeHooks := webhooksFor(WEBHOOK_CONNECTION_SURGE)
+	if now.Sub(_surgeWindowStart) >= WEBHOOK_SURGE_WINDOW {
+		_surgeWindowStart = now
+		_surgeWindowBase = _countConns
+		for _, hook := range surgeHooks {
+			hook.surgeFired = false
+		}
+	}
+
+	surge := _countConns - _surgeWindowBase
+	for _, hook := range surgeHooks {
+		if hook.surgeFired || surge < hook.Surge {
+			continue
+		}
+
+		hook.surgeFired = true
+		sendWebhook(hook, &WebhookConnectionSurgeMsg {
+			Event: WEBHOOK_CONNECTION_SURGE,
+			Time: now.UnixMilli(),
+			Conns: len(_conns),
+			New: surge,
+			Window: int64(WEBHOOK_SURGE_WINDOW / time.Second),
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+
+func TestParseWebhook(t *testing.T) {
+	hook := parseWebhook([]string { "boat_entered_area", "https://example.com/hook", "40", "170", "50", "-170", "Gate" })
+	if hook == nil || hook.AreaName != "Gate" || !hook.Area.contains(45.0, 180.0) || hook.Area.contains(45.0, 0.0) {
+		t.Errorf("Unexpected area webhook: %+v", hook)
+	}
+
+	hook = parseWebhook([]string { "connection_surge", "http://localhost:8080/", "100" })
+	if hook == nil || hook.Surge != 100 {
+		t.Errorf("Unexpected surge webhook: %+v", hook)
+	}
+
+	for _, s := range [][]string {
+		{ "boat_finished" },
+		{ "boat_finished", "ftp://example.com/" },
+		{ "boat_finished", "https://example.com/", "extra" },
+		{ "boat_entered_area", "https://example.com/", "50", "0", "40", "10", "Backwards" },
+		{ "boat_entered_area", "https://example.com/", "40", "0", "50", "10", "" },
+		{ "connection_surge", "https://example.com/", "0" },
+		{ "boat_deleted", "https://example.com/" },
+	} {
+		if parseWebhook(s) != nil {
+			t.Errorf("Expected invalid webhook: %v", s)
+		}
+	}
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	var signature string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Snsw-Signature")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	webhookSecret := _config.WebhookSecret
+	_config.WebhookSecret = "secret"
+	defer func() { _config.WebhookSecret = webhookSecret }()
+
+	err := deliverWebhook(srv.Client(), &WebhookDelivery { Url: srv.URL, Body: []byte(`{"event":"boat_finished"}`) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HMAC-SHA256 of the body, with "secret" as the key
+	if string(body) != `{"event":"boat_finished"}` || signature != "sha256=" + webhookSignature(body) || len(signature) != 71 {
+		t.Errorf("Unexpected webhook request: %s (%s)", body, signature)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	if deliverWebhook(failing.Client(), &WebhookDelivery { Url: failing.URL }) == nil {
+		t.Errorf("No error for failing webhook receiver!")
+	}
+}
+
+func TestUpdateWebhooks(t *testing.T) {
+	_lock.Lock()
+	defer _lock.Unlock()
+
+	area := parseWebhook([]string { "boat_entered_area", "https://example.com/area", "40", "-40", "50", "-30", "Gate" })
+	surge := parseWebhook([]string { "connection_surge", "https://example.com/surge", "3" })
+
+	_webhooksLock.Lock()
+	_webhooks = []*Webhook { area, surge }
+	_webhooksLock.Unlock()
+	_webhookQueue = make(chan *WebhookDelivery, 10)
+	defer func() {
+		_webhooksLock.Lock()
+		_webhooks = nil
+		_webhooksLock.Unlock()
+		_webhookQueue = nil
+	}()
+
+	expect := func (event string) {
+		t.Helper()
+
+		select {
+		case d := <-_webhookQueue:
+			var msg map[string]interface{}
+			json.Unmarshal(d.Body, &msg)
+			if msg["event"] != event {
+				t.Errorf("Expected %s webhook, but got: %s", event, d.Body)
+			}
+		default:
+			if event != "" {
+				t.Errorf("Expected %s webhook, but got none", event)
+			}
+		}
+	}
+
+	now := time.Now()
+	updateWebhooks(map[string]BoatDataLiveRespMsg { "outside": { Lat: 35.0, Lon: -35.0 }, "inside": { Lat: 45.0, Lon: -35.0 } }, now)
+	expect("") // First sightings, inside or not, aren't entries.
+
+	updateWebhooks(map[string]BoatDataLiveRespMsg { "outside": { Lat: 45.0, Lon: -35.0 }, "inside": { Lat: 45.0, Lon: -35.0 } }, now.Add(time.Second))
+	expect(WEBHOOK_BOAT_ENTERED_AREA)
+	expect("")
+
+	// A surge is reported once per window.
+	_countConns += 3
+	defer func() { _countConns -= 3 }()
+	updateWebhooks(nil, now.Add(2 * time.Second))
+	expect(WEBHOOK_CONNECTION_SURGE)
+	updateWebhooks(nil, now.Add(3 * time.Second))
+	expect("")
+}
@@ -0,0 +1,170 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+
+// Wind area:
+//
+// A subscribed connection may send a "wind_area" request for a grid of wind
+// vectors around its boat. The grid is aligned to multiples of its step size
+// (so that nearby boats asking for the same grid share a cached result), and
+// is fetched from the simulator one point at a time with "wind,<lat>,<lon>"
+// requests, each answered with "wind,<lat>,<lon>,ok,<dir>,<speed>".
+
+const WIND_AREA_DEFAULT_SIZE = 11
+const WIND_AREA_MAX_SIZE = 21 // Points per side
+const WIND_AREA_DEFAULT_STEP = 0.5
+const WIND_AREA_MIN_STEP = 0.1 // Degrees
+const WIND_AREA_MAX_STEP = 2.0
+const WIND_AREA_CACHE_TTL = 10 * time.Second
+
+const ERR_WIND_UNAVAILABLE = "wind_unavailable"
+
+type WindAreaMsg struct {
+	Type string `json:"type"`
+	Lat0 float64 `json:"lat0"`
+	Lon0 float64 `json:"lon0"`
+	Step float64 `json:"step"`
+	Size int `json:"size"`
+	Wind [][2]float64 `json:"wind"` // [dir, speed], row by row from (lat0, lon0), with lat then lon increasing
+}
+
+type WindAreaCacheEntry struct {
+	Ready chan int // Closed once Msg has been fetched (and is nil on failure)
+	Msg *WindAreaMsg
+	Expires time.Time
+}
+
+var _windAreaLock sync.Mutex
+var _windAreaCache = make(map[string]*WindAreaCacheEntry)
+
+
+func wsReqWindArea(req *ReqMsg, conn *WsConn) {
+	_lock.Lock()
+	connCtx, subscribed := _conns[conn]
+	data, exists := _latestResps[connCtx.BoatKey]
+	_lock.Unlock()
+
+	if !subscribed || !exists {
+		sendErrorMsg(conn, ERR_WIND_UNAVAILABLE, "No boat position available")
+		return
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = WIND_AREA_DEFAULT_SIZE
+	}
+	step := req.Step
+	if step == 0 {
+		step = WIND_AREA_DEFAULT_STEP
+	}
+	if size < 1 || size > WIND_AREA_MAX_SIZE || step < WIND_AREA_MIN_STEP || step > WIND_AREA_MAX_STEP {
+		sendErrorMsg(conn, ERR_INVALID_REQUEST, "Invalid wind area size or step")
+		return
+	}
+
+	// Align the grid, keeping it within valid latitudes.
+	half := float64(size - 1) / 2.0 * step
+	lat0 := math.Floor((data.Lat - half) / step) * step
+	lat0 = math.Max(-90.0, math.Min(90.0 - float64(size - 1) * step, lat0))
+	lon0 := math.Floor((data.Lon - half) / step) * step
+
+	msg := getWindArea(connCtx.Sim, lat0, lon0, step, size)
+	if msg == nil {
+		sendErrorMsg(conn, ERR_WIND_UNAVAILABLE, "Wind data unavailable")
+		return
+	}
+
+	conn.SendJSON(connCtx.Units.convertWindArea(msg))
+}
+
+// Gets a wind area from the cache, or else from the simulator (with concurrent identical requests sharing one fetch).
+func getWindArea(sim string, lat0 float64, lon0 float64, step float64, size int) *WindAreaMsg {
+	cacheKey := fmt.Sprintf("%s/%.4f,%.4f,%.4f,%d", sim, lat0, lon0, step, size)
+	now := time.Now()
+
+	_windAreaLock.Lock()
+	for k, e := range _windAreaCache {
+		if now.After(e.Expires) {
+			delete(_windAreaCache, k)
+		}
+	}
+
+	entry, exists := _windAreaCache[cacheKey]
+	if !exists {
+		entry = &WindAreaCacheEntry {
+			Ready: make(chan int),
+			Expires: now.Add(WIND_AREA_CACHE_TTL),
+		}
+		_windAreaCache[cacheKey] = entry
+	}
+	_windAreaLock.Unlock()
+
+	if exists {
+		<-entry.Ready
+		return entry.Msg
+	}
+
+	entry.Msg = fetchWindArea(sim, lat0, lon0, step, size)
+	if entry.Msg == nil {
+		// Don't cache failures.
+		_windAreaLock.Lock()
+		delete(_windAreaCache, cacheKey)
+		_windAreaLock.Unlock()
+	}
+	close(entry.Ready)
+
+	return entry.Msg
+}
+
+
+func fetchWindArea(sim string, lat0 float64, lon0 float64, step float64, size int) *WindAreaMsg {
+	wind := simClient(sim).GetWindArea(lat0, lon0, step, size)
+	if wind == nil {
+		return nil
+	}
+
+	for i := range wind {
+		wind[i] = [2]float64 { normalizeCourse(wind[i][0]), clampFinite(wind[i][1], 0.0, SIM_MAX_SPEED) } // See normalize.go.
+	}
+
+	return &WindAreaMsg {
+		Type: "wind_area",
+		Lat0: lat0,
+		Lon0: normalizeLon(lon0),
+		Step: step,
+		Size: size,
+		Wind: wind,
+	}
+}
+
+func formatWindAreaCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// Normalizes a longitude to [-180, 180).
+func normalizeLon(lon float64) float64 {
+	return math.Mod(math.Mod(lon + 180.0, 360.0) + 360.0, 360.0) - 180.0
+}
@@ -0,0 +1,334 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+)
+
+
+// Wire formats:
+//
+// Clients may declare a WebSocket subprotocol (Sec-WebSocket-Protocol) when
+// connecting, to select the wire format of the connection: JSON
+// (SUBPROTOCOL_JSON, also the default if no known subprotocol is declared),
+// MessagePack (SUBPROTOCOL_MSGPACK), NMEA 0183 (SUBPROTOCOL_NMEA; see
+// nmea.go), Signal K (SUBPROTOCOL_SIGNALK; see signalk.go) or compact CSV
+// (SUBPROTOCOL_CSV; see csv.go). Messages are
+// always built as JSON, and converted by the connection's encoder (see
+// encoder.go) in its writer, so not holding up the main loop. For
+// MessagePack, they're sent as binary messages, with object keys in the same
+// order. Likewise, binary request messages on MessagePack connections are
+// converted to JSON before being decoded, so requests are the same in all
+// formats (text requests are always taken as JSON).
+
+const SUBPROTOCOL_JSON = "sailnavsim.v1.json"
+const SUBPROTOCOL_MSGPACK = "sailnavsim.v1.msgpack"
+const SUBPROTOCOL_NMEA = "sailnavsim.v1.nmea"
+const SUBPROTOCOL_SIGNALK = "sailnavsim.v1.signalk"
+const SUBPROTOCOL_CSV = "sailnavsim.v1.csv"
+
+// Subprotocols supported, in order of preference
+var _subprotocols = []string { SUBPROTOCOL_JSON, SUBPROTOCOL_MSGPACK, SUBPROTOCOL_NMEA, SUBPROTOCOL_SIGNALK, SUBPROTOCOL_CSV }
+
+var errMsgpackInvalid = errors.New("Invalid MessagePack data")
+
+
+// Converts a JSON message to MessagePack.
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	err := jsonValueToMsgpack(dec, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func jsonValueToMsgpack(dec *json.Decoder, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		msgpackNumber(buf, v)
+	case string:
+		msgpackString(buf, v)
+
+	case json.Delim:
+		// Elements are converted first, as arrays and maps are preceded by their length.
+		var elems bytes.Buffer
+		n := 0
+		for dec.More() {
+			if v == '{' {
+				tok, err = dec.Token()
+				if err != nil {
+					return err
+				}
+				msgpackString(&elems, tok.(string))
+			}
+
+			err = jsonValueToMsgpack(dec, &elems)
+			if err != nil {
+				return err
+			}
+			n++
+		}
+
+		_, err = dec.Token() // Closing delimiter
+		if err != nil {
+			return err
+		}
+
+		if v == '{' {
+			msgpackHeader(buf, n, 0x80, 0xde, 0xdf)
+		} else {
+			msgpackHeader(buf, n, 0x90, 0xdc, 0xdd)
+		}
+		buf.Write(elems.Bytes())
+	}
+
+	return nil
+}
+
+// Writes a map or array header (fixmap/fixarray, or 16 or 32 bit).
+func msgpackHeader(buf *bytes.Buffer, n int, fix byte, code16 byte, code32 byte) {
+	if n < 16 {
+		buf.WriteByte(fix | byte(n))
+	} else if n <= math.MaxUint16 {
+		buf.WriteByte(code16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	} else {
+		buf.WriteByte(code32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func msgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	if n < 32 {
+		buf.WriteByte(0xa0 | byte(n))
+	} else if n <= math.MaxUint8 {
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	} else if n <= math.MaxUint16 {
+		buf.WriteByte(0xda)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	} else {
+		buf.WriteByte(0xdb)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+	buf.WriteString(s)
+}
+
+// Writes a number as an integer if it is one (and fits in 64 bits), or otherwise as a float64.
+func msgpackNumber(buf *bytes.Buffer, num json.Number) {
+	if i, err := strconv.ParseInt(string(num), 10, 64); err == nil {
+		if i >= 0 && i < 128 {
+			buf.WriteByte(byte(i))
+		} else if i < 0 && i >= -32 {
+			buf.WriteByte(byte(int8(i)))
+		} else {
+			buf.WriteByte(0xd3)
+			buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		}
+		return
+	}
+
+	if u, err := strconv.ParseUint(string(num), 10, 64); err == nil {
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+		return
+	}
+
+	f, _ := num.Float64()
+	buf.WriteByte(0xcb)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+// Converts a MessagePack request message to JSON, failing if it's nested more deeply than maxDepth.
+func msgpackToJson(data []byte, maxDepth int) ([]byte, error) {
+	r := bytes.NewReader(data)
+
+	v, err := msgpackValue(r, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Len() != 0 {
+		return nil, errMsgpackInvalid
+	}
+
+	return json.Marshal(v)
+}
+
+func msgpackValue(r *bytes.Reader, depth int) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, errMsgpackInvalid
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return msgpackMap(r, int(c & 0x0f), depth)
+	case c >= 0x90 && c <= 0x9f:
+		return msgpackArray(r, int(c & 0x0f), depth)
+	case c >= 0xa0 && c <= 0xbf:
+		return msgpackStr(r, uint64(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := msgpackUint(r, 1 << (c - 0xcc))
+		return u, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		u, err := msgpackUint(r, 1 << (c - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		bits := uint(8) << (c - 0xd0)
+		return int64(u << (64 - bits)) >> (64 - bits), nil // Sign extended
+	case 0xca:
+		u, err := msgpackUint(r, 4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := msgpackUint(r, 8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb, 0xc4, 0xc5, 0xc6: // Strings, and binary taken as strings
+		size := 1 << (c - 0xd9)
+		if c <= 0xc6 {
+			size = 1 << (c - 0xc4)
+		}
+		n, err := msgpackUint(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackStr(r, n)
+	case 0xdc, 0xdd:
+		n, err := msgpackUint(r, 2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackArray(r, int(n), depth)
+	case 0xde, 0xdf:
+		n, err := msgpackUint(r, 2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackMap(r, int(n), depth)
+	}
+
+	return nil, errMsgpackInvalid
+}
+
+func msgpackUint(r *bytes.Reader, size int) (uint64, error) {
+	b := make([]byte, 8)
+	_, err := io.ReadFull(r, b[8 - size:])
+	if err != nil {
+		return 0, errMsgpackInvalid
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func msgpackStr(r *bytes.Reader, n uint64) (string, error) {
+	if n > uint64(r.Len()) {
+		return "", errMsgpackInvalid
+	}
+
+	b := make([]byte, n)
+	io.ReadFull(r, b)
+	return string(b), nil
+}
+
+func msgpackArray(r *bytes.Reader, n int, depth int) (interface{}, error) {
+	if depth == 0 {
+		return nil, errReqTooDeep
+	}
+	if n > r.Len() {
+		// Every element takes at least a byte.
+		return nil, errMsgpackInvalid
+	}
+
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := msgpackValue(r, depth - 1)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+
+	return a, nil
+}
+
+func msgpackMap(r *bytes.Reader, n int, depth int) (interface{}, error) {
+	if depth == 0 {
+		return nil, errReqTooDeep
+	}
+	if n > r.Len() / 2 {
+		return nil, errMsgpackInvalid
+	}
+
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := msgpackValue(r, depth - 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, errMsgpackInvalid
+		}
+
+		v, err := msgpackValue(r, depth - 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+
+	return m, nil
+}
@@ -0,0 +1,82 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+
+func TestJsonToMsgpack(t *testing.T) {
+	data, err := jsonToMsgpack([]byte(`{"lat":45.5,"n":-3,"big":300,"ok":true,"none":null,"a":["x"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte {
+		0x86,
+		0xa3, 'l', 'a', 't', 0xcb, 0x40, 0x46, 0xc0, 0, 0, 0, 0, 0,
+		0xa1, 'n', 0xfd,
+		0xa3, 'b', 'i', 'g', 0xd3, 0, 0, 0, 0, 0, 0, 0x01, 0x2c,
+		0xa2, 'o', 'k', 0xc3,
+		0xa4, 'n', 'o', 'n', 'e', 0xc0,
+		0xa1, 'a', 0x91, 0xa1, 'x',
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("Got %x, expected %x", data, expected)
+	}
+
+	// Requests converted back to JSON decode the same.
+	back, err := msgpackToJson(data, REQ_MAX_DEPTH)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v1, v2 map[string]interface{}
+	json.Unmarshal(back, &v1)
+	json.Unmarshal([]byte(`{"a":["x"],"big":300,"lat":45.5,"n":-3,"none":null,"ok":true}`), &v2)
+	if !jsonEqual(v1, v2) {
+		t.Errorf("Got %s back", back)
+	}
+}
+
+func TestMsgpackToJsonInvalid(t *testing.T) {
+	deep := bytes.Repeat([]byte { 0x91 }, REQ_MAX_DEPTH + 1)
+	deep = append(deep, 0xc0)
+
+	for _, data := range [][]byte {
+		{},
+		{ 0xc1 }, // Never used
+		{ 0xa3, 'a' }, // Truncated string
+		{ 0x81, 0x01, 0x02 }, // Non-string key
+		{ 0xdd, 0xff, 0xff, 0xff, 0xff }, // Huge array
+		{ 0xc0, 0xc0 }, // Trailing data
+		deep,
+	} {
+		if _, err := msgpackToJson(data, REQ_MAX_DEPTH); err == nil {
+			t.Errorf("Invalid data accepted: %x", data)
+		}
+	}
+}
+
+func jsonEqual(a interface{}, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,487 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+
+// Outbound message queueing:
+//
+// Every WebSocket connection has a bounded queue of outgoing messages, drained
+// by its own writer goroutine, so that a slow client never blocks the main
+// loop (or anything else holding _lock). When the queue is full, live data
+// messages are handled according to the connection's overflow policy:
+//
+// - "drop-oldest": the oldest queued live data message is dropped.
+// - "coalesce": all queued live data messages are dropped in favour of the new one.
+// - "disconnect": the connection is closed.
+//
+// With the "conflate" policy, a slow client never gets stale positions, even
+// before its queue fills up: a new live data message for a boat replaces (in
+// place) any live data message for the same boat still waiting to be sent. If
+// the queue is full anyway (of other messages), the oldest is dropped.
+//
+// Chat messages (see chat.go) are dropped as live data messages are, but are
+// never conflated, as each one counts.
+//
+// Other (control) messages are never dropped, and may exceed the queue size,
+// but a connection with CONTROL_QUEUE_MAX of them queued is closed, as its
+// client isn't keeping up (and its queue would otherwise grow without bound).
+//
+// Each write has a deadline (-write-timeout), so that a dead peer can't hang
+// its writer goroutine (and keep the connection open) indefinitely.
+
+const QUEUE_POLICY_DROP_OLDEST = "drop-oldest"
+const QUEUE_POLICY_COALESCE = "coalesce"
+const QUEUE_POLICY_DISCONNECT = "disconnect"
+const QUEUE_POLICY_CONFLATE = "conflate"
+
+// Connection types, for the purpose of per-type queue policy overrides
+const CONN_TYPE_BDL = "bdl"
+const CONN_TYPE_BDL_G = "bdl_g"
+const CONN_TYPE_SPECTATOR = "spectator"
+const CONN_TYPE_REPLAY = "replay"
+const CONN_TYPE_GROUP_ALL = "group_all"
+
+// Control messages (and pings) queued on a connection, beyond which it's closed. Well above what a
+// resumed session replays at once (see session.go).
+const CONTROL_QUEUE_MAX = 4 * SESSION_BUFFER_SIZE
+
+// Time allowed for sending the close message on a graceful close
+const CLOSE_WRITE_TIMEOUT = time.Second
+
+type QueuedMsg struct {
+	Data []byte
+	Live bool
+	Key string // Boat key the live data is for ("" if not known)
+	Chat bool // A chat message, queued as live data (see chat.go)
+	Ping bool // WebSocket ping, rather than data (see conn-stats.go)
+	Prepared *websocket.PreparedMessage // Data prepared once for all connections sharing it (see shared-streams.go), or nil
+	Arrived time.Time // When the live data arrived (if known), for measuring delivery latency
+}
+
+type WsConn struct {
+	Conn *websocket.Conn
+	RemoteIp string // Of the client (see client-ip.go)
+	CreatedAt time.Time
+	Encoder Encoder // For the wire format (see encoder.go)
+
+	lock sync.Mutex
+	cond *sync.Cond
+	queue []QueuedMsg
+	policy string
+	closing bool // Close once the queue has been drained.
+	closeCode int // Sent on closing (normal closure, if 0; see close-codes.go)
+	closeReason string
+	closed bool
+	batch bool // Combine queued messages into one frame (see batching.go).
+	held bool // Don't write until the main loop's done queueing an iteration's messages.
+	msgSeq bool // Number queued messages (see msg-seq.go).
+	nextMseq uint64
+	lastLive QueuedMsg // Most recent live data message queued, for keyframes (see msg-seq.go)
+
+	Dropped uint64
+	Coalesced uint64
+	Conflated uint64
+	rttUs int64 // Most recently measured round trip time (0 if not yet measured; see conn-stats.go)
+}
+
+var _countQueueDropped int64 = 0
+var _countQueueCoalesced int64 = 0
+var _countQueueConflated int64 = 0
+var _countQueueDisconnects int64 = 0
+var _countWriteTimeouts int64 = 0
+
+// All connections whose writers are still running (for closing them on shutdown, or counting them)
+var _wsConnsLock sync.Mutex
+var _wsConns = make(map[*WsConn]bool)
+
+
+func newWsConn(conn *websocket.Conn, remoteIp string) *WsConn {
+	wc := &WsConn {
+		Conn: conn,
+		RemoteIp: remoteIp,
+		CreatedAt: time.Now(),
+		Encoder: encoderFor(conn.Subprotocol()),
+		queue: make([]QueuedMsg, 0, _config.QueueSize),
+		policy: _config.QueuePolicy,
+	}
+	wc.cond = sync.NewCond(&wc.lock)
+
+	conn.SetReadLimit(int64(_config.MaxReqSize))
+	conn.SetPongHandler(wc.pongHandler)
+
+	_wsConnsLock.Lock()
+	_wsConns[wc] = true
+	_wsConnsLock.Unlock()
+
+	go wc.writer()
+
+	return wc
+}
+
+// Returns the number of open connections, whether subscribed or not (e.g. idle, or watching the map or a replay).
+func countWsConns() int {
+	_wsConnsLock.Lock()
+	defer _wsConnsLock.Unlock()
+
+	return len(_wsConns)
+}
+
+func isValidQueuePolicy(policy string) bool {
+	switch policy {
+	case QUEUE_POLICY_DROP_OLDEST, QUEUE_POLICY_COALESCE, QUEUE_POLICY_DISCONNECT, QUEUE_POLICY_CONFLATE:
+		return true
+	}
+	return false
+}
+
+// Parses per-connection-type queue policy overrides, given as "<type>=<policy>[,<type>=<policy>...]".
+func parseQueuePolicyOverrides(s string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	if s == "" {
+		return overrides, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.Split(item, "=")
+		if len(kv) != 2 {
+			return nil, errors.New("Invalid queue policy override: " + item)
+		}
+
+		switch kv[0] {
+		case CONN_TYPE_BDL, CONN_TYPE_BDL_G, CONN_TYPE_SPECTATOR, CONN_TYPE_REPLAY, CONN_TYPE_GROUP_ALL:
+		default:
+			return nil, errors.New("Invalid connection type in queue policy override: " + kv[0])
+		}
+
+		if !isValidQueuePolicy(kv[1]) {
+			return nil, errors.New("Invalid queue policy: " + kv[1])
+		}
+
+		overrides[kv[0]] = kv[1]
+	}
+
+	return overrides, nil
+}
+
+// Sets the connection type, which determines its queue overflow policy.
+func (wc *WsConn) SetType(connType string) {
+	policy, exists := _config.QueuePolicyOverrides[connType]
+	if !exists {
+		policy = _config.QueuePolicy
+	}
+
+	wc.setPolicy(policy)
+}
+
+func (wc *WsConn) setPolicy(policy string) {
+	wc.lock.Lock()
+	wc.policy = policy
+	wc.lock.Unlock()
+}
+
+// Queues a control message (never dropped, but see CONTROL_QUEUE_MAX). Returns false if the connection is (or has now been) closed.
+func (wc *WsConn) SendJSON(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+
+	return wc.Send(data, false)
+}
+
+// Queues a live data message. Returns false if the connection is (or has now been) closed.
+func (wc *WsConn) SendLive(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+
+	return wc.Send(data, true)
+}
+
+// Queues a chat message, dropped as live data is when the queue's full. Returns false if the connection is (or has now been) closed.
+func (wc *WsConn) SendChat(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+
+	return wc.enqueue(QueuedMsg { Data: data, Live: true, Chat: true })
+}
+
+func (wc *WsConn) Send(data []byte, live bool) bool {
+	return wc.enqueue(QueuedMsg { Data: data, Live: live })
+}
+
+// Queues a WebSocket ping (never dropped, but see CONTROL_QUEUE_MAX), timed when it's written. Returns false if the connection is (or has now been) closed.
+func (wc *WsConn) SendPing() bool {
+	return wc.enqueue(QueuedMsg { Ping: true })
+}
+
+// Queues (already marshalled) live data for a boat which arrived at the given time. Returns false if the connection is (or has now been) closed.
+func (wc *WsConn) SendLiveAt(boatKey string, data []byte, arrived time.Time) bool {
+	return wc.enqueue(QueuedMsg { Data: data, Live: true, Key: boatKey, Arrived: arrived })
+}
+
+// Queues a live data message shared with other connections (a *SharedLiveMsg). Returns false if the connection is (or has now been) closed.
+func (wc *WsConn) SendSharedAt(boatKey string, shared interface{}, arrived time.Time) bool {
+	sm := shared.(*SharedLiveMsg)
+	return wc.enqueue(QueuedMsg { Data: sm.Data, Prepared: sm.Prepared, Live: true, Key: boatKey, Arrived: arrived })
+}
+
+func (wc *WsConn) enqueue(msg QueuedMsg) bool {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	if wc.closed || wc.closing {
+		return false
+	}
+
+	if !msg.Live && wc.countControlLocked() >= CONTROL_QUEUE_MAX {
+		log.Println("Too many control messages queued; disconnecting client.")
+		atomic.AddInt64(&_countQueueDisconnects, 1)
+		wc.abortLocked()
+		return false
+	}
+
+	msg = wc.numberLocked(msg)
+
+	if msg.Live && !msg.Chat && wc.policy == QUEUE_POLICY_CONFLATE {
+		for i, queued := range wc.queue {
+			if queued.Live && !queued.Chat && queued.Key == msg.Key {
+				wc.queue[i] = msg
+				wc.Conflated++
+				atomic.AddInt64(&_countQueueConflated, 1)
+				return true
+			}
+		}
+	}
+
+	if msg.Live && len(wc.queue) >= liveQueueLimit() {
+		switch wc.policy {
+		case QUEUE_POLICY_DROP_OLDEST, QUEUE_POLICY_CONFLATE:
+			for i, queued := range wc.queue {
+				if queued.Live {
+					wc.queue = append(wc.queue[:i], wc.queue[i + 1:]...)
+					wc.Dropped++
+					atomic.AddInt64(&_countQueueDropped, 1)
+					break
+				}
+			}
+
+		case QUEUE_POLICY_COALESCE:
+			kept := wc.queue[:0]
+			for _, queued := range wc.queue {
+				if queued.Live {
+					wc.Coalesced++
+					atomic.AddInt64(&_countQueueCoalesced, 1)
+				} else {
+					kept = append(kept, queued)
+				}
+			}
+			wc.queue = kept
+
+		default:
+			log.Println("Outbound queue full; disconnecting client.")
+			atomic.AddInt64(&_countQueueDisconnects, 1)
+			wc.abortLocked()
+			return false
+		}
+	}
+
+	wc.queue = append(wc.queue, msg)
+	wc.cond.Signal()
+
+	return true
+}
+
+// Returns the number of control messages (and pings) queued. Caller must hold wc.lock.
+func (wc *WsConn) countControlLocked() int {
+	n := 0
+	for _, queued := range wc.queue {
+		if !queued.Live {
+			n++
+		}
+	}
+	return n
+}
+
+// Closes the connection once all queued messages have been sent.
+func (wc *WsConn) Close() {
+	wc.CloseWithReason(0, "")
+}
+
+// Closes the connection once all queued messages have been sent, with the given close code and reason
+// (see close-codes.go). Has no effect on the close code if the connection is already closing.
+func (wc *WsConn) CloseWithReason(code int, reason string) {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	if !wc.closed && !wc.closing {
+		wc.closeCode = code
+		wc.closeReason = reason
+		wc.closing = true
+		wc.cond.Signal()
+	}
+}
+
+// Closes the connection due to an internal error, discarding anything still queued.
+func (wc *WsConn) CloseWithError() {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	if !wc.closed {
+		wc.queue = wc.queue[:0]
+		wc.closeCode = websocket.CloseInternalServerErr
+		wc.closeReason = ""
+		wc.closing = true
+		wc.cond.Signal()
+	}
+}
+
+func (wc *WsConn) IsClosed() bool {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	return wc.closed || wc.closing
+}
+
+// Closes the connection immediately, discarding anything still queued. Caller must hold wc.lock.
+func (wc *WsConn) abortLocked() {
+	if wc.closed {
+		return
+	}
+
+	wc.closed = true
+	wc.queue = nil
+	wc.Conn.Close()
+	wc.cond.Signal()
+}
+
+// Writes a message (or batch of them) in the connection's wire format, as however many WebSocket messages it takes.
+func (wc *WsConn) writeEncoded(data []byte) error {
+	msgType, msgs, err := wc.Encoder.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		wc.Conn.SetWriteDeadline(time.Now().Add(_config.WriteTimeout))
+		err = wc.Conn.WriteMessage(msgType, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (wc *WsConn) writer() {
+	defer func() {
+		_wsConnsLock.Lock()
+		delete(_wsConns, wc)
+		_wsConnsLock.Unlock()
+	}()
+
+	for {
+		wc.lock.Lock()
+		for (len(wc.queue) == 0 || wc.held) && !wc.closed && !wc.closing {
+			wc.cond.Wait()
+		}
+
+		if wc.closed {
+			wc.lock.Unlock()
+			return
+		}
+
+		if len(wc.queue) == 0 {
+			// Closing, and everything has been sent.
+			wc.closed = true
+			closeCode := wc.closeCode
+			if closeCode == 0 {
+				closeCode = websocket.CloseNormalClosure
+			}
+			closeReason := wc.closeReason
+			wc.lock.Unlock()
+			wc.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, closeReason), time.Now().Add(CLOSE_WRITE_TIMEOUT))
+			wc.Conn.Close()
+			return
+		}
+
+		msg := wc.queue[0]
+		n := 1
+		arrivals := []time.Time { msg.Arrived }
+		if wc.batch && !msg.Ping {
+			// Everything queued (up to any ping) goes in one frame (see batching.go).
+			for n < len(wc.queue) && !wc.queue[n].Ping {
+				arrivals = append(arrivals, wc.queue[n].Arrived)
+				n++
+			}
+			msg = QueuedMsg { Data: batchFrame(wc.queue[:n]) }
+		}
+		copy(wc.queue, wc.queue[n:])
+		wc.queue = wc.queue[:len(wc.queue) - n]
+		wc.lock.Unlock()
+
+		var err error
+		if msg.Ping {
+			now := time.Now()
+			err = wc.Conn.WriteControl(websocket.PingMessage, pingPayload(now), now.Add(_config.WriteTimeout))
+		} else if msg.Prepared != nil && wc.Encoder == _jsonEncoder {
+			// Prepared as JSON (see shared-streams.go).
+			wc.Conn.SetWriteDeadline(time.Now().Add(_config.WriteTimeout))
+			err = wc.Conn.WritePreparedMessage(msg.Prepared)
+		} else {
+			err = wc.writeEncoded(msg.Data)
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Println("Write timed out to client (" + wc.RemoteIp + "); closing connection.")
+				atomic.AddInt64(&_countWriteTimeouts, 1)
+			} else {
+				log.Println(err)
+			}
+
+			wc.lock.Lock()
+			wc.abortLocked()
+			wc.lock.Unlock()
+			return
+		}
+
+		for _, arrived := range arrivals {
+			if !arrived.IsZero() {
+				observeDeliveryLatency(time.Now().Sub(arrived))
+			}
+		}
+	}
+}
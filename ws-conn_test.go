@@ -0,0 +1,131 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+
+// A connection with no writer, so that everything sent stays queued.
+func testQueuedConn(policy string) *WsConn {
+	wc := &WsConn { policy: policy }
+	wc.cond = sync.NewCond(&wc.lock)
+	return wc
+}
+
+// Like testQueuedConn, but backed by a real WebSocket connection (which can be closed).
+func testQueuedWsConn(t *testing.T, policy string) *WsConn {
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader {}
+	server := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws" + strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	wc := testQueuedConn(policy)
+	wc.Conn = <-conns
+	return wc
+}
+
+func expectQueued(t *testing.T, wc *WsConn, expected ...string) {
+	if len(wc.queue) != len(expected) {
+		t.Fatalf("Expected %d queued messages, but got %d!", len(expected), len(wc.queue))
+	}
+
+	for i, msg := range wc.queue {
+		if string(msg.Data) != expected[i] {
+			t.Errorf("Expected queued message %d to be %s, but got %s!", i, expected[i], msg.Data)
+		}
+	}
+}
+
+func TestQueueConflate(t *testing.T) {
+	wc := testQueuedConn(QUEUE_POLICY_CONFLATE)
+
+	wc.Send([]byte("control1"), false)
+	wc.SendLiveAt("a", []byte("a1"), time.Time {})
+	wc.SendLiveAt("b", []byte("b1"), time.Time {})
+	wc.Send([]byte("control2"), false)
+	wc.SendLiveAt("a", []byte("a2"), time.Time {})
+	wc.SendLiveAt("a", []byte("a3"), time.Time {})
+
+	expectQueued(t, wc, "control1", "a3", "b1", "control2")
+	if wc.Conflated != 2 {
+		t.Errorf("Expected 2 conflated messages, but got %d!", wc.Conflated)
+	}
+
+	// Other policies only act once the queue is full.
+	wc = testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	wc.SendLiveAt("a", []byte("a1"), time.Time {})
+	wc.SendLiveAt("a", []byte("a2"), time.Time {})
+
+	expectQueued(t, wc, "a1", "a2")
+}
+
+func TestQueueControlLimit(t *testing.T) {
+	wc := testQueuedWsConn(t, QUEUE_POLICY_DROP_OLDEST)
+
+	// Live data doesn't count towards the limit on control messages.
+	for i := 0; i < 2 * _config.QueueSize; i++ {
+		wc.SendLiveAt("a", []byte("live"), time.Time {})
+	}
+
+	for i := 0; i < CONTROL_QUEUE_MAX; i++ {
+		if !wc.Send([]byte("control"), false) {
+			t.Fatalf("Connection closed after %d control messages!", i)
+		}
+	}
+
+	if wc.Send([]byte("control"), false) || !wc.closed {
+		t.Errorf("Connection not closed with too many control messages queued!")
+	}
+}
+
+func TestQueueChat(t *testing.T) {
+	// Chat messages are never conflated with each other (or live data).
+	wc := testQueuedConn(QUEUE_POLICY_CONFLATE)
+	wc.SendLiveAt("", []byte("live"), time.Time {})
+	wc.SendChat("c1")
+	wc.SendChat("c2")
+	expectQueued(t, wc, "live", `"c1"`, `"c2"`)
+
+	// But are dropped as live data is when the queue's full.
+	wc = testQueuedConn(QUEUE_POLICY_DROP_OLDEST)
+	for i := 0; i < _config.QueueSize + 1; i++ {
+		wc.SendChat(i)
+	}
+	if len(wc.queue) != _config.QueueSize || string(wc.queue[0].Data) != "1" || wc.Dropped != 1 {
+		t.Errorf("Unexpected queue after overflowing with chat: %d queued, %d dropped", len(wc.queue), wc.Dropped)
+	}
+}
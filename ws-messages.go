@@ -0,0 +1,60 @@
+/**
+ * Copyright (C) 2026 ls4096 <ls4096@8bitbyte.ca>
+ *
+ * This program is free software: you can redistribute it and/or modify it
+ * under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT
+ * ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for
+ * more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+
+// Messages sent to clients, other than the live boat data messages themselves,
+// carry a "type" field so that clients can tell them apart from live data.
+
+const ERR_INVALID_SESSION = "invalid_session"
+const ERR_TOO_MANY_SUBSCRIBERS = "too_many_subscribers"
+
+type ErrorMsg struct {
+	Type string `json:"type"`
+	Error string `json:"error"`
+	Msg string `json:"msg"`
+	Limit int `json:"limit,omitempty"`
+	RetryAfter int `json:"retry_after,omitempty"` // Seconds
+}
+
+func sendErrorMsg(conn *WsConn, errCode string, msg string) {
+	conn.SendJSON(&ErrorMsg {
+		Type: "error",
+		Error: errCode,
+		Msg: msg,
+	})
+}
+
+// Sends an error message for a request rejected due to some limit being reached.
+func sendLimitErrorMsg(conn *WsConn, errCode string, msg string, limit int) {
+	conn.SendJSON(&ErrorMsg {
+		Type: "error",
+		Error: errCode,
+		Msg: msg,
+		Limit: limit,
+	})
+}
+
+// Sends an error message for a request that may be retried after some number of seconds.
+func sendRetryErrorMsg(conn *WsConn, errCode string, msg string, retryAfter int) {
+	conn.SendJSON(&ErrorMsg {
+		Type: "error",
+		Error: errCode,
+		Msg: msg,
+		RetryAfter: retryAfter,
+	})
+}